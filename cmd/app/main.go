@@ -1,117 +1,468 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/joho/godotenv"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	"github.com/alex-pyslar/petelka-api/internal/config"
+	"github.com/alex-pyslar/petelka-api/internal/database"
 	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/idempotency"
+	"github.com/alex-pyslar/petelka-api/internal/invoice"
 	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/mailer"
+	"github.com/alex-pyslar/petelka-api/internal/payment"
+	"github.com/alex-pyslar/petelka-api/internal/ratelimit"
 	"github.com/alex-pyslar/petelka-api/internal/repository"
 	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/alex-pyslar/petelka-api/internal/storage"
+	"github.com/alex-pyslar/petelka-api/internal/tracing"
 
 	_ "github.com/alex-pyslar/petelka-api/docs"
 )
 
+// @title Petelka API
+// @version 1.0
+// @description API интернет-магазина пряжи и вязаных изделий.
+// @BasePath /api/v1
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
 func main() {
-	// Инициализация логгера
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "config":
+			os.Exit(runConfigCommand(os.Args[2:]))
+		case "audit":
+			os.Exit(runAuditCommand(os.Args[2:]))
+		}
+	}
+
+	// Логгер с настройками по умолчанию — до загрузки конфигурации
 	log, err := logger.NewLogger()
 	if err != nil {
 		panic(err)
 	}
 
-	// Инициализация конфигурации (DB, Redis, MinIO)
-	cfg, err := config.NewConfig(log)
+	// Загрузка переменных окружения из .env (если файл есть)
+	if err := godotenv.Load(); err != nil {
+		log.Warning("No .env file found, relying on environment variables")
+	}
+
+	// Загрузка и проверка конфигурации
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	// Логгер с уровнем, форматом и сэмплированием из конфигурации
+	log, err = logger.New(logger.Options{
+		Level:              cfg.Log.Level,
+		Format:             cfg.Log.Format,
+		SamplingInitial:    cfg.Log.SamplingInitial,
+		SamplingThereafter: cfg.Log.SamplingThereafter,
+		PackageLevels:      cfg.Log.PackageLevels,
+	})
+	if err != nil {
+		panic(err)
+	}
+	logger.SetDefault(log)
+
+	// === Трассировка ===
+	ctx := context.Background()
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
 	if err != nil {
-		log.Fatalf("Error initializing config: %v", err)
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
+	// === Подключения ===
+	db, err := database.OpenPostgres(ctx, cfg.Database)
+	if err != nil {
+		log.Fatalf("Error connecting to PostgreSQL: %v", err)
+	}
+	redisClient, err := database.OpenRedis(ctx, cfg.Redis)
+	if err != nil {
+		log.Fatalf("Error connecting to Redis: %v", err)
 	}
-	defer cfg.DB.Close()
-	defer cfg.Redis.Close()
 	log.Info("Connected to PostgreSQL and Redis successfully")
 
-	// === Репозитории ===
-	userRepo := repository.NewUserRepository(cfg.DB, cfg.Redis)
-	productRepo := repository.NewProductRepository(cfg.DB, cfg.Redis)
-	categoryRepo := repository.NewCategoryRepository(cfg.DB, cfg.Redis)
-	orderRepo := repository.NewOrderRepository(cfg.DB, cfg.Redis)
-	commentRepo := repository.NewCommentRepository(cfg.DB, cfg.Redis)
-
-	// MinIO как репозиторий
-	photoRepo, err := repository.NewPhotoRepository(
-		cfg.MinioEndpoint,
-		cfg.MinioAccessKey,
-		cfg.MinioSecretKey,
-		cfg.MinioBucket,
-		cfg.MinioUseSSL,
-		cfg.Redis, // только Redis для кэша
-	)
+	objectStore, localStore, err := newObjectStore(cfg.Storage)
 	if err != nil {
-		log.Fatalf("Failed to initialize PhotoRepository: %v", err)
+		log.Fatalf("Failed to initialize object storage: %v", err)
+	}
+	invoiceStore, err := newInvoiceStore(cfg.Storage, cfg.Invoices.Bucket)
+	if err != nil {
+		log.Fatalf("Failed to initialize invoice storage: %v", err)
 	}
 
+	// === Репозитории ===
+	userRepo := repository.NewUserRepository(db, redisClient, cfg.Cache.EntityTTL)
+	productRepo := repository.NewProductRepository(db, redisClient, cfg.Cache.EntityTTL)
+	categoryRepo := repository.NewCategoryRepository(db, redisClient, cfg.Cache.EntityTTL)
+	orderRepo := repository.NewOrderRepository(db, redisClient, cfg.Cache.EntityTTL)
+	commentRepo := repository.NewCommentRepository(db, redisClient, cfg.Cache.EntityTTL)
+	photoRepo := repository.NewPhotoRepository(db, objectStore, redisClient)
+	loginRepo := repository.NewLoginAttemptRepository(db, redisClient)
+	auditRepo := repository.NewAuditRepository(db)
+	paymentRepo := repository.NewPaymentRepository(db, redisClient)
+	addressRepo := repository.NewAddressRepository(db)
+	deliveryRepo := repository.NewDeliveryRepository(db)
+	promoRepo := repository.NewPromoRepository(db)
+	invoiceRepo := repository.NewInvoiceRepository(invoiceStore)
+
 	// === Сервисы ===
-	userService := service.NewUserService(userRepo, log)
-	productService := service.NewProductService(productRepo, log)
-	categoryService := service.NewCategoryService(categoryRepo, log)
-	orderService := service.NewOrderService(orderRepo, log)
-	commentService := service.NewCommentService(commentRepo, log)
-	photoService := service.NewPhotoService(photoRepo, log)
+	userService := service.NewUserService(userRepo)
+	productService := service.NewProductService(productRepo)
+	categoryService := service.NewCategoryService(categoryRepo)
+	orderService := service.NewOrderService(orderRepo)
+	commentService := service.NewCommentService(commentRepo)
+	photoService := service.NewPhotoService(photoRepo, cfg.Photos.URLTTL)
+	auditService := service.NewAuditService(auditRepo)
+	addressService := service.NewAddressService(addressRepo)
+	deliveryService := service.NewDeliveryService(deliveryRepo)
+	promoService := service.NewPromoService(promoRepo)
+	checkoutService := service.NewCheckoutService(orderRepo, productRepo, addressRepo, deliveryService, promoService, cfg.Tax.VATRate)
+	invoiceService := service.NewInvoiceService(invoiceRepo, orderRepo, productRepo, userRepo, paymentRepo, invoice.Shop{
+		Name:    cfg.Invoices.Shop.Name,
+		INN:     cfg.Invoices.Shop.INN,
+		Address: cfg.Invoices.Shop.Address,
+		Email:   cfg.Invoices.Shop.Email,
+		Phone:   cfg.Invoices.Shop.Phone,
+	})
+	if cfg.Payments.Driver == "fake" {
+		log.Warning("Payments use the fake provider: orders are paid without real charges, use it for development only")
+	}
+	paymentService := service.NewPaymentService(paymentRepo, orderRepo, newPaymentProvider(cfg.Payments), service.PaymentOptions{
+		ReturnURL: cfg.Payments.ReturnURL,
+		Timeout:   cfg.Payments.Timeout,
+		Receipts:  invoiceService,
+	})
+	mail := newMailer(cfg.Mail)
+	profileService := service.NewProfileService(userRepo, mail, service.ProfileOptions{
+		ConfirmURL: cfg.Mail.EmailConfirmURL,
+		TokenTTL:   cfg.Mail.EmailConfirmTTL,
+	})
+	loginGuard := service.NewLoginGuard(loginRepo, mail, service.LoginPolicy{
+		Window:          cfg.Login.Window,
+		DelayAfter:      cfg.Login.DelayAfter,
+		BaseDelay:       cfg.Login.BaseDelay,
+		MaxDelay:        cfg.Login.MaxDelay,
+		MaxFailures:     cfg.Login.MaxFailures,
+		IPMaxFailures:   cfg.Login.IPMaxFailures,
+		LockoutDuration: cfg.Login.LockoutDuration,
+	})
+
+	// === Ограничение частоты запросов ===
+	limiter := ratelimit.NewLimiter(redisClient)
+	rateLimit := func(name string, spec config.RatePolicy, key handler.RateLimitKey) mux.MiddlewareFunc {
+		if !cfg.RateLimit.Enabled {
+			return func(next http.Handler) http.Handler { return next }
+		}
+		limit, window, _ := spec.Parse() // формат проверен в config.Validate
+		policy := ratelimit.Policy{Name: name, Limit: limit, Window: window}
+		return handler.RateLimitMiddleware(limiter, policy, key, cfg.RateLimit.FailOpen)
+	}
 
 	// === Хендлеры ===
 	userHandler := handler.NewUserHandler(userService)
+	meHandler := handler.NewMeHandler(userService, profileService, orderService, commentService)
 	productHandler := handler.NewProductHandler(productService)
 	categoryHandler := handler.NewCategoryHandler(categoryService)
-	orderHandler := handler.NewOrderHandler(orderService)
+	orderHandler := handler.NewOrderHandler(orderService, checkoutService)
 	commentHandler := handler.NewCommentHandler(commentService)
-	authHandler := handler.NewAuthHandler(userService)
-	photoHandler := handler.NewPhotoHandler(photoService)
+	authHandler := handler.NewAuthHandler(userService, loginGuard, handler.AuthOptions{
+		JWTKey:            []byte(cfg.JWT.Secret),
+		TokenTTL:          cfg.JWT.TTL,
+		TrustForwardedFor: cfg.RateLimit.TrustForwardedFor,
+		Throttle:          rateLimit("auth", cfg.RateLimit.Auth, handler.KeyByIP(cfg.RateLimit.TrustForwardedFor)),
+	})
+	photoHandler := handler.NewPhotoHandler(photoService, handler.PhotoDelivery{
+		Mode:        cfg.Photos.DeliveryMode,
+		CacheMaxAge: cfg.Photos.CacheMaxAge,
+	})
+
+	auditHandler := handler.NewAuditHandler(auditService)
+	paymentHandler := handler.NewPaymentHandler(paymentService)
+	invoiceHandler := handler.NewInvoiceHandler(invoiceService)
+	addressHandler := handler.NewAddressHandler(addressService)
+	deliveryHandler := handler.NewDeliveryHandler(deliveryService)
+	promoHandler := handler.NewPromoHandler(promoService)
+
+	healthHandler := handler.NewHealthHandler(cfg.Server.HealthCheckTimeout,
+		handler.HealthCheck{Name: "postgres", Check: db.PingContext},
+		handler.HealthCheck{Name: "redis", Check: func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		}},
+		handler.HealthCheck{Name: "storage", Check: objectStore.Ping},
+	)
+
+	// === CORS: preflight обрабатывается до роутера, иначе mux отвечает 405 ===
+	cors := handler.CorsMiddleware(handler.CORSOptions{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	})
 
 	// === Роутинг ===
 	router := mux.NewRouter()
-	router.Use(handler.CorsMiddleware)
-	api := router.PathPrefix("/api").Subrouter()
-
-	// --- Публичные маршруты ---
-	public := api.PathPrefix("").Subrouter()
-	public.HandleFunc("/auth/register", authHandler.Register).Methods("POST")
-	public.HandleFunc("/auth/login", authHandler.Login).Methods("POST")
-	public.HandleFunc("/products", productHandler.ListProducts).Methods("GET")
-	public.HandleFunc("/products/search", productHandler.SearchProducts).Methods("GET")
-	public.HandleFunc("/products/{id}", productHandler.GetProduct).Methods("GET")
-	public.HandleFunc("/categories", categoryHandler.ListCategories).Methods("GET")
-	public.HandleFunc("/categories/{id}", categoryHandler.GetCategory).Methods("GET")
-	public.HandleFunc("/photos/{objectName}", photoHandler.Download).Methods("GET")
-
-	// --- Защищённые маршруты ---
-	protected := api.PathPrefix("").Subrouter()
-	protected.Use(handler.AuthMiddleware(log))
-	protected.HandleFunc("/comments", commentHandler.CreateComment).Methods("POST")
-	protected.HandleFunc("/orders", orderHandler.CreateOrder).Methods("POST")
-
-	// --- Админские маршруты ---
-	admin := api.PathPrefix("").Subrouter()
-	admin.Use(handler.AuthMiddleware(log), handler.AdminMiddleware(log))
-	admin.HandleFunc("/products", productHandler.CreateProduct).Methods("POST")
-	admin.HandleFunc("/products/{id}", productHandler.UpdateProduct).Methods("PUT")
-	admin.HandleFunc("/products/{id}", productHandler.DeleteProduct).Methods("DELETE")
-	admin.HandleFunc("/categories", categoryHandler.CreateCategory).Methods("POST")
-	admin.HandleFunc("/categories/{id}", categoryHandler.UpdateCategory).Methods("PUT")
-	admin.HandleFunc("/categories/{id}", categoryHandler.DeleteCategory).Methods("DELETE")
-	admin.HandleFunc("/users", userHandler.ListUsers).Methods("GET")
-	admin.HandleFunc("/users/{id}", userHandler.UpdateUser).Methods("PUT")
-	admin.HandleFunc("/users/{id}", userHandler.DeleteUser).Methods("DELETE")
-	admin.HandleFunc("/photos", photoHandler.Upload).Methods("POST")
+	router.Use(handler.RouteMiddleware, handler.MetricsMiddleware)
+
+	// --- Локальное хранилище: отдача и приём файлов по подписанным ссылкам ---
+	if localStore != nil {
+		router.PathPrefix("/api/storage/").Handler(http.StripPrefix("/api/storage", localStore))
+	}
+
+	modules := []handler.RouteRegistrar{
+		authHandler, meHandler, productHandler, categoryHandler, commentHandler, orderHandler, userHandler, photoHandler,
+		auditHandler, paymentHandler, addressHandler, deliveryHandler, promoHandler, invoiceHandler,
+		handler.RouteFunc(func(_, _, admin *mux.Router) {
+			admin.Handle("/admin/log-level", log.LevelHandler()).Methods("GET", "PUT")
+		}),
+	}
+	// Журнал аудита: состояние ресурса до изменения берётся у его хендлера
+	audit := handler.AuditMiddleware(auditService, handler.AuditOptions{
+		TrustForwardedFor: cfg.RateLimit.TrustForwardedFor,
+		Snapshots: map[string]handler.AuditSnapshot{
+			"products":       productHandler.Snapshot,
+			"categories":     categoryHandler.Snapshot,
+			"users":          userHandler.Snapshot,
+			"orders":         orderHandler.Snapshot,
+			"comments":       commentHandler.Snapshot,
+			"promo-codes":    promoHandler.Snapshot,
+			"delivery-rates": deliveryHandler.Snapshot,
+		},
+	})
+	// Повторы POST-запросов с Idempotency-Key; в админских маршрутах — до аудита,
+	// чтобы повтор не попадал в журнал
+	idempotent := handler.IdempotencyMiddleware(
+		idempotency.NewStore(redisClient, cfg.Idempotency.TTL, cfg.Idempotency.LockTimeout),
+		cfg.RateLimit.TrustForwardedFor,
+	)
+	apiOpts := handler.APIOptions{
+		JWTKey:    []byte(cfg.JWT.Secret),
+		Accounts:  userService,
+		Public:    []mux.MiddlewareFunc{rateLimit("public", cfg.RateLimit.Public, handler.KeyByIP(cfg.RateLimit.TrustForwardedFor))},
+		Protected: []mux.MiddlewareFunc{rateLimit("protected", cfg.RateLimit.Protected, handler.KeyByUserOrIP(cfg.RateLimit.TrustForwardedFor)), idempotent},
+		Admin:     []mux.MiddlewareFunc{rateLimit("admin", cfg.RateLimit.Admin, handler.KeyByUserOrIP(cfg.RateLimit.TrustForwardedFor)), idempotent, audit},
+	}
+
+	// --- API v1 ---
+	handler.MountAPI(router.PathPrefix("/api/v1").Subrouter(), apiOpts, modules...)
+
+	// --- Маршруты без версии: те же модули, помечены устаревшими ---
+	if cfg.API.LegacyRoutes {
+		legacy := router.PathPrefix("/api").Subrouter()
+		legacy.Use(handler.DeprecationMiddleware(handler.Deprecation{
+			Since:     cfg.API.LegacyDeprecation,
+			Sunset:    cfg.API.LegacySunset,
+			Successor: "/api/v1",
+		}))
+		handler.MountAPI(legacy, apiOpts, modules...)
+	}
 
 	// --- Технические ---
 	router.Handle("/metrics", promhttp.Handler())
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+	router.HandleFunc("/healthz", healthHandler.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.Readiness).Methods("GET")
+
+	// === HTTP-сервер ===
+	srv := &http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           tracing.Middleware(handler.RequestIDMiddleware(log)(handler.AccessLogMiddleware(cors(router)))),
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Infof("Server starting on %s at %s", srv.Addr, time.Now().Format("2006-01-02 15:04:05"))
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	case <-ctx.Done():
+		log.Info("Shutdown signal received, draining in-flight requests")
+	}
+
+	// Сначала снимаем под с балансировки, затем дожидаемся завершения запросов
+	healthHandler.SetDraining()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Errorf("Graceful shutdown failed: %v", err)
+	}
 
-	log.Infof("Server starting on :8080 at %s", time.Now().Format("2006-01-02 15:04:05"))
-	if err := http.ListenAndServe(":8080", router); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if err := redisClient.Close(); err != nil {
+		log.Errorf("Failed to close Redis: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		log.Errorf("Failed to close PostgreSQL: %v", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Errorf("Failed to flush traces: %v", err)
+	}
+	log.Info("Server stopped")
+}
+
+// newObjectStore создаёт хранилище фотографий выбранного драйвера с трассировкой операций.
+// Для локального драйвера дополнительно возвращается сам LocalStore,
+// который нужно смонтировать в роутер для отдачи файлов.
+func newObjectStore(cfg config.StorageConfig) (storage.ObjectStore, *storage.LocalStore, error) {
+	if cfg.Driver == "local" {
+		store, err := storage.NewLocalStore(cfg.LocalDir, cfg.PublicURL, []byte(cfg.SigningKey))
+		if err != nil {
+			return nil, nil, err
+		}
+		return storage.WithTracing(store, "local"), store, nil
+	}
+	store, err := storage.NewMinioStore(
+		cfg.Minio.Endpoint,
+		cfg.Minio.AccessKey,
+		cfg.Minio.SecretKey,
+		cfg.Minio.Bucket,
+		cfg.Minio.UseSSL,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return storage.WithTracing(store, "minio"), nil, nil
+}
+
+// newInvoiceStore создаёт хранилище документов заказов отдельно от фотографий:
+// бакет bucket в MinIO или одноимённый подкаталог для локального драйвера.
+func newInvoiceStore(cfg config.StorageConfig, bucket string) (storage.ObjectStore, error) {
+	if cfg.Driver == "local" {
+		store, err := storage.NewLocalStore(filepath.Join(cfg.LocalDir, bucket), cfg.PublicURL, []byte(cfg.SigningKey))
+		if err != nil {
+			return nil, err
+		}
+		return storage.WithTracing(store, "local"), nil
+	}
+	store, err := storage.NewMinioStore(cfg.Minio.Endpoint, cfg.Minio.AccessKey, cfg.Minio.SecretKey, bucket, cfg.Minio.UseSSL)
+	if err != nil {
+		return nil, err
+	}
+	return storage.WithTracing(store, "minio"), nil
+}
+
+// newMailer создаёт отправителя писем выбранного драйвера.
+func newMailer(cfg config.MailConfig) mailer.Mailer {
+	if cfg.Driver == "smtp" {
+		return mailer.NewSMTPMailer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.From)
+	}
+	return mailer.LogMailer{}
+}
+
+// newPaymentProvider создаёт платёжного провайдера выбранного драйвера.
+func newPaymentProvider(cfg config.PaymentsConfig) payment.PaymentProvider {
+	if cfg.Driver == "yookassa" {
+		return payment.NewYooKassaProvider(cfg.YooKassa.APIURL, cfg.YooKassa.ShopID, cfg.YooKassa.SecretKey)
+	}
+	return payment.NewFakeProvider(cfg.WebhookSecret, cfg.ReturnURL)
+}
+
+// runConfigCommand выполняет подкоманду "config print [--redacted] [--config path]",
+// печатающую итоговую конфигурацию в формате YAML.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: app config print [--redacted] [--config path]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	redacted := fs.Bool("redacted", false, "hide secrets")
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	_ = godotenv.Load()
+	cfg, err := config.Load(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 1
+	}
+	if *redacted {
+		cfg = cfg.Redacted()
+	}
+	out, err := cfg.YAML()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode configuration: %v\n", err)
+		return 1
+	}
+	os.Stdout.Write(out)
+	return 0
+}
+
+// runAuditCommand выполняет подкоманду "audit prune [--older-than duration] [--config path]",
+// удаляющую записи журнала аудита старше срока хранения (по умолчанию audit.retention).
+func runAuditCommand(args []string) int {
+	if len(args) == 0 || args[0] != "prune" {
+		fmt.Fprintln(os.Stderr, "usage: app audit prune [--older-than duration] [--config path]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("audit prune", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", 0, "delete entries older than this (default audit.retention)")
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "path to YAML config file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	_ = godotenv.Load()
+	cfg, err := config.Load(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 1
+	}
+	retention := cfg.Audit.Retention
+	if *olderThan != 0 {
+		retention = *olderThan
+	}
+	if retention <= 0 {
+		fmt.Fprintln(os.Stderr, "--older-than must be positive")
+		return 2
+	}
+
+	ctx := context.Background()
+	db, err := database.OpenPostgres(ctx, cfg.Database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to PostgreSQL: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	deleted, err := service.NewAuditService(repository.NewAuditRepository(db)).Prune(ctx, retention)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
 	}
+	fmt.Printf("deleted %d audit entries older than %s\n", deleted, retention)
+	return 0
 }
@@ -23,4 +23,6 @@ kubectl apply -f deployment.yaml
 kubectl apply -f service.yaml
 
 echo "Перезапуск Deployment для применения нового образа"
-kubectl rollout restart deployment petelka-api
\ No newline at end of file
+kubectl rollout restart deployment petelka-api
+echo "Ожидание завершения выкатки"
+kubectl rollout status deployment petelka-api --timeout=120s
@@ -1,86 +1,681 @@
 package config
 
 import (
-	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/alex-pyslar/petelka-api/internal/logger"
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
-	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
 )
 
+// Config — типизированная конфигурация приложения.
+//
+// Значения применяются в порядке: значения по умолчанию, YAML-файл (если указан),
+// переменные окружения. Имя переменной окружения задаётся тегом env,
+// секреты помечены тегом secret и скрываются при печати с --redacted.
 type Config struct {
-	DB             *sql.DB
-	Redis          *redis.Client
-	MinioEndpoint  string
-	MinioAccessKey string
-	MinioSecretKey string
-	MinioBucket    string
-	MinioUseSSL    bool
+	Server      ServerConfig      `yaml:"server"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Redis       RedisConfig       `yaml:"redis"`
+	Storage     StorageConfig     `yaml:"storage"`
+	Photos      PhotosConfig      `yaml:"photos"`
+	JWT         JWTConfig         `yaml:"jwt"`
+	CORS        CORSConfig        `yaml:"cors"`
+	Cache       CacheConfig       `yaml:"cache"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	Log         LogConfig         `yaml:"log"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Login       LoginConfig       `yaml:"login"`
+	Mail        MailConfig        `yaml:"mail"`
+	API         APIConfig         `yaml:"api"`
+	Audit       AuditConfig       `yaml:"audit"`
+	Payments    PaymentsConfig    `yaml:"payments"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Tax         TaxConfig         `yaml:"tax"`
+	Invoices    InvoicesConfig    `yaml:"invoices"`
 }
 
-func NewConfig(log *logger.Logger) (*Config, error) {
-	// Загружаем .env файл
-	if err := godotenv.Load(); err != nil {
-		log.Warningf("Failed to load .env file: %v", err)
+// ServerConfig — параметры HTTP-сервера.
+type ServerConfig struct {
+	Port               int           `yaml:"port" env:"SERVER_PORT"`
+	ReadTimeout        time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	ReadHeaderTimeout  time.Duration `yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	WriteTimeout       time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout        time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+}
+
+// DatabaseConfig — подключение к PostgreSQL и размеры пула.
+type DatabaseConfig struct {
+	URL             string        `yaml:"url" env:"DATABASE_URL" secret:"true"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+}
+
+// RedisConfig — подключение к Redis.
+type RedisConfig struct {
+	Addr     string `yaml:"addr" env:"REDIS_URL"`
+	Password string `yaml:"password" env:"REDIS_PASS" secret:"true"`
+	DB       int    `yaml:"db" env:"REDIS_DB"`
+}
+
+// StorageConfig — объектное хранилище фотографий.
+type StorageConfig struct {
+	Driver     string      `yaml:"driver" env:"STORAGE_DRIVER"`
+	LocalDir   string      `yaml:"local_dir" env:"STORAGE_LOCAL_DIR"`
+	PublicURL  string      `yaml:"public_url" env:"STORAGE_PUBLIC_URL"`
+	SigningKey string      `yaml:"signing_key" env:"STORAGE_SIGNING_KEY" secret:"true"`
+	Minio      MinioConfig `yaml:"minio"`
+}
+
+// MinioConfig — подключение к MinIO.
+type MinioConfig struct {
+	Endpoint  string `yaml:"endpoint" env:"MINIO_ENDPOINT"`
+	AccessKey string `yaml:"access_key" env:"MINIO_ACCESS_KEY" secret:"true"`
+	SecretKey string `yaml:"secret_key" env:"MINIO_SECRET_KEY" secret:"true"`
+	Bucket    string `yaml:"bucket" env:"MINIO_BUCKET"`
+	UseSSL    bool   `yaml:"use_ssl" env:"MINIO_USE_SSL"`
+}
+
+// PhotosConfig — раздача фотографий: redirect | proxy | url. URLTTL — срок жизни ссылок
+// на хранилище, в том числе ссылок режима url, которые кэшируются на половину срока.
+type PhotosConfig struct {
+	DeliveryMode string        `yaml:"delivery_mode" env:"PHOTO_DELIVERY_MODE"`
+	URLTTL       time.Duration `yaml:"url_ttl" env:"PHOTO_URL_TTL"`
+	CacheMaxAge  time.Duration `yaml:"cache_max_age" env:"PHOTO_CACHE_MAX_AGE"`
+}
+
+// JWTConfig — подпись и срок жизни токенов.
+type JWTConfig struct {
+	Secret string        `yaml:"secret" env:"JWT_SECRET" secret:"true"`
+	TTL    time.Duration `yaml:"ttl" env:"JWT_TTL"`
+}
+
+// CORSConfig — политика CORS для запросов из браузера.
+// Источник задаётся точно ("https://petelka.shop") или шаблоном поддоменов ("https://*.petelka.shop").
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   []string      `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE"`
+}
+
+// CacheConfig — время жизни записей в кэше Redis.
+type CacheConfig struct {
+	EntityTTL time.Duration `yaml:"entity_ttl" env:"CACHE_ENTITY_TTL"`
+}
+
+// TracingConfig — экспорт трассировок OpenTelemetry.
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter" env:"TRACING_EXPORTER"` // none | stdout | otlp
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool    `yaml:"otlp_insecure" env:"TRACING_OTLP_INSECURE"`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+	ServiceName  string  `yaml:"service_name" env:"TRACING_SERVICE_NAME"`
+}
+
+// LogConfig — уровень, формат и сэмплирование логов.
+type LogConfig struct {
+	Level              string            `yaml:"level" env:"LOG_LEVEL"`   // debug | info | warn | error
+	Format             string            `yaml:"format" env:"LOG_FORMAT"` // json | console
+	SamplingInitial    int               `yaml:"sampling_initial" env:"LOG_SAMPLING_INITIAL"`
+	SamplingThereafter int               `yaml:"sampling_thereafter" env:"LOG_SAMPLING_THEREAFTER"`
+	PackageLevels      map[string]string `yaml:"package_levels" env:"LOG_PACKAGE_LEVELS"` // repository=debug,storage=warn
+}
+
+// RateLimitConfig — ограничение частоты запросов по группам маршрутов.
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	// FailOpen пропускает запросы без ограничений, если Redis недоступен.
+	FailOpen bool `yaml:"fail_open" env:"RATE_LIMIT_FAIL_OPEN"`
+	// TrustForwardedFor берёт IP клиента из последнего адреса X-Forwarded-For,
+	// который дописал доверенный прокси перед API.
+	TrustForwardedFor bool       `yaml:"trust_forwarded_for" env:"RATE_LIMIT_TRUST_FORWARDED_FOR"`
+	Public            RatePolicy `yaml:"public" env:"RATE_LIMIT_PUBLIC"`
+	Auth              RatePolicy `yaml:"auth" env:"RATE_LIMIT_AUTH"`
+	Protected         RatePolicy `yaml:"protected" env:"RATE_LIMIT_PROTECTED"`
+	Admin             RatePolicy `yaml:"admin" env:"RATE_LIMIT_ADMIN"`
+}
+
+// APIConfig — версии API. Маршруты без версии (/api/...) повторяют /api/v1
+// и отвечают с заголовками Deprecation и Sunset, пока не будут отключены.
+type APIConfig struct {
+	LegacyRoutes      bool      `yaml:"legacy_routes" env:"API_LEGACY_ROUTES"`
+	LegacyDeprecation time.Time `yaml:"legacy_deprecation" env:"API_LEGACY_DEPRECATION"` // дата в формате 2006-01-02
+	LegacySunset      time.Time `yaml:"legacy_sunset" env:"API_LEGACY_SUNSET"`
+}
+
+// AuditConfig — журнал аудита действий администраторов.
+// Записи старше Retention удаляются командой "app audit prune".
+type AuditConfig struct {
+	Retention time.Duration `yaml:"retention" env:"AUDIT_RETENTION"`
+}
+
+// IdempotencyConfig — повторы запросов с заголовком Idempotency-Key.
+type IdempotencyConfig struct {
+	TTL         time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL"`                   // сколько хранится ответ для повторов
+	LockTimeout time.Duration `yaml:"lock_timeout" env:"IDEMPOTENCY_LOCK_TIMEOUT"` // через сколько освобождается ключ незавершённого запроса
+}
+
+// PaymentsConfig — приём оплаты заказов: yookassa | fake (без внешних вызовов, только
+// для разработки). Драйвер задаётся явно. Уведомления YooKassa не подписаны и
+// проверяются запросом платежа к API; уведомления fake подписываются HMAC-SHA256
+// ключом WebhookSecret, который для этого драйвера обязателен.
+type PaymentsConfig struct {
+	Driver        string         `yaml:"driver" env:"PAYMENTS_DRIVER"`
+	ReturnURL     string         `yaml:"return_url" env:"PAYMENTS_RETURN_URL"` // страница магазина после оплаты
+	Timeout       time.Duration  `yaml:"timeout" env:"PAYMENTS_TIMEOUT"`       // ограничение одного запроса к провайдеру
+	WebhookSecret string         `yaml:"webhook_secret" env:"PAYMENTS_WEBHOOK_SECRET" secret:"true"`
+	YooKassa      YooKassaConfig `yaml:"yookassa"`
+}
+
+// TaxConfig — налоги в заказах.
+type TaxConfig struct {
+	// VATRate — ставка НДС в процентах, включённого в цены товаров и доставки;
+	// 0 — магазин не платит НДС («без НДС»).
+	VATRate int `yaml:"vat_rate" env:"TAX_VAT_RATE"`
+}
+
+// InvoicesConfig — PDF-документы заказов. Квитанции оплаченных заказов хранятся в
+// бакете Bucket драйвера storage (для local — в одноимённом подкаталоге local_dir).
+type InvoicesConfig struct {
+	Bucket string     `yaml:"bucket" env:"INVOICES_BUCKET"`
+	Shop   ShopConfig `yaml:"shop"`
+}
+
+// ShopConfig — реквизиты магазина в шапке документов.
+type ShopConfig struct {
+	Name    string `yaml:"name" env:"SHOP_NAME"`
+	INN     string `yaml:"inn" env:"SHOP_INN"`
+	Address string `yaml:"address" env:"SHOP_ADDRESS"`
+	Email   string `yaml:"email" env:"SHOP_EMAIL"`
+	Phone   string `yaml:"phone" env:"SHOP_PHONE"`
+}
+
+// YooKassaConfig — подключение к API YooKassa.
+type YooKassaConfig struct {
+	APIURL    string `yaml:"api_url" env:"YOOKASSA_API_URL"`
+	ShopID    string `yaml:"shop_id" env:"YOOKASSA_SHOP_ID"`
+	SecretKey string `yaml:"secret_key" env:"YOOKASSA_SECRET_KEY" secret:"true"`
+}
+
+// LoginConfig — защита входа от перебора паролей.
+//
+// Неудачные попытки считаются отдельно по аккаунту и по IP в окне Window.
+// После DelayAfter неудач каждая следующая попытка возможна не раньше, чем через
+// BaseDelay, удваивающийся с каждой неудачей (но не больше MaxDelay).
+// После MaxFailures неудач аккаунт блокируется на LockoutDuration,
+// после IPMaxFailures неудач с одного IP вход с него запрещён до конца окна.
+type LoginConfig struct {
+	Window          time.Duration `yaml:"window" env:"LOGIN_FAILURE_WINDOW"`
+	DelayAfter      int           `yaml:"delay_after" env:"LOGIN_DELAY_AFTER"`
+	BaseDelay       time.Duration `yaml:"base_delay" env:"LOGIN_BASE_DELAY"`
+	MaxDelay        time.Duration `yaml:"max_delay" env:"LOGIN_MAX_DELAY"`
+	MaxFailures     int           `yaml:"max_failures" env:"LOGIN_MAX_FAILURES"`
+	IPMaxFailures   int           `yaml:"ip_max_failures" env:"LOGIN_IP_MAX_FAILURES"`
+	LockoutDuration time.Duration `yaml:"lockout_duration" env:"LOGIN_LOCKOUT_DURATION"`
+}
+
+// MailConfig — отправка писем пользователям: log (только в лог) | smtp.
+type MailConfig struct {
+	Driver string     `yaml:"driver" env:"MAIL_DRIVER"`
+	From   string     `yaml:"from" env:"MAIL_FROM"`
+	SMTP   SMTPConfig `yaml:"smtp"`
+	// EmailConfirmURL — страница фронтенда, подтверждающая смену email (токен передаётся в ?token=).
+	EmailConfirmURL string        `yaml:"email_confirm_url" env:"MAIL_EMAIL_CONFIRM_URL"`
+	EmailConfirmTTL time.Duration `yaml:"email_confirm_ttl" env:"MAIL_EMAIL_CONFIRM_TTL"`
+}
+
+// SMTPConfig — подключение к SMTP-серверу.
+type SMTPConfig struct {
+	Host     string `yaml:"host" env:"SMTP_HOST"`
+	Port     int    `yaml:"port" env:"SMTP_PORT"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// RatePolicy — лимит в формате "запросы/окно", например "100/1m".
+type RatePolicy string
+
+// Parse разбирает лимит на число запросов и длину окна.
+func (p RatePolicy) Parse() (int, time.Duration, error) {
+	countPart, windowPart, ok := strings.Cut(string(p), "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("rate policy %q must look like 100/1m", p)
+	}
+	count, err := strconv.Atoi(countPart)
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("rate policy %q: request count must be a positive integer", p)
+	}
+	window, err := time.ParseDuration(windowPart)
+	if err != nil || window < time.Second {
+		return 0, 0, fmt.Errorf("rate policy %q: window must be a duration of at least 1s", p)
 	}
+	return count, window, nil
+}
 
-	// --- PostgreSQL ---
-	dbConnStr := os.Getenv("DATABASE_URL")
-	if dbConnStr == "" {
-		log.Fatal("DATABASE_URL is not set")
+// Default возвращает конфигурацию со значениями по умолчанию.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:               8080,
+			ReadTimeout:        15 * time.Second,
+			ReadHeaderTimeout:  5 * time.Second,
+			WriteTimeout:       60 * time.Second,
+			IdleTimeout:        120 * time.Second,
+			ShutdownTimeout:    25 * time.Second,
+			HealthCheckTimeout: 2 * time.Second,
+		},
+		Database: DatabaseConfig{
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Redis: RedisConfig{
+			Addr: "localhost:6379",
+		},
+		Storage: StorageConfig{
+			Driver:    "minio",
+			LocalDir:  "./data/photos",
+			PublicURL: "http://localhost:8080/api/storage",
+		},
+		Photos: PhotosConfig{
+			DeliveryMode: "redirect",
+			URLTTL:       15 * time.Minute,
+			CacheMaxAge:  24 * time.Hour,
+		},
+		JWT: JWTConfig{
+			TTL: 24 * time.Hour,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   []string{"https://petelka.shop"},
+			AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Request-ID", "If-Match", "Idempotency-Key"},
+			ExposedHeaders:   []string{"X-Request-ID", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "ETag", "Idempotent-Replayed"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		},
+		Cache: CacheConfig{
+			EntityTTL: 10 * time.Minute,
+		},
+		Tracing: TracingConfig{
+			Exporter:     "none",
+			OTLPEndpoint: "localhost:4318",
+			SampleRatio:  1,
+			ServiceName:  "petelka-api",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:   true,
+			FailOpen:  true,
+			Public:    "300/1m",
+			Auth:      "10/1m",
+			Protected: "120/1m",
+			Admin:     "600/1m",
+		},
+		API: APIConfig{
+			LegacyRoutes: true,
+		},
+		Audit: AuditConfig{
+			Retention: 365 * 24 * time.Hour,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:         24 * time.Hour,
+			LockTimeout: time.Minute,
+		},
+		Payments: PaymentsConfig{
+			ReturnURL: "https://petelka.shop/orders",
+			Timeout:   10 * time.Second,
+			YooKassa: YooKassaConfig{
+				APIURL: "https://api.yookassa.ru/v3",
+			},
+		},
+		Invoices: InvoicesConfig{
+			Bucket: "invoices",
+			Shop: ShopConfig{
+				Name: "Петелька",
+			},
+		},
+		Login: LoginConfig{
+			Window:          15 * time.Minute,
+			DelayAfter:      3,
+			BaseDelay:       time.Second,
+			MaxDelay:        30 * time.Second,
+			MaxFailures:     10,
+			IPMaxFailures:   50,
+			LockoutDuration: 30 * time.Minute,
+		},
+		Mail: MailConfig{
+			Driver: "log",
+			From:   "no-reply@petelka.shop",
+			SMTP: SMTPConfig{
+				Port: 587,
+			},
+			EmailConfirmURL: "https://petelka.shop/confirm-email",
+			EmailConfirmTTL: 24 * time.Hour,
+		},
+		Log: LogConfig{
+			Level:              "info",
+			Format:             "json",
+			SamplingInitial:    100,
+			SamplingThereafter: 100,
+		},
 	}
-	db, err := sql.Open("postgres", dbConnStr)
-	if err != nil {
-		log.Errorf("Failed to open PostgreSQL connection: %v", err)
-		return nil, err
+}
+
+// Load собирает конфигурацию из значений по умолчанию, YAML-файла path
+// (пустой path — без файла) и переменных окружения, затем проверяет её.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
 	}
-	if err := db.Ping(); err != nil {
-		log.Errorf("Failed to ping PostgreSQL: %v", err)
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
 		return nil, err
 	}
-	log.Info("Connected to PostgreSQL successfully")
-
-	// --- Redis ---
-	redisAddr := os.Getenv("REDIS_URL")
-	redisPass := os.Getenv("REDIS_PASS")
-	if redisAddr == "" || redisPass == "" {
-		log.Fatal("REDIS_URL or REDIS_PASS is not set")
-	}
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: redisPass,
-		DB:       0,
-	})
-	if _, err := redisClient.Ping(context.Background()).Result(); err != nil {
-		log.Errorf("Failed to ping Redis: %v", err)
+
+	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
-	log.Info("Connected to Redis successfully")
+	return cfg, nil
+}
+
+// Validate проверяет конфигурацию и возвращает все найденные ошибки сразу.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.HealthCheckTimeout > 0, "server.health_check_timeout must be positive")
+
+	check(c.Database.URL != "", "database.url (DATABASE_URL) is required")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns (%d) must not exceed database.max_open_conns (%d)", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+
+	check(c.Redis.Addr != "", "redis.addr (REDIS_URL) is required")
+	check(c.Redis.DB >= 0 && c.Redis.DB <= 15, "redis.db must be between 0 and 15, got %d", c.Redis.DB)
+
+	switch c.Storage.Driver {
+	case "minio":
+		m := c.Storage.Minio
+		check(m.Endpoint != "" && m.AccessKey != "" && m.SecretKey != "" && m.Bucket != "",
+			"storage.minio requires MINIO_ENDPOINT, MINIO_ACCESS_KEY, MINIO_SECRET_KEY, MINIO_BUCKET")
+	case "local":
+		check(c.Storage.LocalDir != "", "storage.local_dir is required for local storage")
+		check(c.Storage.SigningKey != "", "storage.signing_key (STORAGE_SIGNING_KEY) is required for local storage")
+	default:
+		check(false, "storage.driver must be one of minio, local, got %q", c.Storage.Driver)
+	}
 
-	// --- MinIO ---
-	minioEndpoint := os.Getenv("MINIO_ENDPOINT")
-	minioAccessKey := os.Getenv("MINIO_ACCESS_KEY")
-	minioSecretKey := os.Getenv("MINIO_SECRET_KEY")
-	minioBucket := os.Getenv("MINIO_BUCKET")
-	minioUseSSL := os.Getenv("MINIO_USE_SSL") == "true"
+	switch c.Photos.DeliveryMode {
+	case "url", "proxy", "redirect":
+	default:
+		check(false, "photos.delivery_mode must be one of url, proxy, redirect, got %q", c.Photos.DeliveryMode)
+	}
+	check(c.Photos.URLTTL > 0 && c.Photos.URLTTL <= 7*24*time.Hour, "photos.url_ttl must be positive and not exceed 7 days")
 
-	if minioEndpoint == "" || minioAccessKey == "" || minioSecretKey == "" || minioBucket == "" {
-		return nil, fmt.Errorf("MinIO config missing: MINIO_ENDPOINT, MINIO_ACCESS_KEY, MINIO_SECRET_KEY, MINIO_BUCKET")
+	check(c.JWT.Secret != "", "jwt.secret (JWT_SECRET) is required")
+	check(c.JWT.TTL > 0, "jwt.ttl must be positive")
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			check(!c.CORS.AllowCredentials, "cors.allowed_origins: \"*\" cannot be combined with cors.allow_credentials")
+			continue
+		}
+		check(validOrigin(origin), "cors.allowed_origins: invalid origin %q, expected scheme://host[:port] or scheme://*.domain", origin)
 	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age must not be negative")
 
-	log.Info("MinIO configuration loaded")
+	check(c.API.LegacySunset.IsZero() || c.API.LegacyDeprecation.IsZero() || c.API.LegacySunset.After(c.API.LegacyDeprecation),
+		"api.legacy_sunset must be later than api.legacy_deprecation")
 
-	return &Config{
-		DB:             db,
-		Redis:          redisClient,
-		MinioEndpoint:  minioEndpoint,
-		MinioAccessKey: minioAccessKey,
-		MinioSecretKey: minioSecretKey,
-		MinioBucket:    minioBucket,
-		MinioUseSSL:    minioUseSSL,
-	}, nil
+	check(c.Cache.EntityTTL > 0, "cache.entity_ttl must be positive")
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		check(c.Tracing.OTLPEndpoint != "", "tracing.otlp_endpoint is required for otlp exporter")
+	default:
+		check(false, "tracing.exporter must be one of none, stdout, otlp, got %q", c.Tracing.Exporter)
+	}
+	for name, policy := range map[string]RatePolicy{
+		"public": c.RateLimit.Public, "auth": c.RateLimit.Auth,
+		"protected": c.RateLimit.Protected, "admin": c.RateLimit.Admin,
+	} {
+		if _, _, err := policy.Parse(); err != nil {
+			check(false, "rate_limit.%s: %v", name, err)
+		}
+	}
+
+	check(c.Login.Window > 0, "login.window must be positive")
+	check(c.Login.DelayAfter >= 0, "login.delay_after must not be negative")
+	check(c.Login.BaseDelay >= 0 && c.Login.MaxDelay >= c.Login.BaseDelay, "login.max_delay must not be less than login.base_delay")
+	check(c.Login.MaxFailures > 0, "login.max_failures must be positive")
+	check(c.Login.IPMaxFailures > 0, "login.ip_max_failures must be positive")
+	check(c.Login.LockoutDuration > 0, "login.lockout_duration must be positive")
+
+	check(c.Audit.Retention > 0, "audit.retention must be positive")
+	check(c.Idempotency.TTL > 0, "idempotency.ttl must be positive")
+	check(c.Idempotency.LockTimeout > 0 && c.Idempotency.LockTimeout <= c.Idempotency.TTL,
+		"idempotency.lock_timeout must be positive and not exceed idempotency.ttl")
+
+	switch c.Payments.Driver {
+	case "":
+		check(false, "payments.driver (PAYMENTS_DRIVER) is required: yookassa, or fake for development")
+	case "fake":
+		check(c.Payments.WebhookSecret != "", "payments.webhook_secret (PAYMENTS_WEBHOOK_SECRET) is required for fake driver")
+	case "yookassa":
+		check(c.Payments.YooKassa.ShopID != "", "payments.yookassa.shop_id (YOOKASSA_SHOP_ID) is required for yookassa driver")
+		check(c.Payments.YooKassa.SecretKey != "", "payments.yookassa.secret_key (YOOKASSA_SECRET_KEY) is required for yookassa driver")
+	default:
+		check(false, "payments.driver must be one of fake, yookassa, got %q", c.Payments.Driver)
+	}
+	check(strings.HasPrefix(c.Payments.ReturnURL, "http://") || strings.HasPrefix(c.Payments.ReturnURL, "https://"),
+		"payments.return_url must be an http(s) URL")
+	check(c.Payments.Timeout > 0, "payments.timeout must be positive")
+
+	check(validVATRate(c.Tax.VATRate), "tax.vat_rate must be one of 0, 5, 7, 10, 20, 22, got %d", c.Tax.VATRate)
+
+	check(c.Invoices.Bucket != "" && !strings.ContainsAny(c.Invoices.Bucket, `/\.`),
+		"invoices.bucket (INVOICES_BUCKET) is required and must be a plain name")
+	check(c.Invoices.Shop.Name != "", "invoices.shop.name (SHOP_NAME) is required")
+
+	switch c.Mail.Driver {
+	case "log":
+	case "smtp":
+		check(c.Mail.SMTP.Host != "", "mail.smtp.host (SMTP_HOST) is required for smtp driver")
+		check(c.Mail.SMTP.Port > 0 && c.Mail.SMTP.Port < 65536, "mail.smtp.port must be between 1 and 65535, got %d", c.Mail.SMTP.Port)
+		check(c.Mail.From != "", "mail.from (MAIL_FROM) is required for smtp driver")
+	default:
+		check(false, "mail.driver must be one of log, smtp, got %q", c.Mail.Driver)
+	}
+	check(strings.HasPrefix(c.Mail.EmailConfirmURL, "http://") || strings.HasPrefix(c.Mail.EmailConfirmURL, "https://"),
+		"mail.email_confirm_url must be an http(s) URL")
+	check(c.Mail.EmailConfirmTTL > 0, "mail.email_confirm_ttl must be positive")
+
+	check(validLogLevel(c.Log.Level), "log.level must be one of debug, info, warn, error, got %q", c.Log.Level)
+	check(c.Log.Format == "json" || c.Log.Format == "console", "log.format must be json or console, got %q", c.Log.Format)
+	check(c.Log.SamplingInitial >= 0 && c.Log.SamplingThereafter >= 0, "log sampling values must not be negative")
+	for pkg, level := range c.Log.PackageLevels {
+		check(validLogLevel(level), "log.package_levels.%s: invalid level %q", pkg, level)
+	}
+
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+
+	return errors.Join(errs...)
+}
+
+func validLogLevel(level string) bool {
+	switch level {
+	case "debug", "info", "warn", "error":
+		return true
+	}
+	return false
+}
+
+// validVATRate проверяет, что ставка НДС предусмотрена Налоговым кодексом.
+func validVATRate(rate int) bool {
+	switch rate {
+	case 0, 5, 7, 10, 20, 22:
+		return true
+	}
+	return false
+}
+
+// validOrigin проверяет источник CORS: схема http(s), хост без пути, "*" — только первым элементом хоста.
+func validOrigin(origin string) bool {
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok || (scheme != "http" && scheme != "https") || host == "" || strings.ContainsAny(host, "/?#@") {
+		return false
+	}
+	host = strings.TrimPrefix(host, "*.")
+	return host != "" && !strings.Contains(host, "*")
+}
+
+// Addr возвращает адрес, на котором слушает HTTP-сервер.
+func (c *Config) Addr() string {
+	return fmt.Sprintf(":%d", c.Server.Port)
+}
+
+// Redacted возвращает копию конфигурации, в которой секреты заменены на "******".
+func (c *Config) Redacted() *Config {
+	cp := *c
+	redact(reflect.ValueOf(&cp).Elem())
+	return &cp
+}
+
+// YAML сериализует конфигурацию в формат конфигурационного файла.
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// applyEnv рекурсивно заполняет поля с тегом env из переменных окружения.
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			if err := applyEnv(fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key := field.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(key)
+		if !ok || raw == "" {
+			continue
+		}
+		if err := setField(fv, raw); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, raw string) error {
+	switch {
+	case fv.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+	case fv.Type() == timeType:
+		t, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			if t, err = time.Parse(time.RFC3339, raw); err != nil {
+				return fmt.Errorf("expected date 2006-01-02 or RFC 3339 time, got %q", raw)
+			}
+		}
+		fv.Set(reflect.ValueOf(t))
+	case fv.Kind() == reflect.String:
+		fv.SetString(raw)
+	case fv.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(n))
+	case fv.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case fv.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		fv.Set(reflect.ValueOf(items))
+	case fv.Kind() == reflect.Map && fv.Type().Key().Kind() == reflect.String && fv.Type().Elem().Kind() == reflect.String:
+		items := make(map[string]string)
+		for _, item := range strings.Split(raw, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if !ok || key == "" {
+				return fmt.Errorf("expected key=value pairs, got %q", item)
+			}
+			items[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		fv.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+// redact заменяет значения строковых полей с тегом secret.
+func redact(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			redact(fv)
+			continue
+		}
+		if field.Tag.Get("secret") == "true" && fv.Kind() == reflect.String && fv.String() != "" {
+			fv.SetString("******")
+		}
+	}
 }
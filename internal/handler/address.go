@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// AddressHandler handles the address book of the current user.
+type AddressHandler struct {
+	service *service.AddressService
+}
+
+// NewAddressHandler creates a new AddressHandler instance.
+func NewAddressHandler(s *service.AddressService) *AddressHandler {
+	return &AddressHandler{service: s}
+}
+
+// RegisterRoutes registers address book routes in the access groups.
+func (h *AddressHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	protected.HandleFunc("/me/addresses", h.ListAddresses).Methods("GET")
+	protected.HandleFunc("/me/addresses", h.CreateAddress).Methods("POST")
+	protected.HandleFunc("/me/addresses/{id}", h.UpdateAddress).Methods("PUT")
+	protected.HandleFunc("/me/addresses/{id}", h.PatchAddress).Methods("PATCH")
+	protected.HandleFunc("/me/addresses/{id}", h.DeleteAddress).Methods("DELETE")
+}
+
+// ListAddresses godoc
+// @Summary List current user addresses
+// @Description List the address book of the current user, the default address first
+// @Tags me
+// @Produce json
+// @Success 200 {array} AddressResponse "Addresses"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /me/addresses [get]
+func (h *AddressHandler) ListAddresses(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	addresses, err := h.service.ListAddresses(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewAddressResponses(addresses))
+}
+
+// CreateAddress godoc
+// @Summary Add an address
+// @Description Add an address to the current user's address book. The first address becomes the default one
+// @Tags me
+// @Accept json
+// @Produce json
+// @Param address body AddressRequest true "Address"
+// @Success 201 {object} AddressResponse "Address created"
+// @Failure 400 {string} string "Invalid address"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /me/addresses [post]
+func (h *AddressHandler) CreateAddress(w http.ResponseWriter, r *http.Request) {
+	var req AddressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value(UserIDKey).(int)
+	address := req.Model(userID, 0)
+
+	if err := h.service.CreateAddress(r.Context(), address); err != nil {
+		writeAddressError(w, err)
+		return
+	}
+
+	setETag(w, address.Version)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(NewAddressResponse(address))
+}
+
+// UpdateAddress godoc
+// @Summary Update an address
+// @Description Replace an address of the current user. Orders already placed keep their copy of the address. If If-Match is sent, the address is updated only if it matches its ETag
+// @Tags me
+// @Accept json
+// @Produce json
+// @Param id path int true "Address ID"
+// @Param If-Match header string false "ETag of the address version being replaced; without it the update is unconditional"
+// @Param address body AddressRequest true "Address"
+// @Success 200 {object} AddressResponse "Address updated"
+// @Failure 400 {string} string "Invalid address or ID"
+// @Failure 404 {string} string "Address not found"
+// @Failure 412 {string} string "Address has been modified"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /me/addresses/{id} [put]
+func (h *AddressHandler) UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+	var req AddressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value(UserIDKey).(int)
+	address := req.Model(userID, id)
+	address.Version = version
+
+	h.saveAddress(w, r, address)
+}
+
+// PatchAddress godoc
+// @Summary Partially update an address
+// @Description Apply a JSON Merge Patch (RFC 7386) to an address of the current user. Orders already placed keep their copy of the address. The patch is applied only if If-Match matches the ETag
+// @Tags me
+// @Accept json
+// @Produce json
+// @Param id path int true "Address ID"
+// @Param If-Match header string true "ETag of the address version being patched"
+// @Param patch body AddressRequest true "Fields to change"
+// @Success 200 {object} AddressResponse "Address updated"
+// @Failure 400 {string} string "Invalid patch or ID"
+// @Failure 404 {string} string "Address not found"
+// @Failure 412 {string} string "Address has been modified"
+// @Failure 415 {string} string "Unsupported patch format"
+// @Failure 428 {string} string "If-Match header is required"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /me/addresses/{id} [patch]
+func (h *AddressHandler) PatchAddress(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	current, err := h.service.GetAddress(r.Context(), userID, id)
+	if err != nil {
+		writeAddressError(w, err)
+		return
+	}
+	if version != 0 && version != current.Version {
+		writePreconditionFailed(w)
+		return
+	}
+	var req AddressRequest
+	if !decodeMergePatch(w, r, NewAddressRequest(current), &req) {
+		return
+	}
+	address := req.Model(userID, id)
+	// Изменение применяется к прочитанной версии: параллельная запись приведёт к 412
+	address.Version = current.Version
+
+	h.saveAddress(w, r, address)
+}
+
+// saveAddress сохраняет изменённый адрес и отвечает им и его новой версией.
+func (h *AddressHandler) saveAddress(w http.ResponseWriter, r *http.Request, address *models.Address) {
+	if err := h.service.UpdateAddress(r.Context(), address); err != nil {
+		writeAddressError(w, err)
+		return
+	}
+
+	setETag(w, address.Version)
+	json.NewEncoder(w).Encode(NewAddressResponse(address))
+}
+
+// DeleteAddress godoc
+// @Summary Delete an address
+// @Description Delete an address of the current user
+// @Tags me
+// @Param id path int true "Address ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid ID format"
+// @Failure 404 {string} string "Address not found"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /me/addresses/{id} [delete]
+func (h *AddressHandler) DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	if err := h.service.DeleteAddress(r.Context(), userID, id); err != nil {
+		writeAddressError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeAddressError отвечает кодом, соответствующим ошибке адресной книги.
+func writeAddressError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Address not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrVersionConflict):
+		writePreconditionFailed(w)
+	case errors.Is(err, service.ErrInvalidAddress):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+)
+
+// Журнал аудита: AuditMiddleware записывает каждое успешное изменение в админских
+// маршрутах — кто, что и с каким ресурсом сделал, и какие поля изменились.
+
+// maxAuditBody ограничивает размер ответа, который сохраняется для сравнения.
+const maxAuditBody = 1 << 20
+
+// maxAuditPageLimit ограничивает размер страницы журнала аудита.
+const maxAuditPageLimit = 100
+
+// AuditRecorder сохраняет записи журнала аудита. Реализуется service.AuditService.
+type AuditRecorder interface {
+	Record(ctx context.Context, entry *models.AuditEntry, before, after []byte) error
+}
+
+// AuditSnapshot возвращает текущее состояние ресурса в том виде, в каком его отдаёт API.
+type AuditSnapshot func(ctx context.Context, id string) (interface{}, error)
+
+// AuditOptions — параметры журнала аудита.
+type AuditOptions struct {
+	// TrustForwardedFor — брать IP клиента из X-Forwarded-For (только за доверенным балансировщиком).
+	TrustForwardedFor bool
+	// Snapshots загружают состояние ресурса до изменения по его типу, например "products".
+	// Состояние после изменения берётся из ответа обработчика.
+	Snapshots map[string]AuditSnapshot
+}
+
+// AuditMiddleware записывает в журнал аудита успешные запросы, изменяющие данные
+// (все методы, кроме GET, HEAD и OPTIONS). Подключается в группу админских маршрутов
+// после AuthMiddleware. Тип ресурса, его ID и действие определяются по шаблону маршрута:
+// POST /products — create, PUT и PATCH /products/{id} — update и patch,
+// DELETE — delete, POST /users/{id}/block — block.
+// Ошибка записи в журнал не меняет ответ клиенту.
+func AuditMiddleware(recorder AuditRecorder, opts AuditOptions) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			entry := auditEntry(r)
+			var before []byte
+			if snapshot := opts.Snapshots[entry.ResourceType]; snapshot != nil && entry.ResourceID != "" {
+				if state, err := snapshot(r.Context(), entry.ResourceID); err == nil {
+					before, _ = json.Marshal(state)
+				}
+			}
+
+			rec := &auditWriter{statusRecorder: statusRecorder{ResponseWriter: w}}
+			next.ServeHTTP(rec, r)
+
+			status := rec.statusCode()
+			if status < 200 || status >= 300 {
+				return
+			}
+			after := rec.body.Bytes()
+			if rec.truncated {
+				logger.FromContext(r.Context()).Warningf("Audit: response of %s %s is too large to compare", r.Method, r.URL.Path)
+				before, after = nil, nil
+			}
+			if entry.ResourceID == "" {
+				entry.ResourceID = createdResourceID(after)
+			}
+
+			entry.ActorID, _ = r.Context().Value(UserIDKey).(int)
+			entry.IP = ClientIP(r, opts.TrustForwardedFor)
+			entry.RequestID = RequestIDFromContext(r.Context())
+			// Ответ уже отправлен: отмена запроса клиентом не должна терять запись
+			recorder.Record(context.WithoutCancel(r.Context()), entry, before, after)
+		})
+	}
+}
+
+// auditWriter запоминает код ответа и тело ответа для журнала аудита.
+type auditWriter struct {
+	statusRecorder
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if !w.truncated {
+		if w.body.Len()+len(b) > maxAuditBody {
+			w.truncated = true
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.statusRecorder.Write(b)
+}
+
+// auditEntry определяет действие, тип и ID ресурса по шаблону маршрута запроса.
+// Префиксы api, версии API (v1) и admin пропускаются; ID — значение первой
+// переменной маршрута.
+func auditEntry(r *http.Request) *models.AuditEntry {
+	entry := &models.AuditEntry{}
+	var literals []string
+	for _, segment := range strings.Split(strings.Trim(routeTemplate(r), "/"), "/") {
+		if strings.HasPrefix(segment, "{") {
+			if entry.ResourceID == "" {
+				name, _, _ := strings.Cut(strings.Trim(segment, "{}"), ":")
+				entry.ResourceID = mux.Vars(r)[name]
+			}
+			continue
+		}
+		if len(literals) == 0 && (segment == "api" || segment == "admin" || isAPIVersion(segment)) {
+			continue
+		}
+		literals = append(literals, segment)
+	}
+
+	entry.ResourceType = "unknown"
+	if len(literals) > 0 {
+		entry.ResourceType = literals[0]
+	}
+	if len(literals) > 1 {
+		entry.Action = literals[len(literals)-1]
+		return entry
+	}
+	switch r.Method {
+	case http.MethodPost:
+		entry.Action = "create"
+	case http.MethodPut:
+		entry.Action = "update"
+	default:
+		entry.Action = strings.ToLower(r.Method)
+	}
+	return entry
+}
+
+// isAPIVersion проверяет, что сегмент пути — версия API вида v1.
+func isAPIVersion(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(segment[1:])
+	return err == nil
+}
+
+// createdResourceID возвращает поле id из ответа на создание ресурса.
+func createdResourceID(body []byte) string {
+	var created struct {
+		ID json.Number `json:"id"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return ""
+	}
+	return created.ID.String()
+}
+
+// AuditHandler handles requests to the audit log.
+type AuditHandler struct {
+	service *service.AuditService
+}
+
+// NewAuditHandler creates a new AuditHandler instance.
+func NewAuditHandler(s *service.AuditService) *AuditHandler {
+	return &AuditHandler{service: s}
+}
+
+// RegisterRoutes registers audit log routes in the access groups.
+func (h *AuditHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	admin.HandleFunc("/admin/audit", h.ListAudit).Methods("GET")
+}
+
+// ListAudit godoc
+// @Summary List audit log entries
+// @Description List changes made through admin routes, newest first, with filters and pagination
+// @Tags admin
+// @Produce json
+// @Param actor_id query int false "ID of the admin who made the change"
+// @Param action query string false "Action (create, update, patch, delete, block, ...)"
+// @Param resource_type query string false "Resource type (products, categories, users, ...)"
+// @Param resource_id query string false "Resource ID"
+// @Param from query string false "Changes on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Changes on or before this date (YYYY-MM-DD)"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 50, max 100)"
+// @Success 200 {object} AuditLogResponse "Audit log entries with total count"
+// @Failure 400 {string} string "Invalid query parameters"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/audit [get]
+func (h *AuditHandler) ListAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := service.AuditFilter{
+		Action:       q.Get("action"),
+		ResourceType: q.Get("resource_type"),
+		ResourceID:   q.Get("resource_id"),
+		Page:         1,
+		Limit:        50,
+	}
+
+	if v := q.Get("actor_id"); v != "" {
+		actorID, err := strconv.Atoi(v)
+		if err != nil || actorID < 1 {
+			http.Error(w, "Invalid actor_id format", http.StatusBadRequest)
+			return
+		}
+		filter.ActorID = actorID
+	}
+	if v := q.Get("from"); v != "" {
+		from, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			http.Error(w, "Invalid from format, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		filter.From = from
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			http.Error(w, "Invalid to format, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		filter.To = to.AddDate(0, 0, 1) // включая весь указанный день
+	}
+	if v := q.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			http.Error(w, "Invalid page format", http.StatusBadRequest)
+			return
+		}
+		filter.Page = page
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit format", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(limit, maxAuditPageLimit)
+	}
+
+	entries, totalCount, err := h.service.ListEntries(r.Context(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(AuditLogResponse{
+		Entries:    mapSlice(entries, NewAuditEntryResponse),
+		TotalCount: totalCount,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+	})
+}
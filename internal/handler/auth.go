@@ -3,23 +3,49 @@ package handler
 import (
 	"database/sql"
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/alex-pyslar/petelka-api/internal/models"
 	"github.com/alex-pyslar/petelka-api/internal/service"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 )
 
 // AuthHandler обрабатывает запросы авторизации с использованием JWT
 type AuthHandler struct {
 	userService *service.UserService
+	guard       *service.LoginGuard
+	opts        AuthOptions
 }
 
-// NewAuthHandler создаёт новый обработчик авторизации
-func NewAuthHandler(s *service.UserService) *AuthHandler {
-	return &AuthHandler{userService: s}
+// AuthOptions — параметры выдачи токенов и определения IP клиента.
+type AuthOptions struct {
+	JWTKey            []byte        // ключ подписи токенов
+	TokenTTL          time.Duration // срок действия токена
+	TrustForwardedFor bool          // брать IP клиента из X-Forwarded-For
+	// Throttle — дополнительное ограничение частоты запросов к /auth.
+	Throttle mux.MiddlewareFunc
+}
+
+// NewAuthHandler создаёт новый обработчик авторизации.
+// guard ограничивает перебор паролей и ведёт журнал попыток входа.
+func NewAuthHandler(s *service.UserService, guard *service.LoginGuard, opts AuthOptions) *AuthHandler {
+	return &AuthHandler{userService: s, guard: guard, opts: opts}
+}
+
+// RegisterRoutes регистрирует маршруты входа и регистрации, а также снятия блокировки входа.
+func (h *AuthHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	auth := public.PathPrefix("/auth").Subrouter()
+	if h.opts.Throttle != nil {
+		auth.Use(h.opts.Throttle)
+	}
+	auth.HandleFunc("/register", h.Register).Methods("POST")
+	auth.HandleFunc("/login", h.Login).Methods("POST")
+
+	admin.HandleFunc("/users/{id}/unlock", h.UnlockUser).Methods("POST")
 }
 
 // Register godoc
@@ -28,26 +54,27 @@ func NewAuthHandler(s *service.UserService) *AuthHandler {
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param user body models.User true "Данные пользователя для регистрации"
-// @Success 201 {object} models.User "Пользователь успешно создан"
+// @Param user body RegisterRequest true "Данные пользователя для регистрации"
+// @Success 201 {object} UserResponse "Пользователь успешно создан"
 // @Failure 400 {string} string "Неверный формат запроса или пользователь с таким email уже существует"
 // @Failure 500 {string} string "Внутренняя ошибка сервера"
 // @Router /auth/register [post]
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.userService.CreateUser(r.Context(), &user); err != nil {
+	user := req.Model()
+	if err := h.userService.CreateUser(r.Context(), user); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Устанавливаем статус 201 и возвращаем созданного пользователя
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(NewUserResponse(user))
 }
 
 // Login godoc
@@ -60,6 +87,8 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} LoginResponse "Успешная аутентификация, возвращает токен"
 // @Failure 400 {string} string "Неверный запрос или отсутствуют учетные данные"
 // @Failure 401 {string} string "Неверный email или пароль"
+// @Failure 403 {string} string "Аккаунт заблокирован администратором"
+// @Failure 429 {string} string "Слишком много неудачных попыток, повторите после Retry-After"
 // @Failure 500 {string} string "Внутренняя ошибка сервера"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -69,9 +98,30 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userService.GetUserByEmail(r.Context(), req.Email)
+	ctx := r.Context()
+	attempt := service.LoginAttempt{
+		Email:     req.Email,
+		IP:        ClientIP(r, h.opts.TrustForwardedFor),
+		UserAgent: r.UserAgent(),
+	}
+
+	// Заблокированный аккаунт или действующая задержка — пароль не проверяем
+	if err := h.guard.Check(ctx, attempt); err != nil {
+		var blocked *service.LoginBlockedError
+		if errors.As(err, &blocked) {
+			h.guard.RecordFailure(ctx, attempt, nil, service.LoginReasonBlocked)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+			http.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.userService.GetUserByEmail(ctx, req.Email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			h.guard.RecordFailure(ctx, attempt, nil, service.LoginReasonUnknownUser)
 			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 			return
 		}
@@ -79,12 +129,20 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.userService.VerifyPassword(r.Context(), user.ID, req.Password); err != nil {
+	if err := h.userService.VerifyPassword(ctx, user.ID, req.Password); err != nil {
+		h.guard.RecordFailure(ctx, attempt, user, service.LoginReasonWrongPassword)
 		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 		return
 	}
+	// О блокировке сообщаем только после проверки пароля, чтобы не раскрывать статус аккаунта
+	if user.BlockedAt != nil {
+		h.guard.RecordFailure(ctx, attempt, user, service.LoginReasonUserBlocked)
+		http.Error(w, "Account is blocked", http.StatusForbidden)
+		return
+	}
+	h.guard.RecordSuccess(ctx, attempt, user)
 
-	expirationTime := time.Now().Add(24 * time.Hour) // Токен действителен 24 часа
+	expirationTime := time.Now().Add(h.opts.TokenTTL)
 	claims := &Claims{
 		UserID: user.ID,
 		Email:  user.Email,
@@ -97,7 +155,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	tokenString, err := token.SignedString(h.opts.JWTKey)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -106,6 +164,41 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(LoginResponse{Token: tokenString})
 }
 
+// UnlockUser godoc
+// @Summary Снять блокировку входа
+// @Description Досрочно снимает блокировку входа, наложенную после серии неудачных попыток
+// @Tags auth
+// @Param id path int true "ID пользователя"
+// @Success 204 "Блокировка снята"
+// @Failure 400 {string} string "Неверный формат ID"
+// @Failure 404 {string} string "Пользователь не найден"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Security ApiKeyAuth
+// @Router /users/{id}/unlock [post]
+func (h *AuthHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.guard.Unlock(r.Context(), user.Email); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // LoginRequest представляет структуру запроса для входа
 type LoginRequest struct {
 	Email    string `json:"email"`
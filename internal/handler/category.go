@@ -1,12 +1,13 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
-	"github.com/alex-pyslar/petelka-api/internal/models"
 	"net/http"
 	"strconv"
 
+	"github.com/alex-pyslar/petelka-api/internal/models"
 	"github.com/alex-pyslar/petelka-api/internal/service"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -22,32 +23,58 @@ func NewCategoryHandler(s *service.CategoryService) *CategoryHandler {
 	return &CategoryHandler{service: s}
 }
 
+// RegisterRoutes registers category routes in the access groups.
+func (h *CategoryHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	public.HandleFunc("/categories", h.ListCategories).Methods("GET")
+	public.HandleFunc("/categories/{id}", h.GetCategory).Methods("GET")
+
+	admin.HandleFunc("/categories", h.CreateCategory).Methods("POST")
+	admin.HandleFunc("/categories/{id}", h.UpdateCategory).Methods("PUT")
+	admin.HandleFunc("/categories/{id}", h.PatchCategory).Methods("PATCH")
+	admin.HandleFunc("/categories/{id}", h.DeleteCategory).Methods("DELETE")
+}
+
+// Snapshot returns the current category as the API renders it, for the audit log.
+func (h *CategoryHandler) Snapshot(ctx context.Context, id string) (interface{}, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	category, err := h.service.GetCategory(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	return NewCategoryResponse(category), nil
+}
+
 // CreateCategory godoc
 // @Summary Create a new category
 // @Description Create a new category with the input payload
 // @Tags categories
 // @Accept json
 // @Produce json
-// @Param category body models.Category true "Category object"
-// @Success 201 {object} models.Category "Category created successfully"
+// @Param category body CategoryRequest true "Category object"
+// @Success 201 {object} CategoryResponse "Category created successfully"
 // @Failure 400 {string} string "Invalid request body"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /categories [post]
 func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
-	var category models.Category
-	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	category := req.Model(0)
 
-	if err := h.service.CreateCategory(r.Context(), &category); err != nil {
+	if err := h.service.CreateCategory(r.Context(), category); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	setETag(w, category.Version)
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(category)
+	json.NewEncoder(w).Encode(NewCategoryResponse(category))
 }
 
 // GetCategory godoc
@@ -56,7 +83,7 @@ func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request)
 // @Tags categories
 // @Produce json
 // @Param id path int true "Category ID"
-// @Success 200 {object} models.Category "Category found"
+// @Success 200 {object} CategoryResponse "Category found"
 // @Failure 400 {string} string "Invalid ID format"
 // @Failure 404 {string} string "Category not found"
 // @Failure 500 {string} string "Internal server error"
@@ -85,7 +112,8 @@ func (h *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(category)
+	setETag(w, category.Version)
+	json.NewEncoder(w).Encode(NewCategoryResponse(category))
 }
 
 // ListCategories godoc
@@ -93,7 +121,7 @@ func (h *CategoryHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
 // @Description Retrieve a list of all categories
 // @Tags categories
 // @Produce json
-// @Success 200 {array} models.Category "List of categories"
+// @Success 200 {array} CategoryResponse "List of categories"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /categories [get]
@@ -104,20 +132,22 @@ func (h *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	json.NewEncoder(w).Encode(categories)
+	json.NewEncoder(w).Encode(NewCategoryResponses(categories))
 }
 
 // UpdateCategory godoc
 // @Summary Update an existing category
-// @Description Update category details by ID
+// @Description Replace category details by ID. The category is updated only if If-Match matches its ETag
 // @Tags categories
 // @Accept json
 // @Produce json
 // @Param id path int true "Category ID"
-// @Param category body models.Category true "Category object with updated fields"
-// @Success 200 {object} models.Category "Category updated successfully"
+// @Param If-Match header string false "ETag of the category version being replaced; without it the update is unconditional"
+// @Param category body CategoryRequest true "Category object with updated fields"
+// @Success 200 {object} CategoryResponse "Category updated successfully"
 // @Failure 400 {string} string "Invalid request body or ID"
 // @Failure 404 {string} string "Category not found"
+// @Failure 412 {string} string "Category has been modified"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /categories/{id} [put]
@@ -134,14 +164,53 @@ func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var category models.Category
-	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	category.ID = id
+	category := req.Model(id)
+	category.Version = version
+
+	h.saveCategory(w, r, category)
+}
+
+// PatchCategory godoc
+// @Summary Partially update a category
+// @Description Apply a JSON Merge Patch (RFC 7386) to the category. The patch is applied only if If-Match matches the ETag
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Param If-Match header string true "ETag of the category version being patched"
+// @Param patch body CategoryRequest true "Fields to change"
+// @Success 200 {object} CategoryResponse "Category updated successfully"
+// @Failure 400 {string} string "Invalid patch or ID"
+// @Failure 404 {string} string "Category not found"
+// @Failure 412 {string} string "Category has been modified"
+// @Failure 428 {string} string "If-Match header is required"
+// @Failure 415 {string} string "Unsupported patch format"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /categories/{id} [patch]
+func (h *CategoryHandler) PatchCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
 
-	if err := h.service.UpdateCategory(r.Context(), &category); err != nil {
+	current, err := h.service.GetCategory(r.Context(), id)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "Category not found", http.StatusNotFound)
 			return
@@ -149,8 +218,38 @@ func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if version != 0 && version != current.Version {
+		writePreconditionFailed(w)
+		return
+	}
+
+	var req CategoryRequest
+	if !decodeMergePatch(w, r, NewCategoryRequest(current), &req) {
+		return
+	}
+	category := req.Model(id)
+	category.Version = current.Version
+
+	h.saveCategory(w, r, category)
+}
+
+// saveCategory сохраняет изменённую категорию и отвечает её новой версией.
+func (h *CategoryHandler) saveCategory(w http.ResponseWriter, r *http.Request, category *models.Category) {
+	if err := h.service.UpdateCategory(r.Context(), category); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrVersionConflict) {
+			writePreconditionFailed(w)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	json.NewEncoder(w).Encode(category)
+	setETag(w, category.Version)
+	json.NewEncoder(w).Encode(NewCategoryResponse(category))
 }
 
 // DeleteCategory godoc
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
@@ -22,32 +23,58 @@ func NewCommentHandler(s *service.CommentService) *CommentHandler {
 	return &CommentHandler{service: s}
 }
 
+// RegisterRoutes registers comment routes in the access groups.
+func (h *CommentHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	public.HandleFunc("/comments/{id}", h.GetComment).Methods("GET")
+
+	protected.HandleFunc("/comments", h.CreateComment).Methods("POST")
+
+	admin.HandleFunc("/comments/{id}", h.UpdateComment).Methods("PUT")
+	admin.HandleFunc("/comments/{id}", h.PatchComment).Methods("PATCH")
+	admin.HandleFunc("/comments/{id}", h.DeleteComment).Methods("DELETE")
+}
+
+// Snapshot returns the current comment as the API renders it, for the audit log.
+func (h *CommentHandler) Snapshot(ctx context.Context, id string) (interface{}, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := h.service.GetComment(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	return NewCommentResponse(comment), nil
+}
+
 // CreateComment godoc
 // @Summary Create a new comment
 // @Description Create a new comment with the input payload
 // @Tags comments
 // @Accept json
 // @Produce json
-// @Param comment body models.Comment true "Comment object"
-// @Success 201 {object} models.Comment "Comment created successfully"
+// @Param comment body CommentRequest true "Comment object"
+// @Success 201 {object} CommentResponse "Comment created successfully"
 // @Failure 400 {string} string "Invalid request body"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /comments [post]
 func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
-	var comment models.Comment
-	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	comment := req.Model(0)
 
-	if err := h.service.CreateComment(r.Context(), &comment); err != nil {
+	if err := h.service.CreateComment(r.Context(), comment); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	setETag(w, comment.Version)
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(comment)
+	json.NewEncoder(w).Encode(NewCommentResponse(comment))
 }
 
 // GetComment godoc
@@ -56,7 +83,7 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 // @Tags comments
 // @Produce json
 // @Param id path int true "Comment ID"
-// @Success 200 {object} models.Comment "Comment found"
+// @Success 200 {object} CommentResponse "Comment found"
 // @Failure 400 {string} string "Invalid ID format"
 // @Failure 404 {string} string "Comment not found"
 // @Failure 500 {string} string "Internal server error"
@@ -85,7 +112,8 @@ func (h *CommentHandler) GetComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(comment)
+	setETag(w, comment.Version)
+	json.NewEncoder(w).Encode(NewCommentResponse(comment))
 }
 
 // ListComments godoc
@@ -93,7 +121,7 @@ func (h *CommentHandler) GetComment(w http.ResponseWriter, r *http.Request) {
 // @Description Retrieve a list of all comments
 // @Tags comments
 // @Produce json
-// @Success 200 {array} models.Comment "List of comments"
+// @Success 200 {array} CommentResponse "List of comments"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /comments [get]
@@ -104,7 +132,7 @@ func (h *CommentHandler) ListComments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(comments)
+	json.NewEncoder(w).Encode(NewCommentResponses(comments))
 }
 
 // UpdateComment godoc
@@ -114,10 +142,12 @@ func (h *CommentHandler) ListComments(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Comment ID"
-// @Param comment body models.Comment true "Comment object with updated fields"
-// @Success 200 {object} models.Comment "Comment updated successfully"
+// @Param comment body CommentRequest true "Comment object with updated fields"
+// @Param If-Match header string false "ETag of the comment version being replaced; without it the update is unconditional"
+// @Success 200 {object} CommentResponse "Comment updated successfully"
 // @Failure 400 {string} string "Invalid request body or ID"
 // @Failure 404 {string} string "Comment not found"
+// @Failure 412 {string} string "Comment has been modified"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /comments/{id} [put]
@@ -134,23 +164,93 @@ func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var comment models.Comment
-	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	comment.ID = id
+	comment := req.Model(id)
+	comment.Version = version
+
+	h.saveComment(w, r, comment)
+}
 
-	if err := h.service.UpdateComment(r.Context(), &comment); err != nil {
+// PatchComment godoc
+// @Summary Partially update a comment
+// @Description Apply a JSON Merge Patch (RFC 7386) to the comment. The patch is applied only if If-Match matches the ETag
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Param If-Match header string true "ETag of the comment version being patched"
+// @Param patch body CommentRequest true "Fields to change"
+// @Success 200 {object} CommentResponse "Comment updated successfully"
+// @Failure 400 {string} string "Invalid patch"
+// @Failure 404 {string} string "Comment not found"
+// @Failure 412 {string} string "Comment has been modified"
+// @Failure 415 {string} string "Unsupported patch format"
+// @Failure 428 {string} string "If-Match header is required"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /comments/{id} [patch]
+func (h *CommentHandler) PatchComment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := h.service.GetComment(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if version != 0 && version != current.Version {
+		writePreconditionFailed(w)
+		return
+	}
+
+	var req CommentRequest
+	if !decodeMergePatch(w, r, NewCommentRequest(current), &req) {
+		return
+	}
+	comment := req.Model(id)
+	// Изменение применяется к прочитанной версии: параллельная запись приведёт к 412
+	comment.Version = current.Version
+
+	h.saveComment(w, r, comment)
+}
+
+// saveComment сохраняет изменённый комментарий и отвечает его новой версией.
+func (h *CommentHandler) saveComment(w http.ResponseWriter, r *http.Request, comment *models.Comment) {
+	if err := h.service.UpdateComment(r.Context(), comment); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "Comment not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrVersionConflict) {
+			writePreconditionFailed(w)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(comment)
+	setETag(w, comment.Version)
+	json.NewEncoder(w).Encode(NewCommentResponse(comment))
 }
 
 // DeleteComment godoc
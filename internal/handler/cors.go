@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions — политика CORS.
+//
+// AllowedOrigins содержит точные источники ("https://petelka.shop") и шаблоны поддоменов
+// ("https://*.petelka.shop" — любой поддомен, но не сам petelka.shop).
+type CORSOptions struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration // сколько браузер может кэшировать ответ на preflight
+}
+
+// originPattern — шаблон источника: схема, суффикс хоста и порт.
+type originPattern struct {
+	scheme string
+	suffix string // ".petelka.shop" для "*.petelka.shop"
+	port   string
+}
+
+func (p originPattern) match(scheme, host, port string) bool {
+	return scheme == p.scheme && port == p.port &&
+		strings.HasSuffix(host, p.suffix) && len(host) > len(p.suffix)
+}
+
+// splitOrigin разбирает источник "scheme://host[:port]" на части.
+func splitOrigin(origin string) (scheme, host, port string, ok bool) {
+	scheme, rest, ok := strings.Cut(origin, "://")
+	if !ok || rest == "" || strings.ContainsAny(rest, "/?#@") {
+		return "", "", "", false
+	}
+	host, port = rest, ""
+	if i := strings.LastIndexByte(rest, ':'); i >= 0 && !strings.HasSuffix(rest, "]") {
+		host, port = rest[:i], rest[i+1:]
+	}
+	return strings.ToLower(scheme), strings.ToLower(host), port, host != ""
+}
+
+// CorsMiddleware применяет политику CORS ко всем запросам.
+//
+// Preflight-запросы (OPTIONS с Access-Control-Request-Method) обрабатываются сразу,
+// без передачи в роутер, поэтому middleware нужно ставить снаружи роутера.
+// Для запрещённых источников preflight получает 403, а обычные запросы выполняются
+// без заголовков CORS — браузер сам не отдаст ответ странице.
+func CorsMiddleware(opts CORSOptions) func(http.Handler) http.Handler {
+	exact := make(map[string]bool)
+	var patterns []originPattern
+	anyOrigin := false
+	for _, origin := range opts.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+			continue
+		}
+		scheme, host, port, ok := splitOrigin(origin)
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(host, "*.") {
+			patterns = append(patterns, originPattern{scheme: scheme, suffix: host[1:], port: port})
+			continue
+		}
+		exact[strings.ToLower(origin)] = true
+	}
+
+	allowed := func(origin string) bool {
+		if anyOrigin || exact[strings.ToLower(origin)] {
+			return true
+		}
+		scheme, host, port, ok := splitOrigin(origin)
+		if !ok {
+			return false
+		}
+		for _, p := range patterns {
+			if p.match(scheme, host, port) {
+				return true
+			}
+		}
+		return false
+	}
+
+	methods := strings.Join(opts.AllowedMethods, ", ")
+	headers := strings.Join(opts.AllowedHeaders, ", ")
+	exposed := strings.Join(opts.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Ответ зависит от Origin, даже если источник не разрешён
+			w.Header().Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			ok := allowed(origin)
+
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				if !ok {
+					http.Error(w, "CORS origin not allowed", http.StatusForbidden)
+					return
+				}
+				setAllowOrigin(w, origin, opts.AllowCredentials)
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				if opts.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", maxAge)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if ok {
+				setAllowOrigin(w, origin, opts.AllowCredentials)
+				if exposed != "" {
+					w.Header().Set("Access-Control-Expose-Headers", exposed)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setAllowOrigin(w http.ResponseWriter, origin string, credentials bool) {
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// DeliveryHandler handles delivery pricing rules.
+type DeliveryHandler struct {
+	service *service.DeliveryService
+}
+
+// NewDeliveryHandler creates a new DeliveryHandler instance.
+func NewDeliveryHandler(s *service.DeliveryService) *DeliveryHandler {
+	return &DeliveryHandler{service: s}
+}
+
+// RegisterRoutes registers delivery routes in the access groups.
+func (h *DeliveryHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	public.HandleFunc("/delivery-rates", h.ListRates).Methods("GET")
+
+	admin.HandleFunc("/delivery-rates", h.CreateRate).Methods("POST")
+	admin.HandleFunc("/delivery-rates/{id}", h.UpdateRate).Methods("PUT")
+	admin.HandleFunc("/delivery-rates/{id}", h.PatchRate).Methods("PATCH")
+	admin.HandleFunc("/delivery-rates/{id}", h.DeleteRate).Methods("DELETE")
+}
+
+// Snapshot returns the current delivery rate as the API renders it, for the audit log.
+func (h *DeliveryHandler) Snapshot(ctx context.Context, id string) (interface{}, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := h.service.GetRate(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	return NewDeliveryRateResponse(rate), nil
+}
+
+// ListRates godoc
+// @Summary List delivery rates
+// @Description List the pricing rules of delivery methods (pickup, courier, post). For an order the rule of its region wins over a rule without region, and among those the one with the smallest max_weight that fits the order weight applies
+// @Tags delivery
+// @Produce json
+// @Success 200 {array} DeliveryRateResponse "Delivery rates"
+// @Failure 500 {string} string "Internal server error"
+// @Router /delivery-rates [get]
+func (h *DeliveryHandler) ListRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.service.ListRates(r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewDeliveryRateResponses(rates))
+}
+
+// CreateRate godoc
+// @Summary Create a delivery rate
+// @Tags delivery
+// @Accept json
+// @Produce json
+// @Param rate body DeliveryRateRequest true "Delivery rate"
+// @Success 201 {object} DeliveryRateResponse "Delivery rate created"
+// @Failure 400 {string} string "Invalid delivery rate"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /delivery-rates [post]
+func (h *DeliveryHandler) CreateRate(w http.ResponseWriter, r *http.Request) {
+	var req DeliveryRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rate := req.Model(0)
+
+	if err := h.service.CreateRate(r.Context(), rate); err != nil {
+		writeDeliveryRateError(w, err)
+		return
+	}
+
+	setETag(w, rate.Version)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(NewDeliveryRateResponse(rate))
+}
+
+// UpdateRate godoc
+// @Summary Update a delivery rate
+// @Description Replace a delivery rate. If If-Match is sent, the rate is updated only if it matches its ETag
+// @Tags delivery
+// @Accept json
+// @Produce json
+// @Param id path int true "Delivery rate ID"
+// @Param If-Match header string false "ETag of the delivery rate version being replaced; without it the update is unconditional"
+// @Param rate body DeliveryRateRequest true "Delivery rate"
+// @Success 200 {object} DeliveryRateResponse "Delivery rate updated"
+// @Failure 400 {string} string "Invalid delivery rate or ID"
+// @Failure 404 {string} string "Delivery rate not found"
+// @Failure 412 {string} string "Delivery rate has been modified"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /delivery-rates/{id} [put]
+func (h *DeliveryHandler) UpdateRate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+	var req DeliveryRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rate := req.Model(id)
+	rate.Version = version
+
+	h.saveRate(w, r, rate)
+}
+
+// PatchRate godoc
+// @Summary Partially update a delivery rate
+// @Description Apply a JSON Merge Patch (RFC 7386) to the delivery rate; null clears region or max_weight. The patch is applied only if If-Match matches the ETag
+// @Tags delivery
+// @Accept json
+// @Produce json
+// @Param id path int true "Delivery rate ID"
+// @Param If-Match header string true "ETag of the delivery rate version being patched"
+// @Param patch body DeliveryRateRequest true "Fields to change"
+// @Success 200 {object} DeliveryRateResponse "Delivery rate updated"
+// @Failure 400 {string} string "Invalid patch or ID"
+// @Failure 404 {string} string "Delivery rate not found"
+// @Failure 412 {string} string "Delivery rate has been modified"
+// @Failure 415 {string} string "Unsupported patch format"
+// @Failure 428 {string} string "If-Match header is required"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /delivery-rates/{id} [patch]
+func (h *DeliveryHandler) PatchRate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := h.service.GetRate(r.Context(), id)
+	if err != nil {
+		writeDeliveryRateError(w, err)
+		return
+	}
+	if version != 0 && version != current.Version {
+		writePreconditionFailed(w)
+		return
+	}
+	var req DeliveryRateRequest
+	if !decodeMergePatch(w, r, NewDeliveryRateRequest(current), &req) {
+		return
+	}
+	rate := req.Model(id)
+	// Изменение применяется к прочитанной версии: параллельная запись приведёт к 412
+	rate.Version = current.Version
+
+	h.saveRate(w, r, rate)
+}
+
+// saveRate сохраняет изменённое правило и отвечает им и его новой версией.
+func (h *DeliveryHandler) saveRate(w http.ResponseWriter, r *http.Request, rate *models.DeliveryRate) {
+	if err := h.service.UpdateRate(r.Context(), rate); err != nil {
+		writeDeliveryRateError(w, err)
+		return
+	}
+
+	setETag(w, rate.Version)
+	json.NewEncoder(w).Encode(NewDeliveryRateResponse(rate))
+}
+
+// DeleteRate godoc
+// @Summary Delete a delivery rate
+// @Tags delivery
+// @Param id path int true "Delivery rate ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid ID format"
+// @Failure 404 {string} string "Delivery rate not found"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /delivery-rates/{id} [delete]
+func (h *DeliveryHandler) DeleteRate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeleteRate(r.Context(), id); err != nil {
+		writeDeliveryRateError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeDeliveryRateError отвечает кодом, соответствующим ошибке изменения правил доставки.
+func writeDeliveryRateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Delivery rate not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrVersionConflict):
+		writePreconditionFailed(w)
+	case errors.Is(err, service.ErrInvalidDeliveryRate):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
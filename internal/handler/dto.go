@@ -0,0 +1,732 @@
+package handler
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+)
+
+// Типы запросов и ответов API и их преобразование в модели.
+//
+// Модели из пакета models описывают хранение данных и могут содержать служебные поля
+// (например, хеш пароля), поэтому хендлеры не кодируют их в ответ напрямую.
+
+// mapSlice преобразует каждый элемент items; для nil возвращает пустой срез,
+// чтобы в JSON попадал [] вместо null.
+func mapSlice[T, R any](items []T, f func(T) R) []R {
+	out := make([]R, 0, len(items))
+	for _, item := range items {
+		out = append(out, f(item))
+	}
+	return out
+}
+
+// nonNilInts возвращает пустой список вместо nil, чтобы в JSON был [] вместо null.
+func nonNilInts(ids []int) []int {
+	if ids == nil {
+		return []int{}
+	}
+	return ids
+}
+
+// currencyCode возвращает код валюты сумм; пустой код (записи из кэша до появления
+// столбца currency) — валюта по умолчанию.
+func currencyCode(currency string) string {
+	if currency == "" {
+		return money.DefaultCurrency
+	}
+	return currency
+}
+
+// UserResponse — пользователь в ответах API. Хеш пароля в него не попадает.
+type UserResponse struct {
+	ID        int        `json:"id"`
+	Email     string     `json:"email"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	Phone     string     `json:"phone,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Version   int        `json:"version"`
+	Status    string     `json:"status"` // active, blocked или deleted
+	BlockedAt *time.Time `json:"blocked_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// NewUserResponse преобразует модель пользователя в ответ API.
+func NewUserResponse(u *models.User) UserResponse {
+	return UserResponse{
+		ID:        u.ID,
+		Email:     u.Email,
+		Name:      u.Name,
+		Role:      u.Role,
+		Phone:     u.Phone,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		Version:   u.Version,
+		Status:    u.Status(),
+		BlockedAt: u.BlockedAt,
+		DeletedAt: u.DeletedAt,
+	}
+}
+
+// NewUserResponses преобразует список пользователей.
+func NewUserResponses(users []*models.User) []UserResponse {
+	return mapSlice(users, NewUserResponse)
+}
+
+// UserSearchResponse — страница результатов поиска пользователей.
+type UserSearchResponse struct {
+	Users      []UserResponse `json:"users"`
+	TotalCount int            `json:"total_count"`
+	Page       int            `json:"page"`
+	Limit      int            `json:"limit"`
+}
+
+// RegisterRequest — регистрация покупателя. Роль при регистрации не выбирается.
+type RegisterRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// Model возвращает нового пользователя с ролью "user".
+func (r RegisterRequest) Model() *models.User {
+	return &models.User{Email: r.Email, Name: r.Name, Password: r.Password, Role: "user"}
+}
+
+// CreateUserRequest — создание пользователя администратором.
+type CreateUserRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	Phone    string `json:"phone,omitempty"`
+}
+
+// Model возвращает модель нового пользователя.
+func (r CreateUserRequest) Model() *models.User {
+	return &models.User{Email: r.Email, Name: r.Name, Password: r.Password, Role: r.Role, Phone: r.Phone}
+}
+
+// UpdateUserRequest — изменение пользователя администратором.
+// Пустой Password оставляет пароль без изменений.
+type UpdateUserRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Phone    string `json:"phone,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// NewUpdateUserRequest возвращает текущие данные пользователя как основу для PATCH.
+func NewUpdateUserRequest(u *models.User) UpdateUserRequest {
+	return UpdateUserRequest{Email: u.Email, Name: u.Name, Role: u.Role, Phone: u.Phone}
+}
+
+// Model возвращает модель пользователя с идентификатором id.
+func (r UpdateUserRequest) Model(id int) *models.User {
+	return &models.User{ID: id, Email: r.Email, Name: r.Name, Role: r.Role, Phone: r.Phone, Password: r.Password}
+}
+
+// ProductRequest — создание и изменение товара.
+type ProductRequest struct {
+	Name            string      `json:"name"`
+	Description     string      `json:"description"`
+	Price           money.Money `json:"price" swaggertype:"string" example:"350.50"`
+	Images          []string    `json:"images"`
+	CategoryID      int         `json:"category_id"`
+	Type            string      `json:"type"`
+	Composition     string      `json:"composition,omitempty"`
+	CountryOfOrigin string      `json:"country_of_origin,omitempty"`
+	LengthIn100g    int         `json:"length_in_100g,omitempty"`
+	Size            string      `json:"size,omitempty"`
+	GarmentLength   string      `json:"garment_length,omitempty"`
+	Color           string      `json:"color,omitempty"`
+	Weight          int         `json:"weight,omitempty"` // граммы
+}
+
+// NewProductRequest возвращает изменяемые поля товара как основу для PATCH.
+func NewProductRequest(p *models.Product) ProductRequest {
+	return ProductRequest{
+		Name:            p.Name,
+		Description:     p.Description,
+		Price:           p.Price,
+		Images:          p.Images,
+		CategoryID:      p.CategoryID,
+		Type:            p.Type,
+		Composition:     p.Composition,
+		CountryOfOrigin: p.CountryOfOrigin,
+		LengthIn100g:    p.LengthIn100g,
+		Size:            p.Size,
+		GarmentLength:   p.GarmentLength,
+		Color:           p.Color,
+		Weight:          p.Weight,
+	}
+}
+
+// Model возвращает модель товара с идентификатором id (0 для нового товара).
+func (r ProductRequest) Model(id int) *models.Product {
+	return &models.Product{
+		ID:              id,
+		Name:            r.Name,
+		Description:     r.Description,
+		Price:           r.Price,
+		Images:          r.Images,
+		CategoryID:      r.CategoryID,
+		Type:            r.Type,
+		Composition:     r.Composition,
+		CountryOfOrigin: r.CountryOfOrigin,
+		LengthIn100g:    r.LengthIn100g,
+		Size:            r.Size,
+		GarmentLength:   r.GarmentLength,
+		Color:           r.Color,
+		Weight:          r.Weight,
+	}
+}
+
+// ProductResponse — товар в ответах API.
+type ProductResponse struct {
+	ID              int         `json:"id"`
+	Name            string      `json:"name"`
+	Description     string      `json:"description"`
+	Price           money.Money `json:"price" swaggertype:"string" example:"350.50"`
+	Currency        string      `json:"currency" example:"RUB"`
+	Images          []string    `json:"images"`
+	CategoryID      int         `json:"category_id"`
+	Type            string      `json:"type"`
+	Composition     string      `json:"composition,omitempty"`
+	CountryOfOrigin string      `json:"country_of_origin,omitempty"`
+	LengthIn100g    int         `json:"length_in_100g,omitempty"`
+	Size            string      `json:"size,omitempty"`
+	GarmentLength   string      `json:"garment_length,omitempty"`
+	Color           string      `json:"color,omitempty"`
+	Weight          int         `json:"weight,omitempty"` // граммы
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+	Version         int         `json:"version"`
+}
+
+// NewProductResponse преобразует модель товара в ответ API.
+func NewProductResponse(p *models.Product) ProductResponse {
+	images := p.Images
+	if images == nil {
+		images = []string{}
+	}
+	return ProductResponse{
+		ID:              p.ID,
+		Name:            p.Name,
+		Description:     p.Description,
+		Price:           p.Price,
+		Currency:        currencyCode(p.Currency),
+		Images:          images,
+		CategoryID:      p.CategoryID,
+		Type:            p.Type,
+		Composition:     p.Composition,
+		CountryOfOrigin: p.CountryOfOrigin,
+		LengthIn100g:    p.LengthIn100g,
+		Size:            p.Size,
+		GarmentLength:   p.GarmentLength,
+		Color:           p.Color,
+		Weight:          p.Weight,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+		Version:         p.Version,
+	}
+}
+
+// NewProductResponses преобразует список товаров.
+func NewProductResponses(products []*models.Product) []ProductResponse {
+	return mapSlice(products, NewProductResponse)
+}
+
+// ProductSearchResponse — страница результатов поиска товаров.
+type ProductSearchResponse struct {
+	Products   []ProductResponse `json:"products"`
+	TotalCount int               `json:"total_count"`
+	Page       int               `json:"page"`
+	Limit      int               `json:"limit"`
+}
+
+// CategoryRequest — создание и изменение категории.
+type CategoryRequest struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// NewCategoryRequest возвращает изменяемые поля категории как основу для PATCH.
+func NewCategoryRequest(c *models.Category) CategoryRequest {
+	return CategoryRequest{Name: c.Name, Type: c.Type}
+}
+
+// Model возвращает модель категории с идентификатором id (0 для новой категории).
+func (r CategoryRequest) Model(id int) *models.Category {
+	return &models.Category{ID: id, Name: r.Name, Type: r.Type}
+}
+
+// CategoryResponse — категория в ответах API.
+type CategoryResponse struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
+}
+
+// NewCategoryResponse преобразует модель категории в ответ API.
+func NewCategoryResponse(c *models.Category) CategoryResponse {
+	return CategoryResponse{
+		ID: c.ID, Name: c.Name, Type: c.Type,
+		CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, Version: c.Version,
+	}
+}
+
+// NewCategoryResponses преобразует список категорий.
+func NewCategoryResponses(categories []*models.Category) []CategoryResponse {
+	return mapSlice(categories, NewCategoryResponse)
+}
+
+// OrderRequest — изменение заказа администратором. Сумма, позиции и доставка
+// рассчитываются при оформлении и здесь не меняются.
+type OrderRequest struct {
+	Status string `json:"status"`
+}
+
+// NewOrderRequest возвращает изменяемые поля заказа как основу для PATCH.
+func NewOrderRequest(o *models.Order) OrderRequest {
+	return OrderRequest{Status: o.Status}
+}
+
+// Apply возвращает копию заказа o с полями из запроса.
+func (r OrderRequest) Apply(o *models.Order) *models.Order {
+	order := *o
+	order.Status = r.Status
+	return &order
+}
+
+// OrderItemRequest — товар и его количество в оформляемом заказе.
+type OrderItemRequest struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// CreateOrderRequest — оформление заказа текущим пользователем. Цены, стоимость
+// доставки и скидка рассчитываются сервером; address_id не нужен для самовывоза.
+type CreateOrderRequest struct {
+	Items          []OrderItemRequest `json:"items"`
+	DeliveryMethod string             `json:"delivery_method" enums:"pickup,courier,post"`
+	AddressID      int                `json:"address_id,omitempty"`
+	PromoCode      string             `json:"promo_code,omitempty"`
+}
+
+// Checkout возвращает запрос на оформление заказа для сервиса.
+func (r CreateOrderRequest) Checkout() service.CheckoutRequest {
+	items := make([]service.CheckoutItem, 0, len(r.Items))
+	for _, item := range r.Items {
+		items = append(items, service.CheckoutItem{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	return service.CheckoutRequest{Items: items, DeliveryMethod: r.DeliveryMethod, AddressID: r.AddressID, PromoCode: r.PromoCode}
+}
+
+// OrderItemResponse — позиция заказа с ценой на момент оформления, её долей скидки
+// и НДС, включённым в сумму позиции после скидки.
+type OrderItemResponse struct {
+	ProductID int         `json:"product_id"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `json:"price" swaggertype:"string" example:"350.50"`
+	Discount  money.Money `json:"discount" swaggertype:"string" example:"35.05"`
+	VAT       money.Money `json:"vat" swaggertype:"string" example:"52.58"`
+}
+
+// OrderResponse — заказ в ответах API. Total включает стоимость доставки за вычетом
+// скидки; VAT — включённый в итог НДС по ставке VATRate (сумма НДС позиций и доставки).
+// Позиции возвращаются только при оформлении заказа.
+type OrderResponse struct {
+	ID              int                    `json:"id"`
+	UserID          int                    `json:"user_id"`
+	Total           money.Money            `json:"total" swaggertype:"string" example:"350.50"`
+	Currency        string                 `json:"currency" example:"RUB"`
+	Status          string                 `json:"status"`
+	DeliveryMethod  string                 `json:"delivery_method"`
+	DeliveryCost    money.Money            `json:"delivery_cost" swaggertype:"string" example:"350.50"`
+	PromoCode       string                 `json:"promo_code,omitempty"`
+	Discount        money.Money            `json:"discount" swaggertype:"string" example:"350.50"`
+	VATRate         int                    `json:"vat_rate" example:"20"`
+	VAT             money.Money            `json:"vat" swaggertype:"string" example:"58.42"`
+	ShippingAddress *ShippingAddressFields `json:"shipping_address,omitempty"`
+	Items           []OrderItemResponse    `json:"items,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+	Version         int                    `json:"version"`
+}
+
+// NewOrderResponse преобразует модель заказа в ответ API.
+func NewOrderResponse(o *models.Order) OrderResponse {
+	resp := OrderResponse{
+		ID: o.ID, UserID: o.UserID, Total: o.Total, Currency: currencyCode(o.Currency), Status: o.Status,
+		DeliveryMethod: o.DeliveryMethod, DeliveryCost: o.DeliveryCost, PromoCode: o.PromoCode, Discount: o.Discount,
+		VATRate: o.VATRate, VAT: o.VAT, CreatedAt: o.CreatedAt, UpdatedAt: o.UpdatedAt, Version: o.Version,
+	}
+	if o.ShippingAddress != nil {
+		address := NewShippingAddressFields(*o.ShippingAddress)
+		resp.ShippingAddress = &address
+	}
+	for _, item := range o.Items {
+		resp.Items = append(resp.Items, OrderItemResponse{ProductID: item.ProductID, Quantity: item.Quantity, Price: item.Price, Discount: item.Discount, VAT: item.VAT})
+	}
+	return resp
+}
+
+// NewOrderResponses преобразует список заказов.
+func NewOrderResponses(orders []*models.Order) []OrderResponse {
+	return mapSlice(orders, NewOrderResponse)
+}
+
+// ShippingAddressFields — поля адреса доставки.
+type ShippingAddressFields struct {
+	Recipient  string `json:"recipient"`
+	Phone      string `json:"phone"`
+	Region     string `json:"region"`
+	City       string `json:"city"`
+	Street     string `json:"street"` // улица, дом, квартира
+	PostalCode string `json:"postal_code,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+}
+
+// NewShippingAddressFields преобразует адрес доставки в поля ответа API.
+func NewShippingAddressFields(a models.ShippingAddress) ShippingAddressFields {
+	return ShippingAddressFields(a)
+}
+
+// Model возвращает адрес доставки.
+func (f ShippingAddressFields) Model() models.ShippingAddress {
+	return models.ShippingAddress(f)
+}
+
+// AddressRequest — создание и изменение адреса в адресной книге.
+type AddressRequest struct {
+	ShippingAddressFields
+	IsDefault bool `json:"is_default"`
+}
+
+// NewAddressRequest возвращает поля адреса как основу для PATCH.
+func NewAddressRequest(a *models.Address) AddressRequest {
+	return AddressRequest{ShippingAddressFields: NewShippingAddressFields(a.ShippingAddress), IsDefault: a.IsDefault}
+}
+
+// Model возвращает адрес пользователя userID с идентификатором id (0 для нового адреса).
+func (r AddressRequest) Model(userID, id int) *models.Address {
+	return &models.Address{ID: id, UserID: userID, ShippingAddress: r.ShippingAddressFields.Model(), IsDefault: r.IsDefault}
+}
+
+// AddressResponse — адрес из адресной книги в ответах API.
+type AddressResponse struct {
+	ID int `json:"id"`
+	ShippingAddressFields
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
+}
+
+// NewAddressResponse преобразует адрес в ответ API.
+func NewAddressResponse(a *models.Address) AddressResponse {
+	return AddressResponse{
+		ID:                    a.ID,
+		ShippingAddressFields: NewShippingAddressFields(a.ShippingAddress),
+		IsDefault:             a.IsDefault,
+		CreatedAt:             a.CreatedAt,
+		UpdatedAt:             a.UpdatedAt,
+		Version:               a.Version,
+	}
+}
+
+// NewAddressResponses преобразует список адресов.
+func NewAddressResponses(addresses []*models.Address) []AddressResponse {
+	return mapSlice(addresses, NewAddressResponse)
+}
+
+// DeliveryRateRequest — создание и изменение правила стоимости доставки.
+type DeliveryRateRequest struct {
+	Method    string      `json:"method" enums:"pickup,courier,post"`
+	Region    string      `json:"region,omitempty"`     // пусто — любой регион
+	MaxWeight *int        `json:"max_weight,omitempty"` // граммы; не задан — без ограничения
+	Price     money.Money `json:"price" swaggertype:"string" example:"350.50"`
+}
+
+// NewDeliveryRateRequest возвращает изменяемые поля правила доставки как основу для PATCH.
+func NewDeliveryRateRequest(r *models.DeliveryRate) DeliveryRateRequest {
+	return DeliveryRateRequest{Method: r.Method, Region: r.Region, MaxWeight: r.MaxWeight, Price: r.Price}
+}
+
+// Model возвращает правило с идентификатором id (0 для нового правила).
+func (r DeliveryRateRequest) Model(id int) *models.DeliveryRate {
+	return &models.DeliveryRate{ID: id, Method: r.Method, Region: r.Region, MaxWeight: r.MaxWeight, Price: r.Price}
+}
+
+// DeliveryRateResponse — правило стоимости доставки в ответах API.
+type DeliveryRateResponse struct {
+	ID        int         `json:"id"`
+	Method    string      `json:"method"`
+	Region    string      `json:"region,omitempty"`
+	MaxWeight *int        `json:"max_weight,omitempty"`
+	Price     money.Money `json:"price" swaggertype:"string" example:"350.50"`
+	Currency  string      `json:"currency" example:"RUB"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Version   int         `json:"version"`
+}
+
+// NewDeliveryRateResponse преобразует правило в ответ API.
+func NewDeliveryRateResponse(r *models.DeliveryRate) DeliveryRateResponse {
+	return DeliveryRateResponse{
+		ID: r.ID, Method: r.Method, Region: r.Region, MaxWeight: r.MaxWeight, Price: r.Price,
+		Currency: currencyCode(r.Currency), CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt, Version: r.Version,
+	}
+}
+
+// NewDeliveryRateResponses преобразует список правил.
+func NewDeliveryRateResponses(rates []*models.DeliveryRate) []DeliveryRateResponse {
+	return mapSlice(rates, NewDeliveryRateResponse)
+}
+
+// PromoCodeRequest — создание и изменение промокода. Код приводится к верхнему регистру;
+// пустые product_ids и category_ids — скидка на все товары, is_active по умолчанию true.
+type PromoCodeRequest struct {
+	Code           string      `json:"code"`
+	Kind           string      `json:"kind" enums:"percent,fixed,free_shipping"`
+	PercentBP      int64       `json:"percent_bp,omitempty" example:"1250"`          // для вида percent, в сотых процента
+	Amount         money.Money `json:"amount" swaggertype:"string" example:"300.00"` // для вида fixed
+	StartsAt       *time.Time  `json:"starts_at,omitempty"`
+	EndsAt         *time.Time  `json:"ends_at,omitempty"`
+	MaxUses        *int        `json:"max_uses,omitempty"`
+	MaxUsesPerUser *int        `json:"max_uses_per_user,omitempty"`
+	MinOrderTotal  money.Money `json:"min_order_total" swaggertype:"string" example:"350.50"`
+	ProductIDs     []int       `json:"product_ids"`
+	CategoryIDs    []int       `json:"category_ids"`
+	IsActive       *bool       `json:"is_active,omitempty"`
+}
+
+// NewPromoCodeRequest возвращает изменяемые поля промокода как основу для PATCH.
+func NewPromoCodeRequest(p *models.PromoCode) PromoCodeRequest {
+	isActive := p.IsActive
+	return PromoCodeRequest{
+		Code: p.Code, Kind: p.Kind, PercentBP: p.PercentBP, Amount: p.Amount, StartsAt: p.StartsAt, EndsAt: p.EndsAt,
+		MaxUses: p.MaxUses, MaxUsesPerUser: p.MaxUsesPerUser, MinOrderTotal: p.MinOrderTotal,
+		ProductIDs: nonNilInts(p.ProductIDs), CategoryIDs: nonNilInts(p.CategoryIDs), IsActive: &isActive,
+	}
+}
+
+// Model возвращает промокод с идентификатором id (0 для нового промокода).
+func (r PromoCodeRequest) Model(id int) *models.PromoCode {
+	p := &models.PromoCode{
+		ID: id, Code: r.Code, Kind: r.Kind, PercentBP: r.PercentBP, Amount: r.Amount, StartsAt: r.StartsAt, EndsAt: r.EndsAt,
+		MaxUses: r.MaxUses, MaxUsesPerUser: r.MaxUsesPerUser, MinOrderTotal: r.MinOrderTotal,
+		ProductIDs: r.ProductIDs, CategoryIDs: r.CategoryIDs, IsActive: true,
+	}
+	if r.IsActive != nil {
+		p.IsActive = *r.IsActive
+	}
+	return p
+}
+
+// PromoCodeResponse — промокод в ответах API.
+type PromoCodeResponse struct {
+	ID             int         `json:"id"`
+	Code           string      `json:"code"`
+	Kind           string      `json:"kind"`
+	PercentBP      int64       `json:"percent_bp,omitempty"`
+	Amount         money.Money `json:"amount" swaggertype:"string" example:"300.00"`
+	StartsAt       *time.Time  `json:"starts_at,omitempty"`
+	EndsAt         *time.Time  `json:"ends_at,omitempty"`
+	MaxUses        *int        `json:"max_uses,omitempty"`
+	MaxUsesPerUser *int        `json:"max_uses_per_user,omitempty"`
+	MinOrderTotal  money.Money `json:"min_order_total" swaggertype:"string" example:"350.50"`
+	Currency       string      `json:"currency" example:"RUB"`
+	ProductIDs     []int       `json:"product_ids"`
+	CategoryIDs    []int       `json:"category_ids"`
+	IsActive       bool        `json:"is_active"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+	Version        int         `json:"version"`
+}
+
+// NewPromoCodeResponse преобразует промокод в ответ API.
+func NewPromoCodeResponse(p *models.PromoCode) PromoCodeResponse {
+	return PromoCodeResponse{
+		ID: p.ID, Code: p.Code, Kind: p.Kind, PercentBP: p.PercentBP, Amount: p.Amount, StartsAt: p.StartsAt, EndsAt: p.EndsAt,
+		MaxUses: p.MaxUses, MaxUsesPerUser: p.MaxUsesPerUser, MinOrderTotal: p.MinOrderTotal,
+		Currency: currencyCode(p.Currency), ProductIDs: nonNilInts(p.ProductIDs), CategoryIDs: nonNilInts(p.CategoryIDs), IsActive: p.IsActive,
+		CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt, Version: p.Version,
+	}
+}
+
+// NewPromoCodeResponses преобразует список промокодов.
+func NewPromoCodeResponses(codes []*models.PromoCode) []PromoCodeResponse {
+	return mapSlice(codes, NewPromoCodeResponse)
+}
+
+// PromoCodeStatsResponse — статистика использования промокода.
+type PromoCodeStatsResponse struct {
+	PromoCodeID   int         `json:"promo_code_id"`
+	Uses          int         `json:"uses"`                                                 // оформленных заказов с промокодом
+	Users         int         `json:"users"`                                                // разных покупателей
+	DiscountTotal money.Money `json:"discount_total" swaggertype:"string" example:"350.50"` // сумма скидок
+	OrdersTotal   money.Money `json:"orders_total" swaggertype:"string" example:"350.50"`   // сумма заказов после скидки
+	FirstUsedAt   *time.Time  `json:"first_used_at,omitempty"`
+	LastUsedAt    *time.Time  `json:"last_used_at,omitempty"`
+}
+
+// NewPromoCodeStatsResponse преобразует статистику промокода в ответ API.
+func NewPromoCodeStatsResponse(s *models.PromoCodeStats) PromoCodeStatsResponse {
+	return PromoCodeStatsResponse(*s)
+}
+
+// CommentRequest — создание и изменение комментария.
+type CommentRequest struct {
+	ProductID int    `json:"product_id"`
+	UserID    int    `json:"user_id"`
+	Text      string `json:"text"`
+}
+
+// NewCommentRequest возвращает изменяемые поля комментария как основу для PATCH.
+func NewCommentRequest(c *models.Comment) CommentRequest {
+	return CommentRequest{ProductID: c.ProductID, UserID: c.UserID, Text: c.Text}
+}
+
+// Model возвращает модель комментария с идентификатором id (0 для нового комментария).
+func (r CommentRequest) Model(id int) *models.Comment {
+	return &models.Comment{ID: id, ProductID: r.ProductID, UserID: r.UserID, Text: r.Text}
+}
+
+// CommentResponse — комментарий в ответах API.
+type CommentResponse struct {
+	ID        int       `json:"id"`
+	ProductID int       `json:"product_id"`
+	UserID    int       `json:"user_id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
+}
+
+// NewCommentResponse преобразует модель комментария в ответ API.
+func NewCommentResponse(c *models.Comment) CommentResponse {
+	return CommentResponse{
+		ID: c.ID, ProductID: c.ProductID, UserID: c.UserID, Text: c.Text,
+		CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, Version: c.Version,
+	}
+}
+
+// NewCommentResponses преобразует список комментариев.
+func NewCommentResponses(comments []*models.Comment) []CommentResponse {
+	return mapSlice(comments, NewCommentResponse)
+}
+
+// PhotoResponse — фотография в ответах API.
+type PhotoResponse struct {
+	ObjectName  string    `json:"object_name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	UploadedBy  int       `json:"uploaded_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ProductIDs  []int     `json:"product_ids"`
+}
+
+// NewPhotoResponse преобразует модель фотографии в ответ API.
+func NewPhotoResponse(p *models.Photo) PhotoResponse {
+	productIDs := p.ProductIDs
+	if productIDs == nil {
+		productIDs = []int{}
+	}
+	return PhotoResponse{
+		ObjectName:  p.ObjectName,
+		Size:        p.Size,
+		ContentType: p.ContentType,
+		UploadedBy:  p.UploadedBy,
+		CreatedAt:   p.CreatedAt,
+		ProductIDs:  productIDs,
+	}
+}
+
+// PhotoListResponse — страница списка фотографий.
+type PhotoListResponse struct {
+	Photos    []PhotoResponse `json:"photos"`
+	NextAfter string          `json:"next_after"`
+}
+
+// PhotoUploadResponse — результат загрузки фотографии через API.
+type PhotoUploadResponse struct {
+	ObjectName string `json:"objectName"`
+	URL        string `json:"url"`
+}
+
+// AuditEntryResponse — запись журнала аудита в ответах API.
+type AuditEntryResponse struct {
+	ID           int64           `json:"id"`
+	ActorID      int             `json:"actor_id,omitempty"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id,omitempty"`
+	Changes      json.RawMessage `json:"changes" swaggertype:"object"`
+	IP           string          `json:"ip"`
+	RequestID    string          `json:"request_id,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// NewAuditEntryResponse преобразует запись журнала аудита в ответ API.
+func NewAuditEntryResponse(e *models.AuditEntry) AuditEntryResponse {
+	changes := e.Changes
+	if len(changes) == 0 {
+		changes = json.RawMessage("{}")
+	}
+	return AuditEntryResponse{
+		ID:           e.ID,
+		ActorID:      e.ActorID,
+		Action:       e.Action,
+		ResourceType: e.ResourceType,
+		ResourceID:   e.ResourceID,
+		Changes:      changes,
+		IP:           e.IP,
+		RequestID:    e.RequestID,
+		CreatedAt:    e.CreatedAt,
+	}
+}
+
+// AuditLogResponse — страница журнала аудита.
+type AuditLogResponse struct {
+	Entries    []AuditEntryResponse `json:"entries"`
+	TotalCount int                  `json:"total_count"`
+	Page       int                  `json:"page"`
+	Limit      int                  `json:"limit"`
+}
+
+// PaymentResponse — попытка оплаты заказа в ответах API.
+type PaymentResponse struct {
+	ID              int         `json:"id"`
+	OrderID         int         `json:"order_id"`
+	Provider        string      `json:"provider"`
+	Status          string      `json:"status"`
+	Amount          money.Money `json:"amount" swaggertype:"string" example:"350.50"`
+	Currency        string      `json:"currency"`
+	ConfirmationURL string      `json:"confirmation_url,omitempty"` // страница оплаты для покупателя
+	CreatedAt       time.Time   `json:"created_at"`
+}
+
+// NewPaymentResponse преобразует попытку оплаты в ответ API.
+func NewPaymentResponse(p *models.Payment) PaymentResponse {
+	return PaymentResponse{
+		ID:              p.ID,
+		OrderID:         p.OrderID,
+		Provider:        p.Provider,
+		Status:          p.Status,
+		Amount:          p.Amount,
+		Currency:        p.Currency,
+		ConfirmationURL: p.ConfirmationURL,
+		CreatedAt:       p.CreatedAt,
+	}
+}
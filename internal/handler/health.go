@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+)
+
+// HealthCheck is a single dependency check used by the readiness probe.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthHandler serves liveness and readiness probes.
+type HealthHandler struct {
+	checks   []HealthCheck
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// NewHealthHandler creates a new HealthHandler. Each check is given timeout to complete.
+func NewHealthHandler(timeout time.Duration, checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{checks: checks, timeout: timeout}
+}
+
+// SetDraining makes the readiness probe fail so that the load balancer stops
+// sending new requests while in-flight ones are being drained.
+func (h *HealthHandler) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Liveness godoc
+// @Summary Liveness probe
+// @Description Returns 200 while the process is able to serve HTTP
+// @Tags health
+// @Produce plain
+// @Success 200 {string} string "ok"
+// @Router /healthz [get]
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok"))
+}
+
+// Readiness godoc
+// @Summary Readiness probe
+// @Description Checks PostgreSQL, Redis and object storage. Returns 503 if any check fails or the server is shutting down.
+// @Description Each check reports "ok" or "fail"; error details are only logged
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]interface{} "All dependencies are available"
+// @Failure 503 {object} map[string]interface{} "Some dependency is unavailable"
+// @Router /readyz [get]
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	results := make(map[string]string, len(h.checks))
+	ready := !h.draining.Load()
+
+	if ready {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, c := range h.checks {
+			wg.Add(1)
+			go func(c HealthCheck) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+				defer cancel()
+
+				// Текст ошибки может содержать адреса и сообщения драйверов,
+				// поэтому наружу отдаётся только статус, а подробности — в лог.
+				status := "ok"
+				if err := c.Check(ctx); err != nil {
+					logger.FromContext(ctx).Errorf("Readiness check %s failed: %v", c.Name, err)
+					status = "fail"
+				}
+				mu.Lock()
+				results[c.Name] = status
+				mu.Unlock()
+			}(c)
+		}
+		wg.Wait()
+
+		for _, status := range results {
+			if status != "ok" {
+				ready = false
+			}
+		}
+	}
+
+	resp := map[string]interface{}{
+		"status": "ok",
+		"checks": results,
+	}
+	code := http.StatusOK
+	if !ready {
+		resp["status"] = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+	if h.draining.Load() {
+		resp["status"] = "shutting down"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/alex-pyslar/petelka-api/internal/idempotency"
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
+	"github.com/gorilla/mux"
+)
+
+// IdempotencyKeyHeader — заголовок, которым клиент помечает повторы одного запроса.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength ограничивает длину значения Idempotency-Key.
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBody ограничивает размер тела запроса и ответа, которые сохраняются
+// для повторов.
+const maxIdempotentBody = 1 << 20
+
+// IdempotencyMiddleware выполняет POST-запрос с заголовком Idempotency-Key не более
+// одного раза: ответ сохраняется по ключу пользователя (для анонимных запросов — IP),
+// и повтор с тем же ключом и телом получает его с заголовком Idempotent-Replayed.
+// Пока первый запрос выполняется, повтор получает 409, а тот же ключ с другим телом — 422.
+// Ответы 5xx не сохраняются, чтобы клиент мог повторить запрос. Запросы без заголовка
+// и с другими методами проходят без изменений. Если Redis недоступен, возвращается 503:
+// без проверки ключа повтор мог бы выполниться дважды.
+func IdempotencyMiddleware(store *idempotency.Store, trustForwardedFor bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength), http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			if len(body) > maxIdempotentBody {
+				http.Error(w, "Request body is too large for an idempotent request", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			log := logger.FromContext(r.Context())
+			scope := "ip:" + ClientIP(r, trustForwardedFor)
+			if userID, ok := r.Context().Value(UserIDKey).(int); ok {
+				scope = fmt.Sprintf("user:%d", userID)
+			}
+			storeKey := scope + ":" + key
+			fingerprint := requestFingerprint(r, body)
+
+			stored, err := store.Begin(r.Context(), storeKey, fingerprint)
+			switch {
+			case errors.Is(err, idempotency.ErrInProgress):
+				metrics.IdempotencyTotal.WithLabelValues("in_progress").Inc()
+				http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+				return
+			case errors.Is(err, idempotency.ErrKeyReused):
+				metrics.IdempotencyTotal.WithLabelValues("mismatch").Inc()
+				http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+				return
+			case err != nil:
+				metrics.IdempotencyTotal.WithLabelValues("error").Inc()
+				log.Errorf("Idempotency check failed: %v", err)
+				http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
+				return
+			case stored != nil:
+				metrics.IdempotencyTotal.WithLabelValues("replayed").Inc()
+				log.Infof("Replaying response for Idempotency-Key %q", key)
+				for name, values := range stored.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.Status)
+				w.Write(stored.Body)
+				return
+			}
+			metrics.IdempotencyTotal.WithLabelValues("new").Inc()
+
+			// Ответ уже отправлен: отмена запроса клиентом не должна оставлять ключ занятым
+			ctx := context.WithoutCancel(r.Context())
+			saved := false
+			defer func() {
+				if !saved {
+					if err := store.Release(ctx, storeKey); err != nil {
+						log.Warningf("Failed to release Idempotency-Key %q: %v", key, err)
+					}
+				}
+			}()
+
+			before := make(map[string]bool, len(w.Header()))
+			for name := range w.Header() {
+				before[name] = true
+			}
+			rec := &idempotencyWriter{statusRecorder: statusRecorder{ResponseWriter: w}}
+			next.ServeHTTP(rec, r)
+
+			status := rec.statusCode()
+			if status >= http.StatusInternalServerError || rec.truncated {
+				return
+			}
+			// Сохраняются только заголовки обработчика: X-Request-ID, RateLimit-* и CORS
+			// у повтора свои
+			header := http.Header{}
+			for name, values := range w.Header() {
+				if !before[name] {
+					header[name] = values
+				}
+			}
+			resp := &idempotency.Response{Status: status, Header: header, Body: rec.body.Bytes()}
+			if err := store.Complete(ctx, storeKey, fingerprint, resp); err != nil {
+				log.Errorf("Failed to save response for Idempotency-Key %q: %v", key, err)
+				return
+			}
+			saved = true
+		})
+	}
+}
+
+// requestFingerprint — хэш метода, пути и тела запроса: повтор с тем же ключом
+// должен совпадать с первым запросом.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyWriter запоминает код и тело ответа для повторов.
+type idempotencyWriter struct {
+	statusRecorder
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	if !w.truncated {
+		if w.body.Len()+len(b) > maxIdempotentBody {
+			w.truncated = true
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.statusRecorder.Write(b)
+}
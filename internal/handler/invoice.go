@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/alex-pyslar/petelka-api/internal/invoice"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// InvoiceHandler handles PDF documents of orders.
+type InvoiceHandler struct {
+	service *service.InvoiceService
+}
+
+// NewInvoiceHandler creates a new InvoiceHandler instance.
+func NewInvoiceHandler(s *service.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{service: s}
+}
+
+// RegisterRoutes registers invoice routes in the access groups.
+func (h *InvoiceHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	protected.HandleFunc("/orders/{id}/invoice.pdf", h.GetInvoice).Methods("GET")
+}
+
+// GetInvoice godoc
+// @Summary Get an order invoice
+// @Description Download the PDF document of an order: an invoice while the order is unpaid, and a receipt once it is paid. The receipt is generated once and never changes. Available to the order owner and admins
+// @Tags orders
+// @Produce application/pdf
+// @Param id path int true "Order ID"
+// @Success 200 {file} file "PDF document"
+// @Failure 400 {string} string "Invalid ID format"
+// @Failure 404 {string} string "Order not found"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /orders/{id}/invoice.pdf [get]
+func (h *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value(UserIDKey).(int)
+	role, _ := r.Context().Value(UserRoleKey).(string)
+
+	data, err := h.service.OrderInvoice(r.Context(), userID, role == "admin", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", invoice.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="order-%d.pdf"`, id))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Write(data)
+}
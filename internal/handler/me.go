@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// MeHandler handles requests of the current user to their own profile.
+type MeHandler struct {
+	users    *service.UserService
+	profile  *service.ProfileService
+	orders   *service.OrderService
+	comments *service.CommentService
+}
+
+// NewMeHandler creates a new MeHandler instance.
+func NewMeHandler(users *service.UserService, profile *service.ProfileService, orders *service.OrderService, comments *service.CommentService) *MeHandler {
+	return &MeHandler{users: users, profile: profile, orders: orders, comments: comments}
+}
+
+// RegisterRoutes registers current user routes in the access groups.
+func (h *MeHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	public.HandleFunc("/auth/email/confirm", h.ConfirmEmail).Methods("POST")
+
+	protected.HandleFunc("/me", h.GetMe).Methods("GET")
+	protected.HandleFunc("/me", h.UpdateMe).Methods("PATCH")
+	protected.HandleFunc("/me", h.DeleteMe).Methods("DELETE")
+	protected.HandleFunc("/me/password", h.ChangePassword).Methods("POST")
+	protected.HandleFunc("/me/orders", h.ListMyOrders).Methods("GET")
+	protected.HandleFunc("/me/reviews", h.ListMyReviews).Methods("GET")
+}
+
+// UpdateMeRequest is a partial profile update; omitted fields are left unchanged.
+type UpdateMeRequest struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+	Phone *string `json:"phone,omitempty"`
+}
+
+// UpdateMeResponse returns the updated profile and the email awaiting confirmation, if any.
+type UpdateMeResponse struct {
+	UserResponse
+	PendingEmail string `json:"pending_email,omitempty"`
+}
+
+// ChangePasswordRequest is the body of a password change.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// DeleteMeRequest confirms account deletion with the current password.
+type DeleteMeRequest struct {
+	Password string `json:"password"`
+}
+
+// ConfirmEmailRequest carries the token from the confirmation email.
+type ConfirmEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// GetMe godoc
+// @Summary Get current user profile
+// @Tags me
+// @Produce json
+// @Success 200 {object} UserResponse "Current user"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "User not found"
+// @Security ApiKeyAuth
+// @Router /me [get]
+func (h *MeHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	user, err := h.users.GetUser(r.Context(), userID)
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+
+	setETag(w, user.Version)
+	json.NewEncoder(w).Encode(NewUserResponse(user))
+}
+
+// UpdateMe godoc
+// @Summary Update current user profile
+// @Description Updates name and phone immediately; a new email is applied after confirmation via the link sent to it
+// @Tags me
+// @Accept json
+// @Produce json
+// @Param If-Match header string true "ETag of the profile version being changed"
+// @Param profile body UpdateMeRequest true "Fields to change"
+// @Success 200 {object} UpdateMeResponse "Updated profile"
+// @Failure 400 {string} string "Invalid request body"
+// @Failure 409 {string} string "Email is already in use"
+// @Failure 412 {string} string "Profile has been modified"
+// @Failure 428 {string} string "If-Match header is required"
+// @Security ApiKeyAuth
+// @Router /me [patch]
+func (h *MeHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(UserIDKey).(int)
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, pending, err := h.profile.UpdateProfile(r.Context(), userID, service.ProfileUpdate{
+		Name: req.Name, Email: req.Email, Phone: req.Phone, Version: version,
+	})
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+
+	setETag(w, user.Version)
+	json.NewEncoder(w).Encode(UpdateMeResponse{UserResponse: NewUserResponse(user), PendingEmail: pending})
+}
+
+// ChangePassword godoc
+// @Summary Change current user password
+// @Tags me
+// @Accept json
+// @Param body body ChangePasswordRequest true "Current and new password"
+// @Success 204 "Password changed"
+// @Failure 400 {string} string "Invalid request body or weak password"
+// @Failure 403 {string} string "Current password is incorrect"
+// @Security ApiKeyAuth
+// @Router /me/password [post]
+func (h *MeHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.profile.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword); err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteMe godoc
+// @Summary Delete current user account
+// @Description Anonymizes personal data; orders and reviews are kept without them
+// @Tags me
+// @Accept json
+// @Param body body DeleteMeRequest true "Current password"
+// @Success 204 "Account deleted"
+// @Failure 403 {string} string "Password is incorrect"
+// @Security ApiKeyAuth
+// @Router /me [delete]
+func (h *MeHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	var req DeleteMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.profile.DeleteAccount(r.Context(), userID, req.Password); err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListMyOrders godoc
+// @Summary List current user orders
+// @Tags me
+// @Produce json
+// @Success 200 {array} OrderResponse "Orders, newest first"
+// @Security ApiKeyAuth
+// @Router /me/orders [get]
+func (h *MeHandler) ListMyOrders(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	orders, err := h.orders.ListUserOrders(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(NewOrderResponses(orders))
+}
+
+// ListMyReviews godoc
+// @Summary List current user reviews
+// @Tags me
+// @Produce json
+// @Success 200 {array} CommentResponse "Reviews, newest first"
+// @Security ApiKeyAuth
+// @Router /me/reviews [get]
+func (h *MeHandler) ListMyReviews(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	comments, err := h.comments.ListUserComments(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(NewCommentResponses(comments))
+}
+
+// ConfirmEmail godoc
+// @Summary Confirm email change
+// @Description Applies the email change using the token from the confirmation email
+// @Tags me
+// @Accept json
+// @Param body body ConfirmEmailRequest true "Confirmation token"
+// @Success 204 "Email changed"
+// @Failure 400 {string} string "Token is invalid or expired"
+// @Failure 409 {string} string "Email is already in use"
+// @Router /auth/email/confirm [post]
+func (h *MeHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	var req ConfirmEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.profile.ConfirmEmailChange(r.Context(), req.Token); err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeProfileError maps profile service errors to HTTP statuses.
+func writeProfileError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidProfile), errors.Is(err, service.ErrInvalidEmailToken):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrWrongPassword):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrEmailTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrVersionConflict):
+		writePreconditionFailed(w)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -2,18 +2,23 @@ package handler
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/alex-pyslar/petelka-api/internal/tracing"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 )
 
-// Ключ для подписи JWT
-var jwtKey = []byte(os.Getenv("JWT_SECRET"))
-
 // Claims - структура для токена JWT
 type Claims struct {
 	UserID int    `json:"user_id"`
@@ -26,17 +31,136 @@ type Claims struct {
 type ContextKey string
 
 const (
-	UserIDKey   ContextKey = "userID"
-	UserRoleKey ContextKey = "userRole"
+	UserIDKey    ContextKey = "userID"
+	UserRoleKey  ContextKey = "userRole"
+	RequestIDKey ContextKey = "requestID"
 )
 
-// AuthMiddleware - универсальный middleware для проверки авторизации
-func AuthMiddleware(log *logger.Logger) func(http.Handler) http.Handler {
+// RequestIDHeader — заголовок, в котором передаётся идентификатор запроса.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину идентификатора, присланного клиентом.
+const maxRequestIDLength = 128
+
+// RequestIDFromContext возвращает идентификатор текущего запроса или пустую строку.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey).(string)
+	return id
+}
+
+// validRequestID проверяет, что присланный клиентом идентификатор безопасно писать в логи и заголовки.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestInfo накапливает сведения о запросе, которые становятся известны
+// во внутренних middleware (маршрут, пользователь), для строки access-лога.
+type requestInfo struct {
+	route  string
+	userID int
+}
+
+type requestInfoKey struct{}
+
+func requestInfoFromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// RequestIDMiddleware должен стоять первым в цепочке. Он принимает X-Request-ID
+// от клиента или генерирует новый, возвращает его в ответе, сохраняет в контексте
+// и кладёт в контекст логгер с полями request_id и trace_id.
+func RequestIDMiddleware(log *logger.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			requestID := uuid.New().String()
-			ctx := context.WithValue(r.Context(), "requestID", requestID)
-			r = r.WithContext(ctx)
+			requestID := r.Header.Get(RequestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = uuid.New().String()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			fields := []zap.Field{zap.String("request_id", requestID)}
+			if traceID := tracing.TraceID(r.Context()); traceID != "" {
+				fields = append(fields, zap.String("trace_id", traceID))
+			}
+			ctx := context.WithValue(r.Context(), RequestIDKey, requestID)
+			ctx = logger.WithContext(ctx, log.With(fields...))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AccessLogMiddleware пишет одну структурированную строку лога на каждый запрос.
+// Ставится сразу после RequestIDMiddleware.
+func AccessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{}
+		ctx := context.WithValue(r.Context(), requestInfoKey{}, info)
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		status := rec.statusCode()
+		fields := []zap.Field{
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.String("route", info.route),
+			zap.Int("status", status),
+			zap.Int64("bytes", rec.bytes),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.String("user_agent", r.UserAgent()),
+		}
+		if info.userID != 0 {
+			fields = append(fields, zap.Int("user_id", info.userID))
+		}
+
+		log := logger.FromContext(ctx)
+		if status >= http.StatusInternalServerError {
+			log.Error("http request", fields...)
+		} else {
+			log.Info("http request", fields...)
+		}
+	})
+}
+
+// RouteMiddleware добавляет шаблон маршрута mux в логгер запроса, access-лог
+// и имя серверного спана трассировки.
+// Подключается через router.Use, чтобы маршрут уже был определён.
+func RouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		if info := requestInfoFromContext(r.Context()); info != nil {
+			info.route = route
+		}
+		tracing.SetRoute(r.Context(), r.Method, route)
+		ctx := logger.WithContext(r.Context(), logger.FromContext(r.Context()).With(zap.String("route", route)))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// AccountChecker проверяет, что владелец действующего токена ещё может обращаться к API.
+// Реализуется service.UserService.
+type AccountChecker interface {
+	CheckActive(ctx context.Context, userID int) error
+}
+
+// AuthMiddleware - универсальный middleware для проверки авторизации.
+// jwtKey — ключ, которым подписаны токены. Если accounts не nil, токены удалённых
+// и заблокированных пользователей отклоняются, не дожидаясь истечения срока.
+func AuthMiddleware(jwtKey []byte, accounts AccountChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log := logger.FromContext(r.Context())
 
 			// Пропускаем OPTIONS-запросы
 			if r.Method == "OPTIONS" {
@@ -46,14 +170,14 @@ func AuthMiddleware(log *logger.Logger) func(http.Handler) http.Handler {
 
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				log.Errorf("Authorization header is required, request_id: %s", requestID)
+				log.Error("Authorization header is required")
 				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
 				return
 			}
 
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				log.Errorf("Invalid Authorization header format: %s, request_id: %s", authHeader, requestID)
+				log.Error("Invalid Authorization header format")
 				http.Error(w, "Invalid Authorization header format", http.StatusUnauthorized)
 				return
 			}
@@ -69,14 +193,35 @@ func AuthMiddleware(log *logger.Logger) func(http.Handler) http.Handler {
 			})
 
 			if err != nil || !token.Valid {
-				log.Errorf("Invalid token, request_id: %s: %v", requestID, err)
+				log.Errorf("Invalid token: %v", err)
 				http.Error(w, "Invalid token", http.StatusUnauthorized)
 				return
 			}
 
+			if accounts != nil {
+				if err := accounts.CheckActive(r.Context(), claims.UserID); err != nil {
+					switch {
+					case errors.Is(err, service.ErrUserBlocked):
+						log.Warningf("Rejected token of blocked user ID %d", claims.UserID)
+						http.Error(w, "Account is blocked", http.StatusForbidden)
+					case errors.Is(err, sql.ErrNoRows):
+						log.Warningf("Rejected token of deleted user ID %d", claims.UserID)
+						http.Error(w, "Account no longer exists", http.StatusUnauthorized)
+					default:
+						log.Errorf("Failed to check account of user ID %d: %v", claims.UserID, err)
+						http.Error(w, "Internal server error", http.StatusInternalServerError)
+					}
+					return
+				}
+			}
+
 			// Добавляем ID и роль пользователя в контекст
-			ctx = context.WithValue(ctx, UserIDKey, claims.UserID)
+			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
 			ctx = context.WithValue(ctx, UserRoleKey, claims.Role) // Сохраняем роль
+			ctx = logger.WithContext(ctx, log.With(zap.Int("user_id", claims.UserID)))
+			if info := requestInfoFromContext(ctx); info != nil {
+				info.userID = claims.UserID
+			}
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -84,28 +229,79 @@ func AuthMiddleware(log *logger.Logger) func(http.Handler) http.Handler {
 }
 
 // AdminMiddleware проверяет, является ли пользователь администратором.
-func AdminMiddleware(log *logger.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			requestID := r.Context().Value("requestID").(string)
-			role, ok := r.Context().Value(UserRoleKey).(string)
-			if !ok || role != "admin" {
-				log.Warningf("Access denied: non-admin user trying to access admin route, request_id: %s", requestID)
-				http.Error(w, "Forbidden", http.StatusForbidden)
-				return
-			}
-			next.ServeHTTP(w, r)
-		})
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, ok := r.Context().Value(UserRoleKey).(string)
+		if !ok || role != "admin" {
+			logger.FromContext(r.Context()).Warning("Access denied: non-admin user trying to access admin route")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder запоминает код ответа и размер тела, записанные обработчиком.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap открывает исходный ResponseWriter для http.ResponseController.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *statusRecorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+// routeTemplate возвращает шаблон маршрута mux, по которому обработан запрос.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
 	}
+	return "unmatched"
 }
 
-// CorsMiddleware устанавливает заголовки CORS для всех запросов.
-func CorsMiddleware(next http.Handler) http.Handler {
+// MetricsMiddleware собирает метрики Prometheus по каждому запросу:
+// число запросов, длительность и число выполняющихся запросов.
+// Подключается через router.Use, чтобы маршрут уже был определён.
+func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "https://petelka.shop")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		next.ServeHTTP(w, r)
+		route := routeTemplate(r)
+		inFlight := metrics.HTTPRequestsInFlight.WithLabelValues(route, r.Method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := strconv.Itoa(rec.statusCode())
+		metrics.HTTPRequestsTotal.WithLabelValues(route, r.Method, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
 	})
 }
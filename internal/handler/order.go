@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
@@ -14,40 +15,101 @@ import (
 
 // OrderHandler handles requests to orders.
 type OrderHandler struct {
-	service *service.OrderService
+	service  *service.OrderService
+	checkout *service.CheckoutService
 }
 
 // NewOrderHandler creates a new OrderHandler instance.
-func NewOrderHandler(s *service.OrderService) *OrderHandler {
-	return &OrderHandler{service: s}
+func NewOrderHandler(s *service.OrderService, checkout *service.CheckoutService) *OrderHandler {
+	return &OrderHandler{service: s, checkout: checkout}
+}
+
+// RegisterRoutes registers order routes in the access groups.
+func (h *OrderHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	protected.HandleFunc("/orders", h.CreateOrder).Methods("POST")
+	protected.HandleFunc("/orders/quote", h.QuoteOrder).Methods("POST")
+
+	admin.HandleFunc("/orders", h.ListOrders).Methods("GET")
+	admin.HandleFunc("/orders/{id}", h.GetOrder).Methods("GET")
+	admin.HandleFunc("/orders/{id}", h.UpdateOrder).Methods("PUT")
+	admin.HandleFunc("/orders/{id}", h.PatchOrder).Methods("PATCH")
+	admin.HandleFunc("/orders/{id}", h.DeleteOrder).Methods("DELETE")
+}
+
+// Snapshot returns the current order as the API renders it, for the audit log.
+func (h *OrderHandler) Snapshot(ctx context.Context, id string) (interface{}, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	order, err := h.service.GetOrder(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	return NewOrderResponse(order), nil
 }
 
 // CreateOrder godoc
 // @Summary Create a new order
-// @Description Create a new order with the input payload
+// @Description Place an order of the current user. Item prices, the delivery cost and the promo code discount are computed by the server, and the shipping address is copied into the order
 // @Tags orders
 // @Accept json
 // @Produce json
-// @Param order body models.Order true "Order object"
-// @Success 201 {object} models.Order "Order created successfully"
-// @Failure 400 {string} string "Invalid request body"
+// @Param order body CreateOrderRequest true "Items, delivery method, address and promo code"
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Success 201 {object} OrderResponse "Order created successfully"
+// @Failure 400 {string} string "Invalid request body, product or address"
+// @Failure 422 {string} string "Delivery method is not available or promo code cannot be applied"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /orders [post]
 func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
-	var order models.Order
-	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+	var req CreateOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	userID, _ := r.Context().Value(UserIDKey).(int)
 
-	if err := h.service.CreateOrder(r.Context(), &order); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	order, err := h.checkout.Checkout(r.Context(), userID, req.Checkout())
+	if err != nil {
+		writeCheckoutError(w, err)
 		return
 	}
 
+	setETag(w, order.Version)
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(order)
+	json.NewEncoder(w).Encode(NewOrderResponse(order))
+}
+
+// QuoteOrder godoc
+// @Summary Quote an order
+// @Description Compute the item prices, delivery cost, promo code discount and total of an order without placing it
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Param order body CreateOrderRequest true "Items, delivery method, address and promo code"
+// @Success 200 {object} OrderResponse "Computed order (not saved)"
+// @Failure 400 {string} string "Invalid request body, product or address"
+// @Failure 422 {string} string "Delivery method is not available or promo code cannot be applied"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /orders/quote [post]
+func (h *OrderHandler) QuoteOrder(w http.ResponseWriter, r *http.Request) {
+	var req CreateOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	order, err := h.checkout.Quote(r.Context(), userID, req.Checkout())
+	if err != nil {
+		writeCheckoutError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewOrderResponse(order))
 }
 
 // GetOrder godoc
@@ -56,7 +118,7 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 // @Tags orders
 // @Produce json
 // @Param id path int true "Order ID"
-// @Success 200 {object} models.Order "Order found"
+// @Success 200 {object} OrderResponse "Order found"
 // @Failure 400 {string} string "Invalid ID format"
 // @Failure 404 {string} string "Order not found"
 // @Failure 500 {string} string "Internal server error"
@@ -85,7 +147,8 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(order)
+	setETag(w, order.Version)
+	json.NewEncoder(w).Encode(NewOrderResponse(order))
 }
 
 // ListOrders godoc
@@ -93,7 +156,7 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 // @Description Retrieve a list of all orders
 // @Tags orders
 // @Produce json
-// @Success 200 {array} models.Order "List of orders"
+// @Success 200 {array} OrderResponse "List of orders"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /orders [get]
@@ -104,20 +167,22 @@ func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(orders)
+	json.NewEncoder(w).Encode(NewOrderResponses(orders))
 }
 
 // UpdateOrder godoc
 // @Summary Update an existing order
-// @Description Update order details by ID
+// @Description Replace the order status by ID. Totals and delivery are fixed at checkout. If If-Match is sent, the order is updated only if it matches its ETag
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Param id path int true "Order ID"
-// @Param order body models.Order true "Order object with updated fields"
-// @Success 200 {object} models.Order "Order updated successfully"
+// @Param If-Match header string false "ETag of the order version being replaced; without it the update is unconditional"
+// @Param order body OrderRequest true "Order fields to replace"
+// @Success 200 {object} OrderResponse "Order updated successfully"
 // @Failure 400 {string} string "Invalid request body or ID"
 // @Failure 404 {string} string "Order not found"
+// @Failure 412 {string} string "Order has been modified"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /orders/{id} [put]
@@ -134,14 +199,19 @@ func (h *OrderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var order models.Order
-	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req OrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	order.ID = id
 
-	if err := h.service.UpdateOrder(r.Context(), &order); err != nil {
+	current, err := h.service.GetOrder(r.Context(), id)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "Order not found", http.StatusNotFound)
 			return
@@ -149,8 +219,83 @@ func (h *OrderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	order := req.Apply(current)
+	order.Version = version
 
-	json.NewEncoder(w).Encode(order)
+	h.saveOrder(w, r, order)
+}
+
+// PatchOrder godoc
+// @Summary Partially update an order
+// @Description Apply a JSON Merge Patch (RFC 7386) to the order. The patch is applied only if If-Match matches the ETag
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Param id path int true "Order ID"
+// @Param If-Match header string true "ETag of the order version being patched"
+// @Param patch body OrderRequest true "Fields to change"
+// @Success 200 {object} OrderResponse "Order updated successfully"
+// @Failure 400 {string} string "Invalid patch"
+// @Failure 404 {string} string "Order not found"
+// @Failure 412 {string} string "Order has been modified"
+// @Failure 415 {string} string "Unsupported patch format"
+// @Failure 428 {string} string "If-Match header is required"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /orders/{id} [patch]
+func (h *OrderHandler) PatchOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := h.service.GetOrder(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if version != 0 && version != current.Version {
+		writePreconditionFailed(w)
+		return
+	}
+
+	var req OrderRequest
+	if !decodeMergePatch(w, r, NewOrderRequest(current), &req) {
+		return
+	}
+	order := req.Apply(current)
+	// Изменение применяется к прочитанной версии: параллельная запись приведёт к 412
+	order.Version = current.Version
+
+	h.saveOrder(w, r, order)
+}
+
+// saveOrder сохраняет изменённый заказ и отвечает его новой версией.
+func (h *OrderHandler) saveOrder(w http.ResponseWriter, r *http.Request, order *models.Order) {
+	if err := h.service.UpdateOrder(r.Context(), order); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrVersionConflict) {
+			writePreconditionFailed(w)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	setETag(w, order.Version)
+	json.NewEncoder(w).Encode(NewOrderResponse(order))
 }
 
 // DeleteOrder godoc
@@ -188,3 +333,17 @@ func (h *OrderHandler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// writeCheckoutError отвечает кодом, соответствующим ошибке оформления заказа.
+func writeCheckoutError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidCheckout):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrDeliveryUnavailable),
+		errors.Is(err, service.ErrPromoCodeNotApplicable),
+		errors.Is(err, service.ErrPromoCodeExhausted):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Частичное изменение ресурсов (JSON Merge Patch, RFC 7386) и оптимистическая
+// блокировка через ETag/If-Match. ETag ресурса — его версия в кавычках, например "3".
+
+// maxPatchSize ограничивает размер тела PATCH-запроса.
+const maxPatchSize = 1 << 20
+
+// MergePatchContentType — тип тела PATCH-запросов. Также принимается application/json.
+const MergePatchContentType = "application/merge-patch+json"
+
+// etag возвращает ETag версии ресурса.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag добавляет в ответ ETag версии ресурса.
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// requireIfMatch возвращает версию из заголовка If-Match для PUT и PATCH
+// версионируемых ресурсов. PATCH без заголовка получает 428: патч всегда
+// применяется к конкретной версии. PUT без заголовка выполняется без проверки
+// версии (версия 0), чтобы не ломать существующих клиентов. Для значения, которое
+// не может совпасть с версией, отвечает 412. При ошибке ответ уже записан в w и
+// возвращается false.
+func requireIfMatch(w http.ResponseWriter, r *http.Request) (version int, ok bool) {
+	if strings.TrimSpace(r.Header.Get("If-Match")) == "" {
+		if r.Method != http.MethodPatch {
+			return 0, true
+		}
+		http.Error(w, "If-Match header with the resource ETag is required", http.StatusPreconditionRequired)
+		return 0, false
+	}
+	version, ok = ifMatchVersion(r)
+	if !ok {
+		writePreconditionFailed(w)
+	}
+	return version, ok
+}
+
+// ifMatchVersion возвращает версию из заголовка If-Match. Для "*" возвращается 0
+// (без проверки). ok равен false, если заголовок не может совпасть ни с одной
+// версией: слабый или некорректный ETag, список из нескольких ETag.
+func ifMatchVersion(r *http.Request) (version int, ok bool) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "*" {
+		return 0, true
+	}
+	if len(value) < 3 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.Atoi(value[1 : len(value)-1])
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// writePreconditionFailed отвечает 412, когда версия ресурса не совпала с If-Match.
+func writePreconditionFailed(w http.ResponseWriter) {
+	http.Error(w, "Resource has been modified, fetch it again and retry", http.StatusPreconditionFailed)
+}
+
+// decodeMergePatch применяет тело запроса как JSON Merge Patch к current и
+// декодирует результат в dst. current и dst — запросные DTO ресурса, поэтому
+// патч может менять только доступные клиенту поля; неизвестные поля отклоняются.
+// При ошибке ответ уже записан в w и возвращается false.
+func decodeMergePatch(w http.ResponseWriter, r *http.Request, current, dst interface{}) bool {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != MergePatchContentType && mediaType != "application/json") {
+			http.Error(w, "Content-Type must be "+MergePatchContentType, http.StatusUnsupportedMediaType)
+			return false
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		http.Error(w, "Merge patch must be a JSON object", http.StatusBadRequest)
+		return false
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	merged, err := MergePatch(doc, body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		http.Error(w, "Invalid patch: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// MergePatch применяет JSON Merge Patch (RFC 7386) к документу doc.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := decodeJSONNumbers(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := decodeJSONNumbers(patch, &p); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+// decodeJSONNumbers декодирует JSON, сохраняя числа как json.Number без потери точности.
+func decodeJSONNumbers(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// mergePatch применяет patch к target: null удаляет поле, объекты сливаются
+// рекурсивно, остальные значения заменяются целиком.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
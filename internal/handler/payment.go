@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// maxWebhookSize ограничивает размер уведомления платёжного провайдера.
+const maxWebhookSize = 64 << 10
+
+// PaymentHandler handles order payments and payment provider webhooks.
+type PaymentHandler struct {
+	service *service.PaymentService
+}
+
+// NewPaymentHandler creates a new PaymentHandler instance.
+func NewPaymentHandler(s *service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{service: s}
+}
+
+// RegisterRoutes registers payment routes in the access groups.
+func (h *PaymentHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	public.HandleFunc("/payments/webhook", h.Webhook).Methods("POST")
+
+	protected.HandleFunc("/orders/{id}/pay", h.PayOrder).Methods("POST")
+
+	admin.HandleFunc("/orders/{id}/refund", h.RefundOrder).Methods("POST")
+}
+
+// PayOrder godoc
+// @Summary Pay for an order
+// @Description Start payment of the current user's order and return the URL of the payment page. Repeated calls return the same pending payment
+// @Tags payments
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} PaymentResponse "Payment with confirmation URL"
+// @Failure 400 {string} string "Invalid ID format"
+// @Failure 404 {string} string "Order not found"
+// @Failure 409 {string} string "Order is already paid or payment is in progress"
+// @Failure 502 {string} string "Payment provider error"
+// @Failure 504 {string} string "Payment provider timeout"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /orders/{id}/pay [post]
+func (h *PaymentHandler) PayOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	payment, err := h.service.PayOrder(r.Context(), userID, id)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewPaymentResponse(payment))
+}
+
+// RefundOrder godoc
+// @Summary Refund an order
+// @Description Return the money for a paid order and mark it refunded
+// @Tags payments
+// @Produce json
+// @Param id path int true "Order ID"
+// @Success 200 {object} PaymentResponse "Refunded payment"
+// @Failure 400 {string} string "Invalid ID format"
+// @Failure 409 {string} string "Order has no succeeded payment"
+// @Failure 502 {string} string "Payment provider error"
+// @Failure 504 {string} string "Payment provider timeout"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /orders/{id}/refund [post]
+func (h *PaymentHandler) RefundOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := h.service.RefundOrder(r.Context(), id)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewPaymentResponse(payment))
+}
+
+// Webhook godoc
+// @Summary Payment provider webhook
+// @Description Receive a payment status notification. YooKassa notifications are verified by fetching the payment from the provider API;
+// @Description fake driver notifications must be signed. A succeeded payment marks the order paid; repeated notifications are accepted and ignored
+// @Tags payments
+// @Accept json
+// @Param X-Webhook-Signature header string false "HMAC-SHA256 of the body, hex (fake driver only)"
+// @Success 200 "Notification accepted"
+// @Failure 400 {string} string "Invalid notification or unknown payment"
+// @Failure 401 {string} string "Invalid signature"
+// @Failure 500 {string} string "Internal server error"
+// @Router /payments/webhook [post]
+func (h *PaymentHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		http.Error(w, "Invalid notification", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.HandleWebhook(r.Context(), r.Header, body); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidWebhookSignature):
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		case errors.Is(err, service.ErrInvalidWebhook):
+			http.Error(w, "Invalid notification", http.StatusBadRequest)
+		default:
+			// Провайдер повторит уведомление
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// writePaymentError отвечает кодом, соответствующим ошибке оплаты.
+func writePaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Order not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrOrderAlreadyPaid):
+		http.Error(w, "Order is already paid", http.StatusConflict)
+	case errors.Is(err, service.ErrPaymentInProgress):
+		http.Error(w, "Payment is already in progress, retry shortly", http.StatusConflict)
+	case errors.Is(err, service.ErrNothingToRefund):
+		http.Error(w, "Order has no succeeded payment to refund", http.StatusConflict)
+	case errors.Is(err, service.ErrPaymentTimeout):
+		http.Error(w, "Payment provider did not respond, retry later", http.StatusGatewayTimeout)
+	case errors.Is(err, service.ErrPaymentFailed):
+		http.Error(w, "Payment provider error", http.StatusBadGateway)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
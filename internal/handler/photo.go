@@ -2,20 +2,53 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/alex-pyslar/petelka-api/internal/service"
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 )
 
+// Photo delivery modes for GET /photos/{objectName}.
+const (
+	// PhotoDeliveryURL returns a presigned URL as plain text (legacy behaviour).
+	PhotoDeliveryURL = "url"
+	// PhotoDeliveryProxy streams the object from storage through the API.
+	PhotoDeliveryProxy = "proxy"
+	// PhotoDeliveryRedirect answers with 302 to a short-lived presigned URL.
+	PhotoDeliveryRedirect = "redirect"
+)
+
+// PhotoDelivery configures how photos are served to clients.
+type PhotoDelivery struct {
+	Mode        string
+	CacheMaxAge time.Duration
+}
+
 // PhotoHandler handles HTTP requests for photos.
 type PhotoHandler struct {
-	service *service.PhotoService
+	service  *service.PhotoService
+	delivery PhotoDelivery
 }
 
 // NewPhotoHandler creates a new PhotoHandler.
-func NewPhotoHandler(s *service.PhotoService) *PhotoHandler {
-	return &PhotoHandler{service: s}
+func NewPhotoHandler(s *service.PhotoService, delivery PhotoDelivery) *PhotoHandler {
+	return &PhotoHandler{service: s, delivery: delivery}
+}
+
+// RegisterRoutes registers photo routes in the access groups.
+func (h *PhotoHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	public.HandleFunc("/photos/{objectName}", h.Download).Methods("GET", "HEAD")
+
+	admin.HandleFunc("/photos", h.ListPhotos).Methods("GET")
+	admin.HandleFunc("/photos", h.Upload).Methods("POST")
+	admin.HandleFunc("/photos/bulk-delete", h.BulkDeletePhotos).Methods("POST")
+	admin.HandleFunc("/photos/upload-url", h.UploadURL).Methods("POST")
+	admin.HandleFunc("/photos/{objectName}/confirm", h.ConfirmUpload).Methods("POST")
+	admin.HandleFunc("/photos/{objectName}", h.DeletePhoto).Methods("DELETE")
 }
 
 // Upload godoc
@@ -25,7 +58,7 @@ func NewPhotoHandler(s *service.PhotoService) *PhotoHandler {
 // @Accept  mpfd
 // @Produce  json
 // @Param file formData file true "Image file (max 32MB)"
-// @Success 201 {object} map[string]string "objectName and url"
+// @Success 201 {object} PhotoUploadResponse "objectName and url"
 // @Failure 400 {string} string "Invalid file or format"
 // @Failure 500 {string} string "Upload failed"
 // @Security ApiKeyAuth
@@ -44,8 +77,10 @@ func (h *PhotoHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
 	// Делегируем в сервис (логирование — там!)
-	objectName, url, err := h.service.Upload(r.Context(), file, header.Size, header.Filename, header.Header.Get("Content-Type"))
+	objectName, url, err := h.service.Upload(r.Context(), file, header.Size, header.Filename, header.Header.Get("Content-Type"), userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -53,19 +88,102 @@ func (h *PhotoHandler) Upload(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"objectName": objectName,
-		"url":        url,
-	})
+	json.NewEncoder(w).Encode(PhotoUploadResponse{ObjectName: objectName, URL: url})
+}
+
+// UploadURLRequest describes a file the client is going to upload directly to storage.
+type UploadURLRequest struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+}
+
+// UploadURL godoc
+// @Summary Get a presigned upload policy
+// @Description Returns a presigned POST policy for uploading an image (JPG/PNG/JPEG, max 32MB) directly to MinIO.
+// @Description The client sends a multipart/form-data POST to url with all formData fields followed by the file,
+// @Description then calls /photos/{objectName}/confirm.
+// @Tags photos
+// @Accept json
+// @Produce json
+// @Param request body UploadURLRequest true "File name and size"
+// @Success 201 {object} service.UploadURL "Upload policy"
+// @Failure 400 {string} string "Invalid file or format"
+// @Failure 500 {string} string "Internal error"
+// @Security ApiKeyAuth
+// @Router /photos/upload-url [post]
+func (h *PhotoHandler) UploadURL(w http.ResponseWriter, r *http.Request) {
+	var req UploadURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	upload, err := h.service.CreateUploadURL(r.Context(), req.Filename, req.Size, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(upload)
+}
+
+// ConfirmUpload godoc
+// @Summary Confirm a direct upload
+// @Description Validates an object uploaded with a presigned policy and registers it. Invalid objects are deleted.
+// @Tags photos
+// @Produce json
+// @Param objectName path string true "Object name returned by /photos/upload-url"
+// @Success 201 {object} PhotoResponse "Registered photo"
+// @Failure 400 {string} string "Uploaded file is invalid"
+// @Failure 404 {string} string "Upload not found or expired"
+// @Failure 500 {string} string "Internal error"
+// @Security ApiKeyAuth
+// @Router /photos/{objectName}/confirm [post]
+func (h *PhotoHandler) ConfirmUpload(w http.ResponseWriter, r *http.Request) {
+	objectName := mux.Vars(r)["objectName"]
+	if objectName == "" {
+		http.Error(w, "objectName is required", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value(UserIDKey).(int)
+
+	photo, err := h.service.ConfirmUpload(r.Context(), objectName, userID)
+	if err != nil {
+		if errors.Is(err, service.ErrPhotoNotFound) {
+			http.Error(w, "upload not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidUpload) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(NewPhotoResponse(photo))
 }
 
 // Download godoc
-// @Summary Get photo download URL
-// @Description Returns a presigned URL for downloading the photo (valid for 7 days)
+// @Summary Get photo
+// @Description Depending on server configuration returns a presigned URL as plain text,
+// @Description streams the image with ETag/Last-Modified/Range support, or redirects to a short-lived URL
 // @Tags photos
 // @Produce  plain
+// @Produce  image/jpeg
+// @Produce  image/png
 // @Param objectName path string true "Object name in MinIO"
-// @Success 200 {string} string "Presigned URL"
+// @Param Range header string false "Byte range (proxy mode)"
+// @Param If-None-Match header string false "ETag from a previous response (proxy mode)"
+// @Success 200 {string} string "Presigned URL or image content"
+// @Success 206 {string} string "Partial image content"
+// @Success 302 "Redirect to a short-lived presigned URL"
+// @Success 304 "Not Modified"
 // @Failure 400 {string} string "Invalid objectName"
 // @Failure 404 {string} string "Photo not found"
 // @Failure 500 {string} string "Internal error"
@@ -78,16 +196,180 @@ func (h *PhotoHandler) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	switch h.delivery.Mode {
+	case PhotoDeliveryProxy:
+		h.stream(w, r, objectName)
+	case PhotoDeliveryRedirect:
+		h.redirect(w, r, objectName)
+	default:
+		h.writeURL(w, r, objectName)
+	}
+}
+
+// writeURL returns a cached presigned URL as plain text.
+func (h *PhotoHandler) writeURL(w http.ResponseWriter, r *http.Request, objectName string) {
 	url, err := h.service.GetDownloadURL(r.Context(), objectName)
 	if err != nil {
-		if err.Error() == "photo not found" {
-			http.Error(w, "photo not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writePhotoError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(url))
 }
+
+// redirect sends the client to a short-lived presigned URL.
+func (h *PhotoHandler) redirect(w http.ResponseWriter, r *http.Request, objectName string) {
+	url, err := h.service.GetShortLivedURL(r.Context(), objectName)
+	if err != nil {
+		writePhotoError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// stream proxies the object from storage. http.ServeContent takes care of
+// Range, If-None-Match, If-Modified-Since and HEAD requests.
+func (h *PhotoHandler) stream(w http.ResponseWriter, r *http.Request, objectName string) {
+	obj, err := h.service.Open(r.Context(), objectName)
+	if err != nil {
+		writePhotoError(w, err)
+		return
+	}
+	defer obj.Reader.Close()
+
+	if obj.ETag != "" {
+		w.Header().Set("ETag", fmt.Sprintf("%q", obj.ETag))
+	}
+	if obj.ContentType != "" {
+		w.Header().Set("Content-Type", obj.ContentType)
+	}
+	if h.delivery.CacheMaxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.delivery.CacheMaxAge.Seconds())))
+	}
+
+	http.ServeContent(w, r, objectName, obj.LastModified, obj.Reader)
+}
+
+// writePhotoError maps service errors to HTTP statuses.
+func writePhotoError(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrPhotoNotFound) {
+		http.Error(w, "photo not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// ListPhotos godoc
+// @Summary List photos in storage
+// @Description Returns bucket objects with size, content type, upload time, uploader and referencing products.
+// @Description Pagination is cursor-based: pass next_after from the previous page as after.
+// @Tags photos
+// @Produce json
+// @Param after query string false "Object name to start after"
+// @Param limit query int false "Items per page (default 50, max 1000)"
+// @Success 200 {object} PhotoListResponse "Photos and next_after cursor"
+// @Failure 400 {string} string "Invalid query parameters"
+// @Failure 500 {string} string "Internal error"
+// @Security ApiKeyAuth
+// @Router /photos [get]
+func (h *PhotoHandler) ListPhotos(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit := 50
+	if limitStr := q.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil {
+			http.Error(w, "Invalid limit format", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	photos, next, err := h.service.ListPhotos(r.Context(), q.Get("after"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PhotoListResponse{
+		Photos:    mapSlice(photos, NewPhotoResponse),
+		NextAfter: next,
+	})
+}
+
+// DeletePhoto godoc
+// @Summary Delete a photo
+// @Description Deletes a photo from storage. Photos referenced by products are kept unless force=true.
+// @Tags photos
+// @Param objectName path string true "Object name in MinIO"
+// @Param force query bool false "Delete even if products reference the photo"
+// @Success 204 "No Content"
+// @Failure 404 {string} string "Photo not found"
+// @Failure 409 {string} string "Photo is referenced by products"
+// @Failure 500 {string} string "Internal error"
+// @Security ApiKeyAuth
+// @Router /photos/{objectName} [delete]
+func (h *PhotoHandler) DeletePhoto(w http.ResponseWriter, r *http.Request) {
+	objectName := mux.Vars(r)["objectName"]
+	if objectName == "" {
+		http.Error(w, "objectName is required", http.StatusBadRequest)
+		return
+	}
+	force := r.URL.Query().Get("force") == "true"
+
+	if err := h.service.DeletePhoto(r.Context(), objectName, force); err != nil {
+		if errors.Is(err, service.ErrPhotoInUse) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writePhotoError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxBulkDeletePhotos limits how many photos one bulk delete request may remove.
+const maxBulkDeletePhotos = 100
+
+// BulkDeleteRequest lists photos to delete.
+type BulkDeleteRequest struct {
+	ObjectNames []string `json:"objectNames"`
+	Force       bool     `json:"force"`
+}
+
+// BulkDeletePhotos godoc
+// @Summary Delete several photos
+// @Description Deletes up to 100 photos one by one and reports which were deleted and which failed.
+// @Tags photos
+// @Accept json
+// @Produce json
+// @Param request body BulkDeleteRequest true "Object names and force flag"
+// @Success 200 {object} service.BulkDeleteResult "Deleted and failed photos"
+// @Failure 400 {string} string "Invalid request body or too many photos"
+// @Security ApiKeyAuth
+// @Router /photos/bulk-delete [post]
+func (h *PhotoHandler) BulkDeletePhotos(w http.ResponseWriter, r *http.Request) {
+	var req BulkDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.ObjectNames) == 0 {
+		http.Error(w, "objectNames is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.ObjectNames) > maxBulkDeletePhotos {
+		http.Error(w, fmt.Sprintf("objectNames must contain at most %d photos", maxBulkDeletePhotos), http.StatusBadRequest)
+		return
+	}
+
+	result := h.service.DeletePhotos(r.Context(), req.ObjectNames, req.Force)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
@@ -22,24 +23,50 @@ func NewProductHandler(s *service.ProductService) *ProductHandler {
 	return &ProductHandler{service: s}
 }
 
+// RegisterRoutes registers product routes in the access groups.
+func (h *ProductHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	public.HandleFunc("/products", h.ListProducts).Methods("GET")
+	public.HandleFunc("/products/search", h.SearchProducts).Methods("GET")
+	public.HandleFunc("/products/{id}", h.GetProduct).Methods("GET")
+
+	admin.HandleFunc("/products", h.CreateProduct).Methods("POST")
+	admin.HandleFunc("/products/{id}", h.UpdateProduct).Methods("PUT")
+	admin.HandleFunc("/products/{id}", h.PatchProduct).Methods("PATCH")
+	admin.HandleFunc("/products/{id}", h.DeleteProduct).Methods("DELETE")
+}
+
+// Snapshot returns the current product as the API renders it, for the audit log.
+func (h *ProductHandler) Snapshot(ctx context.Context, id string) (interface{}, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	product, err := h.service.GetProduct(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	return NewProductResponse(product), nil
+}
+
 // CreateProduct godoc
 // @Summary Create a new product
 // @Description Create a new product with the input payload
 // @Tags products
 // @Accept json
 // @Produce json
-// @Param product body models.Product true "Product object"
-// @Success 201 {object} models.Product "Product created successfully"
+// @Param product body ProductRequest true "Product object"
+// @Success 201 {object} ProductResponse "Product created successfully"
 // @Failure 400 {string} string "Invalid request body or product type"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /products [post]
 func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
-	var product models.Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+	var req ProductRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	product := req.Model(0)
 
 	// Проверка поля Type
 	if product.Type != "yarn" && product.Type != "garment" {
@@ -47,13 +74,14 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.CreateProduct(r.Context(), &product); err != nil {
+	if err := h.service.CreateProduct(r.Context(), product); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	setETag(w, product.Version)
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(product)
+	json.NewEncoder(w).Encode(NewProductResponse(product))
 }
 
 // GetProduct godoc
@@ -62,7 +90,7 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 // @Tags products
 // @Produce json
 // @Param id path int true "Product ID"
-// @Success 200 {object} models.Product "Product found"
+// @Success 200 {object} ProductResponse "Product found"
 // @Failure 400 {string} string "Invalid ID format"
 // @Failure 404 {string} string "Product not found"
 // @Failure 500 {string} string "Internal server error"
@@ -91,7 +119,8 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(product)
+	setETag(w, product.Version)
+	json.NewEncoder(w).Encode(NewProductResponse(product))
 }
 
 // ListProducts godoc
@@ -99,7 +128,7 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
 // @Description Retrieve a list of all products
 // @Tags products
 // @Produce json
-// @Success 200 {array} models.Product "List of products"
+// @Success 200 {array} ProductResponse "List of products"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /products [get]
@@ -110,7 +139,7 @@ func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(products)
+	json.NewEncoder(w).Encode(NewProductResponses(products))
 }
 
 // SearchProducts godoc
@@ -124,7 +153,7 @@ func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
 // @Param color query string false "Product color (partial match)"
 // @Param page query int false "Page number (default 1)"
 // @Param limit query int false "Items per page (default 10)"
-// @Success 200 {object} map[string]interface{} "List of products with total count"
+// @Success 200 {object} ProductSearchResponse "List of products with total count"
 // @Failure 400 {string} string "Invalid query parameters"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
@@ -172,26 +201,27 @@ func (h *ProductHandler) SearchProducts(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	resp := map[string]interface{}{
-		"products":    products,
-		"total_count": totalCount,
-		"page":        page,
-		"limit":       limit,
-	}
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(ProductSearchResponse{
+		Products:   NewProductResponses(products),
+		TotalCount: totalCount,
+		Page:       page,
+		Limit:      limit,
+	})
 }
 
 // UpdateProduct godoc
 // @Summary Update an existing product
-// @Description Update product details by ID
+// @Description Replace product details by ID. The product is updated only if If-Match matches its ETag
 // @Tags products
 // @Accept json
 // @Produce json
 // @Param id path int true "Product ID"
-// @Param product body models.Product true "Product object with updated fields"
-// @Success 200 {object} models.Product "Product updated successfully"
+// @Param If-Match header string false "ETag of the product version being replaced; without it the update is unconditional"
+// @Param product body ProductRequest true "Product object with updated fields"
+// @Success 200 {object} ProductResponse "Product updated successfully"
 // @Failure 400 {string} string "Invalid request body or product type"
 // @Failure 404 {string} string "Product not found"
+// @Failure 412 {string} string "Product has been modified"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /products/{id} [put]
@@ -208,29 +238,99 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var product models.Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req ProductRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	product.ID = id
+	product := req.Model(id)
+	product.Version = version
+
+	h.saveProduct(w, r, product)
+}
 
+// PatchProduct godoc
+// @Summary Partially update a product
+// @Description Apply a JSON Merge Patch (RFC 7386) to the product. The patch is applied only if If-Match matches the ETag
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param If-Match header string true "ETag of the product version being patched"
+// @Param patch body ProductRequest true "Fields to change; null removes optional fields"
+// @Success 200 {object} ProductResponse "Product updated successfully"
+// @Failure 400 {string} string "Invalid patch or product type"
+// @Failure 404 {string} string "Product not found"
+// @Failure 412 {string} string "Product has been modified"
+// @Failure 428 {string} string "If-Match header is required"
+// @Failure 415 {string} string "Unsupported patch format"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /products/{id} [patch]
+func (h *ProductHandler) PatchProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := h.service.GetProduct(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if version != 0 && version != current.Version {
+		writePreconditionFailed(w)
+		return
+	}
+
+	var req ProductRequest
+	if !decodeMergePatch(w, r, NewProductRequest(current), &req) {
+		return
+	}
+	product := req.Model(id)
+	// Изменение применяется к прочитанной версии: параллельная запись приведёт к 412
+	product.Version = current.Version
+
+	h.saveProduct(w, r, product)
+}
+
+// saveProduct проверяет и сохраняет изменённый товар и отвечает его новой версией.
+func (h *ProductHandler) saveProduct(w http.ResponseWriter, r *http.Request, product *models.Product) {
 	// Проверка поля Type
 	if product.Type != "yarn" && product.Type != "garment" {
 		http.Error(w, "Invalid product type: must be 'yarn' or 'garment'", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.service.UpdateProduct(r.Context(), &product); err != nil {
+	if err := h.service.UpdateProduct(r.Context(), product); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "Product not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrVersionConflict) {
+			writePreconditionFailed(w)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(product)
+	setETag(w, product.Version)
+	json.NewEncoder(w).Encode(NewProductResponse(product))
 }
 
 // DeleteProduct godoc
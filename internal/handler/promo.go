@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// PromoHandler handles promo code management.
+type PromoHandler struct {
+	service *service.PromoService
+}
+
+// NewPromoHandler creates a new PromoHandler instance.
+func NewPromoHandler(s *service.PromoService) *PromoHandler {
+	return &PromoHandler{service: s}
+}
+
+// RegisterRoutes registers promo code routes in the access groups.
+func (h *PromoHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	admin.HandleFunc("/promo-codes", h.ListPromoCodes).Methods("GET")
+	admin.HandleFunc("/promo-codes", h.CreatePromoCode).Methods("POST")
+	admin.HandleFunc("/promo-codes/{id}", h.GetPromoCode).Methods("GET")
+	admin.HandleFunc("/promo-codes/{id}", h.UpdatePromoCode).Methods("PUT")
+	admin.HandleFunc("/promo-codes/{id}", h.PatchPromoCode).Methods("PATCH")
+	admin.HandleFunc("/promo-codes/{id}", h.DeletePromoCode).Methods("DELETE")
+	admin.HandleFunc("/promo-codes/{id}/stats", h.GetPromoCodeStats).Methods("GET")
+}
+
+// Snapshot returns the current promo code as the API renders it, for the audit log.
+func (h *PromoHandler) Snapshot(ctx context.Context, id string) (interface{}, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	promo, err := h.service.GetPromoCode(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	return NewPromoCodeResponse(promo), nil
+}
+
+// ListPromoCodes godoc
+// @Summary List promo codes
+// @Tags promo-codes
+// @Produce json
+// @Success 200 {array} PromoCodeResponse "Promo codes"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /promo-codes [get]
+func (h *PromoHandler) ListPromoCodes(w http.ResponseWriter, r *http.Request) {
+	codes, err := h.service.ListPromoCodes(r.Context())
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewPromoCodeResponses(codes))
+}
+
+// GetPromoCode godoc
+// @Summary Get a promo code
+// @Tags promo-codes
+// @Produce json
+// @Param id path int true "Promo code ID"
+// @Success 200 {object} PromoCodeResponse "Promo code"
+// @Failure 400 {string} string "Invalid ID format"
+// @Failure 404 {string} string "Promo code not found"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /promo-codes/{id} [get]
+func (h *PromoHandler) GetPromoCode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	promo, err := h.service.GetPromoCode(r.Context(), id)
+	if err != nil {
+		writePromoCodeError(w, err)
+		return
+	}
+
+	setETag(w, promo.Version)
+	json.NewEncoder(w).Encode(NewPromoCodeResponse(promo))
+}
+
+// CreatePromoCode godoc
+// @Summary Create a promo code
+// @Description Create a percentage, fixed amount or free shipping promo code with an optional validity window, usage limits, minimum order total and product/category restrictions
+// @Tags promo-codes
+// @Accept json
+// @Produce json
+// @Param promo body PromoCodeRequest true "Promo code"
+// @Success 201 {object} PromoCodeResponse "Promo code created"
+// @Failure 400 {string} string "Invalid promo code"
+// @Failure 409 {string} string "Promo code already exists"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /promo-codes [post]
+func (h *PromoHandler) CreatePromoCode(w http.ResponseWriter, r *http.Request) {
+	var req PromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	promo := req.Model(0)
+
+	if err := h.service.CreatePromoCode(r.Context(), promo); err != nil {
+		writePromoCodeError(w, err)
+		return
+	}
+
+	setETag(w, promo.Version)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(NewPromoCodeResponse(promo))
+}
+
+// UpdatePromoCode godoc
+// @Summary Update a promo code
+// @Description Replace a promo code. Orders already placed keep their discount. If If-Match is sent, the promo code is updated only if it matches its ETag
+// @Tags promo-codes
+// @Accept json
+// @Produce json
+// @Param id path int true "Promo code ID"
+// @Param If-Match header string false "ETag of the promo code version being replaced; without it the update is unconditional"
+// @Param promo body PromoCodeRequest true "Promo code"
+// @Success 200 {object} PromoCodeResponse "Promo code updated"
+// @Failure 400 {string} string "Invalid promo code or ID"
+// @Failure 404 {string} string "Promo code not found"
+// @Failure 409 {string} string "Promo code already exists"
+// @Failure 412 {string} string "Promo code has been modified"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /promo-codes/{id} [put]
+func (h *PromoHandler) UpdatePromoCode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+	var req PromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	promo := req.Model(id)
+	promo.Version = version
+
+	h.savePromoCode(w, r, promo)
+}
+
+// PatchPromoCode godoc
+// @Summary Partially update a promo code
+// @Description Apply a JSON Merge Patch (RFC 7386) to the promo code, e.g. {"is_active": false}. Orders already placed keep their discount. The patch is applied only if If-Match matches the ETag
+// @Tags promo-codes
+// @Accept json
+// @Produce json
+// @Param id path int true "Promo code ID"
+// @Param If-Match header string true "ETag of the promo code version being patched"
+// @Param patch body PromoCodeRequest true "Fields to change"
+// @Success 200 {object} PromoCodeResponse "Promo code updated"
+// @Failure 400 {string} string "Invalid patch or ID"
+// @Failure 404 {string} string "Promo code not found"
+// @Failure 409 {string} string "Promo code already exists"
+// @Failure 412 {string} string "Promo code has been modified"
+// @Failure 415 {string} string "Unsupported patch format"
+// @Failure 428 {string} string "If-Match header is required"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /promo-codes/{id} [patch]
+func (h *PromoHandler) PatchPromoCode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := h.service.GetPromoCode(r.Context(), id)
+	if err != nil {
+		writePromoCodeError(w, err)
+		return
+	}
+	if version != 0 && version != current.Version {
+		writePreconditionFailed(w)
+		return
+	}
+	var req PromoCodeRequest
+	if !decodeMergePatch(w, r, NewPromoCodeRequest(current), &req) {
+		return
+	}
+	promo := req.Model(id)
+	// Изменение применяется к прочитанной версии: параллельная запись приведёт к 412
+	promo.Version = current.Version
+
+	h.savePromoCode(w, r, promo)
+}
+
+// savePromoCode сохраняет изменённый промокод и отвечает им и его новой версией.
+func (h *PromoHandler) savePromoCode(w http.ResponseWriter, r *http.Request, promo *models.PromoCode) {
+	if err := h.service.UpdatePromoCode(r.Context(), promo); err != nil {
+		writePromoCodeError(w, err)
+		return
+	}
+
+	setETag(w, promo.Version)
+	json.NewEncoder(w).Encode(NewPromoCodeResponse(promo))
+}
+
+// DeletePromoCode godoc
+// @Summary Delete a promo code
+// @Description Delete a promo code and its usage statistics. Orders keep the code and discount
+// @Tags promo-codes
+// @Param id path int true "Promo code ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid ID format"
+// @Failure 404 {string} string "Promo code not found"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /promo-codes/{id} [delete]
+func (h *PromoHandler) DeletePromoCode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DeletePromoCode(r.Context(), id); err != nil {
+		writePromoCodeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPromoCodeStats godoc
+// @Summary Get promo code usage statistics
+// @Description Number of orders and customers that used the promo code, total discount and total of those orders
+// @Tags promo-codes
+// @Produce json
+// @Param id path int true "Promo code ID"
+// @Success 200 {object} PromoCodeStatsResponse "Usage statistics"
+// @Failure 400 {string} string "Invalid ID format"
+// @Failure 404 {string} string "Promo code not found"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /promo-codes/{id}/stats [get]
+func (h *PromoHandler) GetPromoCodeStats(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.service.Stats(r.Context(), id)
+	if err != nil {
+		writePromoCodeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(NewPromoCodeStatsResponse(stats))
+}
+
+// writePromoCodeError отвечает кодом, соответствующим ошибке управления промокодами.
+func writePromoCodeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Promo code not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidPromoCode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrPromoCodeTaken):
+		http.Error(w, "Promo code already exists", http.StatusConflict)
+	case errors.Is(err, service.ErrVersionConflict):
+		writePreconditionFailed(w)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
+	"github.com/alex-pyslar/petelka-api/internal/ratelimit"
+)
+
+// RateLimitKey возвращает ключ, по которому считаются запросы (IP, пользователь).
+type RateLimitKey func(r *http.Request) string
+
+// ClientIP возвращает IP клиента. При trustForwardedFor берётся последний адрес
+// из X-Forwarded-For — его добавил доверенный прокси перед API. Остальные адреса
+// присылает клиент, и подделать их может кто угодно. Включать только за одним
+// доверенным балансировщиком, который дописывает адрес в заголовок.
+func ClientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		forwarded := r.Header.Values("X-Forwarded-For")
+		if len(forwarded) > 0 {
+			last := forwarded[len(forwarded)-1]
+			if i := strings.LastIndex(last, ","); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByIP считает запросы по IP клиента.
+func KeyByIP(trustForwardedFor bool) RateLimitKey {
+	return func(r *http.Request) string {
+		return "ip:" + ClientIP(r, trustForwardedFor)
+	}
+}
+
+// KeyByUserOrIP считает запросы по ID пользователя из AuthMiddleware,
+// а для анонимных запросов — по IP.
+func KeyByUserOrIP(trustForwardedFor bool) RateLimitKey {
+	return func(r *http.Request) string {
+		if userID, ok := r.Context().Value(UserIDKey).(int); ok {
+			return fmt.Sprintf("user:%d", userID)
+		}
+		return "ip:" + ClientIP(r, trustForwardedFor)
+	}
+}
+
+// RateLimitMiddleware ограничивает частоту запросов по политике policy.
+// В ответ добавляются заголовки RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset,
+// а при превышении лимита возвращается 429 с Retry-After.
+// Если Redis недоступен, при failOpen запрос пропускается, иначе возвращается 503.
+func RateLimitMiddleware(limiter *ratelimit.Limiter, policy ratelimit.Policy, key RateLimitKey, failOpen bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := limiter.Allow(r.Context(), policy, key(r))
+			if err != nil {
+				metrics.RateLimitErrorsTotal.WithLabelValues(policy.Name).Inc()
+				logger.FromContext(r.Context()).Warningf("Rate limit check failed for policy %s: %v", policy.Name, err)
+				if failOpen {
+					next.ServeHTTP(w, r)
+					return
+				}
+				http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
+				return
+			}
+
+			reset := ceilSeconds(result.ResetAfter)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(reset))
+
+			if !result.Allowed {
+				metrics.RateLimitRejectedTotal.WithLabelValues(policy.Name).Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(max(reset, 1)))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Уровни доступа групп маршрутов.
+const (
+	AccessPublic    = "public"
+	AccessProtected = "protected"
+	AccessAdmin     = "admin"
+)
+
+// RouteRegistrar — модуль API, который сам регистрирует свои маршруты
+// в группах публичных, защищённых и админских маршрутов.
+type RouteRegistrar interface {
+	RegisterRoutes(public, protected, admin *mux.Router)
+}
+
+// RouteFunc позволяет использовать обычную функцию как RouteRegistrar.
+type RouteFunc func(public, protected, admin *mux.Router)
+
+// RegisterRoutes вызывает f.
+func (f RouteFunc) RegisterRoutes(public, protected, admin *mux.Router) {
+	f(public, protected, admin)
+}
+
+// APIOptions — параметры монтирования одной версии API.
+type APIOptions struct {
+	JWTKey []byte
+	// Accounts отклоняет токены заблокированных и удалённых пользователей; nil — без проверки.
+	Accounts AccountChecker
+	// Дополнительные middleware групп (например, ограничение частоты запросов).
+	// Для protected и admin они выполняются после проверки токена.
+	Public, Protected, Admin []mux.MiddlewareFunc
+}
+
+// APIGroups — группы маршрутов одной версии API по уровню доступа.
+type APIGroups struct {
+	Public, Protected, Admin *mux.Router
+}
+
+// MountAPI создаёт в r группы маршрутов с проверкой доступа и регистрирует в них модули.
+func MountAPI(r *mux.Router, opts APIOptions, modules ...RouteRegistrar) APIGroups {
+	public := r.PathPrefix("").Subrouter()
+	public.Use(opts.Public...)
+
+	protected := r.PathPrefix("").Subrouter()
+	protected.Use(AuthMiddleware(opts.JWTKey, opts.Accounts))
+	protected.Use(opts.Protected...)
+
+	admin := r.PathPrefix("").Subrouter()
+	admin.Use(AuthMiddleware(opts.JWTKey, opts.Accounts), AdminMiddleware)
+	admin.Use(opts.Admin...)
+
+	for _, m := range modules {
+		m.RegisterRoutes(public, protected, admin)
+	}
+	return APIGroups{Public: public, Protected: protected, Admin: admin}
+}
+
+// RouteInfo описывает зарегистрированный маршрут API.
+type RouteInfo struct {
+	Method string
+	Path   string
+	Access string
+}
+
+// Routes перечисляет маршруты всех групп, отсортированные по пути и методу.
+func (g APIGroups) Routes() []RouteInfo {
+	var routes []RouteInfo
+	for access, router := range map[string]*mux.Router{
+		AccessPublic: g.Public, AccessProtected: g.Protected, AccessAdmin: g.Admin,
+	} {
+		router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			path, err := route.GetPathTemplate()
+			if err != nil {
+				return nil
+			}
+			methods, err := route.GetMethods()
+			if err != nil {
+				return nil // промежуточный префикс без обработчика
+			}
+			for _, method := range methods {
+				routes = append(routes, RouteInfo{Method: method, Path: path, Access: access})
+			}
+			return nil
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Deprecation описывает вывод версии API из эксплуатации.
+type Deprecation struct {
+	Since     time.Time // дата объявления устаревшей; нулевая — "Deprecation: true"
+	Sunset    time.Time // дата отключения; нулевая — без заголовка Sunset
+	Successor string    // путь новой версии для заголовка Link
+}
+
+// DeprecationMiddleware добавляет к ответам заголовки Deprecation, Sunset и Link
+// (RFC 9745, RFC 8594), чтобы клиенты успели перейти на новую версию API.
+func DeprecationMiddleware(d Deprecation) mux.MiddlewareFunc {
+	deprecation := "true"
+	if !d.Since.IsZero() {
+		deprecation = "@" + strconv.FormatInt(d.Since.Unix(), 10)
+	}
+	var sunset string
+	if !d.Sunset.IsZero() {
+		sunset = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+	var link string
+	if d.Successor != "" {
+		link = "<" + strings.TrimSuffix(d.Successor, "/") + `>; rel="successor-version"`
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecation)
+			if sunset != "" {
+				w.Header().Set("Sunset", sunset)
+			}
+			if link != "" {
+				w.Header().Add("Link", link)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/alex-pyslar/petelka-api/internal/models"
 	"github.com/alex-pyslar/petelka-api/internal/service"
@@ -22,32 +24,58 @@ func NewUserHandler(s *service.UserService) *UserHandler {
 	return &UserHandler{service: s}
 }
 
+// RegisterRoutes registers user routes in the access groups.
+func (h *UserHandler) RegisterRoutes(public, protected, admin *mux.Router) {
+	admin.HandleFunc("/users", h.ListUsers).Methods("GET")
+	admin.HandleFunc("/users/{id}", h.GetUser).Methods("GET")
+	admin.HandleFunc("/users/{id}", h.UpdateUser).Methods("PUT")
+	admin.HandleFunc("/users/{id}", h.PatchUser).Methods("PATCH")
+	admin.HandleFunc("/users/{id}", h.DeleteUser).Methods("DELETE")
+	admin.HandleFunc("/users/{id}/block", h.BlockUser).Methods("POST")
+	admin.HandleFunc("/users/{id}/unblock", h.UnblockUser).Methods("POST")
+}
+
+// Snapshot returns the current user as the API renders it, for the audit log.
+func (h *UserHandler) Snapshot(ctx context.Context, id string) (interface{}, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := h.service.GetUser(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	return NewUserResponse(user), nil
+}
+
 // CreateUser godoc
 // @Summary Create a new user
 // @Description Create a new user with the input payload
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param user body models.User true "User object"
-// @Success 201 {object} models.User "User created successfully"
+// @Param user body CreateUserRequest true "User object"
+// @Success 201 {object} UserResponse "User created successfully"
 // @Failure 400 {string} string "Invalid request body"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /users [post]
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.service.CreateUser(r.Context(), &user); err != nil {
+	user := req.Model()
+	if err := h.service.CreateUser(r.Context(), user); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	setETag(w, user.Version)
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(NewUserResponse(user))
 }
 
 // GetUser godoc
@@ -56,7 +84,7 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 // @Tags users
 // @Produce json
 // @Param id path int true "User ID"
-// @Success 200 {object} models.User "User found"
+// @Success 200 {object} UserResponse "User found"
 // @Failure 400 {string} string "Invalid ID format"
 // @Failure 404 {string} string "User not found"
 // @Failure 500 {string} string "Internal server error"
@@ -85,39 +113,175 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(user)
+	setETag(w, user.Version)
+	json.NewEncoder(w).Encode(NewUserResponse(user))
 }
 
+// maxUserSearchLimit ограничивает размер страницы поиска пользователей.
+const maxUserSearchLimit = 100
+
 // ListUsers godoc
-// @Summary List all users
-// @Description Retrieve a list of all users
+// @Summary Search users
+// @Description Search users by email, name, role, status and registration date with pagination. Deleted users are listed only with status=deleted
 // @Tags users
 // @Produce json
-// @Success 200 {array} models.User "List of users"
+// @Param email query string false "Email (partial match)"
+// @Param name query string false "Name (partial match)"
+// @Param role query string false "Role"
+// @Param status query string false "active, blocked or deleted"
+// @Param created_from query string false "Registered on or after this date (YYYY-MM-DD)"
+// @Param created_to query string false "Registered on or before this date (YYYY-MM-DD)"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 20, max 100)"
+// @Success 200 {object} UserSearchResponse "Users with total count"
+// @Failure 400 {string} string "Invalid query parameters"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /users [get]
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.service.ListUsers(r.Context())
+	q := r.URL.Query()
+	filter := service.UserFilter{
+		Email:  q.Get("email"),
+		Name:   q.Get("name"),
+		Role:   q.Get("role"),
+		Status: q.Get("status"),
+		Page:   1,
+		Limit:  20,
+	}
+
+	switch filter.Status {
+	case "", models.UserStatusActive, models.UserStatusBlocked, models.UserStatusDeleted:
+	default:
+		http.Error(w, "Invalid status: must be 'active', 'blocked' or 'deleted'", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("created_from"); v != "" {
+		from, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			http.Error(w, "Invalid created_from format, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		filter.CreatedFrom = from
+	}
+	if v := q.Get("created_to"); v != "" {
+		to, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			http.Error(w, "Invalid created_to format, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		filter.CreatedTo = to.AddDate(0, 0, 1) // включая весь указанный день
+	}
+	if v := q.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			http.Error(w, "Invalid page format", http.StatusBadRequest)
+			return
+		}
+		filter.Page = page
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit format", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(limit, maxUserSearchLimit)
+	}
+
+	users, totalCount, err := h.service.SearchUsers(r.Context(), filter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(UserSearchResponse{
+		Users:      NewUserResponses(users),
+		TotalCount: totalCount,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+	})
+}
+
+// BlockUser godoc
+// @Summary Block a user
+// @Description Forbid the user to log in; existing tokens of the user stop working immediately
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} UserResponse "User blocked"
+// @Failure 400 {string} string "Invalid ID format or own account"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/{id}/block [post]
+func (h *UserHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	h.setBlocked(w, r, true)
+}
+
+// UnblockUser godoc
+// @Summary Unblock a user
+// @Tags users
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} UserResponse "User unblocked"
+// @Failure 400 {string} string "Invalid ID format"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/{id}/unblock [post]
+func (h *UserHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	h.setBlocked(w, r, false)
+}
+
+func (h *UserHandler) setBlocked(w http.ResponseWriter, r *http.Request, blocked bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	if blocked && isCurrentUser(r, id) {
+		http.Error(w, "You cannot block your own account", http.StatusBadRequest)
+		return
+	}
+
+	var user *models.User
+	if blocked {
+		user, err = h.service.BlockUser(r.Context(), id)
+	} else {
+		user, err = h.service.UnblockUser(r.Context(), id)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	setETag(w, user.Version)
+	json.NewEncoder(w).Encode(NewUserResponse(user))
+}
+
+// isCurrentUser сообщает, выполняет ли запрос сам пользователь id.
+func isCurrentUser(r *http.Request, id int) bool {
+	userID, ok := r.Context().Value(UserIDKey).(int)
+	return ok && userID == id
 }
 
 // UpdateUser godoc
 // @Summary Update an existing user
-// @Description Update user details by ID
+// @Description Replace user details by ID; an omitted password is left unchanged. The user is updated only if If-Match matches its ETag
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
-// @Param user body models.User true "User object with updated fields"
-// @Success 200 {object} models.User "User updated successfully"
+// @Param If-Match header string false "ETag of the user version being replaced; without it the update is unconditional"
+// @Param user body UpdateUserRequest true "User object with updated fields"
+// @Success 200 {object} UserResponse "User updated successfully"
 // @Failure 400 {string} string "Invalid request body or ID"
 // @Failure 404 {string} string "User not found"
+// @Failure 412 {string} string "User has been modified"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
 // @Router /users/{id} [put]
@@ -134,32 +298,100 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	user.ID = id
 
-	if err := h.service.UpdateUser(r.Context(), &user); err != nil {
+	user := req.Model(id)
+	user.Version = version
+	h.saveUser(w, r, user)
+}
+
+// PatchUser godoc
+// @Summary Partially update a user
+// @Description Apply a JSON Merge Patch (RFC 7386) to the user; "password" sets a new password. The patch is applied only if If-Match matches the ETag
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param If-Match header string true "ETag of the user version being patched"
+// @Param patch body UpdateUserRequest true "Fields to change"
+// @Success 200 {object} UserResponse "User updated successfully"
+// @Failure 400 {string} string "Invalid patch or ID"
+// @Failure 404 {string} string "User not found"
+// @Failure 412 {string} string "User has been modified"
+// @Failure 428 {string} string "If-Match header is required"
+// @Failure 415 {string} string "Unsupported patch format"
+// @Failure 500 {string} string "Internal server error"
+// @Security ApiKeyAuth
+// @Router /users/{id} [patch]
+func (h *UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := h.service.GetUser(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if version != 0 && version != current.Version {
+		writePreconditionFailed(w)
+		return
+	}
+
+	var req UpdateUserRequest
+	if !decodeMergePatch(w, r, NewUpdateUserRequest(current), &req) {
+		return
+	}
+	user := req.Model(id)
+	user.Version = current.Version
+	h.saveUser(w, r, user)
+}
+
+// saveUser сохраняет изменённого пользователя и отвечает его новой версией.
+func (h *UserHandler) saveUser(w http.ResponseWriter, r *http.Request, user *models.User) {
+	if err := h.service.UpdateUser(r.Context(), user); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "User not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrVersionConflict) {
+			writePreconditionFailed(w)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(user)
+	setETag(w, user.Version)
+	json.NewEncoder(w).Encode(NewUserResponse(user))
 }
 
 // DeleteUser godoc
 // @Summary Delete a user
-// @Description Delete a user by ID
+// @Description Soft-delete a user by ID: personal data is anonymized, orders and reviews are kept
 // @Tags users
 // @Param id path int true "User ID"
 // @Success 204 "No Content"
-// @Failure 400 {string} string "Invalid ID format"
+// @Failure 400 {string} string "Invalid ID format or own account"
 // @Failure 404 {string} string "User not found"
 // @Failure 500 {string} string "Internal server error"
 // @Security ApiKeyAuth
@@ -176,6 +408,10 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid ID format", http.StatusBadRequest)
 		return
 	}
+	if isCurrentUser(r, id) {
+		http.Error(w, "Use DELETE /me to delete your own account", http.StatusBadRequest)
+		return
+	}
 
 	if err := h.service.DeleteUser(r.Context(), id); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -0,0 +1,107 @@
+// Package idempotency хранит в Redis ответы на запросы с заголовком Idempotency-Key,
+// чтобы повтор запроса клиентом не выполнял его второй раз.
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Ошибки повторного использования ключа.
+var (
+	// ErrInProgress — запрос с этим ключом ещё выполняется.
+	ErrInProgress = errors.New("request with this idempotency key is still in progress")
+	// ErrKeyReused — ключ уже использован для запроса с другим телом.
+	ErrKeyReused = errors.New("idempotency key was used for a different request")
+)
+
+// Response — сохранённый ответ на запрос.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// record — значение ключа в Redis: отпечаток запроса и, когда запрос завершён, ответ.
+type record struct {
+	Fingerprint string    `json:"fingerprint"`
+	Response    *Response `json:"response,omitempty"`
+}
+
+// Store хранит ключи в Redis, поэтому повтор распознаётся на любой реплике API.
+type Store struct {
+	redis   *redis.Client
+	ttl     time.Duration // сколько хранится ответ
+	lockTTL time.Duration // сколько ключ считается занятым выполняющимся запросом
+}
+
+// NewStore создаёт хранилище: ответы хранятся ttl, а ключ запроса, который не
+// завершился (например, под упал), освобождается через lockTTL.
+func NewStore(redis *redis.Client, ttl, lockTTL time.Duration) *Store {
+	return &Store{redis: redis, ttl: ttl, lockTTL: lockTTL}
+}
+
+// acquire занимает ключ, если он свободен, иначе возвращает его текущее значение.
+var acquire = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current then
+	return current
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return false
+`)
+
+// Begin занимает ключ key для запроса с отпечатком fingerprint. Если ключ свободен,
+// возвращается nil — запрос нужно выполнить и вызвать Complete или Release.
+// Если запрос с этим ключом уже завершён, возвращается его сохранённый ответ.
+// Для выполняющегося запроса возвращается ErrInProgress, для другого тела — ErrKeyReused.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*Response, error) {
+	pending, err := json.Marshal(record{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	current, err := acquire.Run(ctx, s.redis, []string{redisKey(key)}, pending, s.lockTTL.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("idempotency key check failed: %w", err)
+	}
+
+	var rec record
+	if err := json.Unmarshal([]byte(current), &rec); err != nil {
+		return nil, fmt.Errorf("invalid idempotency record: %w", err)
+	}
+	if rec.Fingerprint != fingerprint {
+		return nil, ErrKeyReused
+	}
+	if rec.Response == nil {
+		return nil, ErrInProgress
+	}
+	return rec.Response, nil
+}
+
+// Complete сохраняет ответ на запрос с ключом key на время хранения.
+func (s *Store) Complete(ctx context.Context, key, fingerprint string, resp *Response) error {
+	value, err := json.Marshal(record{Fingerprint: fingerprint, Response: resp})
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(ctx, redisKey(key), value, s.ttl).Err()
+}
+
+// Release освобождает ключ запроса, ответ на который не сохраняется,
+// чтобы клиент мог повторить запрос с тем же ключом.
+func (s *Store) Release(ctx context.Context, key string) error {
+	return s.redis.Del(ctx, redisKey(key)).Err()
+}
+
+func redisKey(key string) string {
+	return "idempotency:" + key
+}
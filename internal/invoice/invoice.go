@@ -0,0 +1,271 @@
+// Package invoice формирует PDF-документы заказа: счёт на оплату и квитанцию об оплате.
+//
+// Документ строится только из переданных данных, поэтому одинаковые данные дают
+// побайтно одинаковый PDF. Шрифт DejaVu Sans встроен в бинарник: стандартные
+// шрифты PDF не содержат кириллицы.
+package invoice
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/go-pdf/fpdf"
+)
+
+// Виды документов.
+const (
+	KindInvoice = "invoice" // счёт на оплату ещё не оплаченного заказа
+	KindReceipt = "receipt" // квитанция об оплате заказа
+)
+
+// ContentType — тип содержимого документов.
+const ContentType = "application/pdf"
+
+//go:embed fonts/DejaVuSansCondensed.ttf
+var fontRegular []byte
+
+//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+var fontBold []byte
+
+const fontFamily = "DejaVu"
+
+// Shop — реквизиты магазина в шапке документа; пустые поля не выводятся.
+type Shop struct {
+	Name    string
+	INN     string
+	Address string
+	Email   string
+	Phone   string
+}
+
+// Document — данные документа заказа.
+type Document struct {
+	Kind         string
+	IssuedAt     time.Time      // дата документа: для квитанции — дата оплаты
+	Buyer        string         // имя и email покупателя
+	Order        *models.Order  // заказ с позициями
+	ProductNames map[int]string // названия товаров по ID; без названия выводится "Товар #ID"
+}
+
+// Колонки таблицы позиций, мм: ширина страницы A4 без полей — 190.
+// Сумма позиции — последняя колонка, под ней выводятся итоги.
+var columns = []struct {
+	title string
+	width float64
+	align string
+}{
+	{"№", 10, "C"},
+	{"Наименование", 75, "L"},
+	{"Кол-во", 15, "R"},
+	{"Цена, руб.", 25, "R"},
+	{"НДС, руб.", 30, "R"},
+	{"Сумма, руб.", 35, "R"},
+}
+
+// deliveryMethods — названия способов доставки в документе.
+var deliveryMethods = map[string]string{
+	models.DeliveryPickup:  "самовывоз",
+	models.DeliveryCourier: "курьер",
+	models.DeliveryPost:    "почта",
+}
+
+// Title возвращает заголовок документа, например "Счёт на оплату заказа № 12 от 02.01.2026".
+func (d *Document) Title() string {
+	kind := "Счёт на оплату"
+	if d.Kind == KindReceipt {
+		kind = "Квитанция об оплате"
+	}
+	return fmt.Sprintf("%s заказа № %d от %s", kind, d.Order.ID, d.IssuedAt.Format("02.01.2006"))
+}
+
+// VATLabel возвращает подпись строки НДС в итогах, например "В т.ч. НДС 20%",
+// или "Без НДС", если заказ оформлен без НДС.
+func (d *Document) VATLabel() string {
+	if d.Order.VATRate <= 0 {
+		return "Без НДС"
+	}
+	return fmt.Sprintf("В т.ч. НДС %d%%", d.Order.VATRate)
+}
+
+// DeliveryVAT возвращает НДС доставки: НДС заказа — сумма НДС позиций и доставки.
+func (d *Document) DeliveryVAT() money.Money {
+	vat := d.Order.VAT
+	for _, item := range d.Order.Items {
+		vat = vat.Sub(item.VAT)
+	}
+	return vat
+}
+
+// Render записывает документ doc магазина shop в w в формате PDF.
+func Render(w io.Writer, shop Shop, doc *Document) error {
+	order := doc.Order
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(10, 15, 10)
+	pdf.SetCatalogSort(true)
+	pdf.SetCreationDate(doc.IssuedAt)
+	pdf.SetTitle(doc.Title(), true)
+	pdf.SetAuthor(shop.Name, true)
+	pdf.AddUTF8FontFromBytes(fontFamily, "", fontRegular)
+	pdf.AddUTF8FontFromBytes(fontFamily, "B", fontBold)
+	pdf.AddPage()
+
+	// Реквизиты магазина
+	pdf.SetFont(fontFamily, "B", 14)
+	pdf.CellFormat(0, 7, shop.Name, "", 1, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "", 9)
+	for _, line := range []string{labeled("ИНН", shop.INN), shop.Address, joinNonEmpty(", ", shop.Phone, shop.Email)} {
+		if line != "" {
+			pdf.CellFormat(0, 5, line, "", 1, "L", false, 0, "")
+		}
+	}
+	pdf.Ln(6)
+
+	pdf.SetFont(fontFamily, "B", 13)
+	pdf.MultiCell(0, 7, doc.Title(), "", "L", false)
+	pdf.Ln(2)
+
+	// Покупатель и доставка
+	pdf.SetFont(fontFamily, "", 10)
+	delivery := deliveryMethods[order.DeliveryMethod]
+	if delivery == "" {
+		delivery = order.DeliveryMethod
+	}
+	for _, line := range []string{
+		labeled("Покупатель", doc.Buyer),
+		labeled("Доставка", delivery),
+		labeled("Адрес", formatAddress(order.ShippingAddress)),
+	} {
+		if line != "" {
+			pdf.MultiCell(0, 5, line, "", "L", false)
+		}
+	}
+	pdf.Ln(4)
+
+	// Позиции
+	pdf.SetFont(fontFamily, "B", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for _, c := range columns {
+		pdf.CellFormat(c.width, 7, c.title, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont(fontFamily, "", 9)
+	subtotal := money.Money{}
+	for i, item := range order.Items {
+		name := doc.ProductNames[item.ProductID]
+		if name == "" {
+			name = fmt.Sprintf("Товар #%d", item.ProductID)
+		}
+		amount := item.Price.Mul(int64(item.Quantity))
+		subtotal = subtotal.Add(amount)
+		cells := []string{
+			fmt.Sprint(i + 1),
+			fitText(pdf, name, columns[1].width-2),
+			fmt.Sprint(item.Quantity),
+			item.Price.String(),
+			vatCell(order, item.VAT),
+			amount.String(),
+		}
+		for j, c := range columns {
+			pdf.CellFormat(c.width, 6, cells[j], "1", 0, c.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(2)
+
+	// Итоги
+	totals := [][2]string{{"Товары", subtotal.String()}}
+	if !order.Discount.IsZero() {
+		label := "Скидка"
+		if order.PromoCode != "" {
+			label += " (промокод " + order.PromoCode + ")"
+		}
+		totals = append(totals, [2]string{label, "-" + order.Discount.String()})
+	}
+	delivery = "Доставка"
+	if order.VATRate > 0 {
+		delivery += ", в т.ч. НДС " + doc.DeliveryVAT().String()
+	}
+	totals = append(totals, [2]string{delivery, order.DeliveryCost.String()})
+	last := columns[len(columns)-1]
+	labelWidth := 0.0
+	for _, c := range columns[:len(columns)-1] {
+		labelWidth += c.width
+	}
+	for _, t := range totals {
+		pdf.CellFormat(labelWidth, 6, t[0], "", 0, "R", false, 0, "")
+		pdf.CellFormat(last.width, 6, t[1], "", 1, "R", false, 0, "")
+	}
+	pdf.SetFont(fontFamily, "B", 11)
+	pdf.CellFormat(labelWidth, 8, "Итого, руб.", "", 0, "R", false, 0, "")
+	pdf.CellFormat(last.width, 8, order.Total.String(), "", 1, "R", false, 0, "")
+	pdf.SetFont(fontFamily, "", 10)
+	vat := ""
+	if order.VATRate > 0 {
+		vat = order.VAT.String()
+	}
+	pdf.CellFormat(labelWidth, 6, doc.VATLabel(), "", 0, "R", false, 0, "")
+	pdf.CellFormat(last.width, 6, vat, "", 1, "R", false, 0, "")
+	pdf.Ln(6)
+
+	pdf.SetFont(fontFamily, "", 9)
+	if doc.Kind == KindReceipt {
+		pdf.MultiCell(0, 5, fmt.Sprintf("Заказ оплачен %s. Спасибо за покупку!", doc.IssuedAt.Format("02.01.2006 15:04")), "", "L", false)
+	} else {
+		pdf.MultiCell(0, 5, "Счёт действителен до изменения цен в каталоге. Оплатить заказ можно в личном кабинете.", "", "L", false)
+	}
+
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("failed to render invoice: %w", err)
+	}
+	return pdf.Output(w)
+}
+
+// vatCell возвращает НДС позиции для таблицы или "без НДС", если заказ оформлен без НДС.
+func vatCell(order *models.Order, vat money.Money) string {
+	if order.VATRate <= 0 {
+		return "без НДС"
+	}
+	return vat.String()
+}
+
+// fitText обрезает текст по ширине width, добавляя многоточие.
+func fitText(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "…"
+}
+
+func labeled(label, value string) string {
+	if value == "" {
+		return ""
+	}
+	return label + ": " + value
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, sep)
+}
+
+// formatAddress возвращает адрес доставки одной строкой.
+func formatAddress(a *models.ShippingAddress) string {
+	if a == nil {
+		return ""
+	}
+	return joinNonEmpty(", ", a.PostalCode, a.Region, a.City, a.Street, joinNonEmpty(", ", a.Recipient, a.Phone))
+}
@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// packageLevelCore фильтрует записи по уровню с учётом переопределений для пакетов.
+// Пакет определяется по функции, из которой вызван логгер, поэтому окончательное
+// решение принимается в Write, когда zap уже заполнил Caller.
+type packageLevelCore struct {
+	zapcore.Core
+	level     zap.AtomicLevel
+	overrides map[string]zapcore.Level
+	min       zapcore.Level // самый низкий уровень среди переопределений
+}
+
+func newPackageLevelCore(core zapcore.Core, level zap.AtomicLevel, overrides map[string]zapcore.Level) *packageLevelCore {
+	min := zapcore.InvalidLevel
+	for _, lvl := range overrides {
+		if min == zapcore.InvalidLevel || lvl < min {
+			min = lvl
+		}
+	}
+	return &packageLevelCore{Core: core, level: level, overrides: overrides, min: min}
+}
+
+func (c *packageLevelCore) Enabled(lvl zapcore.Level) bool {
+	if c.level.Enabled(lvl) {
+		return true
+	}
+	return c.min != zapcore.InvalidLevel && lvl >= c.min
+}
+
+func (c *packageLevelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &packageLevelCore{Core: c.Core.With(fields), level: c.level, overrides: c.overrides, min: c.min}
+}
+
+func (c *packageLevelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	return ce.AddCore(ent, c)
+}
+
+func (c *packageLevelCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if len(c.overrides) > 0 {
+		if lvl, ok := c.overrides[callerPackage(ent.Caller)]; ok {
+			if ent.Level < lvl {
+				return nil
+			}
+			return c.Core.Write(ent, fields)
+		}
+	}
+	if !c.level.Enabled(ent.Level) {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// callerPackage возвращает последний элемент пути пакета вызывающей функции:
+// "github.com/alex-pyslar/petelka-api/internal/service.(*ProductService).GetProduct" -> "service".
+func callerPackage(caller zapcore.EntryCaller) string {
+	fn := caller.Function
+	if i := strings.LastIndex(fn, "/"); i >= 0 {
+		fn = fn[i+1:]
+	}
+	if i := strings.Index(fn, "."); i >= 0 {
+		fn = fn[:i]
+	}
+	return fn
+}
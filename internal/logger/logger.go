@@ -1,6 +1,13 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -8,21 +15,101 @@ import (
 // Logger предоставляет интерфейс для ведения журнала с различными уровнями.
 type Logger struct {
 	*zap.Logger
+	level zap.AtomicLevel
+}
+
+// Options задаёт параметры логгера.
+type Options struct {
+	// Level — уровень по умолчанию: debug, info, warn, error.
+	Level string
+	// Format — формат вывода: json или console.
+	Format string
+	// SamplingInitial и SamplingThereafter включают сэмплирование одинаковых записей:
+	// в секунду пишутся первые SamplingInitial записей, затем каждая SamplingThereafter-я.
+	// Нулевой SamplingInitial отключает сэмплирование.
+	SamplingInitial    int
+	SamplingThereafter int
+	// PackageLevels переопределяет уровень для отдельных пакетов по последнему
+	// элементу пути импорта, например {"repository": "debug", "storage": "warn"}.
+	PackageLevels map[string]string
+	// Output — куда писать логи; по умолчанию os.Stdout.
+	Output zapcore.WriteSyncer
 }
 
-// NewLogger создает и инициализирует новый экземпляр Logger.
+// NewLogger создает и инициализирует новый экземпляр Logger с настройками по умолчанию:
+// JSON в stdout, уровень info.
 func NewLogger() (*Logger, error) {
-	config := zap.NewProductionConfig()
-	config.OutputPaths = []string{"stdout"} // Вывод в консоль
-	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	config.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	return New(Options{Level: "info", Format: "json", SamplingInitial: 100, SamplingThereafter: 100})
+}
 
-	logger, err := config.Build(zap.AddCallerSkip(1))
+// New создаёт логгер с заданными параметрами.
+func New(opts Options) (*Logger, error) {
+	level, err := zap.ParseAtomicLevel(opts.Level)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid log level %q: %w", opts.Level, err)
+	}
+
+	overrides := make(map[string]zapcore.Level, len(opts.PackageLevels))
+	for pkg, name := range opts.PackageLevels {
+		lvl, err := zapcore.ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level %q for package %s: %w", name, pkg, err)
+		}
+		overrides[pkg] = lvl
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+
+	var encoder zapcore.Encoder
+	switch opts.Format {
+	case "json", "":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case "console":
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be json or console", opts.Format)
+	}
+
+	output := opts.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	// Уровни фильтрует packageLevelCore, поэтому базовое ядро пропускает всё.
+	// Сэмплер оборачивает его снаружи: packageLevelCore добавляет в запись себя,
+	// а не вложенное ядро, и сэмплер внутри него не вызывался бы.
+	var core zapcore.Core = zapcore.NewCore(encoder, zapcore.Lock(output), zapcore.DebugLevel)
+	core = newPackageLevelCore(core, level, overrides)
+	if opts.SamplingInitial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, opts.SamplingInitial, opts.SamplingThereafter)
 	}
 
-	return &Logger{logger}, nil
+	logger := zap.New(core,
+		zap.AddCaller(),
+		zap.AddCallerSkip(1),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	)
+	return &Logger{Logger: logger, level: level}, nil
+}
+
+// LevelHandler возвращает HTTP-обработчик для просмотра (GET) и изменения (PUT)
+// уровня логирования во время работы: PUT {"level": "debug"}.
+func (l *Logger) LevelHandler() http.Handler {
+	return l.level
+}
+
+// Debug записывает отладочное сообщение.
+func (l *Logger) Debug(msg string, fields ...zap.Field) {
+	l.Logger.Debug(msg, fields...)
+}
+
+// Debugf записывает форматированное отладочное сообщение.
+func (l *Logger) Debugf(msg string, args ...interface{}) {
+	l.Logger.Sugar().Debugf(msg, args...)
 }
 
 // Info записывает информационное сообщение.
@@ -64,3 +151,35 @@ func (l *Logger) Fatal(msg string, fields ...zap.Field) {
 func (l *Logger) Fatalf(msg string, args ...interface{}) {
 	l.Logger.Sugar().Fatalf(msg, args...)
 }
+
+// With возвращает дочерний логгер, добавляющий поля fields к каждой записи.
+func (l *Logger) With(fields ...zap.Field) *Logger {
+	return &Logger{Logger: l.Logger.With(fields...), level: l.level}
+}
+
+type contextKey struct{}
+
+var defaultLogger atomic.Pointer[Logger]
+
+func init() {
+	defaultLogger.Store(&Logger{Logger: zap.NewNop(), level: zap.NewAtomicLevel()})
+}
+
+// SetDefault задаёт логгер, который FromContext возвращает для контекстов без логгера.
+func SetDefault(l *Logger) {
+	defaultLogger.Store(l)
+}
+
+// WithContext возвращает копию ctx, содержащую логгер l.
+func WithContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext возвращает логгер текущего запроса с его полями (request_id, user_id, route),
+// сохранённый middleware, или логгер по умолчанию, если в контексте его нет.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return defaultLogger.Load()
+}
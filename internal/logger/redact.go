@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// MaskEmail скрывает локальную часть адреса, оставляя первый символ и домен:
+// "ivan.petrov@mail.ru" -> "i***@mail.ru". Строка без "@" маскируется целиком.
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return maskAll(email)
+	}
+	_, first := utf8.DecodeRuneInString(email)
+	return email[:first] + "***" + email[at:]
+}
+
+// MaskPhone оставляет видимыми только последние две цифры номера: "+79991234567" -> "***67".
+func MaskPhone(phone string) string {
+	digits := make([]rune, 0, len(phone))
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) <= 2 {
+		return maskAll(phone)
+	}
+	return "***" + string(digits[len(digits)-2:])
+}
+
+// Email возвращает поле лога с замаскированным адресом.
+func Email(key, email string) zap.Field {
+	return zap.String(key, MaskEmail(email))
+}
+
+// Phone возвращает поле лога с замаскированным номером телефона.
+func Phone(key, phone string) zap.Field {
+	return zap.String(key, MaskPhone(phone))
+}
+
+func maskAll(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "***"
+}
@@ -0,0 +1,73 @@
+// Package mailer отправляет служебные письма пользователям.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+)
+
+// Message — письмо в виде простого текста.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма. Реализации должны быть безопасны для конкурентного использования.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer не отправляет письма, а пишет их в лог. Используется локально и в тестах.
+type LogMailer struct{}
+
+// Send записывает письмо в лог с замаскированным адресом получателя.
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	logger.FromContext(ctx).Infof("Mail to %s: %s", logger.MaskEmail(msg.To), msg.Subject)
+	return nil
+}
+
+// SMTPMailer отправляет письма через SMTP-сервер с авторизацией PLAIN.
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer создаёт отправителя через SMTP-сервер host:port от имени from.
+// Пустой username отключает авторизацию.
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		auth: auth,
+		from: from,
+	}
+}
+
+// Send отправляет письмо. net/smtp не поддерживает контекст, поэтому ctx используется только для логов.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid mail header")
+	}
+	body := "From: " + m.from + "\r\n" +
+		"To: " + msg.To + "\r\n" +
+		"Subject: " + msg.Subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + msg.Body
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(body)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	logger.FromContext(ctx).Infof("Sent mail to %s: %s", logger.MaskEmail(msg.To), msg.Subject)
+	return nil
+}
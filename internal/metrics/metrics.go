@@ -0,0 +1,142 @@
+// Package metrics содержит метрики Prometheus, которые экспортирует приложение на /metrics.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "petelka"
+
+// HTTP-метрики. Метка route — шаблон маршрута mux (например, /api/products/{id}),
+// а не фактический путь, чтобы число временных рядов не зависело от ID в URL.
+var (
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status code.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "Number of HTTP requests currently being served by route and method.",
+	}, []string{"route", "method"})
+)
+
+// Метрики хранилищ.
+var (
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "PostgreSQL query latency by statement name.",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"query"})
+
+	CacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Redis cache lookups by entity and result (hit or miss).",
+	}, []string{"entity", "result"})
+)
+
+// Метрики ограничения частоты запросов.
+var (
+	RateLimitRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ratelimit",
+		Name:      "rejected_total",
+		Help:      "Requests rejected with 429 by rate limit policy.",
+	}, []string{"policy"})
+
+	RateLimitErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ratelimit",
+		Name:      "errors_total",
+		Help:      "Rate limit checks that failed because Redis was unavailable, by policy.",
+	}, []string{"policy"})
+
+	IdempotencyTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "idempotency",
+		Name:      "requests_total",
+		Help:      "Requests with Idempotency-Key by outcome (new, replayed, in_progress, mismatch, error).",
+	}, []string{"outcome"})
+)
+
+// Метрики защиты входа.
+var (
+	LoginAttemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "login",
+		Name:      "attempts_total",
+		Help:      "Login attempts by result (success, failure, blocked, user_blocked).",
+	}, []string{"result"})
+
+	AccountLockoutsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "login",
+		Name:      "lockouts_total",
+		Help:      "Accounts temporarily locked after too many failed logins.",
+	})
+)
+
+// Бизнес-метрики.
+var (
+	OrdersCreatedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_created_total",
+		Help:      "Total number of orders created.",
+	})
+
+	RegistrationsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "user_registrations_total",
+		Help:      "Total number of registered users.",
+	})
+
+	PhotoUploadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "photo_uploads_total",
+		Help:      "Total number of uploaded photos by upload method (api or direct).",
+	}, []string{"method"})
+
+	PaymentsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payments_total",
+		Help:      "Total number of payment outcomes by provider and status (succeeded, canceled, failed, timeout, refunded).",
+	}, []string{"provider", "status"})
+)
+
+// ObserveQuery засекает время выполнения запроса к БД.
+// Использование: defer metrics.ObserveQuery("product.get")()
+func ObserveQuery(name string) func() {
+	start := time.Now()
+	return func() {
+		DBQueryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	}
+}
+
+// CacheHit отмечает попадание в кэш для сущности entity.
+func CacheHit(entity string) {
+	CacheRequestsTotal.WithLabelValues(entity, "hit").Inc()
+}
+
+// CacheMiss отмечает промах кэша для сущности entity.
+func CacheMiss(entity string) {
+	CacheRequestsTotal.WithLabelValues(entity, "miss").Inc()
+}
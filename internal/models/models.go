@@ -1,57 +1,203 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/money"
+)
+
+// Состояния учётной записи пользователя.
+const (
+	UserStatusActive  = "active"
+	UserStatusBlocked = "blocked" // вход и доступ к API запрещены администратором
+	UserStatusDeleted = "deleted" // персональные данные обезличены, история заказов сохранена
+)
 
 // User представляет пользователя в системе.
+// Password заполняется только при создании и смене пароля (открытый текст до хеширования)
+// и никогда не сериализуется: хеш читается отдельно через UserRepository.GetUserPassword.
 type User struct {
-	ID        int       `json:"id"`
-	Email     string    `json:"email"`
-	Name      string    `json:"name"`
-	Role      string    `json:"role"`
-	Password  string    `json:"password,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int        `json:"id"`
+	Email     string     `json:"email"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	Phone     string     `json:"phone,omitempty"`
+	Password  string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Version   int        `json:"version"`
+	BlockedAt *time.Time `json:"blocked_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Status возвращает состояние учётной записи.
+func (u *User) Status() string {
+	switch {
+	case u.DeletedAt != nil:
+		return UserStatusDeleted
+	case u.BlockedAt != nil:
+		return UserStatusBlocked
+	}
+	return UserStatusActive
 }
 
 // Product представляет товар (пряжа или готовое изделие).
 type Product struct {
-	ID              int      `json:"id"`
-	Name            string   `json:"name"`
-	Description     string   `json:"description"`
-	Price           float64  `json:"price"`
-	Images          []string `json:"images"`
-	CategoryID      int      `json:"category_id"`
-	Type            string   `json:"type"`
-	Composition     string   `json:"composition,omitempty"`
-	CountryOfOrigin string   `json:"country_of_origin,omitempty"`
-	LengthIn100g    int      `json:"length_in_100g,omitempty"`
-	Size            string   `json:"size,omitempty"`
-	GarmentLength   string   `json:"garment_length,omitempty"`
-	Color           string   `json:"color,omitempty"`
+	ID              int         `json:"id"`
+	Name            string      `json:"name"`
+	Description     string      `json:"description"`
+	Price           money.Money `json:"price"`
+	Currency        string      `json:"currency"` // валюта цены, ISO 4217
+	Images          []string    `json:"images"`
+	CategoryID      int         `json:"category_id"`
+	Type            string      `json:"type"`
+	Composition     string      `json:"composition,omitempty"`
+	CountryOfOrigin string      `json:"country_of_origin,omitempty"`
+	LengthIn100g    int         `json:"length_in_100g,omitempty"`
+	Size            string      `json:"size,omitempty"`
+	GarmentLength   string      `json:"garment_length,omitempty"`
+	Color           string      `json:"color,omitempty"`
+	Weight          int         `json:"weight,omitempty"` // граммы
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+	Version         int         `json:"version"`
 }
 
 // Category представляет категорию товаров.
 type Category struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
-	Type string `json:"type"`
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
 }
 
-// Order представляет заказ, сделанный пользователем.
+// Статусы заказа, которые выставляет сервер. Остальные статусы задаются клиентом.
+const (
+	OrderStatusNew      = "new"      // оформлен покупателем, ожидает оплаты
+	OrderStatusPaid     = "paid"     // оплачен через платёжного провайдера
+	OrderStatusRefunded = "refunded" // деньги возвращены покупателю
+)
+
+// Способы доставки заказа.
+const (
+	DeliveryPickup  = "pickup"  // самовывоз, адрес не нужен
+	DeliveryCourier = "courier" // курьер по адресу
+	DeliveryPost    = "post"    // почтовое отправление
+)
+
+// Order представляет заказ, сделанный пользователем. Total включает DeliveryCost
+// за вычетом Discount — скидки по промокоду PromoCode. ShippingAddress — копия адреса на момент оформления, изменения адресной книги
+// на заказ не влияют. Items заполняется только при оформлении заказа. VAT — НДС по ставке
+// VATRate, включённый в Total: сумма НДС позиций и доставки.
 type Order struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	Total     float64   `json:"total"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              int              `json:"id"`
+	UserID          int              `json:"user_id"`
+	Total           money.Money      `json:"total"`
+	Currency        string           `json:"currency"` // валюта всех сумм заказа, ISO 4217
+	Status          string           `json:"status"`
+	DeliveryMethod  string           `json:"delivery_method"`
+	DeliveryCost    money.Money      `json:"delivery_cost"`
+	PromoCode       string           `json:"promo_code,omitempty"`
+	Discount        money.Money      `json:"discount"`
+	VATRate         int              `json:"vat_rate"` // проценты; 0 — без НДС
+	VAT             money.Money      `json:"vat"`
+	ShippingAddress *ShippingAddress `json:"shipping_address,omitempty"`
+	Items           []OrderItem      `json:"items,omitempty"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	Version         int              `json:"version"`
 }
 
 // OrderItem представляет отдельный товар в заказе.
 type OrderItem struct {
-	ID        int     `json:"id"`
-	OrderID   int     `json:"order_id"`
-	ProductID int     `json:"product_id"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	ID        int         `json:"id"`
+	OrderID   int         `json:"order_id"`
+	ProductID int         `json:"product_id"`
+	Quantity  int         `json:"quantity"`
+	Price     money.Money `json:"price"`
+	Discount  money.Money `json:"discount"` // доля скидки заказа, приходящаяся на позицию
+	VAT       money.Money `json:"vat"`      // НДС, включённый в стоимость позиции после скидки
+}
+
+// ShippingAddress — адрес доставки.
+type ShippingAddress struct {
+	Recipient  string `json:"recipient"`
+	Phone      string `json:"phone"`
+	Region     string `json:"region"`
+	City       string `json:"city"`
+	Street     string `json:"street"` // улица, дом, квартира
+	PostalCode string `json:"postal_code,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+}
+
+// Address — адрес из адресной книги пользователя.
+type Address struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
+	ShippingAddress
+	IsDefault bool      `json:"is_default"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
+}
+
+// DeliveryRate — правило стоимости доставки способом Method в регион Region
+// (пустой — любой регион) для заказов весом до MaxWeight граммов (nil — без ограничения).
+type DeliveryRate struct {
+	ID        int         `json:"id"`
+	Method    string      `json:"method"`
+	Region    string      `json:"region,omitempty"`
+	MaxWeight *int        `json:"max_weight,omitempty"`
+	Price     money.Money `json:"price"`
+	Currency  string      `json:"currency"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Version   int         `json:"version"`
+}
+
+// Виды скидки по промокоду.
+const (
+	PromoPercent      = "percent"       // PercentBP сотых процента от стоимости подходящих товаров
+	PromoFixed        = "fixed"         // Amount, но не больше стоимости подходящих товаров
+	PromoFreeShipping = "free_shipping" // бесплатная доставка
+)
+
+// PromoCode — промокод на скидку. Код действует с StartsAt до EndsAt (nil — без
+// ограничения), не более MaxUses раз всего и MaxUsesPerUser раз на покупателя
+// (nil — без ограничения), для заказов с товарами не дешевле MinOrderTotal.
+// Если заданы ProductIDs или CategoryIDs, скидка считается только от этих товаров.
+type PromoCode struct {
+	ID             int         `json:"id"`
+	Code           string      `json:"code"`
+	Kind           string      `json:"kind"`
+	PercentBP      int64       `json:"percent_bp,omitempty"` // для вида percent, в сотых процента (1250 — 12.5%)
+	Amount         money.Money `json:"amount"`               // для вида fixed
+	StartsAt       *time.Time  `json:"starts_at,omitempty"`
+	EndsAt         *time.Time  `json:"ends_at,omitempty"`
+	MaxUses        *int        `json:"max_uses,omitempty"`
+	MaxUsesPerUser *int        `json:"max_uses_per_user,omitempty"`
+	MinOrderTotal  money.Money `json:"min_order_total"`
+	Currency       string      `json:"currency"` // валюта Amount и MinOrderTotal
+	ProductIDs     []int       `json:"product_ids"`
+	CategoryIDs    []int       `json:"category_ids"`
+	IsActive       bool        `json:"is_active"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+	Version        int         `json:"version"`
+}
+
+// PromoCodeStats — статистика использования промокода в оформленных заказах.
+type PromoCodeStats struct {
+	PromoCodeID   int         `json:"promo_code_id"`
+	Uses          int         `json:"uses"`
+	Users         int         `json:"users"`
+	DiscountTotal money.Money `json:"discount_total"`
+	OrdersTotal   money.Money `json:"orders_total"`
+	FirstUsedAt   *time.Time  `json:"first_used_at,omitempty"`
+	LastUsedAt    *time.Time  `json:"last_used_at,omitempty"`
 }
 
 // Comment представляет комментарий к товару.
@@ -61,4 +207,70 @@ type Comment struct {
 	UserID    int       `json:"user_id"`
 	Text      string    `json:"text"`
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int       `json:"version"`
+}
+
+// Photo представляет загруженную фотографию в объектном хранилище.
+// UploadedBy равен 0 для объектов, загруженных до появления учёта фотографий.
+type Photo struct {
+	ObjectName  string    `json:"object_name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	UploadedBy  int       `json:"uploaded_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ProductIDs  []int     `json:"product_ids"`
+}
+
+// LoginAttempt — запись журнала попыток входа.
+// UserID равен нулю, если пользователь с таким email не найден.
+type LoginAttempt struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id,omitempty"`
+	Email     string    `json:"email"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditEntry — запись журнала аудита об изменении, выполненном администратором.
+// Changes содержит изменившиеся поля ресурса: {"price": {"before": 100, "after": 120}}.
+type AuditEntry struct {
+	ID           int64           `json:"id"`
+	ActorID      int             `json:"actor_id,omitempty"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id,omitempty"`
+	Changes      json.RawMessage `json:"changes"`
+	IP           string          `json:"ip"`
+	RequestID    string          `json:"request_id,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// Статусы попытки оплаты заказа.
+const (
+	PaymentStatusPending   = "pending"   // ожидает оплаты или ответа провайдера
+	PaymentStatusSucceeded = "succeeded" // оплачен
+	PaymentStatusCanceled  = "canceled"  // отменён покупателем или провайдером
+	PaymentStatusFailed    = "failed"    // провайдер отклонил создание платежа
+	PaymentStatusRefunded  = "refunded"  // деньги возвращены
+)
+
+// Payment — попытка оплаты заказа через платёжного провайдера.
+// ProviderPaymentID пуст, пока провайдер не подтвердил создание платежа.
+type Payment struct {
+	ID                int         `json:"id"`
+	OrderID           int         `json:"order_id"`
+	Provider          string      `json:"provider"`
+	ProviderPaymentID string      `json:"provider_payment_id,omitempty"`
+	IdempotenceKey    string      `json:"idempotence_key"`
+	Status            string      `json:"status"`
+	Amount            money.Money `json:"amount"`
+	Currency          string      `json:"currency"`
+	ConfirmationURL   string      `json:"confirmation_url,omitempty"`
+	Error             string      `json:"error,omitempty"`
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
 }
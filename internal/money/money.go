@@ -0,0 +1,319 @@
+// Package money хранит денежные суммы в минимальных единицах валюты (копейках),
+// чтобы суммы заказов не расходились с суммами позиций из-за округления float64.
+//
+// Правила округления:
+//   - скидка в процентах (Percent) округляется до копейки по математическим правилам:
+//     половина копейки — от нуля;
+//   - скидка на весь заказ распределяется по позициям через Allocate, чтобы сумма
+//     скидок позиций точно совпала со скидкой заказа;
+//   - НДС считается отдельно для каждой строки заказа (позиции и доставки) от её суммы
+//     после скидки. Цены включают НДС, поэтому он выделяется из суммы (VAT):
+//     сумма × ставка / (100 + ставка), с округлением до копейки, половина — от нуля.
+//     НДС заказа — сумма НДС строк, от итога заказа он заново не вычисляется.
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency — валюта магазина; Money с пустой валютой считается в ней.
+const DefaultCurrency = "RUB"
+
+// Scale — число минимальных единиц в основной (копеек в рубле).
+const Scale = 100
+
+// maxIntegerDigits ограничивает целую часть при разборе, чтобы сумма в копейках поместилась в int64.
+const maxIntegerDigits = 16
+
+// ErrInvalidAmount возвращается для строки, которая не является суммой с точностью до копейки.
+var ErrInvalidAmount = errors.New("invalid money amount")
+
+// Money — денежная сумма. В JSON записывается строкой с двумя знаками после точки
+// ("350.50"), а читается из строки или числа; в базе данных хранится в копейках (BIGINT).
+// Валюта документа (товара, заказа, платежа) хранится и передаётся рядом с суммами —
+// в столбце и поле currency.
+type Money struct {
+	Amount   int64  // минимальные единицы валюты
+	Currency string // код ISO 4217; пусто — DefaultCurrency
+}
+
+// New возвращает сумму amount копеек в валюте магазина.
+func New(amount int64) Money {
+	return Money{Amount: amount}
+}
+
+// Parse разбирает сумму в основных единицах: "350", "350.5", "-12.05".
+// Более двух знаков после точки — ошибка: сумма не округляется молча.
+func Parse(s string) (Money, error) {
+	str := s
+	negative := strings.HasPrefix(str, "-")
+	if negative {
+		str = str[1:]
+	}
+	whole, frac, hasPoint := strings.Cut(str, ".")
+	if whole == "" || len(whole) > maxIntegerDigits || (hasPoint && (frac == "" || len(frac) > 2)) ||
+		!isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	units, _ := strconv.ParseInt(whole, 10, 64)
+	cents, _ := strconv.ParseInt((frac + "00")[:2], 10, 64)
+	amount := units*Scale + cents
+	if negative {
+		amount = -amount
+	}
+	return New(amount), nil
+}
+
+// MustParse — Parse, который паникует при ошибке. Для констант и тестов.
+func MustParse(s string) Money {
+	m, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String возвращает сумму в основных единицах с двумя знаками после точки.
+func (m Money) String() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+	units, cents := amount/Scale, amount%Scale
+	if units < 0 {
+		units = -units
+	}
+	if cents < 0 {
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units, cents)
+}
+
+// CurrencyCode возвращает валюту суммы с учётом DefaultCurrency.
+func (m Money) CurrencyCode() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+// IsZero сообщает, что сумма равна нулю.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive сообщает, что сумма больше нуля.
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// IsNegative сообщает, что сумма меньше нуля.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Cmp сравнивает суммы одной валюты: -1, если m меньше o, 0 — если равны, +1 — если больше.
+func (m Money) Cmp(o Money) int {
+	m.currencyWith(o)
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	}
+	return 0
+}
+
+// Add возвращает m + o. Суммы должны быть в одной валюте.
+func (m Money) Add(o Money) Money {
+	return Money{Amount: m.Amount + o.Amount, Currency: m.currencyWith(o)}
+}
+
+// Sub возвращает m - o. Суммы должны быть в одной валюте.
+func (m Money) Sub(o Money) Money {
+	return Money{Amount: m.Amount - o.Amount, Currency: m.currencyWith(o)}
+}
+
+// Mul возвращает сумму m, умноженную на количество n.
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Percent возвращает долю суммы в basisPoints сотых процента (1250 — 12.5%),
+// округлённую до копейки (половина — от нуля).
+func (m Money) Percent(basisPoints int64) Money {
+	return Money{Amount: mulDivRound(m.Amount, basisPoints, 100*Scale), Currency: m.Currency}
+}
+
+// VAT возвращает НДС по ставке ratePercent процентов, включённый в сумму m:
+// m × ratePercent / (100 + ratePercent), округлённый до копейки (половина — от нуля).
+func (m Money) VAT(ratePercent int64) Money {
+	if ratePercent <= 0 {
+		return Money{Currency: m.Currency}
+	}
+	return Money{Amount: mulDivRound(m.Amount, ratePercent, 100+ratePercent), Currency: m.Currency}
+}
+
+// Allocate делит сумму m на части пропорционально весам weights методом наибольших
+// остатков: сумма частей всегда равна m, а каждая часть отличается от точной доли
+// меньше чем на копейку. При нулевой сумме весов сумма делится поровну.
+func (m Money) Allocate(weights []int64) []Money {
+	parts := make([]Money, len(weights))
+	if len(weights) == 0 {
+		return parts
+	}
+	var total int64
+	for _, w := range weights {
+		if w < 0 {
+			panic("money: negative allocation weight")
+		}
+		total += w
+	}
+	if total == 0 {
+		weights = make([]int64, len(weights))
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = int64(len(weights))
+	}
+
+	amount := m.Amount
+	if amount < 0 {
+		amount = -amount
+	}
+	remainders := make([]*big.Int, len(weights))
+	allocated := int64(0)
+	bigTotal := big.NewInt(total)
+	for i, w := range weights {
+		q, r := new(big.Int).QuoRem(new(big.Int).Mul(big.NewInt(amount), big.NewInt(w)), bigTotal, new(big.Int))
+		parts[i] = Money{Amount: q.Int64(), Currency: m.Currency}
+		remainders[i] = r
+		allocated += q.Int64()
+	}
+	// Оставшиеся копейки — частям с наибольшими остатками, при равенстве — первым
+	for left := amount - allocated; left > 0; left-- {
+		best := -1
+		for i, r := range remainders {
+			if r.Sign() > 0 && (best < 0 || r.Cmp(remainders[best]) > 0) {
+				best = i
+			}
+		}
+		parts[best].Amount++
+		remainders[best].SetInt64(0)
+	}
+	if m.Amount < 0 {
+		for i := range parts {
+			parts[i].Amount = -parts[i].Amount
+		}
+	}
+	return parts
+}
+
+// Min возвращает меньшую из сумм одной валюты.
+func Min(a, b Money) Money {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+// Sum возвращает сумму amounts. Суммы должны быть в одной валюте.
+func Sum(amounts ...Money) Money {
+	var total Money
+	for _, m := range amounts {
+		total = total.Add(m)
+	}
+	return total
+}
+
+// currencyWith возвращает общую валюту m и o; сложение сумм в разных валютах — ошибка программы.
+func (m Money) currencyWith(o Money) string {
+	switch {
+	case m.Currency == "":
+		return o.Currency
+	case o.Currency == "" || o.Currency == m.Currency:
+		return m.Currency
+	}
+	panic(fmt.Sprintf("money: currency mismatch %s and %s", m.Currency, o.Currency))
+}
+
+// mulDivRound возвращает a*b/c, округлённое до целого (половина — от нуля), без переполнения.
+func mulDivRound(a, b, c int64) int64 {
+	num := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	den := big.NewInt(c)
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	// |r| * 2 >= |c| — округляем от нуля
+	if new(big.Int).Abs(new(big.Int).Lsh(r, 1)).Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign()*den.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+// MarshalJSON записывает сумму строкой: "350.50".
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON читает сумму из строки "350.50" или числа 350.5. Число разбирается
+// по записи, без преобразования во float64; null оставляет сумму без изменений.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	text := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+	parsed, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value записывает сумму в базу данных в копейках.
+func (m Money) Value() (driver.Value, error) {
+	return m.Amount, nil
+}
+
+// Scan читает сумму в копейках из столбца BIGINT.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		*m = New(v)
+	case []byte:
+		amount, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not an amount in minor units", ErrInvalidAmount, v)
+		}
+		*m = New(amount)
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+	return nil
+}
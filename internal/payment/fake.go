@@ -0,0 +1,197 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/money"
+)
+
+// FakeProvider — провайдер без внешних вызовов для локального запуска и тестов.
+// Платежи хранятся в памяти и остаются в статусе pending, пока тест не завершит их
+// через Complete; FailNext и SetDelay позволяют проверить ошибки и таймауты провайдера.
+type FakeProvider struct {
+	secret     []byte
+	confirmURL string
+
+	mu       sync.Mutex
+	seq      int
+	payments map[string]*Payment
+	byKey    map[string]string // ключ идемпотентности → ID платежа
+	refunds  map[string]money.Money
+	failNext error
+	delay    time.Duration
+}
+
+// NewFakeProvider создаёт фиктивного провайдера. Уведомления подписываются ключом
+// webhookSecret, ссылка на оплату строится из confirmURL с параметром ?payment=.
+func NewFakeProvider(webhookSecret, confirmURL string) *FakeProvider {
+	return &FakeProvider{
+		secret:     []byte(webhookSecret),
+		confirmURL: confirmURL,
+		payments:   make(map[string]*Payment),
+		byKey:      make(map[string]string),
+		refunds:    make(map[string]money.Money),
+	}
+}
+
+// Name возвращает "fake".
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// FailNext заставляет следующий вызов CreatePayment или Refund вернуть err.
+func (p *FakeProvider) FailNext(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failNext = err
+}
+
+// SetDelay задерживает каждый вызов на d или до отмены контекста (для проверки таймаутов).
+func (p *FakeProvider) SetDelay(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.delay = d
+}
+
+// CreatePayment создаёт платёж в статусе pending. Повтор с тем же ключом
+// идемпотентности возвращает ранее созданный платёж.
+func (p *FakeProvider) CreatePayment(ctx context.Context, req CreateRequest) (*Payment, error) {
+	if err := p.call(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.byKey[req.IdempotenceKey]; ok && req.IdempotenceKey != "" {
+		return p.copy(id), nil
+	}
+	p.seq++
+	id := fmt.Sprintf("fake-%d", p.seq)
+	p.payments[id] = &Payment{
+		ID:              id,
+		Status:          StatusPending,
+		Amount:          money.Money{Amount: req.Amount.Amount, Currency: req.Currency},
+		Currency:        req.Currency,
+		ConfirmationURL: p.confirmURL + "?payment=" + url.QueryEscape(id),
+		Metadata:        req.Metadata,
+	}
+	if req.IdempotenceKey != "" {
+		p.byKey[req.IdempotenceKey] = id
+	}
+	return p.copy(id), nil
+}
+
+// GetPayment возвращает текущее состояние платежа.
+func (p *FakeProvider) GetPayment(ctx context.Context, id string) (*Payment, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.payments[id]; !ok {
+		return nil, ErrNotFound
+	}
+	return p.copy(id), nil
+}
+
+// Refund запоминает возврат по оплаченному платежу.
+func (p *FakeProvider) Refund(ctx context.Context, paymentID string, amount money.Money, idempotenceKey string) error {
+	if err := p.call(ctx); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payment, ok := p.payments[paymentID]
+	if !ok {
+		return ErrNotFound
+	}
+	if payment.Status != StatusSucceeded {
+		return fmt.Errorf("fake: payment %s is %s, only succeeded payments can be refunded", paymentID, payment.Status)
+	}
+	p.refunds[paymentID] = amount
+	return nil
+}
+
+// Refunded возвращает сумму возврата по платежу.
+func (p *FakeProvider) Refunded(paymentID string) (money.Money, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	amount, ok := p.refunds[paymentID]
+	return amount, ok
+}
+
+// ParseWebhook проверяет подпись уведомления и разбирает его.
+func (p *FakeProvider) ParseWebhook(_ context.Context, header http.Header, body []byte) (*Event, error) {
+	return parseNotification(p.secret, header, body)
+}
+
+// Complete переводит платёж в status (StatusSucceeded или StatusCanceled) и возвращает
+// подписанное уведомление об этом: тело и значение заголовка SignatureHeader.
+func (p *FakeProvider) Complete(paymentID, status string) (body []byte, signature string, err error) {
+	p.mu.Lock()
+	payment, ok := p.payments[paymentID]
+	if !ok {
+		p.mu.Unlock()
+		return nil, "", ErrNotFound
+	}
+	payment.Status = status
+	n := notification{
+		Type:  "notification",
+		Event: "payment." + status,
+		Object: paymentObject{
+			ID:       payment.ID,
+			Status:   payment.Status,
+			Amount:   formatAmount(payment.Amount, payment.Currency),
+			Metadata: payment.Metadata,
+		},
+	}
+	p.mu.Unlock()
+
+	body, err = json.Marshal(n)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, Sign(p.secret, body), nil
+}
+
+// call выполняет задержку и возвращает ошибку, заданную через FailNext.
+func (p *FakeProvider) call(ctx context.Context) error {
+	if err := p.wait(ctx); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.failNext
+	p.failNext = nil
+	return err
+}
+
+// wait ждёт заданную задержку или отмену контекста.
+func (p *FakeProvider) wait(ctx context.Context) error {
+	p.mu.Lock()
+	delay := p.delay
+	p.mu.Unlock()
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// copy возвращает копию платежа, чтобы вызывающий не менял внутреннее состояние.
+func (p *FakeProvider) copy(id string) *Payment {
+	cp := *p.payments[id]
+	return &cp
+}
@@ -0,0 +1,187 @@
+// Package payment принимает оплату заказов через платёжных провайдеров.
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alex-pyslar/petelka-api/internal/money"
+)
+
+// Статусы платежа у провайдера.
+const (
+	StatusPending   = "pending"   // ожидает оплаты покупателем
+	StatusSucceeded = "succeeded" // оплачен
+	StatusCanceled  = "canceled"  // отменён или отклонён
+)
+
+// SignatureHeader — заголовок с подписью уведомления фиктивного провайдера: HMAC-SHA256 тела в hex.
+const SignatureHeader = "X-Webhook-Signature"
+
+var (
+	// ErrInvalidSignature возвращается, если подпись уведомления отсутствует или не совпадает.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrInvalidNotification возвращается для уведомления, которое не удалось разобрать.
+	ErrInvalidNotification = errors.New("invalid webhook notification")
+	// ErrNotFound возвращается, если провайдер не знает платёж с таким ID.
+	ErrNotFound = errors.New("payment not found")
+)
+
+// CreateRequest — параметры нового платежа.
+type CreateRequest struct {
+	Amount      money.Money
+	Currency    string
+	Description string
+	// ReturnURL — страница, на которую провайдер вернёт покупателя после оплаты.
+	ReturnURL string
+	// IdempotenceKey — повторный запрос с тем же ключом возвращает тот же платёж.
+	IdempotenceKey string
+	// Metadata передаётся провайдеру и возвращается в уведомлениях.
+	Metadata map[string]string
+}
+
+// Payment — платёж на стороне провайдера.
+type Payment struct {
+	ID              string
+	Status          string
+	Amount          money.Money
+	Currency        string
+	ConfirmationURL string // страница оплаты для покупателя
+	Metadata        map[string]string
+}
+
+// Event — проверенное уведомление провайдера об изменении статуса платежа.
+// Статус и сумма берутся из источника, которому провайдер доверяет: из
+// подписанного тела или из ответа API провайдера.
+type Event struct {
+	PaymentID string
+	Status    string
+	Amount    money.Money // сумма платежа с валютой из уведомления
+	Metadata  map[string]string
+}
+
+// PaymentProvider создаёт платежи, проверяет их статус и возвращает деньги.
+// Реализации должны быть безопасны для конкурентного использования.
+type PaymentProvider interface {
+	// Name возвращает имя провайдера, которое сохраняется вместе с платежом.
+	Name() string
+	CreatePayment(ctx context.Context, req CreateRequest) (*Payment, error)
+	GetPayment(ctx context.Context, id string) (*Payment, error)
+	// Refund возвращает amount по оплаченному платежу. Повтор с тем же ключом не создаёт второй возврат.
+	Refund(ctx context.Context, paymentID string, amount money.Money, idempotenceKey string) error
+	// ParseWebhook проверяет подлинность уведомления и разбирает его. Если уведомления
+	// провайдера не подписаны, статус и сумма платежа запрашиваются у его API.
+	// Для уведомлений не о платежах (например, о возвратах) возвращается nil без ошибки.
+	ParseWebhook(ctx context.Context, header http.Header, body []byte) (*Event, error)
+}
+
+// Sign возвращает подпись тела уведомления ключом secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature сравнивает подпись из заголовка с ожидаемой за постоянное время.
+// С пустым ключом подпись может посчитать кто угодно, поэтому такие уведомления отклоняются.
+func verifySignature(secret []byte, header http.Header, body []byte) error {
+	if len(secret) == 0 {
+		return ErrInvalidSignature
+	}
+	signature, err := hex.DecodeString(strings.TrimSpace(header.Get(SignatureHeader)))
+	if err != nil || len(signature) == 0 {
+		return ErrInvalidSignature
+	}
+	expected, _ := hex.DecodeString(Sign(secret, body))
+	if !hmac.Equal(signature, expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// notification — тело уведомления в формате YooKassa:
+// {"type": "notification", "event": "payment.succeeded", "object": {...}}.
+type notification struct {
+	Type   string        `json:"type"`
+	Event  string        `json:"event"`
+	Object paymentObject `json:"object"`
+}
+
+// paymentObject — платёж в API и уведомлениях YooKassa.
+type paymentObject struct {
+	ID           string            `json:"id"`
+	Status       string            `json:"status"`
+	Amount       amount            `json:"amount"`
+	Confirmation *confirmation     `json:"confirmation,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+type amount struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+type confirmation struct {
+	Type            string `json:"type"`
+	ReturnURL       string `json:"return_url,omitempty"`
+	ConfirmationURL string `json:"confirmation_url,omitempty"`
+}
+
+// formatAmount записывает сумму так, как её принимает API: "100.00".
+func formatAmount(value money.Money, currency string) amount {
+	return amount{Value: value.String(), Currency: currency}
+}
+
+// toPayment преобразует платёж из формата API.
+func (o paymentObject) toPayment() *Payment {
+	p := &Payment{ID: o.ID, Status: o.Status, Currency: o.Amount.Currency, Metadata: o.Metadata}
+	p.Amount, _ = money.Parse(o.Amount.Value)
+	p.Amount.Currency = o.Amount.Currency
+	if o.Confirmation != nil {
+		p.ConfirmationURL = o.Confirmation.ConfirmationURL
+	}
+	return p
+}
+
+// parseNotification проверяет подпись и разбирает уведомление о платеже.
+func parseNotification(secret []byte, header http.Header, body []byte) (*Event, error) {
+	if err := verifySignature(secret, header, body); err != nil {
+		return nil, err
+	}
+	n, err := decodeNotification(body)
+	if err != nil || n == nil {
+		return nil, err
+	}
+	event := &Event{PaymentID: n.Object.ID, Status: n.Object.Status, Metadata: n.Object.Metadata}
+	if n.Object.Amount.Value != "" {
+		amount, err := money.Parse(n.Object.Amount.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+		}
+		event.Amount = money.Money{Amount: amount.Amount, Currency: n.Object.Amount.Currency}
+	}
+	return event, nil
+}
+
+// decodeNotification разбирает тело уведомления без проверки подлинности.
+// Для уведомлений не о платежах возвращается nil без ошибки.
+func decodeNotification(body []byte) (*notification, error) {
+	var n notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+	}
+	if !strings.HasPrefix(n.Event, "payment.") {
+		return nil, nil
+	}
+	if n.Object.ID == "" || n.Object.Status == "" {
+		return nil, fmt.Errorf("%w: payment id and status are required", ErrInvalidNotification)
+	}
+	return &n, nil
+}
@@ -0,0 +1,151 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/alex-pyslar/petelka-api/internal/money"
+)
+
+// DefaultYooKassaURL — адрес API YooKassa.
+const DefaultYooKassaURL = "https://api.yookassa.ru/v3"
+
+// YooKassaProvider принимает оплату через API YooKassa: платёж создаётся с
+// подтверждением через redirect и автоматическим списанием, покупатель оплачивает
+// его на странице confirmation_url, а о результате сообщает уведомление.
+// YooKassa не подписывает уведомления, поэтому их тело не считается достоверным:
+// статус и сумма платежа перепроверяются запросом к API.
+type YooKassaProvider struct {
+	apiURL    string
+	shopID    string
+	secretKey string
+	client    *http.Client
+}
+
+// NewYooKassaProvider создаёт провайдера для магазина shopID.
+// Пустой apiURL означает DefaultYooKassaURL.
+func NewYooKassaProvider(apiURL, shopID, secretKey string) *YooKassaProvider {
+	if apiURL == "" {
+		apiURL = DefaultYooKassaURL
+	}
+	return &YooKassaProvider{
+		apiURL:    strings.TrimSuffix(apiURL, "/"),
+		shopID:    shopID,
+		secretKey: secretKey,
+		client:    &http.Client{},
+	}
+}
+
+// Name возвращает "yookassa".
+func (p *YooKassaProvider) Name() string {
+	return "yookassa"
+}
+
+// CreatePayment создаёт платёж и возвращает ссылку на страницу оплаты.
+func (p *YooKassaProvider) CreatePayment(ctx context.Context, req CreateRequest) (*Payment, error) {
+	body := map[string]interface{}{
+		"amount":       formatAmount(req.Amount, req.Currency),
+		"capture":      true,
+		"confirmation": confirmation{Type: "redirect", ReturnURL: req.ReturnURL},
+		"description":  req.Description,
+		"metadata":     req.Metadata,
+	}
+	var out paymentObject
+	if err := p.do(ctx, "POST", "/payments", req.IdempotenceKey, body, &out); err != nil {
+		return nil, err
+	}
+	return out.toPayment(), nil
+}
+
+// GetPayment возвращает текущее состояние платежа.
+func (p *YooKassaProvider) GetPayment(ctx context.Context, id string) (*Payment, error) {
+	var out paymentObject
+	if err := p.do(ctx, "GET", "/payments/"+url.PathEscape(id), "", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.toPayment(), nil
+}
+
+// Refund создаёт возврат по оплаченному платежу.
+func (p *YooKassaProvider) Refund(ctx context.Context, paymentID string, value money.Money, idempotenceKey string) error {
+	payment, err := p.GetPayment(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{
+		"payment_id": paymentID,
+		"amount":     formatAmount(value, payment.Currency),
+	}
+	return p.do(ctx, "POST", "/refunds", idempotenceKey, body, nil)
+}
+
+// ParseWebhook разбирает уведомление и запрашивает упомянутый в нём платёж через
+// GET /payments/{id}. Событие содержит только статус, сумму и метаданные из ответа
+// API, так что поддельное уведомление не может изменить состояние платежа.
+// Платёж, неизвестный провайдеру, возвращает ErrInvalidNotification.
+func (p *YooKassaProvider) ParseWebhook(ctx context.Context, _ http.Header, body []byte) (*Event, error) {
+	n, err := decodeNotification(body)
+	if err != nil || n == nil {
+		return nil, err
+	}
+	current, err := p.GetPayment(ctx, n.Object.ID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: unknown payment %s", ErrInvalidNotification, n.Object.ID)
+		}
+		return nil, fmt.Errorf("failed to verify payment %s: %w", n.Object.ID, err)
+	}
+	return &Event{PaymentID: current.ID, Status: current.Status, Amount: current.Amount, Metadata: current.Metadata}, nil
+}
+
+// do выполняет запрос к API с Basic-авторизацией магазина и декодирует ответ в out.
+func (p *YooKassaProvider) do(ctx context.Context, method, path, idempotenceKey string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.shopID, p.secretKey)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotenceKey != "" {
+		req.Header.Set("Idempotence-Key", idempotenceKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code        string `json:"code"`
+			Description string `json:"description"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&apiErr)
+		return fmt.Errorf("yookassa %s %s: status %d: %s %s", method, path, resp.StatusCode, apiErr.Code, apiErr.Description)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
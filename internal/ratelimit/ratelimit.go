@@ -0,0 +1,87 @@
+// Package ratelimit реализует ограничение частоты запросов по скользящему окну в Redis.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Policy описывает лимит: не более Limit запросов за Window.
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// Result — решение лимитера по одному запросу.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter — через сколько освободится ближайший слот в окне.
+	ResetAfter time.Duration
+}
+
+// Limiter считает запросы в Redis, поэтому лимит общий для всех реплик API.
+type Limiter struct {
+	redis *redis.Client
+}
+
+// NewLimiter создаёт лимитер поверх клиента Redis.
+func NewLimiter(redis *redis.Client) *Limiter {
+	return &Limiter{redis: redis}
+}
+
+// slidingWindow хранит в отсортированном множестве метки времени запросов за окно.
+// Время берётся из Redis, чтобы часы разных подов не влияли на подсчёт.
+// Возвращает {разрешён, число запросов в окне, метку самого старого запроса в мс}.
+var slidingWindow = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], 0, now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], window)
+	count = count + 1
+	allowed = 1
+end
+
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local oldestScore = now
+if oldest[2] then
+	oldestScore = tonumber(oldest[2])
+end
+return {allowed, count, now, oldestScore}
+`)
+
+// Allow учитывает запрос с ключом key (IP или ID пользователя) по политике policy.
+func (l *Limiter) Allow(ctx context.Context, policy Policy, key string) (*Result, error) {
+	redisKey := fmt.Sprintf("ratelimit:%s:%s", policy.Name, key)
+	values, err := slidingWindow.Run(ctx, l.redis, []string{redisKey},
+		policy.Window.Milliseconds(), policy.Limit, uuid.New().String(),
+	).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("rate limit check failed: %w", err)
+	}
+
+	allowed, count, now, oldest := values[0] == 1, int(values[1]), values[2], values[3]
+	resetAfter := time.Duration(oldest+policy.Window.Milliseconds()-now) * time.Millisecond
+	if resetAfter < 0 {
+		resetAfter = 0
+	}
+	return &Result{
+		Allowed:    allowed,
+		Limit:      policy.Limit,
+		Remaining:  max(policy.Limit-count, 0),
+		ResetAfter: resetAfter,
+	}, nil
+}
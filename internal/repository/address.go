@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/alex-pyslar/petelka-api/internal/models"
+)
+
+// AddressRepository хранит адресные книги пользователей.
+type AddressRepository struct {
+	db *sql.DB
+}
+
+// NewAddressRepository создаёт репозиторий адресов.
+func NewAddressRepository(db *sql.DB) *AddressRepository {
+	return &AddressRepository{db: db}
+}
+
+const addressColumns = `id, user_id, recipient, phone, region, city, street, postal_code, comment, is_default,
+	created_at, updated_at, version`
+
+func scanAddress(row interface{ Scan(...interface{}) error }) (*models.Address, error) {
+	a := &models.Address{}
+	err := row.Scan(&a.ID, &a.UserID, &a.Recipient, &a.Phone, &a.Region, &a.City, &a.Street, &a.PostalCode,
+		&a.Comment, &a.IsDefault, &a.CreatedAt, &a.UpdatedAt, &a.Version)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// ListAddresses возвращает адреса пользователя: сначала адрес по умолчанию, затем новые.
+func (r *AddressRepository) ListAddresses(ctx context.Context, userID int) ([]*models.Address, error) {
+	ctx, end := startQuery(ctx, "addresses.list")
+	defer end()
+	query := `SELECT ` + addressColumns + ` FROM addresses WHERE user_id = $1 ORDER BY is_default DESC, id DESC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []*models.Address{}
+	for rows.Next() {
+		a, err := scanAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// GetAddress возвращает адрес пользователя userID. Чужой адрес считается отсутствующим.
+func (r *AddressRepository) GetAddress(ctx context.Context, userID, id int) (*models.Address, error) {
+	ctx, end := startQuery(ctx, "addresses.get")
+	defer end()
+	query := `SELECT ` + addressColumns + ` FROM addresses WHERE id = $1 AND user_id = $2`
+	return scanAddress(r.db.QueryRowContext(ctx, query, id, userID))
+}
+
+// CreateAddress сохраняет новый адрес. Первый адрес пользователя и адрес с IsDefault
+// становятся адресом по умолчанию.
+func (r *AddressRepository) CreateAddress(ctx context.Context, a *models.Address) error {
+	ctx, end := startQuery(ctx, "addresses.create")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if a.IsDefault {
+		if err := clearDefaultAddress(ctx, tx, a.UserID); err != nil {
+			return err
+		}
+	}
+	query := `INSERT INTO addresses (user_id, recipient, phone, region, city, street, postal_code, comment, is_default)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+	                  $9 OR NOT EXISTS(SELECT 1 FROM addresses WHERE user_id = $1))
+	          RETURNING id, is_default, created_at, updated_at, version`
+	err = tx.QueryRowContext(ctx, query, a.UserID, a.Recipient, a.Phone, a.Region, a.City, a.Street, a.PostalCode,
+		a.Comment, a.IsDefault).Scan(&a.ID, &a.IsDefault, &a.CreatedAt, &a.UpdatedAt, &a.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateAddress изменяет адрес пользователя a.UserID с проверкой a.Version (0 — без
+// проверки) и записывает в a новую версию. Если адрес не найден, возвращается
+// sql.ErrNoRows, если его версия изменилась — ErrVersionConflict. Снять отметку адреса
+// по умолчанию можно, только назначив другой адрес.
+func (r *AddressRepository) UpdateAddress(ctx context.Context, a *models.Address) error {
+	ctx, end := startQuery(ctx, "addresses.update")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if a.IsDefault {
+		if err := clearDefaultAddress(ctx, tx, a.UserID); err != nil {
+			return err
+		}
+	}
+	query := `UPDATE addresses SET recipient = $1, phone = $2, region = $3, city = $4, street = $5, postal_code = $6,
+	          comment = $7, is_default = is_default OR $8, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $9 AND user_id = $10 AND ($11::int = 0 OR version = $11)
+	          RETURNING is_default, created_at, updated_at, version`
+	// Чужой адрес считается отсутствующим, а не изменённым
+	userAddresses := fmt.Sprintf(`(SELECT id FROM addresses WHERE user_id = %d) AS user_addresses`, a.UserID)
+	err = updateVersioned(ctx, tx, userAddresses, a.ID, query,
+		[]interface{}{a.Recipient, a.Phone, a.Region, a.City, a.Street, a.PostalCode, a.Comment, a.IsDefault, a.ID, a.UserID, a.Version},
+		&a.IsDefault, &a.CreatedAt, &a.UpdatedAt, &a.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteAddress удаляет адрес пользователя. Если удалён адрес по умолчанию,
+// им становится последний добавленный из оставшихся. Заказы хранят копию адреса
+// и не меняются.
+func (r *AddressRepository) DeleteAddress(ctx context.Context, userID, id int) error {
+	ctx, end := startQuery(ctx, "addresses.delete")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var wasDefault bool
+	err = tx.QueryRowContext(ctx, `DELETE FROM addresses WHERE id = $1 AND user_id = $2 RETURNING is_default`, id, userID).
+		Scan(&wasDefault)
+	if err != nil {
+		return err
+	}
+	if wasDefault {
+		query := `UPDATE addresses SET is_default = TRUE
+		          WHERE id = (SELECT MAX(id) FROM addresses WHERE user_id = $1)`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// clearDefaultAddress снимает отметку адреса по умолчанию у всех адресов пользователя.
+func clearDefaultAddress(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, `UPDATE addresses SET is_default = FALSE WHERE user_id = $1 AND is_default`, userID)
+	return err
+}
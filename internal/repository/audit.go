@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/lib/pq"
+)
+
+// AuditRepository хранит журнал аудита действий администраторов.
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository создаёт репозиторий журнала аудита.
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// AuditFilter — условия выборки записей журнала аудита.
+type AuditFilter struct {
+	ActorID      int       // 0 — любой администратор
+	Action       string    // точное совпадение действия
+	ResourceType string    // точное совпадение типа ресурса
+	ResourceID   string    // точное совпадение идентификатора ресурса
+	From         time.Time // записи не раньше этого момента; нулевой — без ограничения
+	To           time.Time // записи раньше этого момента; нулевой — без ограничения
+	Page         int
+	Limit        int
+}
+
+// userPIIFields — поля пользователя с персональными данными. После удаления
+// пользователя они не хранятся и в записях журнала аудита о нём.
+var userPIIFields = []string{"email", "name", "phone"}
+
+// CreateEntry добавляет запись в журнал аудита. Из записи об уже удалённом
+// пользователе (в том числе о самом удалении) персональные данные не сохраняются.
+func (r *AuditRepository) CreateEntry(ctx context.Context, entry *models.AuditEntry) error {
+	ctx, end := startQuery(ctx, "audit_log.create")
+	defer end()
+	query := `INSERT INTO audit_log (actor_id, action, resource_type, resource_id, changes, ip, request_id)
+		VALUES ($1, $2, $3::text, $4::text,
+			CASE WHEN $3::text = 'users' AND EXISTS (SELECT 1 FROM users WHERE id::text = $4::text AND deleted_at IS NOT NULL)
+				THEN $5::jsonb - $8::text[] ELSE $5::jsonb END,
+			$6, $7)
+		RETURNING id, created_at`
+	var actorID sql.NullInt64
+	if entry.ActorID != 0 {
+		actorID = sql.NullInt64{Int64: int64(entry.ActorID), Valid: true}
+	}
+	changes := []byte(entry.Changes)
+	if len(changes) == 0 {
+		changes = []byte("{}")
+	}
+	return r.db.QueryRowContext(ctx, query, actorID, entry.Action, entry.ResourceType, entry.ResourceID,
+		changes, entry.IP, entry.RequestID, pq.Array(userPIIFields)).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// anonymizeUserAudit удаляет персональные данные пользователя id из записей журнала
+// аудита о нём. Вызывается в транзакции удаления пользователя.
+func anonymizeUserAudit(ctx context.Context, tx *sql.Tx, id int) error {
+	query := `UPDATE audit_log SET changes = changes - $1::text[] WHERE resource_type = 'users' AND resource_id = $2`
+	_, err := tx.ExecContext(ctx, query, pq.Array(userPIIFields), strconv.Itoa(id))
+	return err
+}
+
+// ListEntries возвращает страницу записей журнала по фильтру, новые первыми,
+// вместе с общим числом найденных.
+func (r *AuditRepository) ListEntries(ctx context.Context, filter AuditFilter) ([]*models.AuditEntry, int, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(format string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.ActorID != 0 {
+		addCondition("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		addCondition("resource_type = $%d", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		addCondition("resource_id = $%d", filter.ResourceID)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+	var where string
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+
+	ctx, end := startQuery(ctx, "audit_log.list")
+	defer end()
+
+	var totalCount int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_log`+where, args...).Scan(&totalCount); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT id, actor_id, action, resource_type, resource_id, changes, ip, request_id, created_at
+		FROM audit_log` + where + fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit, (filter.Page-1)*filter.Limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		entry := &models.AuditEntry{}
+		var actorID sql.NullInt64
+		var changes []byte
+		if err := rows.Scan(&entry.ID, &actorID, &entry.Action, &entry.ResourceType, &entry.ResourceID,
+			&changes, &entry.IP, &entry.RequestID, &entry.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entry.ActorID = int(actorID.Int64)
+		entry.Changes = changes
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return entries, totalCount, nil
+}
+
+// PruneEntries удаляет записи старше before и возвращает их число.
+func (r *AuditRepository) PruneEntries(ctx context.Context, before time.Time) (int64, error) {
+	ctx, end := startQuery(ctx, "audit_log.prune")
+	defer end()
+	result, err := r.db.ExecContext(ctx, `DELETE FROM audit_log WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	"time"
 
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
 	"github.com/alex-pyslar/petelka-api/internal/models"
 	"github.com/redis/go-redis/v9"
 )
@@ -16,17 +17,21 @@ import (
 type CategoryRepository struct {
 	db    *sql.DB
 	redis *redis.Client
+	ttl   time.Duration // время жизни записей в кэше
 }
 
 // NewCategoryRepository создаёт новый репозиторий для категорий.
-func NewCategoryRepository(db *sql.DB, redis *redis.Client) *CategoryRepository {
-	return &CategoryRepository{db: db, redis: redis}
+func NewCategoryRepository(db *sql.DB, redis *redis.Client, ttl time.Duration) *CategoryRepository {
+	return &CategoryRepository{db: db, redis: redis, ttl: ttl}
 }
 
 // CreateCategory создаёт новую категорию в базе данных.
 func (r *CategoryRepository) CreateCategory(ctx context.Context, category *models.Category) error {
-	query := `INSERT INTO categories (name, type) VALUES ($1, $2) RETURNING id`
-	err := r.db.QueryRowContext(ctx, query, category.Name, category.Type).Scan(&category.ID)
+	ctx, end := startQuery(ctx, "categories.create")
+	defer end()
+	query := `INSERT INTO categories (name, type) VALUES ($1, $2) RETURNING id, created_at, updated_at, version`
+	err := r.db.QueryRowContext(ctx, query, category.Name, category.Type).
+		Scan(&category.ID, &category.CreatedAt, &category.UpdatedAt, &category.Version)
 	if err != nil {
 		return err
 	}
@@ -41,13 +46,18 @@ func (r *CategoryRepository) GetCategory(ctx context.Context, id int) (*models.C
 	if err == nil {
 		var category models.Category
 		if err := json.Unmarshal([]byte(cached), &category); err == nil {
+			metrics.CacheHit("category")
 			return &category, nil
 		}
 	}
+	metrics.CacheMiss("category")
 
 	var category models.Category
-	query := `SELECT id, name, type FROM categories WHERE id = $1`
-	err = r.db.QueryRowContext(ctx, query, id).Scan(&category.ID, &category.Name, &category.Type)
+	ctx, end := startQuery(ctx, "categories.get")
+	defer end()
+	query := `SELECT id, name, type, created_at, updated_at, version FROM categories WHERE id = $1`
+	err = r.db.QueryRowContext(ctx, query, id).
+		Scan(&category.ID, &category.Name, &category.Type, &category.CreatedAt, &category.UpdatedAt, &category.Version)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, sql.ErrNoRows
@@ -57,7 +67,7 @@ func (r *CategoryRepository) GetCategory(ctx context.Context, id int) (*models.C
 
 	data, err := json.Marshal(category)
 	if err == nil {
-		r.redis.Set(ctx, cacheKey, data, 10*time.Minute)
+		r.redis.Set(ctx, cacheKey, data, r.ttl)
 	}
 
 	return &category, nil
@@ -65,7 +75,9 @@ func (r *CategoryRepository) GetCategory(ctx context.Context, id int) (*models.C
 
 // ListCategories получает список всех категорий.
 func (r *CategoryRepository) ListCategories(ctx context.Context) ([]*models.Category, error) {
-	query := `SELECT id, name, type FROM categories`
+	ctx, end := startQuery(ctx, "categories.list")
+	defer end()
+	query := `SELECT id, name, type, created_at, updated_at, version FROM categories`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -75,7 +87,7 @@ func (r *CategoryRepository) ListCategories(ctx context.Context) ([]*models.Cate
 	var categories []*models.Category
 	for rows.Next() {
 		var c models.Category
-		if err := rows.Scan(&c.ID, &c.Name, &c.Type); err != nil {
+		if err := rows.Scan(&c.ID, &c.Name, &c.Type, &c.CreatedAt, &c.UpdatedAt, &c.Version); err != nil {
 			return nil, err
 		}
 		categories = append(categories, &c)
@@ -87,19 +99,21 @@ func (r *CategoryRepository) ListCategories(ctx context.Context) ([]*models.Cate
 	return categories, nil
 }
 
-// UpdateCategory обновляет существующую категорию.
+// UpdateCategory обновляет существующую категорию с проверкой category.Version
+// (см. UpdateProduct).
 func (r *CategoryRepository) UpdateCategory(ctx context.Context, category *models.Category) error {
-	query := `UPDATE categories SET name = $1, type = $2 WHERE id = $3`
-	result, err := r.db.ExecContext(ctx, query, category.Name, category.Type, category.ID)
+	ctx, end := startQuery(ctx, "categories.update")
+	defer end()
+	query := `UPDATE categories SET name = $1, type = $2, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $3 AND ($4::int = 0 OR version = $4)
+	          RETURNING created_at, updated_at, version`
+	err := updateVersioned(ctx, r.db, "categories", category.ID, query,
+		[]interface{}{category.Name, category.Type, category.ID, category.Version},
+		&category.CreatedAt, &category.UpdatedAt, &category.Version)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
-
 	cacheKey := fmt.Sprintf("category:%d", category.ID)
 	r.redis.Del(ctx, cacheKey)
 
@@ -108,6 +122,8 @@ func (r *CategoryRepository) UpdateCategory(ctx context.Context, category *model
 
 // DeleteCategory удаляет категорию по ID.
 func (r *CategoryRepository) DeleteCategory(ctx context.Context, id int) error {
+	ctx, end := startQuery(ctx, "categories.delete")
+	defer end()
 	query := `DELETE FROM categories WHERE id = $1`
 	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
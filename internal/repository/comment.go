@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	"time"
 
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
 	"github.com/alex-pyslar/petelka-api/internal/models"
 	"github.com/redis/go-redis/v9"
 )
@@ -16,17 +17,21 @@ import (
 type CommentRepository struct {
 	db    *sql.DB
 	redis *redis.Client
+	ttl   time.Duration // время жизни записей в кэше
 }
 
 // NewCommentRepository создаёт новый репозиторий для комментариев.
-func NewCommentRepository(db *sql.DB, redis *redis.Client) *CommentRepository {
-	return &CommentRepository{db: db, redis: redis}
+func NewCommentRepository(db *sql.DB, redis *redis.Client, ttl time.Duration) *CommentRepository {
+	return &CommentRepository{db: db, redis: redis, ttl: ttl}
 }
 
 // CreateComment создаёт новый комментарий в базе данных.
 func (r *CommentRepository) CreateComment(ctx context.Context, comment *models.Comment) error {
-	query := `INSERT INTO comments (product_id, user_id, text, created_at) VALUES ($1, $2, $3, $4) RETURNING id`
-	err := r.db.QueryRowContext(ctx, query, comment.ProductID, comment.UserID, comment.Text, time.Now()).Scan(&comment.ID)
+	ctx, end := startQuery(ctx, "comments.create")
+	defer end()
+	query := `INSERT INTO comments (product_id, user_id, text, created_at) VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at, version`
+	err := r.db.QueryRowContext(ctx, query, comment.ProductID, comment.UserID, comment.Text, time.Now()).
+		Scan(&comment.ID, &comment.CreatedAt, &comment.UpdatedAt, &comment.Version)
 	if err != nil {
 		return err
 	}
@@ -42,13 +47,17 @@ func (r *CommentRepository) GetComment(ctx context.Context, id int) (*models.Com
 	cached, err := r.redis.Get(ctx, cacheKey).Result()
 	if err == nil {
 		if err := json.Unmarshal([]byte(cached), &comment); err == nil {
+			metrics.CacheHit("comment")
 			return &comment, nil
 		}
 	}
+	metrics.CacheMiss("comment")
 
 	// Если в кэше нет, получаем из БД
-	query := `SELECT id, product_id, user_id, text, created_at FROM comments WHERE id = $1`
-	err = r.db.QueryRowContext(ctx, query, id).Scan(&comment.ID, &comment.ProductID, &comment.UserID, &comment.Text, &comment.CreatedAt)
+	ctx, end := startQuery(ctx, "comments.get")
+	defer end()
+	query := `SELECT id, product_id, user_id, text, created_at, updated_at, version FROM comments WHERE id = $1`
+	err = r.db.QueryRowContext(ctx, query, id).Scan(&comment.ID, &comment.ProductID, &comment.UserID, &comment.Text, &comment.CreatedAt, &comment.UpdatedAt, &comment.Version)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, sql.ErrNoRows
@@ -59,14 +68,16 @@ func (r *CommentRepository) GetComment(ctx context.Context, id int) (*models.Com
 	// Сохраняем в кэш
 	data, err := json.Marshal(comment)
 	if err == nil {
-		r.redis.Set(ctx, cacheKey, data, 10*time.Minute)
+		r.redis.Set(ctx, cacheKey, data, r.ttl)
 	}
 	return &comment, nil
 }
 
 // ListComments получает список всех комментариев.
 func (r *CommentRepository) ListComments(ctx context.Context) ([]*models.Comment, error) {
-	query := `SELECT id, product_id, user_id, text, created_at FROM comments`
+	ctx, end := startQuery(ctx, "comments.list")
+	defer end()
+	query := `SELECT id, product_id, user_id, text, created_at, updated_at, version FROM comments`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -76,7 +87,7 @@ func (r *CommentRepository) ListComments(ctx context.Context) ([]*models.Comment
 	var comments []*models.Comment
 	for rows.Next() {
 		var c models.Comment
-		err := rows.Scan(&c.ID, &c.ProductID, &c.UserID, &c.Text, &c.CreatedAt)
+		err := rows.Scan(&c.ID, &c.ProductID, &c.UserID, &c.Text, &c.CreatedAt, &c.UpdatedAt, &c.Version)
 		if err != nil {
 			return nil, err
 		}
@@ -88,17 +99,43 @@ func (r *CommentRepository) ListComments(ctx context.Context) ([]*models.Comment
 	return comments, nil
 }
 
-// UpdateComment обновляет существующий комментарий.
-func (r *CommentRepository) UpdateComment(ctx context.Context, comment *models.Comment) error {
-	query := `UPDATE comments SET product_id = $1, user_id = $2, text = $3 WHERE id = $4`
-	result, err := r.db.ExecContext(ctx, query, comment.ProductID, comment.UserID, comment.Text, comment.ID)
+// ListCommentsByUser получает комментарии пользователя, начиная с последних.
+func (r *CommentRepository) ListCommentsByUser(ctx context.Context, userID int) ([]*models.Comment, error) {
+	ctx, end := startQuery(ctx, "comments.list_by_user")
+	defer end()
+	query := `SELECT id, product_id, user_id, text, created_at, updated_at, version FROM comments WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	comments := []*models.Comment{}
+	for rows.Next() {
+		var c models.Comment
+		if err := rows.Scan(&c.ID, &c.ProductID, &c.UserID, &c.Text, &c.CreatedAt, &c.UpdatedAt, &c.Version); err != nil {
+			return nil, err
+		}
+		comments = append(comments, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// UpdateComment обновляет существующий комментарий с проверкой comment.Version (см. UpdateProduct).
+func (r *CommentRepository) UpdateComment(ctx context.Context, comment *models.Comment) error {
+	ctx, end := startQuery(ctx, "comments.update")
+	defer end()
+	query := `UPDATE comments SET product_id = $1, user_id = $2, text = $3, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $4 AND ($5::int = 0 OR version = $5)
+	          RETURNING created_at, updated_at, version`
+	err := updateVersioned(ctx, r.db, "comments", comment.ID, query,
+		[]interface{}{comment.ProductID, comment.UserID, comment.Text, comment.ID, comment.Version},
+		&comment.CreatedAt, &comment.UpdatedAt, &comment.Version)
+	if err != nil {
+		return err
 	}
 
 	cacheKey := fmt.Sprintf("comment:%d", comment.ID)
@@ -109,6 +146,8 @@ func (r *CommentRepository) UpdateComment(ctx context.Context, comment *models.C
 
 // DeleteComment удаляет комментарий по ID.
 func (r *CommentRepository) DeleteComment(ctx context.Context, id int) error {
+	ctx, end := startQuery(ctx, "comments.delete")
+	defer end()
 	query := `DELETE FROM comments WHERE id = $1`
 	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/alex-pyslar/petelka-api/internal/models"
+)
+
+// DeliveryRepository хранит правила расчёта стоимости доставки.
+type DeliveryRepository struct {
+	db *sql.DB
+}
+
+// NewDeliveryRepository создаёт репозиторий правил доставки.
+func NewDeliveryRepository(db *sql.DB) *DeliveryRepository {
+	return &DeliveryRepository{db: db}
+}
+
+const deliveryRateColumns = `id, method, region, max_weight, price, currency, created_at, updated_at, version`
+
+func scanDeliveryRate(row interface{ Scan(...interface{}) error }) (*models.DeliveryRate, error) {
+	rate := &models.DeliveryRate{}
+	var maxWeight sql.NullInt64
+	err := row.Scan(&rate.ID, &rate.Method, &rate.Region, &maxWeight, &rate.Price, &rate.Currency, &rate.CreatedAt, &rate.UpdatedAt,
+		&rate.Version)
+	if err != nil {
+		return nil, err
+	}
+	if maxWeight.Valid {
+		w := int(maxWeight.Int64)
+		rate.MaxWeight = &w
+	}
+	return rate, nil
+}
+
+// ListRates возвращает все правила, сгруппированные по способу и региону.
+func (r *DeliveryRepository) ListRates(ctx context.Context) ([]*models.DeliveryRate, error) {
+	ctx, end := startQuery(ctx, "delivery_rates.list")
+	defer end()
+	query := `SELECT ` + deliveryRateColumns + ` FROM delivery_rates
+	          ORDER BY method, region, max_weight NULLS LAST, id`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []*models.DeliveryRate{}
+	for rows.Next() {
+		rate, err := scanDeliveryRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// GetRate возвращает правило по ID. Если правило не найдено, возвращается sql.ErrNoRows.
+func (r *DeliveryRepository) GetRate(ctx context.Context, id int) (*models.DeliveryRate, error) {
+	ctx, end := startQuery(ctx, "delivery_rates.get")
+	defer end()
+	query := `SELECT ` + deliveryRateColumns + ` FROM delivery_rates WHERE id = $1`
+	return scanDeliveryRate(r.db.QueryRowContext(ctx, query, id))
+}
+
+// FindRate выбирает правило для доставки способом method в регион region заказа весом
+// weight граммов: правило региона важнее общего, из подходящих по весу берётся правило
+// с наименьшим ограничением. Если подходящего правила нет, возвращается sql.ErrNoRows.
+func (r *DeliveryRepository) FindRate(ctx context.Context, method, region string, weight int) (*models.DeliveryRate, error) {
+	ctx, end := startQuery(ctx, "delivery_rates.find")
+	defer end()
+	query := `SELECT ` + deliveryRateColumns + ` FROM delivery_rates
+	          WHERE method = $1 AND (region = '' OR LOWER(region) = LOWER($2))
+	            AND (max_weight IS NULL OR max_weight >= $3)
+	          ORDER BY region = '', max_weight NULLS LAST, id
+	          LIMIT 1`
+	return scanDeliveryRate(r.db.QueryRowContext(ctx, query, method, region, weight))
+}
+
+// CreateRate сохраняет новое правило.
+func (r *DeliveryRepository) CreateRate(ctx context.Context, rate *models.DeliveryRate) error {
+	ctx, end := startQuery(ctx, "delivery_rates.create")
+	defer end()
+	query := `INSERT INTO delivery_rates (method, region, max_weight, price, currency)
+	          VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'RUB'))
+	          RETURNING id, currency, created_at, updated_at, version`
+	return r.db.QueryRowContext(ctx, query, rate.Method, rate.Region, rate.MaxWeight, rate.Price, rate.Currency).
+		Scan(&rate.ID, &rate.Currency, &rate.CreatedAt, &rate.UpdatedAt, &rate.Version)
+}
+
+// UpdateRate изменяет правило с проверкой rate.Version (0 — без проверки) и записывает
+// в rate новую версию. Если правило не найдено, возвращается sql.ErrNoRows, если его
+// версия изменилась — ErrVersionConflict.
+func (r *DeliveryRepository) UpdateRate(ctx context.Context, rate *models.DeliveryRate) error {
+	ctx, end := startQuery(ctx, "delivery_rates.update")
+	defer end()
+	query := `UPDATE delivery_rates SET method = $1, region = $2, max_weight = $3, price = $4,
+	          version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $5 AND ($6::int = 0 OR version = $6)
+	          RETURNING currency, created_at, updated_at, version`
+	return updateVersioned(ctx, r.db, "delivery_rates", rate.ID, query,
+		[]interface{}{rate.Method, rate.Region, rate.MaxWeight, rate.Price, rate.ID, rate.Version},
+		&rate.Currency, &rate.CreatedAt, &rate.UpdatedAt, &rate.Version)
+}
+
+// DeleteRate удаляет правило. Если правило не найдено, возвращается sql.ErrNoRows.
+func (r *DeliveryRepository) DeleteRate(ctx context.Context, id int) error {
+	ctx, end := startQuery(ctx, "delivery_rates.delete")
+	defer end()
+	result, err := r.db.ExecContext(ctx, `DELETE FROM delivery_rates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
+	"github.com/alex-pyslar/petelka-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startQuery открывает спан запроса к PostgreSQL и засекает его длительность.
+// В спан попадает только имя запроса (например, "products.get"), но не значения параметров.
+// Использование:
+//
+//	ctx, end := startQuery(ctx, "products.get")
+//	defer end()
+func startQuery(ctx context.Context, name string) (context.Context, func()) {
+	ctx, span := tracing.Tracer().Start(ctx, "db "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", name),
+		),
+	)
+	observe := metrics.ObserveQuery(name)
+	return ctx, func() {
+		observe()
+		span.End()
+	}
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/alex-pyslar/petelka-api/internal/storage"
+)
+
+// ErrInvoiceNotFound возвращается, если документ заказа ещё не сохранён.
+var ErrInvoiceNotFound = storage.ErrNotFound
+
+// InvoiceRepository хранит PDF-документы оплаченных заказов в отдельном объектном
+// хранилище, чтобы они не попадали в список фотографий.
+type InvoiceRepository struct {
+	store storage.ObjectStore
+}
+
+// NewInvoiceRepository создаёт репозиторий документов заказов поверх объектного хранилища.
+func NewInvoiceRepository(store storage.ObjectStore) *InvoiceRepository {
+	return &InvoiceRepository{store: store}
+}
+
+func invoiceKey(orderID int) string {
+	return fmt.Sprintf("order-%d.pdf", orderID)
+}
+
+// GetInvoice возвращает сохранённый документ заказа. Если документа нет,
+// возвращается ErrInvoiceNotFound.
+func (r *InvoiceRepository) GetInvoice(ctx context.Context, orderID int) ([]byte, error) {
+	reader, _, err := r.store.Get(ctx, invoiceKey(orderID))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// SaveInvoice сохраняет документ заказа, если он ещё не сохранён, и возвращает
+// содержимое сохранённого документа: однажды сохранённый документ не перезаписывается.
+func (r *InvoiceRepository) SaveInvoice(ctx context.Context, orderID int, data []byte, contentType string) ([]byte, error) {
+	existing, err := r.GetInvoice(ctx, orderID)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, ErrInvoiceNotFound) {
+		return nil, err
+	}
+	if err := r.store.Put(ctx, invoiceKey(orderID), bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
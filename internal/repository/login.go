@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// LoginAttemptRepository хранит счётчики неудачных входов и блокировки в Redis,
+// а журнал попыток входа — в базе данных.
+type LoginAttemptRepository struct {
+	db    *sql.DB
+	redis *redis.Client
+}
+
+// NewLoginAttemptRepository создаёт репозиторий попыток входа.
+func NewLoginAttemptRepository(db *sql.DB, redis *redis.Client) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db, redis: redis}
+}
+
+// FailureCounter — число неудачных попыток в текущем окне и время последней из них.
+type FailureCounter struct {
+	Count int
+	Last  time.Time
+	TTL   time.Duration // сколько осталось до сброса счётчика
+}
+
+func failuresKey(scope, id string) string {
+	return "login_fail:" + scope + ":" + id
+}
+
+func lockKey(email string) string {
+	return "login_lock:" + email
+}
+
+// Failures возвращает счётчик неудач для scope ("account" или "ip").
+// Отсутствующий счётчик возвращается с нулевыми значениями.
+func (r *LoginAttemptRepository) Failures(ctx context.Context, scope, id string) (*FailureCounter, error) {
+	key := failuresKey(scope, id)
+	var (
+		fields *redis.MapStringStringCmd
+		ttl    *redis.DurationCmd
+	)
+	_, err := r.redis.Pipelined(ctx, func(p redis.Pipeliner) error {
+		fields = p.HGetAll(ctx, key)
+		ttl = p.PTTL(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counter := &FailureCounter{}
+	values := fields.Val()
+	counter.Count, _ = strconv.Atoi(values["count"])
+	if ms, err := strconv.ParseInt(values["last"], 10, 64); err == nil {
+		counter.Last = time.UnixMilli(ms)
+	}
+	if d := ttl.Val(); d > 0 {
+		counter.TTL = d
+	}
+	return counter, nil
+}
+
+// AddFailure увеличивает счётчик неудач и возвращает новое значение.
+// Окно отсчитывается от первой неудачи: повторные неудачи его не продлевают.
+func (r *LoginAttemptRepository) AddFailure(ctx context.Context, scope, id string, window time.Duration) (int, error) {
+	key := failuresKey(scope, id)
+	var count *redis.IntCmd
+	_, err := r.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		count = p.HIncrBy(ctx, key, "count", 1)
+		p.HSet(ctx, key, "last", time.Now().UnixMilli())
+		p.ExpireNX(ctx, key, window)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(count.Val()), nil
+}
+
+// ResetFailures удаляет счётчик неудач.
+func (r *LoginAttemptRepository) ResetFailures(ctx context.Context, scope, id string) error {
+	return r.redis.Del(ctx, failuresKey(scope, id)).Err()
+}
+
+// Lock блокирует вход в аккаунт на duration.
+func (r *LoginAttemptRepository) Lock(ctx context.Context, email string, duration time.Duration) error {
+	return r.redis.Set(ctx, lockKey(email), time.Now().Unix(), duration).Err()
+}
+
+// LockTTL возвращает оставшееся время блокировки аккаунта или ноль, если блокировки нет.
+func (r *LoginAttemptRepository) LockTTL(ctx context.Context, email string) (time.Duration, error) {
+	ttl, err := r.redis.PTTL(ctx, lockKey(email)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Unlock снимает блокировку аккаунта и сбрасывает его счётчик неудач.
+func (r *LoginAttemptRepository) Unlock(ctx context.Context, email string) error {
+	return r.redis.Del(ctx, lockKey(email), failuresKey("account", email)).Err()
+}
+
+// CreateAttempt записывает попытку входа в журнал.
+func (r *LoginAttemptRepository) CreateAttempt(ctx context.Context, attempt *models.LoginAttempt) error {
+	ctx, end := startQuery(ctx, "login_attempts.create")
+	defer end()
+	query := `INSERT INTO login_attempts (user_id, email, ip, user_agent, success, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	var userID sql.NullInt64
+	if attempt.UserID != 0 {
+		userID = sql.NullInt64{Int64: int64(attempt.UserID), Valid: true}
+	}
+	if attempt.CreatedAt.IsZero() {
+		attempt.CreatedAt = time.Now()
+	}
+	return r.db.QueryRowContext(ctx, query, userID, attempt.Email, attempt.IP, attempt.UserAgent,
+		attempt.Success, attempt.Reason, attempt.CreatedAt).Scan(&attempt.ID)
+}
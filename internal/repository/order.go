@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
 	"github.com/alex-pyslar/petelka-api/internal/models"
 	"github.com/redis/go-redis/v9"
 )
@@ -15,23 +16,98 @@ import (
 type OrderRepository struct {
 	db    *sql.DB
 	redis *redis.Client
+	ttl   time.Duration // время жизни записей в кэше
 }
 
 // NewOrderRepository создаёт новый репозиторий для заказов.
-func NewOrderRepository(db *sql.DB, redis *redis.Client) *OrderRepository {
-	return &OrderRepository{db: db, redis: redis}
+func NewOrderRepository(db *sql.DB, redis *redis.Client, ttl time.Duration) *OrderRepository {
+	return &OrderRepository{db: db, redis: redis, ttl: ttl}
 }
 
-// CreateOrder создаёт новый заказ в базе данных.
+const orderColumns = `id, user_id, total, currency, status, delivery_method, delivery_cost, promo_code, discount,
+	vat_rate, vat, shipping_address, created_at, updated_at, version`
+
+func scanOrder(row interface{ Scan(...interface{}) error }) (*models.Order, error) {
+	var o models.Order
+	var address []byte
+	err := row.Scan(&o.ID, &o.UserID, &o.Total, &o.Currency, &o.Status, &o.DeliveryMethod, &o.DeliveryCost, &o.PromoCode, &o.Discount,
+		&o.VATRate, &o.VAT, &address, &o.CreatedAt, &o.UpdatedAt, &o.Version)
+	if err != nil {
+		return nil, err
+	}
+	if address != nil {
+		o.ShippingAddress = &models.ShippingAddress{}
+		if err := json.Unmarshal(address, o.ShippingAddress); err != nil {
+			return nil, fmt.Errorf("invalid shipping address of order %d: %w", o.ID, err)
+		}
+	}
+	return &o, nil
+}
+
+// CreateOrder создаёт новый заказ в базе данных. Пустой способ доставки — самовывоз.
 func (r *OrderRepository) CreateOrder(ctx context.Context, order *models.Order) error {
-	query := `INSERT INTO orders (user_id, total, status, created_at) VALUES ($1, $2, $3, $4) RETURNING id`
-	err := r.db.QueryRowContext(ctx, query, order.UserID, order.Total, order.Status, time.Now()).Scan(&order.ID)
+	ctx, end := startQuery(ctx, "orders.create")
+	defer end()
+	return insertOrder(ctx, r.db, order)
+}
+
+// CreateOrderWithItems в одной транзакции создаёт заказ и его позиции. Если к заказу
+// применён промокод, в той же транзакции учитывается его использование; при исчерпанном
+// лимите возвращается ErrPromoCodeExhausted, а если промокод уже не действует —
+// ErrPromoCodeUnavailable, и заказ не создаётся.
+func (r *OrderRepository) CreateOrderWithItems(ctx context.Context, order *models.Order, items []models.OrderItem) error {
+	ctx, end := startQuery(ctx, "orders.create_with_items")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	if err := insertOrder(ctx, tx, order); err != nil {
+		return err
+	}
+	if order.PromoCode != "" {
+		if err := usePromoCode(ctx, tx, order); err != nil {
+			return err
+		}
+	}
+	query := `INSERT INTO order_items (order_id, product_id, quantity, price, discount, vat) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	for i := range items {
+		items[i].OrderID = order.ID
+		if err := tx.QueryRowContext(ctx, query, order.ID, items[i].ProductID, items[i].Quantity, items[i].Price, items[i].Discount, items[i].VAT).
+			Scan(&items[i].ID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	order.Items = items
 	return nil
 }
 
+// insertOrder добавляет заказ через db или транзакцию.
+func insertOrder(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, order *models.Order) error {
+	var address []byte
+	if order.ShippingAddress != nil {
+		var err error
+		if address, err = json.Marshal(order.ShippingAddress); err != nil {
+			return err
+		}
+	}
+	query := `INSERT INTO orders (user_id, total, currency, status, delivery_method, delivery_cost, promo_code, discount,
+	              vat_rate, vat, shipping_address, created_at)
+	          VALUES ($1, $2, COALESCE(NULLIF($3, ''), 'RUB'), $4, COALESCE(NULLIF($5, ''), 'pickup'), $6, $7, $8, $9, $10, $11, $12)
+	          RETURNING id, currency, delivery_method, created_at, updated_at, version`
+	return q.QueryRowContext(ctx, query, order.UserID, order.Total, order.Currency, order.Status, order.DeliveryMethod, order.DeliveryCost,
+		order.PromoCode, order.Discount, order.VATRate, order.VAT, address, time.Now()).
+		Scan(&order.ID, &order.Currency, &order.DeliveryMethod, &order.CreatedAt, &order.UpdatedAt, &order.Version)
+}
+
 // GetOrder получает заказ по ID, используя кэш Redis.
 func (r *OrderRepository) GetOrder(ctx context.Context, id int) (*models.Order, error) {
 	cacheKey := fmt.Sprintf("order:%d", id)
@@ -41,31 +117,62 @@ func (r *OrderRepository) GetOrder(ctx context.Context, id int) (*models.Order,
 	cached, err := r.redis.Get(ctx, cacheKey).Result()
 	if err == nil {
 		if err := json.Unmarshal([]byte(cached), &order); err == nil {
+			metrics.CacheHit("order")
 			return &order, nil
 		}
 	}
+	metrics.CacheMiss("order")
 
 	// Если в кэше нет, получаем из БД
-	query := `SELECT id, user_id, total, status, created_at FROM orders WHERE id = $1`
-	err = r.db.QueryRowContext(ctx, query, id).Scan(&order.ID, &order.UserID, &order.Total, &order.Status, &order.CreatedAt)
+	ctx, end := startQuery(ctx, "orders.get")
+	defer end()
+	fetched, err := scanOrder(r.db.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = $1`, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, sql.ErrNoRows
 		}
 		return nil, err
 	}
+	order = *fetched
 
 	// Сохраняем в кэш
 	data, err := json.Marshal(order)
 	if err == nil {
-		r.redis.Set(ctx, cacheKey, data, 10*time.Minute)
+		r.redis.Set(ctx, cacheKey, data, r.ttl)
 	}
 	return &order, nil
 }
 
+// ListOrderItems возвращает позиции заказа в порядке добавления.
+func (r *OrderRepository) ListOrderItems(ctx context.Context, orderID int) ([]models.OrderItem, error) {
+	ctx, end := startQuery(ctx, "order_items.list")
+	defer end()
+	rows, err := r.db.QueryContext(ctx, `SELECT id, order_id, product_id, quantity, price, discount, vat
+	          FROM order_items WHERE order_id = $1 ORDER BY id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.OrderItem{}
+	for rows.Next() {
+		var item models.OrderItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.Price, &item.Discount, &item.VAT); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 // ListOrders получает список всех заказов.
 func (r *OrderRepository) ListOrders(ctx context.Context) ([]*models.Order, error) {
-	query := `SELECT id, user_id, total, status, created_at FROM orders`
+	ctx, end := startQuery(ctx, "orders.list")
+	defer end()
+	query := `SELECT ` + orderColumns + ` FROM orders`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -74,11 +181,11 @@ func (r *OrderRepository) ListOrders(ctx context.Context) ([]*models.Order, erro
 
 	var orders []*models.Order
 	for rows.Next() {
-		var o models.Order
-		if err := rows.Scan(&o.ID, &o.UserID, &o.Total, &o.Status, &o.CreatedAt); err != nil {
+		o, err := scanOrder(rows)
+		if err != nil {
 			return nil, err
 		}
-		orders = append(orders, &o)
+		orders = append(orders, o)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -87,17 +194,43 @@ func (r *OrderRepository) ListOrders(ctx context.Context) ([]*models.Order, erro
 	return orders, nil
 }
 
-// UpdateOrder обновляет существующий заказ.
-func (r *OrderRepository) UpdateOrder(ctx context.Context, order *models.Order) error {
-	query := `UPDATE orders SET user_id = $1, total = $2, status = $3 WHERE id = $4`
-	result, err := r.db.ExecContext(ctx, query, order.UserID, order.Total, order.Status, order.ID)
+// ListOrdersByUser получает заказы пользователя, начиная с последних.
+func (r *OrderRepository) ListOrdersByUser(ctx context.Context, userID int) ([]*models.Order, error) {
+	ctx, end := startQuery(ctx, "orders.list_by_user")
+	defer end()
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	orders := []*models.Order{}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// UpdateOrder обновляет существующий заказ с проверкой order.Version (см. UpdateProduct).
+func (r *OrderRepository) UpdateOrder(ctx context.Context, order *models.Order) error {
+	ctx, end := startQuery(ctx, "orders.update")
+	defer end()
+	query := `UPDATE orders SET user_id = $1, total = $2, status = $3, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $4 AND ($5::int = 0 OR version = $5)
+	          RETURNING created_at, updated_at, version`
+	err := updateVersioned(ctx, r.db, "orders", order.ID, query,
+		[]interface{}{order.UserID, order.Total, order.Status, order.ID, order.Version},
+		&order.CreatedAt, &order.UpdatedAt, &order.Version)
+	if err != nil {
+		return err
 	}
 
 	cacheKey := fmt.Sprintf("order:%d", order.ID)
@@ -108,6 +241,8 @@ func (r *OrderRepository) UpdateOrder(ctx context.Context, order *models.Order)
 
 // DeleteOrder удаляет заказ по ID.
 func (r *OrderRepository) DeleteOrder(ctx context.Context, id int) error {
+	ctx, end := startQuery(ctx, "orders.delete")
+	defer end()
 	query := `DELETE FROM orders WHERE id = $1`
 	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
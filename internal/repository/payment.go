@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// PaymentRepository хранит попытки оплаты заказов и переводит заказы в оплаченные.
+type PaymentRepository struct {
+	db    *sql.DB
+	redis *redis.Client // для сброса кэша заказов при смене их статуса
+}
+
+// NewPaymentRepository создаёт репозиторий платежей.
+func NewPaymentRepository(db *sql.DB, redis *redis.Client) *PaymentRepository {
+	return &PaymentRepository{db: db, redis: redis}
+}
+
+const paymentColumns = `id, order_id, provider, COALESCE(provider_payment_id, ''), idempotence_key, status,
+	amount, currency, confirmation_url, error, created_at, updated_at`
+
+func scanPayment(row interface{ Scan(...interface{}) error }) (*models.Payment, error) {
+	p := &models.Payment{}
+	err := row.Scan(&p.ID, &p.OrderID, &p.Provider, &p.ProviderPaymentID, &p.IdempotenceKey, &p.Status,
+		&p.Amount, &p.Currency, &p.ConfirmationURL, &p.Error, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// CreatePayment сохраняет новую попытку оплаты. Если у заказа уже есть попытка
+// в статусе pending, возвращается ошибка уникальности (23505).
+func (r *PaymentRepository) CreatePayment(ctx context.Context, p *models.Payment) error {
+	ctx, end := startQuery(ctx, "payments.create")
+	defer end()
+	query := `INSERT INTO payments (order_id, provider, idempotence_key, status, amount, currency)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at, updated_at`
+	return r.db.QueryRowContext(ctx, query, p.OrderID, p.Provider, p.IdempotenceKey, p.Status, p.Amount, p.Currency).
+		Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+}
+
+// GetPayment возвращает попытку оплаты по ID.
+func (r *PaymentRepository) GetPayment(ctx context.Context, id int) (*models.Payment, error) {
+	ctx, end := startQuery(ctx, "payments.get")
+	defer end()
+	return scanPayment(r.db.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = $1`, id))
+}
+
+// GetPaymentByProviderID возвращает попытку оплаты по ID платежа у провайдера.
+func (r *PaymentRepository) GetPaymentByProviderID(ctx context.Context, provider, providerPaymentID string) (*models.Payment, error) {
+	ctx, end := startQuery(ctx, "payments.get_by_provider_id")
+	defer end()
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE provider = $1 AND provider_payment_id = $2`
+	return scanPayment(r.db.QueryRowContext(ctx, query, provider, providerPaymentID))
+}
+
+// LatestPayment возвращает последнюю попытку оплаты заказа.
+func (r *PaymentRepository) LatestPayment(ctx context.Context, orderID int) (*models.Payment, error) {
+	ctx, end := startQuery(ctx, "payments.latest")
+	defer end()
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = $1 ORDER BY id DESC LIMIT 1`
+	return scanPayment(r.db.QueryRowContext(ctx, query, orderID))
+}
+
+// UpdatePayment сохраняет ответ провайдера: ID платежа, статус, ссылку на оплату и ошибку.
+func (r *PaymentRepository) UpdatePayment(ctx context.Context, p *models.Payment) error {
+	ctx, end := startQuery(ctx, "payments.update")
+	defer end()
+	query := `UPDATE payments SET provider_payment_id = NULLIF($1, ''), status = $2, confirmation_url = $3,
+	          error = $4, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $5 RETURNING updated_at`
+	return r.db.QueryRowContext(ctx, query, p.ProviderPaymentID, p.Status, p.ConfirmationURL, p.Error, p.ID).
+		Scan(&p.UpdatedAt)
+}
+
+// SettlePayment завершает ожидающую попытку оплаты со статусом status и, если платёж
+// прошёл, переводит заказ в оплаченные. Попытка, которая уже завершена, не меняется,
+// поэтому повторное уведомление безопасно: в этом случае возвращается false.
+func (r *PaymentRepository) SettlePayment(ctx context.Context, id int, providerPaymentID, status string) (bool, error) {
+	orderStatus := ""
+	if status == models.PaymentStatusSucceeded {
+		orderStatus = models.OrderStatusPaid
+	}
+	return r.transition(ctx, "payments.settle", id, providerPaymentID, models.PaymentStatusPending, status, orderStatus)
+}
+
+// RefundPayment отмечает оплаченную попытку и её заказ как возвращённые.
+// Если попытка не в статусе succeeded, возвращается false.
+func (r *PaymentRepository) RefundPayment(ctx context.Context, id int) (bool, error) {
+	return r.transition(ctx, "payments.refund", id, "", models.PaymentStatusSucceeded,
+		models.PaymentStatusRefunded, models.OrderStatusRefunded)
+}
+
+// transition в одной транзакции переводит попытку оплаты из статуса from в to и,
+// если orderStatus не пуст, меняет статус заказа с увеличением его версии.
+func (r *PaymentRepository) transition(ctx context.Context, name string, id int, providerPaymentID, from, to, orderStatus string) (bool, error) {
+	ctx, end := startQuery(ctx, name)
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var orderID int
+	query := `UPDATE payments SET status = $1, provider_payment_id = COALESCE(provider_payment_id, NULLIF($2, '')),
+	          updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $3 AND status = $4 RETURNING order_id`
+	err = tx.QueryRowContext(ctx, query, to, providerPaymentID, id, from).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if orderStatus != "" {
+		query = `UPDATE orders SET status = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		         WHERE id = $2 AND status <> $1`
+		if _, err := tx.ExecContext(ctx, query, orderStatus, orderID); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	r.redis.Del(ctx, fmt.Sprintf("order:%d", orderID))
+	return true, nil
+}
@@ -2,73 +2,66 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
+	"strconv"
 	"time"
 
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/storage"
 	"github.com/google/uuid"
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrPhotoNotFound возвращается, если объект отсутствует в хранилище.
+var ErrPhotoNotFound = storage.ErrNotFound
+
 type PhotoRepository struct {
-	client     *minio.Client
-	bucketName string
-	redis      *redis.Client
+	db    *sql.DB
+	store storage.ObjectStore
+	redis *redis.Client
 }
 
-func NewPhotoRepository(
-	minioEndpoint, accessKey, secretKey, bucket string,
-	useSSL bool, redis *redis.Client,
-) (*PhotoRepository, error) {
-
-	client, err := minio.New(minioEndpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: useSSL,
-	})
-	if err != nil {
-		return nil, err
-	}
+// PhotoObject описывает содержимое фотографии, открытое для чтения.
+// Reader поддерживает Seek, поэтому его можно отдавать через http.ServeContent.
+type PhotoObject struct {
+	Reader       io.ReadSeekCloser
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
 
-	ctx := context.Background()
-	exists, err := client.BucketExists(ctx, bucket)
-	if err != nil {
-		return nil, err
-	}
-	if !exists {
-		if err = client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}); err != nil {
-			return nil, err
-		}
-	}
+// NewPhotoRepository создаёт репозиторий фотографий поверх объектного хранилища.
+func NewPhotoRepository(db *sql.DB, store storage.ObjectStore, redis *redis.Client) *PhotoRepository {
+	return &PhotoRepository{db: db, store: store, redis: redis}
+}
 
-	return &PhotoRepository{
-		client:     client,
-		bucketName: bucket,
-		redis:      redis,
-	}, nil
+// NewObjectName генерирует уникальное имя объекта с расширением исходного файла.
+func NewObjectName(filename string) string {
+	return uuid.New().String() + filepath.Ext(filename)
 }
 
-func (r *PhotoRepository) Upload(ctx context.Context, file io.Reader, size int64, filename, contentType string) (string, string, error) {
-	ext := filepath.Ext(filename)
-	objectName := uuid.New().String() + ext
+// Upload сохраняет файл в хранилище и возвращает имя объекта и ссылку на него
+// со сроком жизни expiry.
+func (r *PhotoRepository) Upload(ctx context.Context, file io.Reader, size int64, filename, contentType string, expiry time.Duration) (string, string, error) {
+	objectName := NewObjectName(filename)
 
-	_, err := r.client.PutObject(ctx, r.bucketName, objectName, file, size, minio.PutObjectOptions{
-		ContentType: contentType,
-	})
-	if err != nil {
+	if err := r.store.Put(ctx, objectName, file, size, contentType); err != nil {
 		return "", "", err
 	}
 
-	url, err := r.client.PresignedGetObject(ctx, r.bucketName, objectName, 7*24*time.Hour, nil)
+	presigned, err := r.store.PresignGet(ctx, objectName, expiry)
 	if err != nil {
 		return "", "", err
 	}
 
-	presigned := url.String()
-
 	// Инвалидация кэша
 	cacheKey := fmt.Sprintf("photo_url:%s", objectName)
 	r.redis.Del(ctx, cacheKey)
@@ -76,27 +69,250 @@ func (r *PhotoRepository) Upload(ctx context.Context, file io.Reader, size int64
 	return objectName, presigned, nil
 }
 
-func (r *PhotoRepository) GetPresignedURL(ctx context.Context, objectName string) (string, error) {
+// GetPresignedURL возвращает ссылку на скачивание со сроком жизни expiry, используя кэш
+// Redis. Перед созданием ссылки проверяется, что объект существует: иначе возвращается
+// ErrPhotoNotFound.
+func (r *PhotoRepository) GetPresignedURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
 	cacheKey := fmt.Sprintf("photo_url:%s", objectName)
 
 	// Попробуем из кэша
 	if cached, err := r.redis.Get(ctx, cacheKey).Result(); err == nil {
 		var url string
 		if json.Unmarshal([]byte(cached), &url) == nil {
+			metrics.CacheHit("photo_url")
 			return url, nil
 		}
 	}
+	metrics.CacheMiss("photo_url")
 
 	// Генерируем
-	url, err := r.client.PresignedGetObject(ctx, r.bucketName, objectName, 7*24*time.Hour, nil)
+	if _, err := r.store.Stat(ctx, objectName); err != nil {
+		return "", err
+	}
+	presigned, err := r.store.PresignGet(ctx, objectName, expiry)
 	if err != nil {
 		return "", err
 	}
-	presigned := url.String()
 
-	// Кэшируем
+	// Кэшируем на половину срока жизни ссылки, чтобы не отдавать почти истёкшие URL
 	data, _ := json.Marshal(presigned)
-	r.redis.Set(ctx, cacheKey, data, 7*24*time.Hour)
+	r.redis.Set(ctx, cacheKey, data, expiry/2)
 
 	return presigned, nil
 }
+
+// PresignGet генерирует ссылку на скачивание с заданным сроком жизни без кэширования.
+// Как и GetPresignedURL, для отсутствующего объекта возвращает ErrPhotoNotFound.
+func (r *PhotoRepository) PresignGet(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+	if _, err := r.store.Stat(ctx, objectName); err != nil {
+		return "", err
+	}
+	return r.store.PresignGet(ctx, objectName, expiry)
+}
+
+// GetObject открывает объект из хранилища для потоковой отдачи.
+// Если объекта нет, возвращает ErrPhotoNotFound.
+func (r *PhotoRepository) GetObject(ctx context.Context, objectName string) (*PhotoObject, error) {
+	reader, info, err := r.store.Get(ctx, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PhotoObject{
+		Reader:       reader,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// PresignUpload создаёт форму для прямой загрузки объекта в хранилище.
+// Форма ограничивает имя объекта, Content-Type и размер файла.
+func (r *PhotoRepository) PresignUpload(ctx context.Context, objectName, contentType string, maxSize int64, expiry time.Duration) (string, map[string]string, error) {
+	policy, err := r.store.PresignUpload(ctx, objectName, contentType, maxSize, expiry)
+	if err != nil {
+		return "", nil, err
+	}
+	return policy.URL, policy.FormData, nil
+}
+
+// StatObject возвращает метаданные объекта без загрузки содержимого.
+func (r *PhotoRepository) StatObject(ctx context.Context, objectName string) (*models.Photo, error) {
+	info, err := r.store.Stat(ctx, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Photo{
+		ObjectName:  objectName,
+		Size:        info.Size,
+		ContentType: info.ContentType,
+		CreatedAt:   info.LastModified,
+	}, nil
+}
+
+// RemoveObject удаляет объект из хранилища и сбрасывает кэш ссылки на него.
+// Кэш сбрасывается до и после удаления, чтобы параллельный GetPresignedURL
+// не успел закэшировать ссылку на уже удалённый объект.
+func (r *PhotoRepository) RemoveObject(ctx context.Context, objectName string) error {
+	cacheKey := fmt.Sprintf("photo_url:%s", objectName)
+	r.redis.Del(ctx, cacheKey)
+	if err := r.store.Delete(ctx, objectName); err != nil {
+		return err
+	}
+	r.redis.Del(ctx, cacheKey)
+	return nil
+}
+
+// ListObjects возвращает до limit объектов бакета, следующих за after в лексикографическом порядке.
+// Второе значение — курсор для следующей страницы или пустая строка, если объектов больше нет.
+func (r *PhotoRepository) ListObjects(ctx context.Context, after string, limit int) ([]*models.Photo, string, error) {
+	infos, next, err := r.store.List(ctx, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	photos := make([]*models.Photo, 0, len(infos))
+	for _, info := range infos {
+		photos = append(photos, &models.Photo{
+			ObjectName:  info.Key,
+			Size:        info.Size,
+			ContentType: info.ContentType,
+			CreatedAt:   info.LastModified,
+		})
+	}
+	return photos, next, nil
+}
+
+// FillPhotoDetails дополняет фотографии данными из базы: автором загрузки,
+// Content-Type и списком товаров, в изображениях которых встречается объект.
+func (r *PhotoRepository) FillPhotoDetails(ctx context.Context, photos []*models.Photo) error {
+	if len(photos) == 0 {
+		return nil
+	}
+	byName := make(map[string]*models.Photo, len(photos))
+	names := make([]string, 0, len(photos))
+	for _, p := range photos {
+		p.ProductIDs = []int{}
+		byName[p.ObjectName] = p
+		names = append(names, p.ObjectName)
+	}
+
+	ctx, end := startQuery(ctx, "photos.details")
+	defer end()
+	query := `SELECT object_name, content_type, uploaded_by FROM photos WHERE object_name = ANY($1)`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(names))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, contentType string
+		var uploadedBy sql.NullInt64
+		if err := rows.Scan(&name, &contentType, &uploadedBy); err != nil {
+			return err
+		}
+		if p, ok := byName[name]; ok {
+			if p.ContentType == "" {
+				p.ContentType = contentType
+			}
+			p.UploadedBy = int(uploadedBy.Int64)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	refs, err := r.ProductReferences(ctx, names)
+	if err != nil {
+		return err
+	}
+	for name, ids := range refs {
+		byName[name].ProductIDs = ids
+	}
+	return nil
+}
+
+// ProductReferences возвращает ID товаров, ссылающихся на каждый из объектов.
+// В images товаров может храниться как имя объекта, так и полная ссылка на него.
+func (r *PhotoRepository) ProductReferences(ctx context.Context, names []string) (map[string][]int, error) {
+	query := `SELECT n.name, p.id
+	          FROM unnest($1::text[]) AS n(name)
+	          JOIN products p ON EXISTS (SELECT 1 FROM unnest(p.images) img WHERE strpos(img, n.name) > 0)
+	          ORDER BY p.id`
+	ctx, end := startQuery(ctx, "products.photo_references")
+	defer end()
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := make(map[string][]int)
+	for rows.Next() {
+		var name string
+		var id int
+		if err := rows.Scan(&name, &id); err != nil {
+			return nil, err
+		}
+		refs[name] = append(refs[name], id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// DeletePhoto удаляет объект из хранилища и его запись из базы данных.
+func (r *PhotoRepository) DeletePhoto(ctx context.Context, objectName string) error {
+	if _, err := r.StatObject(ctx, objectName); err != nil {
+		return err
+	}
+	if err := r.RemoveObject(ctx, objectName); err != nil {
+		return err
+	}
+	ctx, end := startQuery(ctx, "photos.delete")
+	defer end()
+	_, err := r.db.ExecContext(ctx, `DELETE FROM photos WHERE object_name = $1`, objectName)
+	return err
+}
+
+// SavePendingUpload запоминает, кто запросил прямую загрузку объекта.
+func (r *PhotoRepository) SavePendingUpload(ctx context.Context, objectName string, userID int, ttl time.Duration) error {
+	cacheKey := fmt.Sprintf("photo_upload:%s", objectName)
+	return r.redis.Set(ctx, cacheKey, userID, ttl).Err()
+}
+
+// GetPendingUpload возвращает ID пользователя, запросившего загрузку. Запись не удаляется,
+// чтобы подтверждение можно было повторить после сбоя. Если запись отсутствует или истекла,
+// возвращает ErrPhotoNotFound.
+func (r *PhotoRepository) GetPendingUpload(ctx context.Context, objectName string) (int, error) {
+	cacheKey := fmt.Sprintf("photo_upload:%s", objectName)
+	value, err := r.redis.Get(ctx, cacheKey).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, ErrPhotoNotFound
+		}
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// DeletePendingUpload удаляет запись о прямой загрузке после её подтверждения.
+func (r *PhotoRepository) DeletePendingUpload(ctx context.Context, objectName string) error {
+	cacheKey := fmt.Sprintf("photo_upload:%s", objectName)
+	return r.redis.Del(ctx, cacheKey).Err()
+}
+
+// CreatePhoto регистрирует загруженную фотографию в базе данных.
+func (r *PhotoRepository) CreatePhoto(ctx context.Context, photo *models.Photo) error {
+	var uploadedBy sql.NullInt64
+	if photo.UploadedBy > 0 {
+		uploadedBy = sql.NullInt64{Int64: int64(photo.UploadedBy), Valid: true}
+	}
+	ctx, end := startQuery(ctx, "photos.create")
+	defer end()
+	query := `INSERT INTO photos (object_name, size, content_type, uploaded_by, created_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.ExecContext(ctx, query, photo.ObjectName, photo.Size, photo.ContentType, uploadedBy, photo.CreatedAt)
+	return err
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
 	"github.com/alex-pyslar/petelka-api/internal/models"
 	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
@@ -17,17 +18,20 @@ import (
 type ProductRepository struct {
 	db    *sql.DB
 	redis *redis.Client
+	ttl   time.Duration // время жизни записей в кэше
 }
 
 // NewProductRepository создаёт новый репозиторий для товаров.
-func NewProductRepository(db *sql.DB, redis *redis.Client) *ProductRepository {
-	return &ProductRepository{db: db, redis: redis}
+func NewProductRepository(db *sql.DB, redis *redis.Client, ttl time.Duration) *ProductRepository {
+	return &ProductRepository{db: db, redis: redis, ttl: ttl}
 }
 
 // CreateProduct создаёт новый товар в базе данных.
 func (r *ProductRepository) CreateProduct(ctx context.Context, product *models.Product) error {
-	query := `INSERT INTO products (name, description, price, category_id, images, type, composition, country_of_origin, length_in_100g, size, garment_length, color) 
-          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
+	query := `INSERT INTO products (name, description, price, category_id, images, type, composition, country_of_origin, length_in_100g, size, garment_length, color, weight, currency) 
+          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, COALESCE(NULLIF($14, ''), 'RUB')) RETURNING id, currency, created_at, updated_at, version`
+	ctx, end := startQuery(ctx, "products.create")
+	defer end()
 	err := r.db.QueryRowContext(ctx, query,
 		product.Name,
 		product.Description,
@@ -41,7 +45,9 @@ func (r *ProductRepository) CreateProduct(ctx context.Context, product *models.P
 		product.Size,
 		product.GarmentLength,
 		product.Color,
-	).Scan(&product.ID)
+		product.Weight,
+		product.Currency,
+	).Scan(&product.ID, &product.Currency, &product.CreatedAt, &product.UpdatedAt, &product.Version)
 	if err != nil {
 		return err
 	}
@@ -57,18 +63,23 @@ func (r *ProductRepository) GetProduct(ctx context.Context, id int) (*models.Pro
 	cached, err := r.redis.Get(ctx, cacheKey).Result()
 	if err == nil {
 		if err := json.Unmarshal([]byte(cached), &product); err == nil {
+			metrics.CacheHit("product")
 			return &product, nil
 		}
 	}
+	metrics.CacheMiss("product")
 
 	// Если в кэше нет, получаем из БД
-	query := `SELECT id, name, description, price, category_id, images, type, composition, country_of_origin, length_in_100g, size, garment_length, color 
+	query := `SELECT id, name, description, price, currency, category_id, images, type, composition, country_of_origin, length_in_100g, size, garment_length, color, weight, created_at, updated_at, version
 	          FROM products WHERE id = $1`
+	ctx, end := startQuery(ctx, "products.get")
+	defer end()
 	err = r.db.QueryRowContext(ctx, query, id).Scan(
 		&product.ID,
 		&product.Name,
 		&product.Description,
 		&product.Price,
+		&product.Currency,
 		&product.CategoryID,
 		pq.Array(&product.Images),
 		&product.Type,
@@ -78,6 +89,10 @@ func (r *ProductRepository) GetProduct(ctx context.Context, id int) (*models.Pro
 		&product.Size,
 		&product.GarmentLength,
 		&product.Color,
+		&product.Weight,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+		&product.Version,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -89,15 +104,17 @@ func (r *ProductRepository) GetProduct(ctx context.Context, id int) (*models.Pro
 	// Сохраняем в кэш
 	data, err := json.Marshal(product)
 	if err == nil {
-		r.redis.Set(ctx, cacheKey, data, 10*time.Minute)
+		r.redis.Set(ctx, cacheKey, data, r.ttl)
 	}
 	return &product, nil
 }
 
 // ListProducts получает список всех товаров.
 func (r *ProductRepository) ListProducts(ctx context.Context) ([]*models.Product, error) {
-	query := `SELECT id, name, description, price, category_id, images, type, composition, country_of_origin, length_in_100g, size, garment_length, color 
+	query := `SELECT id, name, description, price, currency, category_id, images, type, composition, country_of_origin, length_in_100g, size, garment_length, color, weight, created_at, updated_at, version
 	          FROM products`
+	ctx, end := startQuery(ctx, "products.list")
+	defer end()
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -112,6 +129,7 @@ func (r *ProductRepository) ListProducts(ctx context.Context) ([]*models.Product
 			&p.Name,
 			&p.Description,
 			&p.Price,
+			&p.Currency,
 			&p.CategoryID,
 			pq.Array(&p.Images),
 			&p.Type,
@@ -121,6 +139,10 @@ func (r *ProductRepository) ListProducts(ctx context.Context) ([]*models.Product
 			&p.Size,
 			&p.GarmentLength,
 			&p.Color,
+			&p.Weight,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			&p.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -172,7 +194,7 @@ func (r *ProductRepository) SearchProducts(
 		argIndex++
 	}
 
-	query := `SELECT id, name, description, price, category_id, images, type, composition, country_of_origin, length_in_100g, size, garment_length, color 
+	query := `SELECT id, name, description, price, currency, category_id, images, type, composition, country_of_origin, length_in_100g, size, garment_length, color, weight, created_at, updated_at, version
 	          FROM products`
 	countQuery := `SELECT COUNT(*) FROM products`
 
@@ -194,6 +216,8 @@ func (r *ProductRepository) SearchProducts(
 	args = append(args, limit, offset)
 
 	var totalCount int
+	ctx, end := startQuery(ctx, "products.search")
+	defer end()
 	if err := r.db.QueryRowContext(ctx, countQuery, countQueryArgs...).Scan(&totalCount); err != nil {
 		return nil, 0, err
 	}
@@ -208,10 +232,11 @@ func (r *ProductRepository) SearchProducts(
 	for rows.Next() {
 		var p models.Product
 		if err := rows.Scan(
-			&p.ID, &p.Name, &p.Description, &p.Price,
+			&p.ID, &p.Name, &p.Description, &p.Price, &p.Currency,
 			&p.CategoryID, pq.Array(&p.Images), &p.Type, &p.Composition,
 			&p.CountryOfOrigin, &p.LengthIn100g, &p.Size,
-			&p.GarmentLength, &p.Color,
+			&p.GarmentLength, &p.Color, &p.Weight,
+			&p.CreatedAt, &p.UpdatedAt, &p.Version,
 		); err != nil {
 			return nil, 0, err
 		}
@@ -225,12 +250,18 @@ func (r *ProductRepository) SearchProducts(
 	return products, totalCount, nil
 }
 
-// UpdateProduct обновляет существующий товар.
+// UpdateProduct обновляет существующий товар. Если product.Version не равен нулю,
+// товар обновляется только в этой версии, иначе возвращается ErrVersionConflict.
+// Новые версия и время изменения записываются в product.
 func (r *ProductRepository) UpdateProduct(ctx context.Context, product *models.Product) error {
 	query := `UPDATE products SET name = $1, description = $2, price = $3, category_id = $4, images = $5, type = $6, 
-	          composition = $7, country_of_origin = $8, length_in_100g = $9, size = $10, garment_length = $11, color = $12 
-	          WHERE id = $13`
-	result, err := r.db.ExecContext(ctx, query,
+	          composition = $7, country_of_origin = $8, length_in_100g = $9, size = $10, garment_length = $11, color = $12, weight = $13,
+	          version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $14 AND ($15::int = 0 OR version = $15)
+	          RETURNING currency, created_at, updated_at, version`
+	ctx, end := startQuery(ctx, "products.update")
+	defer end()
+	err := updateVersioned(ctx, r.db, "products", product.ID, query, []interface{}{
 		product.Name,
 		product.Description,
 		product.Price,
@@ -243,17 +274,14 @@ func (r *ProductRepository) UpdateProduct(ctx context.Context, product *models.P
 		product.Size,
 		product.GarmentLength,
 		product.Color,
+		product.Weight,
 		product.ID,
-	)
+		product.Version,
+	}, &product.Currency, &product.CreatedAt, &product.UpdatedAt, &product.Version)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
-
 	cacheKey := fmt.Sprintf("product:%d", product.ID)
 	r.redis.Del(ctx, cacheKey)
 
@@ -262,6 +290,8 @@ func (r *ProductRepository) UpdateProduct(ctx context.Context, product *models.P
 
 // DeleteProduct удаляет товар по ID.
 func (r *ProductRepository) DeleteProduct(ctx context.Context, id int) error {
+	ctx, end := startQuery(ctx, "products.delete")
+	defer end()
 	query := `DELETE FROM products WHERE id = $1`
 	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
@@ -278,3 +308,28 @@ func (r *ProductRepository) DeleteProduct(ctx context.Context, id int) error {
 
 	return nil
 }
+
+// ProductNames возвращает названия товаров по их ID; удалённых товаров в результате нет.
+func (r *ProductRepository) ProductNames(ctx context.Context, ids []int) (map[int]string, error) {
+	ctx, end := startQuery(ctx, "products.names")
+	defer end()
+	rows, err := r.db.QueryContext(ctx, `SELECT id, name FROM products WHERE id = ANY($1)`, arrayFromInts(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[int]string, len(ids))
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
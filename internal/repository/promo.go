@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/lib/pq"
+)
+
+// ErrPromoCodeExhausted возвращается, если лимит использований промокода — общий
+// или на покупателя — уже исчерпан.
+var ErrPromoCodeExhausted = errors.New("promo code usage limit reached")
+
+// ErrPromoCodeUnavailable возвращается, если к моменту сохранения заказа промокод
+// удалён, выключен или вне срока действия.
+var ErrPromoCodeUnavailable = errors.New("promo code is no longer available")
+
+// PromoRepository хранит промокоды и учёт их использования.
+type PromoRepository struct {
+	db *sql.DB
+}
+
+// NewPromoRepository создаёт репозиторий промокодов.
+func NewPromoRepository(db *sql.DB) *PromoRepository {
+	return &PromoRepository{db: db}
+}
+
+const promoCodeColumns = `id, code, kind, percent_bp, amount, starts_at, ends_at, max_uses, max_uses_per_user, min_order_total, currency,
+	product_ids, category_ids, is_active, created_at, updated_at, version`
+
+func scanPromoCode(row interface{ Scan(...interface{}) error }) (*models.PromoCode, error) {
+	p := &models.PromoCode{}
+	var startsAt, endsAt sql.NullTime
+	var maxUses, maxUsesPerUser sql.NullInt64
+	var productIDs, categoryIDs pq.Int64Array
+	err := row.Scan(&p.ID, &p.Code, &p.Kind, &p.PercentBP, &p.Amount, &startsAt, &endsAt, &maxUses, &maxUsesPerUser, &p.MinOrderTotal, &p.Currency,
+		&productIDs, &categoryIDs, &p.IsActive, &p.CreatedAt, &p.UpdatedAt, &p.Version)
+	if err != nil {
+		return nil, err
+	}
+	p.StartsAt = nullTime(startsAt)
+	p.EndsAt = nullTime(endsAt)
+	p.MaxUses = nullInt(maxUses)
+	p.MaxUsesPerUser = nullInt(maxUsesPerUser)
+	p.ProductIDs = intsFromArray(productIDs)
+	p.CategoryIDs = intsFromArray(categoryIDs)
+	return p, nil
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullInt(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
+
+func intsFromArray(a pq.Int64Array) []int {
+	ids := make([]int, len(a))
+	for i, id := range a {
+		ids[i] = int(id)
+	}
+	return ids
+}
+
+func arrayFromInts(ids []int) pq.Int64Array {
+	a := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		a[i] = int64(id)
+	}
+	return a
+}
+
+// ListPromoCodes возвращает все промокоды, начиная с последних.
+func (r *PromoRepository) ListPromoCodes(ctx context.Context) ([]*models.PromoCode, error) {
+	ctx, end := startQuery(ctx, "promo_codes.list")
+	defer end()
+	rows, err := r.db.QueryContext(ctx, `SELECT `+promoCodeColumns+` FROM promo_codes ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := []*models.PromoCode{}
+	for rows.Next() {
+		p, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// GetPromoCode возвращает промокод по ID.
+func (r *PromoRepository) GetPromoCode(ctx context.Context, id int) (*models.PromoCode, error) {
+	ctx, end := startQuery(ctx, "promo_codes.get")
+	defer end()
+	return scanPromoCode(r.db.QueryRowContext(ctx, `SELECT `+promoCodeColumns+` FROM promo_codes WHERE id = $1`, id))
+}
+
+// GetPromoCodeByCode возвращает промокод по коду в верхнем регистре.
+func (r *PromoRepository) GetPromoCodeByCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	ctx, end := startQuery(ctx, "promo_codes.get_by_code")
+	defer end()
+	return scanPromoCode(r.db.QueryRowContext(ctx, `SELECT `+promoCodeColumns+` FROM promo_codes WHERE code = $1`, code))
+}
+
+// CreatePromoCode сохраняет новый промокод. Если код уже занят, возвращается ошибка уникальности (23505).
+func (r *PromoRepository) CreatePromoCode(ctx context.Context, p *models.PromoCode) error {
+	ctx, end := startQuery(ctx, "promo_codes.create")
+	defer end()
+	query := `INSERT INTO promo_codes (code, kind, percent_bp, amount, starts_at, ends_at, max_uses, max_uses_per_user,
+	              min_order_total, product_ids, category_ids, is_active)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	          RETURNING id, currency, created_at, updated_at, version`
+	return r.db.QueryRowContext(ctx, query, p.Code, p.Kind, p.PercentBP, p.Amount, p.StartsAt, p.EndsAt, p.MaxUses, p.MaxUsesPerUser,
+		p.MinOrderTotal, arrayFromInts(p.ProductIDs), arrayFromInts(p.CategoryIDs), p.IsActive).
+		Scan(&p.ID, &p.Currency, &p.CreatedAt, &p.UpdatedAt, &p.Version)
+}
+
+// UpdatePromoCode изменяет промокод, если его версия совпадает с p.Version (0 — без проверки).
+// Если промокод не найден, возвращается sql.ErrNoRows, если изменён — ErrVersionConflict.
+func (r *PromoRepository) UpdatePromoCode(ctx context.Context, p *models.PromoCode) error {
+	ctx, end := startQuery(ctx, "promo_codes.update")
+	defer end()
+	query := `UPDATE promo_codes SET code = $1, kind = $2, percent_bp = $3, amount = $4, starts_at = $5, ends_at = $6,
+	              max_uses = $7, max_uses_per_user = $8, min_order_total = $9, product_ids = $10, category_ids = $11,
+	              is_active = $12, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $13 AND ($14::int = 0 OR version = $14)
+	          RETURNING currency, created_at, updated_at, version`
+	return updateVersioned(ctx, r.db, "promo_codes", p.ID, query,
+		[]interface{}{p.Code, p.Kind, p.PercentBP, p.Amount, p.StartsAt, p.EndsAt, p.MaxUses, p.MaxUsesPerUser,
+			p.MinOrderTotal, arrayFromInts(p.ProductIDs), arrayFromInts(p.CategoryIDs), p.IsActive, p.ID, p.Version},
+		&p.Currency, &p.CreatedAt, &p.UpdatedAt, &p.Version)
+}
+
+// DeletePromoCode удаляет промокод вместе с учётом его использований; заказы сохраняют
+// код и скидку. Если промокод не найден, возвращается sql.ErrNoRows.
+func (r *PromoRepository) DeletePromoCode(ctx context.Context, id int) error {
+	ctx, end := startQuery(ctx, "promo_codes.delete")
+	defer end()
+	result, err := r.db.ExecContext(ctx, `DELETE FROM promo_codes WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CountUsages возвращает число использований промокода всего и покупателем userID.
+func (r *PromoRepository) CountUsages(ctx context.Context, promoCodeID, userID int) (total, byUser int, err error) {
+	ctx, end := startQuery(ctx, "promo_code_usages.count")
+	defer end()
+	query := `SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2) FROM promo_code_usages WHERE promo_code_id = $1`
+	err = r.db.QueryRowContext(ctx, query, promoCodeID, userID).Scan(&total, &byUser)
+	return total, byUser, err
+}
+
+// Stats возвращает статистику использования промокода. Если промокод не найден,
+// возвращается sql.ErrNoRows.
+func (r *PromoRepository) Stats(ctx context.Context, id int) (*models.PromoCodeStats, error) {
+	ctx, end := startQuery(ctx, "promo_code_usages.stats")
+	defer end()
+	query := `SELECT p.id, COUNT(u.id), COUNT(DISTINCT u.user_id), COALESCE(SUM(u.discount), 0)::bigint,
+	              COALESCE(SUM(o.total), 0)::bigint, MIN(u.created_at), MAX(u.created_at)
+	          FROM promo_codes p
+	          LEFT JOIN promo_code_usages u ON u.promo_code_id = p.id
+	          LEFT JOIN orders o ON o.id = u.order_id
+	          WHERE p.id = $1
+	          GROUP BY p.id`
+	stats := &models.PromoCodeStats{}
+	var first, last sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&stats.PromoCodeID, &stats.Uses, &stats.Users,
+		&stats.DiscountTotal, &stats.OrdersTotal, &first, &last)
+	if err != nil {
+		return nil, err
+	}
+	stats.FirstUsedAt = nullTime(first)
+	stats.LastUsedAt = nullTime(last)
+	return stats, nil
+}
+
+// usePromoCode учитывает использование промокода order.PromoCode заказом order в транзакции
+// tx. Строка промокода блокируется до конца транзакции, поэтому параллельные заказы
+// не превысят лимиты, а промокод, удалённый, выключенный или истёкший после расчёта
+// заказа, не будет применён (ErrPromoCodeUnavailable).
+func usePromoCode(ctx context.Context, tx *sql.Tx, order *models.Order) error {
+	var id int
+	var isActive bool
+	var startsAt, endsAt sql.NullTime
+	var maxUses, maxUsesPerUser sql.NullInt64
+	err := tx.QueryRowContext(ctx, `SELECT id, is_active, starts_at, ends_at, max_uses, max_uses_per_user
+	          FROM promo_codes WHERE code = $1 FOR UPDATE`,
+		order.PromoCode).Scan(&id, &isActive, &startsAt, &endsAt, &maxUses, &maxUsesPerUser)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPromoCodeUnavailable
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if !isActive || (startsAt.Valid && now.Before(startsAt.Time)) || (endsAt.Valid && !now.Before(endsAt.Time)) {
+		return ErrPromoCodeUnavailable
+	}
+
+	var total, byUser int64
+	query := `SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2) FROM promo_code_usages WHERE promo_code_id = $1`
+	if err := tx.QueryRowContext(ctx, query, id, order.UserID).Scan(&total, &byUser); err != nil {
+		return err
+	}
+	if (maxUses.Valid && total >= maxUses.Int64) || (maxUsesPerUser.Valid && byUser >= maxUsesPerUser.Int64) {
+		return ErrPromoCodeExhausted
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO promo_code_usages (promo_code_id, user_id, order_id, discount)
+	          VALUES ($1, $2, $3, $4)`, id, order.UserID, order.ID, order.Discount)
+	return err
+}
@@ -2,11 +2,16 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
 	"github.com/alex-pyslar/petelka-api/internal/models"
 	"github.com/redis/go-redis/v9"
 )
@@ -15,69 +20,87 @@ import (
 type UserRepository struct {
 	db    *sql.DB
 	redis *redis.Client
+	ttl   time.Duration // время жизни записей в кэше
 }
 
 // NewUserRepository создаёт новый репозиторий для пользователей.
-func NewUserRepository(db *sql.DB, redis *redis.Client) *UserRepository {
-	return &UserRepository{db: db, redis: redis}
+func NewUserRepository(db *sql.DB, redis *redis.Client, ttl time.Duration) *UserRepository {
+	return &UserRepository{db: db, redis: redis, ttl: ttl}
 }
 
 // CreateUser creates a new user in the database.
 func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
-	query := `INSERT INTO users (email, name, password, created_at, role) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	query := `INSERT INTO users (email, name, password, created_at, role, phone) VALUES ($1, $2, $3, $4, $5, $6)
+	          RETURNING id, created_at, updated_at, version`
 
-	err := r.db.QueryRowContext(ctx, query, user.Email, user.Name, user.Password, time.Now(), user.Role).Scan(&user.ID)
+	ctx, end := startQuery(ctx, "users.create")
+	defer end()
+	err := r.db.QueryRowContext(ctx, query, user.Email, user.Name, user.Password, time.Now(), user.Role, user.Phone).
+		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Version)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// GetUser gets a user by ID, using Redis cache.
+// userColumns — столбцы пользователя для scanUser. Хеш пароля в них не входит.
+const userColumns = `id, email, name, role, phone, created_at, updated_at, version, blocked_at, deleted_at`
+
+// activeUsers — пользователи, которые не удалены; используется для проверки существования
+// записи при обновлении, чтобы изменение удалённого пользователя возвращало sql.ErrNoRows.
+const activeUsers = `(SELECT id FROM users WHERE deleted_at IS NULL) AS active_users`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.Phone, &u.CreatedAt, &u.UpdatedAt, &u.Version, &u.BlockedAt, &u.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUser gets a user by ID, using Redis cache. Удалённые пользователи не возвращаются.
 func (r *UserRepository) GetUser(ctx context.Context, id int) (*models.User, error) {
 	cacheKey := fmt.Sprintf("user:%d", id)
-	var user models.User
 
 	cached, err := r.redis.Get(ctx, cacheKey).Result()
 	if err == nil {
+		var user models.User
 		if err := json.Unmarshal([]byte(cached), &user); err == nil {
+			metrics.CacheHit("user")
 			return &user, nil
 		}
 	}
+	metrics.CacheMiss("user")
 
-	query := `SELECT id, email, name, role, password, created_at FROM users WHERE id = $1`
-	err = r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Password, &user.CreatedAt)
+	ctx, end := startQuery(ctx, "users.get")
+	defer end()
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at IS NULL`
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, sql.ErrNoRows
-		}
 		return nil, err
 	}
 
 	data, err := json.Marshal(user)
 	if err == nil {
-		r.redis.Set(ctx, cacheKey, data, 10*time.Minute)
+		r.redis.Set(ctx, cacheKey, data, r.ttl)
 	}
-	return &user, nil
+	return user, nil
 }
 
 // GetUserByEmail gets a user by email.
 func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	var user models.User
-	query := `SELECT id, email, name, role, password, created_at FROM users WHERE email = $1`
-	err := r.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Password, &user.CreatedAt)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, sql.ErrNoRows
-		}
-		return nil, err
-	}
-	return &user, nil
+	ctx, end := startQuery(ctx, "users.get_by_email")
+	defer end()
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1 AND deleted_at IS NULL`
+	return scanUser(r.db.QueryRowContext(ctx, query, email))
 }
 
-// ListUsers gets a list of all users.
+// ListUsers gets a list of all users, кроме удалённых.
 func (r *UserRepository) ListUsers(ctx context.Context) ([]*models.User, error) {
-	query := `SELECT id, email, name, role, password, created_at FROM users`
+	ctx, end := startQuery(ctx, "users.list")
+	defer end()
+	query := `SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL ORDER BY id`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -86,11 +109,11 @@ func (r *UserRepository) ListUsers(ctx context.Context) ([]*models.User, error)
 
 	var users []*models.User
 	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.Password, &u.CreatedAt); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
-		users = append(users, &u)
+		users = append(users, u)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -98,17 +121,116 @@ func (r *UserRepository) ListUsers(ctx context.Context) ([]*models.User, error)
 	return users, nil
 }
 
-// UpdateUser updates an existing user.
-func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) error {
-	query := `UPDATE users SET email = $1, name = $2, role = $3, password = $4 WHERE id = $5`
-	result, err := r.db.ExecContext(ctx, query, user.Email, user.Name, user.Role, user.Password, user.ID)
+// UserFilter — условия поиска пользователей администратором.
+type UserFilter struct {
+	Email       string    // часть email без учёта регистра
+	Name        string    // часть имени без учёта регистра
+	Role        string    // точное совпадение роли
+	Status      string    // models.UserStatus*; пустой — активные и заблокированные
+	CreatedFrom time.Time // регистрация не раньше этого момента; нулевой — без ограничения
+	CreatedTo   time.Time // регистрация раньше этого момента; нулевой — без ограничения
+	Page        int
+	Limit       int
+}
+
+// SearchUsers ищет пользователей по фильтру и возвращает страницу результатов
+// вместе с общим числом найденных.
+func (r *UserRepository) SearchUsers(ctx context.Context, filter UserFilter) ([]*models.User, int, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(format string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.Email != "" {
+		addCondition("LOWER(email) LIKE $%d", "%"+strings.ToLower(filter.Email)+"%")
+	}
+	if filter.Name != "" {
+		addCondition("LOWER(name) LIKE $%d", "%"+strings.ToLower(filter.Name)+"%")
+	}
+	if filter.Role != "" {
+		addCondition("role = $%d", filter.Role)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		addCondition("created_at >= $%d", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		addCondition("created_at < $%d", filter.CreatedTo)
+	}
+	switch filter.Status {
+	case models.UserStatusActive:
+		conditions = append(conditions, "deleted_at IS NULL AND blocked_at IS NULL")
+	case models.UserStatusBlocked:
+		conditions = append(conditions, "deleted_at IS NULL AND blocked_at IS NOT NULL")
+	case models.UserStatusDeleted:
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	default:
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+
+	ctx, end := startQuery(ctx, "users.search")
+	defer end()
+
+	var totalCount int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+where, args...).Scan(&totalCount); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users` + where +
+		fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit, (filter.Page-1)*filter.Limit)...)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
+	defer rows.Close()
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	users := []*models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return users, totalCount, nil
+}
+
+// SetBlocked блокирует пользователя или снимает блокировку. Повторная блокировка
+// сохраняет исходное время. Для удалённого пользователя возвращается sql.ErrNoRows.
+func (r *UserRepository) SetBlocked(ctx context.Context, id int, blocked bool) error {
+	ctx, end := startQuery(ctx, "users.set_blocked")
+	defer end()
+	query := `UPDATE users SET blocked_at = CASE WHEN $1 THEN COALESCE(blocked_at, CURRENT_TIMESTAMP) END,
+	          version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $2 AND deleted_at IS NULL`
+	return r.execUserUpdate(ctx, id, query, blocked, id)
+}
+
+// UpdateUser updates an existing user. Пароль меняется отдельно через UpdatePassword.
+// Если user.Version не равен нулю, при несовпадении версии возвращается ErrVersionConflict.
+func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	ctx, end := startQuery(ctx, "users.update")
+	defer end()
+	query := `UPDATE users SET email = $1, name = $2, role = $3, phone = $4, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $5 AND ($6::int = 0 OR version = $6) AND deleted_at IS NULL
+	          RETURNING created_at, updated_at, version`
+	err := updateVersioned(ctx, r.db, activeUsers, user.ID, query,
+		[]interface{}{user.Email, user.Name, user.Role, user.Phone, user.ID, user.Version},
+		&user.CreatedAt, &user.UpdatedAt, &user.Version)
+	if err != nil {
+		return err
 	}
 
 	cacheKey := fmt.Sprintf("user:%d", user.ID)
@@ -117,29 +239,80 @@ func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) erro
 	return nil
 }
 
-// DeleteUser удаляет пользователя по ID.
+// DeleteUser удаляет пользователя мягко: запись остаётся, чтобы сохранить историю
+// заказов и отзывов, но email, имя, телефон и пароль обезличиваются, журнал входов
+// теряет email, IP и User-Agent, адресная книга удаляется, в копиях адресов заказов
+// остаются только регион и город, а записи журнала аудита о пользователе теряют его
+// персональные данные. Войти в удалённый аккаунт нельзя.
 func (r *UserRepository) DeleteUser(ctx context.Context, id int) error {
-	query := `DELETE FROM users WHERE id = $1`
-	result, err := r.db.ExecContext(ctx, query, id)
+	ctx, end := startQuery(ctx, "users.delete")
+	defer end()
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	anonymousEmail := fmt.Sprintf("deleted-%d@deleted.invalid", id)
+	query := `UPDATE users SET email = $1, name = 'Deleted user', phone = '', password = '',
+	          blocked_at = NULL, deleted_at = CURRENT_TIMESTAMP, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $2 AND deleted_at IS NULL`
+	result, err := tx.ExecContext(ctx, query, anonymousEmail, id)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
 		return sql.ErrNoRows
 	}
 
-	cacheKey := fmt.Sprintf("user:%d", id)
-	r.redis.Del(ctx, cacheKey)
+	query = `UPDATE login_attempts SET email = $1, ip = '', user_agent = '' WHERE user_id = $2`
+	if _, err := tx.ExecContext(ctx, query, anonymousEmail, id); err != nil {
+		return err
+	}
+	if err := anonymizeUserAudit(ctx, tx, id); err != nil {
+		return err
+	}
+	// ON DELETE CASCADE при мягком удалении не срабатывает
+	if _, err := tx.ExecContext(ctx, `DELETE FROM addresses WHERE user_id = $1`, id); err != nil {
+		return err
+	}
+	query = `UPDATE orders SET shipping_address = shipping_address - 'postal_code' - 'comment'
+	             || '{"recipient": "", "phone": "", "street": ""}'::jsonb
+	         WHERE user_id = $1 AND shipping_address IS NOT NULL
+	         RETURNING id`
+	rows, err := tx.QueryContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	cacheKeys := []string{fmt.Sprintf("user:%d", id)}
+	for rows.Next() {
+		var orderID int
+		if err := rows.Scan(&orderID); err != nil {
+			rows.Close()
+			return err
+		}
+		cacheKeys = append(cacheKeys, fmt.Sprintf("order:%d", orderID))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 
+	r.redis.Del(ctx, cacheKeys...)
 	return nil
 }
 
 // GetUserPassword получает хешированный пароль пользователя по ID.
+// Это единственный запрос, читающий хеш: остальные методы его не загружают.
 func (r *UserRepository) GetUserPassword(ctx context.Context, id int) (string, error) {
 	var hashedPassword string
-	query := `SELECT password FROM users WHERE id = $1`
+	ctx, end := startQuery(ctx, "users.get_password")
+	defer end()
+	query := `SELECT password FROM users WHERE id = $1 AND deleted_at IS NULL`
 	err := r.db.QueryRowContext(ctx, query, id).Scan(&hashedPassword)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -149,3 +322,76 @@ func (r *UserRepository) GetUserPassword(ctx context.Context, id int) (string, e
 	}
 	return hashedPassword, nil
 }
+
+// UpdateProfile обновляет имя и телефон пользователя с проверкой user.Version
+// (см. UpdateUser) и записывает в user новую версию.
+func (r *UserRepository) UpdateProfile(ctx context.Context, user *models.User) error {
+	ctx, end := startQuery(ctx, "users.update_profile")
+	defer end()
+	query := `UPDATE users SET name = $1, phone = $2, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $3 AND ($4::int = 0 OR version = $4) AND deleted_at IS NULL
+	          RETURNING updated_at, version`
+	err := updateVersioned(ctx, r.db, activeUsers, user.ID, query,
+		[]interface{}{user.Name, user.Phone, user.ID, user.Version}, &user.UpdatedAt, &user.Version)
+	if err != nil {
+		return err
+	}
+	r.redis.Del(ctx, fmt.Sprintf("user:%d", user.ID))
+	return nil
+}
+
+// UpdateEmail меняет email пользователя. Занятый email приводит к ошибке уникальности.
+func (r *UserRepository) UpdateEmail(ctx context.Context, id int, email string) error {
+	ctx, end := startQuery(ctx, "users.update_email")
+	defer end()
+	query := `UPDATE users SET email = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND deleted_at IS NULL`
+	return r.execUserUpdate(ctx, id, query, email, id)
+}
+
+// UpdatePassword сохраняет новый хеш пароля пользователя. Хеш не входит в представление
+// пользователя в API, поэтому его смена не меняет версию записи.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, hashedPassword string) error {
+	ctx, end := startQuery(ctx, "users.update_password")
+	defer end()
+	query := `UPDATE users SET password = $1 WHERE id = $2 AND deleted_at IS NULL`
+	return r.execUserUpdate(ctx, id, query, hashedPassword, id)
+}
+
+// execUserUpdate выполняет UPDATE одного пользователя и сбрасывает его запись в кэше.
+func (r *UserRepository) execUserUpdate(ctx context.Context, id int, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	r.redis.Del(ctx, fmt.Sprintf("user:%d", id))
+	return nil
+}
+
+// emailChangeKey хранит в Redis хеш токена, а не сам токен.
+func emailChangeKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "email_change:" + hex.EncodeToString(sum[:])
+}
+
+// SaveEmailChange запоминает запрос на смену email до подтверждения по токену.
+func (r *UserRepository) SaveEmailChange(ctx context.Context, token string, id int, email string, ttl time.Duration) error {
+	return r.redis.Set(ctx, emailChangeKey(token), strconv.Itoa(id)+":"+email, ttl).Err()
+}
+
+// TakeEmailChange возвращает и удаляет запрос на смену email.
+// Если токен неизвестен или истёк, возвращается redis.Nil.
+func (r *UserRepository) TakeEmailChange(ctx context.Context, token string) (int, string, error) {
+	value, err := r.redis.GetDel(ctx, emailChangeKey(token)).Result()
+	if err != nil {
+		return 0, "", err
+	}
+	idPart, email, _ := strings.Cut(value, ":")
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		return 0, "", fmt.Errorf("malformed email change record: %w", err)
+	}
+	return id, email, nil
+}
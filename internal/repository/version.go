@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrVersionConflict возвращается, если запись изменилась после того, как клиент
+// прочитал её версию (оптимистическая блокировка по столбцу version).
+var ErrVersionConflict = errors.New("record was modified by another request")
+
+// rowQuerier выполняет запрос, возвращающий одну строку: *sql.DB или *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// updateVersioned выполняет UPDATE ... RETURNING над одной записью table и сканирует
+// возвращённые столбцы в dest. table — таблица или подзапрос в FROM, по которому
+// проверяется существование записи. Запрос должен увеличивать version и проверять ожидаемую
+// версию условием "($n::int = 0 OR version = $n)", где 0 означает безусловное обновление.
+// Если запись не обновилась, отличает её отсутствие (sql.ErrNoRows) от конфликта версий.
+// db — соединение или транзакция.
+func updateVersioned(ctx context.Context, db rowQuerier, table string, id int, query string, args []interface{}, dest ...interface{}) error {
+	err := db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM `+table+` WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return sql.ErrNoRows
+}
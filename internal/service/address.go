@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+)
+
+// ErrInvalidAddress — адрес не заполнен или заполнен неверно.
+var ErrInvalidAddress = errors.New("invalid address")
+
+// AddressService управляет адресной книгой пользователя.
+type AddressService struct {
+	repo *repository.AddressRepository
+}
+
+// NewAddressService создаёт сервис адресной книги.
+func NewAddressService(repo *repository.AddressRepository) *AddressService {
+	return &AddressService{repo: repo}
+}
+
+// ListAddresses возвращает адреса пользователя, адрес по умолчанию — первым.
+func (s *AddressService) ListAddresses(ctx context.Context, userID int) ([]*models.Address, error) {
+	addresses, err := s.repo.ListAddresses(ctx, userID)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Failed to fetch addresses of user ID %d: %v", userID, err)
+		return nil, fmt.Errorf("failed to fetch addresses: %w", err)
+	}
+	return addresses, nil
+}
+
+// GetAddress возвращает адрес пользователя userID. Чужой адрес считается
+// отсутствующим (sql.ErrNoRows).
+func (s *AddressService) GetAddress(ctx context.Context, userID, id int) (*models.Address, error) {
+	address, err := s.repo.GetAddress(ctx, userID, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("address not found: %w", err)
+		}
+		logger.FromContext(ctx).Errorf("Failed to fetch address ID %d: %v", id, err)
+		return nil, fmt.Errorf("failed to fetch address: %w", err)
+	}
+	return address, nil
+}
+
+// CreateAddress проверяет и сохраняет новый адрес пользователя a.UserID.
+func (s *AddressService) CreateAddress(ctx context.Context, a *models.Address) error {
+	log := logger.FromContext(ctx)
+	if err := normalizeAddress(&a.ShippingAddress); err != nil {
+		return err
+	}
+	if err := s.repo.CreateAddress(ctx, a); err != nil {
+		log.Errorf("Failed to create address for user ID %d: %v", a.UserID, err)
+		return fmt.Errorf("failed to create address: %w", err)
+	}
+	log.Infof("Created address ID %d for user ID %d", a.ID, a.UserID)
+	return nil
+}
+
+// UpdateAddress проверяет и сохраняет изменённый адрес. Чужой адрес считается
+// отсутствующим (sql.ErrNoRows).
+func (s *AddressService) UpdateAddress(ctx context.Context, a *models.Address) error {
+	log := logger.FromContext(ctx)
+	if err := normalizeAddress(&a.ShippingAddress); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateAddress(ctx, a); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("address not found: %w", err)
+		}
+		log.Errorf("Failed to update address ID %d: %v", a.ID, err)
+		return fmt.Errorf("failed to update address: %w", err)
+	}
+	log.Infof("Updated address ID %d of user ID %d", a.ID, a.UserID)
+	return nil
+}
+
+// DeleteAddress удаляет адрес пользователя. Оформленные заказы хранят копию адреса.
+func (s *AddressService) DeleteAddress(ctx context.Context, userID, id int) error {
+	log := logger.FromContext(ctx)
+	if err := s.repo.DeleteAddress(ctx, userID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("address not found: %w", err)
+		}
+		log.Errorf("Failed to delete address ID %d: %v", id, err)
+		return fmt.Errorf("failed to delete address: %w", err)
+	}
+	log.Infof("Deleted address ID %d of user ID %d", id, userID)
+	return nil
+}
+
+// normalizeAddress убирает лишние пробелы и проверяет обязательные поля адреса.
+func normalizeAddress(a *models.ShippingAddress) error {
+	for _, field := range []*string{&a.Recipient, &a.Region, &a.City, &a.Street, &a.PostalCode, &a.Comment} {
+		*field = strings.TrimSpace(*field)
+	}
+	a.Phone = normalizePhone(a.Phone)
+
+	switch {
+	case a.Recipient == "" || len(a.Recipient) > 255:
+		return fmt.Errorf("%w: recipient must be 1 to 255 characters", ErrInvalidAddress)
+	case !phonePattern.MatchString(a.Phone):
+		return fmt.Errorf("%w: phone must contain 10 to 15 digits", ErrInvalidAddress)
+	case a.Region == "" || len(a.Region) > 100:
+		return fmt.Errorf("%w: region must be 1 to 100 characters", ErrInvalidAddress)
+	case a.City == "" || len(a.City) > 100:
+		return fmt.Errorf("%w: city must be 1 to 100 characters", ErrInvalidAddress)
+	case a.Street == "" || len(a.Street) > 255:
+		return fmt.Errorf("%w: street must be 1 to 255 characters", ErrInvalidAddress)
+	case len(a.PostalCode) > 20:
+		return fmt.Errorf("%w: postal_code must be at most 20 characters", ErrInvalidAddress)
+	case len(a.Comment) > 1000:
+		return fmt.Errorf("%w: comment must be at most 1000 characters", ErrInvalidAddress)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+)
+
+// AuditFilter — условия выборки записей журнала аудита.
+type AuditFilter = repository.AuditFilter
+
+// AuditService ведёт журнал аудита действий администраторов.
+type AuditService struct {
+	repo *repository.AuditRepository
+}
+
+// NewAuditService создаёт сервис журнала аудита.
+func NewAuditService(repo *repository.AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record сохраняет запись журнала. before и after — JSON-представления ресурса до
+// и после изменения (пустые, если ресурса не было или он удалён); в запись попадают
+// только различающиеся поля.
+func (s *AuditService) Record(ctx context.Context, entry *models.AuditEntry, before, after []byte) error {
+	log := logger.FromContext(ctx)
+
+	changes, err := DiffJSON(before, after)
+	if err != nil {
+		log.Warningf("Failed to diff %s %s for audit: %v", entry.ResourceType, entry.ResourceID, err)
+		changes = json.RawMessage("{}")
+	}
+	entry.Changes = changes
+
+	if err := s.repo.CreateEntry(ctx, entry); err != nil {
+		log.Errorf("Failed to record audit entry %s %s %s: %v", entry.Action, entry.ResourceType, entry.ResourceID, err)
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListEntries возвращает страницу журнала аудита по фильтру.
+func (s *AuditService) ListEntries(ctx context.Context, filter AuditFilter) ([]*models.AuditEntry, int, error) {
+	log := logger.FromContext(ctx)
+	log.Debugf("Listing audit entries: action=%q resource=%s/%s page=%d limit=%d",
+		filter.Action, filter.ResourceType, filter.ResourceID, filter.Page, filter.Limit)
+
+	entries, total, err := s.repo.ListEntries(ctx, filter)
+	if err != nil {
+		log.Errorf("Failed to list audit entries: %v", err)
+		return nil, 0, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	return entries, total, nil
+}
+
+// Prune удаляет записи журнала старше retention и возвращает их число.
+func (s *AuditService) Prune(ctx context.Context, retention time.Duration) (int64, error) {
+	log := logger.FromContext(ctx)
+
+	before := time.Now().Add(-retention)
+	deleted, err := s.repo.PruneEntries(ctx, before)
+	if err != nil {
+		log.Errorf("Failed to prune audit entries: %v", err)
+		return 0, fmt.Errorf("failed to prune audit entries: %w", err)
+	}
+	log.Infof("Pruned %d audit entries older than %s", deleted, before.Format(time.RFC3339))
+	return deleted, nil
+}
+
+// AuditChange — значение поля до и после изменения. Отсутствующее значение опускается.
+type AuditChange struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// DiffJSON сравнивает два JSON-объекта по полям верхнего уровня и возвращает
+// изменившиеся поля в виде {"поле": {"before": ..., "after": ...}}. Пустой документ
+// считается пустым объектом. Документы, которые не являются объектами, сравниваются
+// целиком под ключом "$".
+func DiffJSON(before, after []byte) (json.RawMessage, error) {
+	var b, a interface{}
+	if err := decodeAuditDoc(before, &b); err != nil {
+		return nil, err
+	}
+	if err := decodeAuditDoc(after, &a); err != nil {
+		return nil, err
+	}
+
+	changes := map[string]AuditChange{}
+	bObj, bOK := b.(map[string]interface{})
+	aObj, aOK := a.(map[string]interface{})
+	if (b != nil && !bOK) || (a != nil && !aOK) {
+		if !reflect.DeepEqual(a, b) {
+			changes["$"] = AuditChange{Before: b, After: a}
+		}
+		return json.Marshal(changes)
+	}
+
+	for key, value := range bObj {
+		if !reflect.DeepEqual(value, aObj[key]) {
+			changes[key] = AuditChange{Before: value, After: aObj[key]}
+		}
+	}
+	for key, value := range aObj {
+		if _, ok := bObj[key]; !ok && value != nil {
+			changes[key] = AuditChange{After: value}
+		}
+	}
+	return json.Marshal(changes)
+}
+
+// decodeAuditDoc декодирует JSON с сохранением точности чисел; пустой ввод даёт nil.
+func decodeAuditDoc(data []byte, v interface{}) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+)
+
+// ErrInvalidCheckout — заказ нельзя оформить: нет позиций, неизвестный товар или способ доставки.
+var ErrInvalidCheckout = errors.New("invalid order")
+
+// maxItemQuantity ограничивает количество одного товара в заказе.
+const maxItemQuantity = 999
+
+// CheckoutItem — товар и его количество в оформляемом заказе.
+type CheckoutItem struct {
+	ProductID int
+	Quantity  int
+}
+
+// CheckoutRequest — данные для оформления заказа. AddressID — адрес из адресной
+// книги покупателя, обязателен для всех способов доставки, кроме самовывоза.
+// PromoCode необязателен.
+type CheckoutRequest struct {
+	Items          []CheckoutItem
+	DeliveryMethod string
+	AddressID      int
+	PromoCode      string
+}
+
+// CheckoutService оформляет заказы: цены товаров, стоимость доставки и скидка
+// по промокоду рассчитываются на сервере, а не принимаются от клиента. Цены
+// включают НДС по ставке vatRate, который выделяется в каждой позиции и в доставке.
+type CheckoutService struct {
+	orders    *repository.OrderRepository
+	products  *repository.ProductRepository
+	addresses *repository.AddressRepository
+	delivery  *DeliveryService
+	promos    *PromoService
+	vatRate   int
+}
+
+// NewCheckoutService создаёт сервис оформления заказов. vatRate — ставка НДС в процентах, 0 — без НДС.
+func NewCheckoutService(orders *repository.OrderRepository, products *repository.ProductRepository, addresses *repository.AddressRepository, delivery *DeliveryService, promos *PromoService, vatRate int) *CheckoutService {
+	return &CheckoutService{orders: orders, products: products, addresses: addresses, delivery: delivery, promos: promos, vatRate: vatRate}
+}
+
+// Quote рассчитывает заказ пользователя userID, не сохраняя его.
+func (s *CheckoutService) Quote(ctx context.Context, userID int, req CheckoutRequest) (*models.Order, error) {
+	return s.price(ctx, userID, req)
+}
+
+// Checkout рассчитывает и сохраняет заказ пользователя userID со статусом new.
+// В заказ копируется адрес доставки, а в позиции — текущие цены товаров. Использование
+// промокода учитывается вместе с заказом, поэтому его лимиты не превышаются.
+func (s *CheckoutService) Checkout(ctx context.Context, userID int, req CheckoutRequest) (*models.Order, error) {
+	log := logger.FromContext(ctx)
+	log.Infof("Checking out order for user ID: %d", userID)
+
+	order, err := s.price(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.orders.CreateOrderWithItems(ctx, order, order.Items); err != nil {
+		if errors.Is(err, ErrPromoCodeExhausted) {
+			return nil, fmt.Errorf("%w: %s", ErrPromoCodeExhausted, order.PromoCode)
+		}
+		if errors.Is(err, repository.ErrPromoCodeUnavailable) {
+			return nil, fmt.Errorf("%w: promo code %q is no longer available", ErrPromoCodeNotApplicable, order.PromoCode)
+		}
+		log.Errorf("Failed to create order for user ID %d: %v", userID, err)
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	metrics.OrdersCreatedTotal.Inc()
+	log.Infof("Created order ID %d: total %s, delivery %s %s, discount %s", order.ID, order.Total,
+		order.DeliveryMethod, order.DeliveryCost, order.Discount)
+	return order, nil
+}
+
+// price собирает заказ: позиции по текущим ценам, адрес, стоимость доставки по весу
+// и скидку по промокоду, распределённую по позициям, а затем выделяет НДС по правилу
+// из пакета money.
+func (s *CheckoutService) price(ctx context.Context, userID int, req CheckoutRequest) (*models.Order, error) {
+	method := strings.TrimSpace(req.DeliveryMethod)
+	if method == "" {
+		method = models.DeliveryPickup
+	}
+	if !IsDeliveryMethod(method) {
+		return nil, fmt.Errorf("%w: delivery_method must be one of %s", ErrInvalidCheckout, strings.Join(DeliveryMethods, ", "))
+	}
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: order must contain at least one item", ErrInvalidCheckout)
+	}
+
+	// Повторяющиеся товары объединяются в одну позицию
+	quantities := make(map[int]int, len(req.Items))
+	var productIDs []int
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity of product %d must be positive", ErrInvalidCheckout, item.ProductID)
+		}
+		if _, ok := quantities[item.ProductID]; !ok {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+		if quantities[item.ProductID] > maxItemQuantity {
+			return nil, fmt.Errorf("%w: quantity of product %d must be at most %d", ErrInvalidCheckout, item.ProductID, maxItemQuantity)
+		}
+	}
+
+	order := &models.Order{UserID: userID, Status: models.OrderStatusNew, DeliveryMethod: method,
+		Currency: money.DefaultCurrency, VATRate: s.vatRate}
+	var subtotal money.Money
+	var weight int
+	var discountItems []DiscountItem
+	for _, id := range productIDs {
+		product, err := s.products.GetProduct(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: product %d not found", ErrInvalidCheckout, id)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch product: %w", err)
+		}
+		if !sameCurrency(product.Currency) {
+			return nil, fmt.Errorf("%w: product %d is priced in %s", ErrInvalidCheckout, id, product.Currency)
+		}
+		quantity := quantities[id]
+		line := product.Price.Mul(int64(quantity))
+		order.Items = append(order.Items, models.OrderItem{ProductID: id, Quantity: quantity, Price: product.Price})
+		subtotal = subtotal.Add(line)
+		discountItems = append(discountItems, DiscountItem{ProductID: id, CategoryID: product.CategoryID, Amount: line})
+		weight += product.Weight * quantity
+	}
+
+	region := ""
+	if method != models.DeliveryPickup {
+		if req.AddressID == 0 {
+			return nil, fmt.Errorf("%w: address_id is required for %s delivery", ErrInvalidCheckout, method)
+		}
+		address, err := s.addresses.GetAddress(ctx, userID, req.AddressID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: address %d not found", ErrInvalidCheckout, req.AddressID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch address: %w", err)
+		}
+		snapshot := address.ShippingAddress
+		order.ShippingAddress = &snapshot
+		region = address.Region
+	}
+
+	cost, err := s.delivery.Quote(ctx, method, region, weight)
+	if err != nil {
+		return nil, err
+	}
+	order.DeliveryCost = cost
+
+	if strings.TrimSpace(req.PromoCode) != "" {
+		promo, discount, err := s.promos.Apply(ctx, userID, req.PromoCode, discountItems, cost)
+		if err != nil {
+			return nil, err
+		}
+		order.PromoCode = promo.Code
+		order.Discount = discount
+		for i, share := range AllocateDiscount(promo, discountItems, discount) {
+			order.Items[i].Discount = share
+		}
+	}
+	order.Total = subtotal.Add(cost).Sub(order.Discount)
+	s.applyVAT(order)
+	return order, nil
+}
+
+// applyVAT выделяет НДС в каждой позиции и в доставке после скидки. Скидка на доставку —
+// часть скидки заказа, не распределённая по позициям. НДС заказа — сумма НДС строк.
+func (s *CheckoutService) applyVAT(order *models.Order) {
+	rate := int64(order.VATRate)
+	var itemsDiscount money.Money
+	order.VAT = money.Money{}
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.VAT = item.Price.Mul(int64(item.Quantity)).Sub(item.Discount).VAT(rate)
+		itemsDiscount = itemsDiscount.Add(item.Discount)
+		order.VAT = order.VAT.Add(item.VAT)
+	}
+	delivery := order.DeliveryCost.Sub(order.Discount.Sub(itemsDiscount))
+	order.VAT = order.VAT.Add(delivery.VAT(rate))
+}
+
+// sameCurrency сообщает, выражена ли сумма в валюте заказов; пустая валюта — валюта по умолчанию.
+func sameCurrency(currency string) bool {
+	return currency == "" || currency == money.DefaultCurrency
+}
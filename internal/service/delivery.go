@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+)
+
+// Ошибки расчёта доставки.
+var (
+	ErrInvalidDeliveryRate = errors.New("invalid delivery rate")
+	ErrDeliveryUnavailable = errors.New("delivery method is not available for this region and weight")
+)
+
+// DeliveryMethods — поддерживаемые способы доставки.
+var DeliveryMethods = []string{models.DeliveryPickup, models.DeliveryCourier, models.DeliveryPost}
+
+// IsDeliveryMethod проверяет, что method — один из DeliveryMethods.
+func IsDeliveryMethod(method string) bool {
+	for _, m := range DeliveryMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryService рассчитывает стоимость доставки по правилам из базы данных.
+type DeliveryService struct {
+	repo *repository.DeliveryRepository
+}
+
+// NewDeliveryService создаёт сервис доставки.
+func NewDeliveryService(repo *repository.DeliveryRepository) *DeliveryService {
+	return &DeliveryService{repo: repo}
+}
+
+// Quote возвращает стоимость доставки способом method в регион region заказа весом
+// weight граммов. Если подходящего правила нет, возвращается ErrDeliveryUnavailable.
+func (s *DeliveryService) Quote(ctx context.Context, method, region string, weight int) (money.Money, error) {
+	rate, err := s.repo.FindRate(ctx, method, strings.TrimSpace(region), weight)
+	if errors.Is(err, sql.ErrNoRows) {
+		return money.Money{}, fmt.Errorf("%w: %s to %q, %d g", ErrDeliveryUnavailable, method, region, weight)
+	}
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Failed to find delivery rate for %s: %v", method, err)
+		return money.Money{}, fmt.Errorf("failed to find delivery rate: %w", err)
+	}
+	return rate.Price, nil
+}
+
+// ListRates возвращает все правила стоимости доставки.
+func (s *DeliveryService) ListRates(ctx context.Context) ([]*models.DeliveryRate, error) {
+	rates, err := s.repo.ListRates(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Failed to fetch delivery rates: %v", err)
+		return nil, fmt.Errorf("failed to fetch delivery rates: %w", err)
+	}
+	return rates, nil
+}
+
+// GetRate возвращает правило по ID.
+func (s *DeliveryService) GetRate(ctx context.Context, id int) (*models.DeliveryRate, error) {
+	rate, err := s.repo.GetRate(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("delivery rate not found: %w", err)
+		}
+		logger.FromContext(ctx).Errorf("Failed to fetch delivery rate ID %d: %v", id, err)
+		return nil, fmt.Errorf("failed to fetch delivery rate: %w", err)
+	}
+	return rate, nil
+}
+
+// CreateRate проверяет и сохраняет новое правило.
+func (s *DeliveryService) CreateRate(ctx context.Context, rate *models.DeliveryRate) error {
+	if err := validateDeliveryRate(rate); err != nil {
+		return err
+	}
+	if err := s.repo.CreateRate(ctx, rate); err != nil {
+		logger.FromContext(ctx).Errorf("Failed to create delivery rate: %v", err)
+		return fmt.Errorf("failed to create delivery rate: %w", err)
+	}
+	logger.FromContext(ctx).Infof("Created delivery rate ID %d for %s", rate.ID, rate.Method)
+	return nil
+}
+
+// UpdateRate проверяет и сохраняет изменённое правило.
+func (s *DeliveryService) UpdateRate(ctx context.Context, rate *models.DeliveryRate) error {
+	if err := validateDeliveryRate(rate); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateRate(ctx, rate); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("delivery rate not found: %w", err)
+		}
+		logger.FromContext(ctx).Errorf("Failed to update delivery rate ID %d: %v", rate.ID, err)
+		return fmt.Errorf("failed to update delivery rate: %w", err)
+	}
+	logger.FromContext(ctx).Infof("Updated delivery rate ID %d", rate.ID)
+	return nil
+}
+
+// DeleteRate удаляет правило.
+func (s *DeliveryService) DeleteRate(ctx context.Context, id int) error {
+	if err := s.repo.DeleteRate(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("delivery rate not found: %w", err)
+		}
+		logger.FromContext(ctx).Errorf("Failed to delete delivery rate ID %d: %v", id, err)
+		return fmt.Errorf("failed to delete delivery rate: %w", err)
+	}
+	logger.FromContext(ctx).Infof("Deleted delivery rate ID %d", id)
+	return nil
+}
+
+func validateDeliveryRate(rate *models.DeliveryRate) error {
+	rate.Region = strings.TrimSpace(rate.Region)
+	switch {
+	case !IsDeliveryMethod(rate.Method):
+		return fmt.Errorf("%w: method must be one of %s", ErrInvalidDeliveryRate, strings.Join(DeliveryMethods, ", "))
+	case len(rate.Region) > 100:
+		return fmt.Errorf("%w: region must be at most 100 characters", ErrInvalidDeliveryRate)
+	case rate.MaxWeight != nil && *rate.MaxWeight <= 0:
+		return fmt.Errorf("%w: max_weight must be positive", ErrInvalidDeliveryRate)
+	case rate.Price.IsNegative():
+		return fmt.Errorf("%w: price must not be negative", ErrInvalidDeliveryRate)
+	}
+	return nil
+}
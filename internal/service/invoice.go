@@ -0,0 +1,144 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/invoice"
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+)
+
+// InvoiceService формирует PDF-документы заказов. Для неоплаченного заказа каждый
+// раз строится счёт по текущим данным, а квитанция оплаченного заказа формируется
+// один раз и затем отдаётся из хранилища без изменений.
+type InvoiceService struct {
+	repo     *repository.InvoiceRepository
+	orders   *repository.OrderRepository
+	products *repository.ProductRepository
+	users    *repository.UserRepository
+	payments *repository.PaymentRepository
+	shop     invoice.Shop
+}
+
+// NewInvoiceService создаёт сервис документов заказов с реквизитами магазина shop.
+func NewInvoiceService(repo *repository.InvoiceRepository, orders *repository.OrderRepository, products *repository.ProductRepository,
+	users *repository.UserRepository, payments *repository.PaymentRepository, shop invoice.Shop) *InvoiceService {
+	return &InvoiceService{repo: repo, orders: orders, products: products, users: users, payments: payments, shop: shop}
+}
+
+// OrderInvoice возвращает PDF-документ заказа orderID для пользователя userID:
+// квитанцию, если заказ оплачен, иначе счёт на оплату. Администратор (admin) может
+// получить документ любого заказа, остальным чужой заказ считается отсутствующим
+// (sql.ErrNoRows).
+func (s *InvoiceService) OrderInvoice(ctx context.Context, userID int, admin bool, orderID int) ([]byte, error) {
+	log := logger.FromContext(ctx)
+	log.Debugf("Fetching invoice of order ID %d", orderID)
+
+	order, err := s.orders.GetOrder(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("order not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to fetch order: %w", err)
+	}
+	if !admin && order.UserID != userID {
+		log.Warningf("User ID %d tried to get invoice of order ID %d of another user", userID, orderID)
+		return nil, fmt.Errorf("order not found: %w", sql.ErrNoRows)
+	}
+
+	if !isPaid(order) {
+		return s.render(ctx, order, invoice.KindInvoice, time.Now())
+	}
+	data, err := s.repo.GetInvoice(ctx, orderID)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, repository.ErrInvoiceNotFound) {
+		log.Errorf("Failed to fetch stored invoice of order ID %d: %v", orderID, err)
+		return nil, fmt.Errorf("failed to fetch invoice: %w", err)
+	}
+	// Квитанция не сохранилась при оплате: формируем её сейчас
+	return s.storeReceipt(ctx, order)
+}
+
+// StoreReceipt формирует и сохраняет квитанцию оплаченного заказа orderID.
+// Уже сохранённая квитанция не перезаписывается.
+func (s *InvoiceService) StoreReceipt(ctx context.Context, orderID int) error {
+	order, err := s.orders.GetOrder(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch order: %w", err)
+	}
+	if !isPaid(order) {
+		return fmt.Errorf("order %d is not paid", orderID)
+	}
+	_, err = s.storeReceipt(ctx, order)
+	return err
+}
+
+func (s *InvoiceService) storeReceipt(ctx context.Context, order *models.Order) ([]byte, error) {
+	log := logger.FromContext(ctx)
+
+	paidAt := order.UpdatedAt
+	p, err := s.payments.LatestPayment(ctx, order.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to fetch payment: %w", err)
+	}
+	if p != nil {
+		paidAt = p.UpdatedAt
+	}
+
+	data, err := s.render(ctx, order, invoice.KindReceipt, paidAt)
+	if err != nil {
+		return nil, err
+	}
+	data, err = s.repo.SaveInvoice(ctx, order.ID, data, invoice.ContentType)
+	if err != nil {
+		log.Errorf("Failed to store receipt of order ID %d: %v", order.ID, err)
+		return nil, fmt.Errorf("failed to store receipt: %w", err)
+	}
+	log.Infof("Stored receipt of order ID %d", order.ID)
+	return data, nil
+}
+
+// render строит документ заказа по текущим данным заказа, покупателя и каталога.
+func (s *InvoiceService) render(ctx context.Context, order *models.Order, kind string, issuedAt time.Time) ([]byte, error) {
+	items, err := s.orders.ListOrderItems(ctx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch order items: %w", err)
+	}
+	order.Items = items
+
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ProductID
+	}
+	names, err := s.products.ProductNames(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch product names: %w", err)
+	}
+
+	doc := &invoice.Document{Kind: kind, IssuedAt: issuedAt, Order: order, ProductNames: names}
+	if user, err := s.users.GetUser(ctx, order.UserID); err == nil {
+		doc.Buyer = fmt.Sprintf("%s (%s)", user.Name, user.Email)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to fetch buyer: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := invoice.Render(&buf, s.shop, doc); err != nil {
+		logger.FromContext(ctx).Errorf("Failed to render %s of order ID %d: %v", kind, order.ID, err)
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isPaid сообщает, что заказ был оплачен; после возврата денег квитанция сохраняется.
+func isPaid(order *models.Order) bool {
+	return order.Status == models.OrderStatusPaid || order.Status == models.OrderStatusRefunded
+}
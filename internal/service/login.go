@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/mailer"
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+)
+
+// Причины неудачного входа, записываемые в журнал.
+const (
+	LoginReasonUnknownUser   = "unknown_user"
+	LoginReasonWrongPassword = "wrong_password"
+	LoginReasonBlocked       = "blocked"      // вход временно заблокирован после серии неудач
+	LoginReasonUserBlocked   = "user_blocked" // пользователь заблокирован администратором
+)
+
+// LoginPolicy — параметры защиты входа от перебора паролей.
+type LoginPolicy struct {
+	Window          time.Duration // окно подсчёта неудач
+	DelayAfter      int           // число неудач, после которого включается задержка
+	BaseDelay       time.Duration // первая задержка, удваивается с каждой неудачей
+	MaxDelay        time.Duration
+	MaxFailures     int // неудач до блокировки аккаунта
+	IPMaxFailures   int // неудач с одного IP до запрета входа с него
+	LockoutDuration time.Duration
+}
+
+// LoginAttempt — данные о попытке входа, нужные для счётчиков и журнала.
+type LoginAttempt struct {
+	Email     string
+	IP        string
+	UserAgent string
+}
+
+// LoginBlockedError возвращается, если попытку входа нужно отклонить без проверки пароля.
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+	Locked     bool // аккаунт заблокирован, а не просто действует задержка
+}
+
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("account is temporarily locked, retry after %s", e.RetryAfter)
+	}
+	return fmt.Sprintf("too many failed login attempts, retry after %s", e.RetryAfter)
+}
+
+// LoginGuard защищает вход от перебора паролей: считает неудачи по аккаунту и IP,
+// вводит нарастающую задержку, блокирует аккаунт и ведёт журнал попыток.
+//
+// Ошибки Redis не мешают входу: защита в этом случае отключается с предупреждением в логе.
+type LoginGuard struct {
+	repo   *repository.LoginAttemptRepository
+	mailer mailer.Mailer
+	policy LoginPolicy
+}
+
+// NewLoginGuard создаёт защиту входа. Уведомления о блокировке отправляются через m.
+func NewLoginGuard(repo *repository.LoginAttemptRepository, m mailer.Mailer, policy LoginPolicy) *LoginGuard {
+	return &LoginGuard{repo: repo, mailer: m, policy: policy}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Check проверяет, можно ли сейчас проверять пароль для attempt.
+// Возвращает *LoginBlockedError, если аккаунт заблокирован или действует задержка.
+func (g *LoginGuard) Check(ctx context.Context, attempt LoginAttempt) error {
+	log := logger.FromContext(ctx)
+	email := normalizeEmail(attempt.Email)
+
+	lockTTL, err := g.repo.LockTTL(ctx, email)
+	if err != nil {
+		log.Warningf("Login protection unavailable: %v", err)
+		return nil
+	}
+	if lockTTL > 0 {
+		return &LoginBlockedError{RetryAfter: lockTTL, Locked: true}
+	}
+
+	account, err := g.repo.Failures(ctx, "account", email)
+	if err != nil {
+		log.Warningf("Login protection unavailable: %v", err)
+		return nil
+	}
+	ip, err := g.repo.Failures(ctx, "ip", attempt.IP)
+	if err != nil {
+		log.Warningf("Login protection unavailable: %v", err)
+		return nil
+	}
+
+	if ip.Count >= g.policy.IPMaxFailures {
+		return &LoginBlockedError{RetryAfter: ip.TTL}
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	for _, counter := range []*repository.FailureCounter{account, ip} {
+		if w := counter.Last.Add(g.delay(counter.Count)).Sub(now); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return &LoginBlockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// delay возвращает задержку перед следующей попыткой после failures неудач.
+func (g *LoginGuard) delay(failures int) time.Duration {
+	if failures < g.policy.DelayAfter || g.policy.BaseDelay <= 0 {
+		return 0
+	}
+	d := g.policy.BaseDelay
+	for i := g.policy.DelayAfter; i < failures && d < g.policy.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > g.policy.MaxDelay {
+		d = g.policy.MaxDelay
+	}
+	return d
+}
+
+// RecordFailure учитывает неудачную попытку входа. user равен nil, если пользователь не найден.
+// При достижении MaxFailures аккаунт блокируется, а пользователю отправляется уведомление.
+func (g *LoginGuard) RecordFailure(ctx context.Context, attempt LoginAttempt, user *models.User, reason string) {
+	log := logger.FromContext(ctx)
+	email := normalizeEmail(attempt.Email)
+	metrics.LoginAttemptsTotal.WithLabelValues(loginResult(reason)).Inc()
+	g.audit(ctx, attempt, user, false, reason)
+	if reason == LoginReasonBlocked || reason == LoginReasonUserBlocked {
+		return
+	}
+
+	if _, err := g.repo.AddFailure(ctx, "ip", attempt.IP, g.policy.Window); err != nil {
+		log.Warningf("Failed to count login failure for IP: %v", err)
+	}
+	count, err := g.repo.AddFailure(ctx, "account", email, g.policy.Window)
+	if err != nil {
+		log.Warningf("Failed to count login failure for %s: %v", logger.MaskEmail(email), err)
+		return
+	}
+	if count < g.policy.MaxFailures {
+		return
+	}
+
+	if err := g.repo.Lock(ctx, email, g.policy.LockoutDuration); err != nil {
+		log.Errorf("Failed to lock account %s: %v", logger.MaskEmail(email), err)
+		return
+	}
+	if err := g.repo.ResetFailures(ctx, "account", email); err != nil {
+		log.Warningf("Failed to reset login failures for %s: %v", logger.MaskEmail(email), err)
+	}
+	metrics.AccountLockoutsTotal.Inc()
+	log.Warningf("Account %s locked for %s after %d failed logins", logger.MaskEmail(email), g.policy.LockoutDuration, count)
+
+	if user != nil {
+		go g.notifyLocked(context.WithoutCancel(ctx), user)
+	}
+}
+
+// RecordSuccess учитывает успешный вход и сбрасывает счётчик неудач аккаунта.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, attempt LoginAttempt, user *models.User) {
+	metrics.LoginAttemptsTotal.WithLabelValues("success").Inc()
+	g.audit(ctx, attempt, user, true, "")
+	if err := g.repo.ResetFailures(ctx, "account", normalizeEmail(attempt.Email)); err != nil {
+		logger.FromContext(ctx).Warningf("Failed to reset login failures: %v", err)
+	}
+}
+
+// Unlock снимает блокировку аккаунта досрочно.
+func (g *LoginGuard) Unlock(ctx context.Context, email string) error {
+	log := logger.FromContext(ctx)
+	email = normalizeEmail(email)
+	if err := g.repo.Unlock(ctx, email); err != nil {
+		log.Errorf("Failed to unlock account %s: %v", logger.MaskEmail(email), err)
+		return fmt.Errorf("failed to unlock account: %w", err)
+	}
+	log.Infof("Account %s unlocked", logger.MaskEmail(email))
+	return nil
+}
+
+// audit записывает попытку в журнал. Ошибка записи не должна мешать входу, поэтому только логируется.
+func (g *LoginGuard) audit(ctx context.Context, attempt LoginAttempt, user *models.User, success bool, reason string) {
+	entry := &models.LoginAttempt{
+		Email:     normalizeEmail(attempt.Email),
+		IP:        attempt.IP,
+		UserAgent: attempt.UserAgent,
+		Success:   success,
+		Reason:    reason,
+	}
+	if user != nil {
+		entry.UserID = user.ID
+	}
+	if err := g.repo.CreateAttempt(ctx, entry); err != nil {
+		logger.FromContext(ctx).Errorf("Failed to write login audit entry: %v", err)
+	}
+}
+
+func (g *LoginGuard) notifyLocked(ctx context.Context, user *models.User) {
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Вход в аккаунт временно заблокирован",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\n"+
+			"Мы зафиксировали несколько неудачных попыток входа в ваш аккаунт и заблокировали вход на %s.\n"+
+			"Если это были не вы, рекомендуем сменить пароль после разблокировки.\n",
+			user.Name, g.policy.LockoutDuration),
+	}
+	if err := g.mailer.Send(ctx, msg); err != nil {
+		logger.FromContext(ctx).Errorf("Failed to send lockout notification to %s: %v", logger.MaskEmail(user.Email), err)
+	}
+}
+
+func loginResult(reason string) string {
+	switch reason {
+	case LoginReasonBlocked, LoginReasonUserBlocked:
+		return reason
+	}
+	return "failure"
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/payment"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Ошибки оплаты заказов.
+var (
+	ErrOrderAlreadyPaid  = errors.New("order is already paid")
+	ErrPaymentInProgress = errors.New("payment of the order is already being created")
+	ErrPaymentTimeout    = errors.New("payment provider did not respond in time")
+	ErrPaymentFailed     = errors.New("payment provider rejected the request")
+	ErrNothingToRefund   = errors.New("order has no succeeded payment to refund")
+
+	ErrInvalidWebhookSignature = payment.ErrInvalidSignature
+	ErrInvalidWebhook          = payment.ErrInvalidNotification
+)
+
+// paymentCurrency — валюта, в которой выставляются заказы.
+const paymentCurrency = "RUB"
+
+// PaymentOptions — параметры приёма оплаты.
+type PaymentOptions struct {
+	ReturnURL string          // страница, на которую покупатель вернётся после оплаты
+	Timeout   time.Duration   // ограничение времени одного запроса к провайдеру
+	Receipts  *InvoiceService // сохраняет квитанцию оплаченного заказа; nil — квитанция формируется при первом запросе
+}
+
+// PaymentService принимает оплату заказов через платёжного провайдера.
+type PaymentService struct {
+	repo     *repository.PaymentRepository
+	orders   *repository.OrderRepository
+	provider payment.PaymentProvider
+	opts     PaymentOptions
+}
+
+// NewPaymentService создаёт сервис оплаты заказов через provider.
+func NewPaymentService(repo *repository.PaymentRepository, orders *repository.OrderRepository, provider payment.PaymentProvider, opts PaymentOptions) *PaymentService {
+	return &PaymentService{repo: repo, orders: orders, provider: provider, opts: opts}
+}
+
+// PayOrder начинает оплату заказа пользователя userID и возвращает попытку оплаты
+// со ссылкой на страницу оплаты. Если у заказа уже есть ожидающий платёж, он
+// возвращается повторно; попытка, на которую провайдер не ответил вовремя,
+// повторяется с тем же ключом идемпотентности, так что второй платёж не создаётся.
+// Чужой заказ считается отсутствующим (sql.ErrNoRows).
+func (s *PaymentService) PayOrder(ctx context.Context, userID, orderID int) (*models.Payment, error) {
+	log := logger.FromContext(ctx)
+	log.Infof("Starting payment of order ID %d", orderID)
+
+	order, err := s.orders.GetOrder(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("order not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to fetch order: %w", err)
+	}
+	if order.UserID != userID {
+		log.Warningf("User ID %d tried to pay order ID %d of another user", userID, orderID)
+		return nil, fmt.Errorf("order not found: %w", sql.ErrNoRows)
+	}
+	if order.Status == models.OrderStatusPaid || order.Status == models.OrderStatusRefunded {
+		return nil, ErrOrderAlreadyPaid
+	}
+
+	p, err := s.repo.LatestPayment(ctx, orderID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to fetch payment: %w", err)
+	}
+	if p != nil && p.Status == models.PaymentStatusPending && p.Provider == s.provider.Name() {
+		if p.ProviderPaymentID == "" {
+			// Предыдущая попытка не получила ответа провайдера: повторяем её
+			return s.createAtProvider(ctx, p, order)
+		}
+		return s.refresh(ctx, p)
+	}
+
+	p = &models.Payment{
+		OrderID:        orderID,
+		Provider:       s.provider.Name(),
+		IdempotenceKey: uuid.NewString(),
+		Status:         models.PaymentStatusPending,
+		Amount:         order.Total,
+		Currency:       paymentCurrency,
+	}
+	if err := s.repo.CreatePayment(ctx, p); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			// Параллельный запрос уже создаёт платёж этого заказа
+			return nil, ErrPaymentInProgress
+		}
+		log.Errorf("Failed to save payment of order ID %d: %v", orderID, err)
+		return nil, fmt.Errorf("failed to save payment: %w", err)
+	}
+	return s.createAtProvider(ctx, p, order)
+}
+
+// createAtProvider создаёт платёж у провайдера и сохраняет его ответ.
+func (s *PaymentService) createAtProvider(ctx context.Context, p *models.Payment, order *models.Order) (*models.Payment, error) {
+	log := logger.FromContext(ctx)
+
+	pctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	created, err := s.provider.CreatePayment(pctx, payment.CreateRequest{
+		Amount:         p.Amount,
+		Currency:       p.Currency,
+		Description:    fmt.Sprintf("Заказ №%d", order.ID),
+		ReturnURL:      s.opts.ReturnURL,
+		IdempotenceKey: p.IdempotenceKey,
+		Metadata:       map[string]string{"payment_id": strconv.Itoa(p.ID), "order_id": strconv.Itoa(order.ID)},
+	})
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			// Платёж мог быть создан: попытка остаётся pending и будет повторена с тем же ключом
+			log.Warningf("Payment provider timed out creating payment ID %d", p.ID)
+			metrics.PaymentsTotal.WithLabelValues(p.Provider, "timeout").Inc()
+			return nil, ErrPaymentTimeout
+		}
+		log.Errorf("Payment provider failed to create payment ID %d: %v", p.ID, err)
+		p.Status = models.PaymentStatusFailed
+		p.Error = err.Error()
+		if err := s.repo.UpdatePayment(ctx, p); err != nil {
+			log.Errorf("Failed to save failed payment ID %d: %v", p.ID, err)
+		}
+		metrics.PaymentsTotal.WithLabelValues(p.Provider, p.Status).Inc()
+		return nil, fmt.Errorf("%w: %v", ErrPaymentFailed, err)
+	}
+
+	p.ProviderPaymentID = created.ID
+	p.ConfirmationURL = created.ConfirmationURL
+	if err := s.repo.UpdatePayment(ctx, p); err != nil {
+		log.Errorf("Failed to save provider payment %s for payment ID %d: %v", created.ID, p.ID, err)
+		return nil, fmt.Errorf("failed to save payment: %w", err)
+	}
+	if created.Status != models.PaymentStatusPending {
+		return s.settle(ctx, p, created.Status)
+	}
+
+	log.Infof("Created payment ID %d (%s %s) for order ID %d", p.ID, p.Provider, p.ProviderPaymentID, order.ID)
+	return p, nil
+}
+
+// refresh проверяет у провайдера статус ожидающего платежа: если уведомление
+// потерялось, платёж завершается по ответу провайдера.
+func (s *PaymentService) refresh(ctx context.Context, p *models.Payment) (*models.Payment, error) {
+	pctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	current, err := s.provider.GetPayment(pctx, p.ProviderPaymentID)
+	if err != nil {
+		// Ссылка на оплату по-прежнему действительна, статус уточнит уведомление
+		logger.FromContext(ctx).Warningf("Failed to refresh payment ID %d: %v", p.ID, err)
+		return p, nil
+	}
+	if current.Status == models.PaymentStatusPending {
+		return p, nil
+	}
+	if current.Status == models.PaymentStatusSucceeded && !amountMatches(p, current.Amount) {
+		logger.FromContext(ctx).Errorf("Provider payment %s amount %s %s does not match payment ID %d amount %s %s",
+			current.ID, current.Amount, current.Amount.CurrencyCode(), p.ID, p.Amount, p.Currency)
+		return p, nil
+	}
+	return s.settle(ctx, p, current.Status)
+}
+
+// settle завершает попытку оплаты; для оплаченной возвращает ErrOrderAlreadyPaid.
+func (s *PaymentService) settle(ctx context.Context, p *models.Payment, status string) (*models.Payment, error) {
+	applied, err := s.repo.SettlePayment(ctx, p.ID, p.ProviderPaymentID, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to settle payment: %w", err)
+	}
+	if status == models.PaymentStatusSucceeded {
+		if applied {
+			s.storeReceipt(ctx, p.OrderID)
+		}
+		return nil, ErrOrderAlreadyPaid
+	}
+	p.Status = status
+	return p, nil
+}
+
+// HandleWebhook проверяет подлинность уведомления провайдера и применяет новый статус
+// платежа: оплаченный платёж переводит заказ в статус paid. Повторные и запоздавшие
+// уведомления о завершённых платежах ничего не меняют.
+func (s *PaymentService) HandleWebhook(ctx context.Context, header http.Header, body []byte) error {
+	log := logger.FromContext(ctx)
+
+	pctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	event, err := s.provider.ParseWebhook(pctx, header, body)
+	cancel()
+	if err != nil {
+		if errors.Is(err, ErrInvalidWebhookSignature) || errors.Is(err, ErrInvalidWebhook) {
+			log.Warningf("Rejected payment webhook: %v", err)
+			return err
+		}
+		log.Errorf("Failed to verify payment webhook: %v", err)
+		return fmt.Errorf("failed to verify webhook: %w", err)
+	}
+	if event == nil {
+		log.Debug("Ignoring webhook that is not about a payment")
+		return nil
+	}
+	if event.Status != models.PaymentStatusSucceeded && event.Status != models.PaymentStatusCanceled {
+		log.Debugf("Ignoring payment %s status %s", event.PaymentID, event.Status)
+		return nil
+	}
+
+	p, err := s.repo.GetPaymentByProviderID(ctx, s.provider.Name(), event.PaymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Уведомление могло прийти раньше, чем сохранился ответ на создание платежа
+		if id, convErr := strconv.Atoi(event.Metadata["payment_id"]); convErr == nil {
+			p, err = s.repo.GetPayment(ctx, id)
+			if err == nil && p.ProviderPaymentID != "" && p.ProviderPaymentID != event.PaymentID {
+				err = sql.ErrNoRows
+			}
+		}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warningf("Ignoring webhook for unknown payment %s", event.PaymentID)
+		return nil
+	}
+	if err != nil {
+		log.Errorf("Failed to fetch payment %s: %v", event.PaymentID, err)
+		return fmt.Errorf("failed to fetch payment: %w", err)
+	}
+	if event.Status == models.PaymentStatusSucceeded && !amountMatches(p, event.Amount) {
+		log.Warningf("Rejected webhook for payment ID %d: amount %s %s does not match %s %s",
+			p.ID, event.Amount, event.Amount.CurrencyCode(), p.Amount, p.Currency)
+		return fmt.Errorf("%w: amount does not match the payment", ErrInvalidWebhook)
+	}
+
+	applied, err := s.repo.SettlePayment(ctx, p.ID, event.PaymentID, event.Status)
+	if err != nil {
+		log.Errorf("Failed to settle payment ID %d: %v", p.ID, err)
+		return fmt.Errorf("failed to settle payment: %w", err)
+	}
+	if !applied {
+		log.Infof("Payment ID %d is already %s, webhook ignored", p.ID, p.Status)
+		return nil
+	}
+
+	metrics.PaymentsTotal.WithLabelValues(p.Provider, event.Status).Inc()
+	log.Infof("Payment ID %d of order ID %d is %s", p.ID, p.OrderID, event.Status)
+	if event.Status == models.PaymentStatusSucceeded {
+		s.storeReceipt(ctx, p.OrderID)
+	}
+	return nil
+}
+
+// amountMatches сообщает, что провайдер подтвердил ровно ту сумму и валюту,
+// на которые создавался платёж.
+func amountMatches(p *models.Payment, amount money.Money) bool {
+	return amount.Amount == p.Amount.Amount && amount.Currency == p.Currency
+}
+
+// storeReceipt сохраняет квитанцию только что оплаченного заказа. Ошибка не отменяет
+// оплату: квитанция будет сформирована при первом запросе.
+func (s *PaymentService) storeReceipt(ctx context.Context, orderID int) {
+	if s.opts.Receipts == nil {
+		return
+	}
+	if err := s.opts.Receipts.StoreReceipt(ctx, orderID); err != nil {
+		logger.FromContext(ctx).Errorf("Failed to store receipt of order ID %d: %v", orderID, err)
+	}
+}
+
+// RefundOrder возвращает деньги за оплаченный заказ и переводит его в статус refunded.
+func (s *PaymentService) RefundOrder(ctx context.Context, orderID int) (*models.Payment, error) {
+	log := logger.FromContext(ctx)
+	log.Infof("Refunding order ID %d", orderID)
+
+	p, err := s.repo.LatestPayment(ctx, orderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNothingToRefund
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch payment: %w", err)
+	}
+	if p.Status != models.PaymentStatusSucceeded || p.Provider != s.provider.Name() {
+		return nil, ErrNothingToRefund
+	}
+
+	pctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	if err := s.provider.Refund(pctx, p.ProviderPaymentID, p.Amount, p.IdempotenceKey+"-refund"); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrPaymentTimeout
+		}
+		log.Errorf("Payment provider failed to refund payment ID %d: %v", p.ID, err)
+		return nil, fmt.Errorf("%w: %v", ErrPaymentFailed, err)
+	}
+
+	if _, err := s.repo.RefundPayment(ctx, p.ID); err != nil {
+		log.Errorf("Failed to save refund of payment ID %d: %v", p.ID, err)
+		return nil, fmt.Errorf("failed to save refund: %w", err)
+	}
+	metrics.PaymentsTotal.WithLabelValues(p.Provider, models.PaymentStatusRefunded).Inc()
+	p.Status = models.PaymentStatusRefunded
+	log.Infof("Refunded payment ID %d of order ID %d", p.ID, orderID)
+	return p, nil
+}
@@ -5,61 +5,352 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
+	"github.com/alex-pyslar/petelka-api/internal/models"
 	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
 )
 
+// ErrPhotoNotFound возвращается, если фотографии нет в хранилище.
+var ErrPhotoNotFound = repository.ErrPhotoNotFound
+
+// ErrInvalidUpload возвращается, если загруженный напрямую объект не прошёл проверку.
+var ErrInvalidUpload = errors.New("uploaded file is invalid")
+
+// maxPhotoSize — максимальный размер загружаемой фотографии.
+const maxPhotoSize = 32 << 20
+
+// photoContentTypes сопоставляет допустимые расширения с Content-Type.
+var photoContentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+}
+
+// UploadURL описывает POST-политику для прямой загрузки фотографии в хранилище.
+type UploadURL struct {
+	ObjectName string            `json:"objectName"`
+	URL        string            `json:"url"`
+	FormData   map[string]string `json:"formData"`
+	ExpiresAt  time.Time         `json:"expiresAt"`
+	MaxSize    int64             `json:"maxSize"`
+}
+
 type PhotoService struct {
-	repo *repository.PhotoRepository
-	log  *logger.Logger
+	repo   *repository.PhotoRepository
+	urlTTL time.Duration
 }
 
-func NewPhotoService(repo *repository.PhotoRepository, log *logger.Logger) *PhotoService {
-	return &PhotoService{repo: repo, log: log}
+// NewPhotoService создаёт сервис фотографий. urlTTL — срок жизни ссылок на хранилище:
+// редиректов, ссылок режима url и после загрузки, а также политик прямой загрузки.
+func NewPhotoService(repo *repository.PhotoRepository, urlTTL time.Duration) *PhotoService {
+	return &PhotoService{repo: repo, urlTTL: urlTTL}
 }
 
-func (s *PhotoService) Upload(ctx context.Context, file io.Reader, size int64, filename, contentType string) (string, string, error) {
-	s.log.Infof("Attempting to upload photo: %s (size: %d bytes)", filename, size)
+// validatePhoto проверяет размер и расширение файла.
+func (s *PhotoService) validatePhoto(ctx context.Context, size int64, filename string) error {
+	log := logger.FromContext(ctx)
 
-	// Валидация
 	if size <= 0 {
-		s.log.Warningf("Upload rejected: invalid size %d", size)
-		return "", "", fmt.Errorf("invalid file size")
+		log.Warningf("Upload rejected: invalid size %d", size)
+		return fmt.Errorf("invalid file size")
+	}
+	if size > maxPhotoSize {
+		log.Warningf("Upload rejected: file too large (%d bytes)", size)
+		return fmt.Errorf("file too large: max 32MB")
 	}
-	if size > 32<<20 {
-		s.log.Warningf("Upload rejected: file too large (%d bytes)", size)
-		return "", "", fmt.Errorf("file too large: max 32MB")
+	ext := strings.ToLower(filepath.Ext(filename))
+	if _, ok := photoContentTypes[ext]; !ok {
+		log.Warningf("Upload rejected: invalid file type %s", ext)
+		return fmt.Errorf("invalid file type: only jpg, png, jpeg")
 	}
-	ext := filepath.Ext(filename)
-	if ext != ".jpg" && ext != ".png" && ext != ".jpeg" {
-		s.log.Warningf("Upload rejected: invalid file type %s", ext)
-		return "", "", fmt.Errorf("invalid file type: only jpg, png, jpeg")
+	return nil
+}
+
+func (s *PhotoService) Upload(ctx context.Context, file io.Reader, size int64, filename, contentType string, uploaderID int) (string, string, error) {
+	log := logger.FromContext(ctx)
+	log.Infof("Attempting to upload photo: %s (size: %d bytes)", filename, size)
+
+	if err := s.validatePhoto(ctx, size, filename); err != nil {
+		return "", "", err
 	}
 
-	objectName, url, err := s.repo.Upload(ctx, file, size, filename, contentType)
+	objectName, url, err := s.repo.Upload(ctx, file, size, filename, contentType, s.urlTTL)
 	if err != nil {
-		s.log.Errorf("Failed to upload photo %s: %v", filename, err)
+		log.Errorf("Failed to upload photo %s: %v", filename, err)
 		return "", "", fmt.Errorf("upload failed: %w", err)
 	}
 
-	s.log.Infof("Successfully uploaded photo: objectName=%s", objectName)
+	photo := &models.Photo{
+		ObjectName:  objectName,
+		Size:        size,
+		ContentType: contentType,
+		UploadedBy:  uploaderID,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repo.CreatePhoto(ctx, photo); err != nil {
+		log.Errorf("Failed to register photo %s: %v", objectName, err)
+		return "", "", fmt.Errorf("failed to register photo: %w", err)
+	}
+
+	metrics.PhotoUploadsTotal.WithLabelValues("api").Inc()
+	log.Infof("Successfully uploaded photo: objectName=%s", objectName)
 	return objectName, url, nil
 }
 
+// CreateUploadURL выдаёт POST-политику для загрузки файла напрямую в хранилище,
+// минуя API. После загрузки клиент должен вызвать ConfirmUpload.
+func (s *PhotoService) CreateUploadURL(ctx context.Context, filename string, size int64, userID int) (*UploadURL, error) {
+	log := logger.FromContext(ctx)
+	log.Infof("Creating upload URL for photo: %s (size: %d bytes)", filename, size)
+
+	if err := s.validatePhoto(ctx, size, filename); err != nil {
+		return nil, err
+	}
+	contentType := photoContentTypes[strings.ToLower(filepath.Ext(filename))]
+	objectName := repository.NewObjectName(strings.ToLower(filename))
+
+	url, formData, err := s.repo.PresignUpload(ctx, objectName, contentType, maxPhotoSize, s.urlTTL)
+	if err != nil {
+		log.Errorf("Failed to presign upload for %s: %v", objectName, err)
+		return nil, fmt.Errorf("failed to create upload URL: %w", err)
+	}
+
+	// Запись живёт чуть дольше политики, чтобы успеть подтвердить загрузку.
+	if err := s.repo.SavePendingUpload(ctx, objectName, userID, 2*s.urlTTL); err != nil {
+		log.Errorf("Failed to save pending upload %s: %v", objectName, err)
+		return nil, fmt.Errorf("failed to create upload URL: %w", err)
+	}
+
+	log.Infof("Created upload URL: objectName=%s", objectName)
+	return &UploadURL{
+		ObjectName: objectName,
+		URL:        url,
+		FormData:   formData,
+		ExpiresAt:  time.Now().Add(s.urlTTL),
+		MaxSize:    maxPhotoSize,
+	}, nil
+}
+
+// ConfirmUpload проверяет объект, загруженный по POST-политике, и регистрирует его.
+// Объект, не прошедший проверку, удаляется из хранилища. Запись о загрузке удаляется
+// только после регистрации, поэтому при сбое подтверждение можно повторить, а
+// повторное подтверждение уже зарегистрированного объекта возвращает ErrPhotoNotFound.
+func (s *PhotoService) ConfirmUpload(ctx context.Context, objectName string, userID int) (*models.Photo, error) {
+	log := logger.FromContext(ctx)
+	log.Infof("Confirming direct upload: objectName=%s", objectName)
+
+	ownerID, err := s.repo.GetPendingUpload(ctx, objectName)
+	if err != nil {
+		if errors.Is(err, ErrPhotoNotFound) {
+			log.Warningf("No pending upload for %s", objectName)
+			return nil, err
+		}
+		log.Errorf("Failed to read pending upload %s: %v", objectName, err)
+		return nil, fmt.Errorf("failed to confirm upload: %w", err)
+	}
+	if ownerID != userID {
+		log.Warningf("User %d tried to confirm upload %s requested by user %d", userID, objectName, ownerID)
+		return nil, ErrPhotoNotFound
+	}
+
+	photo, err := s.repo.StatObject(ctx, objectName)
+	if err != nil {
+		if errors.Is(err, ErrPhotoNotFound) {
+			log.Warningf("Confirmed object %s is missing in storage", objectName)
+			return nil, err
+		}
+		log.Errorf("Failed to stat object %s: %v", objectName, err)
+		return nil, fmt.Errorf("failed to confirm upload: %w", err)
+	}
+
+	expected := photoContentTypes[strings.ToLower(filepath.Ext(objectName))]
+	if err := s.validatePhoto(ctx, photo.Size, objectName); err != nil || photo.ContentType != expected {
+		log.Warningf("Uploaded object %s failed validation (size=%d, content_type=%s)", objectName, photo.Size, photo.ContentType)
+		if rmErr := s.repo.RemoveObject(ctx, objectName); rmErr != nil {
+			log.Errorf("Failed to remove invalid object %s: %v", objectName, rmErr)
+			return nil, ErrInvalidUpload
+		}
+		s.deletePendingUpload(ctx, objectName)
+		return nil, ErrInvalidUpload
+	}
+
+	photo.UploadedBy = userID
+	if err := s.repo.CreatePhoto(ctx, photo); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			// Параллельный запрос уже подтвердил эту загрузку
+			log.Warningf("Upload %s is already confirmed", objectName)
+			return nil, ErrPhotoNotFound
+		}
+		log.Errorf("Failed to register photo %s: %v", objectName, err)
+		return nil, fmt.Errorf("failed to register photo: %w", err)
+	}
+	s.deletePendingUpload(ctx, objectName)
+
+	metrics.PhotoUploadsTotal.WithLabelValues("direct").Inc()
+	log.Infof("Successfully confirmed upload: objectName=%s", objectName)
+	return photo, nil
+}
+
+// deletePendingUpload удаляет запись о завершённой загрузке. Ошибка только
+// логируется: запись всё равно истечёт, а повторное подтверждение не пройдёт.
+func (s *PhotoService) deletePendingUpload(ctx context.Context, objectName string) {
+	if err := s.repo.DeletePendingUpload(ctx, objectName); err != nil {
+		logger.FromContext(ctx).Errorf("Failed to delete pending upload %s: %v", objectName, err)
+	}
+}
+
+// GetDownloadURL возвращает ссылку на скачивание со сроком жизни urlTTL. Ссылка
+// кэшируется на половину срока; для отсутствующего объекта возвращается ErrPhotoNotFound.
 func (s *PhotoService) GetDownloadURL(ctx context.Context, objectName string) (string, error) {
-	s.log.Infof("Fetching download URL for objectName: %s", objectName)
+	log := logger.FromContext(ctx)
+	log.Debugf("Fetching download URL for objectName: %s", objectName)
+
+	url, err := s.repo.GetPresignedURL(ctx, objectName, s.urlTTL)
+	if err != nil {
+		if errors.Is(err, ErrPhotoNotFound) {
+			log.Warningf("Photo not found in storage: %s", objectName)
+			return "", ErrPhotoNotFound
+		}
+		log.Errorf("Failed to generate URL for %s: %v", objectName, err)
+		return "", fmt.Errorf("failed to get download URL: %w", err)
+	}
+
+	log.Debugf("Generated download URL for %s", objectName)
+	return url, nil
+}
+
+// GetShortLivedURL возвращает ссылку на скачивание со сроком жизни urlTTL.
+// Такие ссылки не кэшируются, поэтому после удаления объекта быстро перестают работать.
+// Для отсутствующего объекта возвращается ErrPhotoNotFound.
+func (s *PhotoService) GetShortLivedURL(ctx context.Context, objectName string) (string, error) {
+	log := logger.FromContext(ctx)
+	log.Debugf("Generating short-lived URL for objectName: %s", objectName)
 
-	url, err := s.repo.GetPresignedURL(ctx, objectName)
+	url, err := s.repo.PresignGet(ctx, objectName, s.urlTTL)
 	if err != nil {
-		if err.Error() == "The specified key does not exist." {
-			s.log.Warningf("Photo not found in MinIO: %s", objectName)
-			return "", fmt.Errorf("photo not found")
+		if errors.Is(err, ErrPhotoNotFound) {
+			log.Warningf("Photo not found in storage: %s", objectName)
+			return "", ErrPhotoNotFound
 		}
-		s.log.Errorf("Failed to generate URL for %s: %v", objectName, err)
+		log.Errorf("Failed to generate short-lived URL for %s: %v", objectName, err)
 		return "", fmt.Errorf("failed to get download URL: %w", err)
 	}
 
-	s.log.Infof("Generated download URL for %s", objectName)
 	return url, nil
 }
+
+// Open открывает фотографию для потоковой отдачи через API.
+// Вызывающая сторона обязана закрыть PhotoObject.Reader.
+func (s *PhotoService) Open(ctx context.Context, objectName string) (*repository.PhotoObject, error) {
+	log := logger.FromContext(ctx)
+	log.Debugf("Opening photo for streaming: %s", objectName)
+
+	obj, err := s.repo.GetObject(ctx, objectName)
+	if err != nil {
+		if errors.Is(err, ErrPhotoNotFound) {
+			log.Warningf("Photo not found in MinIO: %s", objectName)
+			return nil, err
+		}
+		log.Errorf("Failed to open photo %s: %v", objectName, err)
+		return nil, fmt.Errorf("failed to open photo: %w", err)
+	}
+
+	return obj, nil
+}
+
+// ErrPhotoInUse возвращается при попытке удалить фотографию, на которую ссылаются товары.
+var ErrPhotoInUse = errors.New("photo is referenced by products")
+
+// BulkDeleteResult — итог массового удаления фотографий.
+type BulkDeleteResult struct {
+	Deleted []string          `json:"deleted"`
+	Failed  []BulkDeleteError `json:"failed"`
+}
+
+// BulkDeleteError описывает фотографию, которую не удалось удалить.
+type BulkDeleteError struct {
+	ObjectName string `json:"objectName"`
+	Error      string `json:"error"`
+}
+
+// ListPhotos возвращает страницу объектов бакета с данными о загрузке и ссылающихся товарах.
+func (s *PhotoService) ListPhotos(ctx context.Context, after string, limit int) ([]*models.Photo, string, error) {
+	log := logger.FromContext(ctx)
+	log.Debugf("Listing photos: after=%s, limit=%d", after, limit)
+
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	photos, next, err := s.repo.ListObjects(ctx, after, limit)
+	if err != nil {
+		log.Errorf("Failed to list photos: %v", err)
+		return nil, "", fmt.Errorf("failed to list photos: %w", err)
+	}
+	if err := s.repo.FillPhotoDetails(ctx, photos); err != nil {
+		log.Errorf("Failed to load photo details: %v", err)
+		return nil, "", fmt.Errorf("failed to list photos: %w", err)
+	}
+
+	log.Debugf("Successfully listed %d photos", len(photos))
+	return photos, next, nil
+}
+
+// DeletePhoto удаляет фотографию. Если на неё ссылаются товары, удаление
+// выполняется только при force.
+func (s *PhotoService) DeletePhoto(ctx context.Context, objectName string, force bool) error {
+	log := logger.FromContext(ctx)
+	log.Infof("Deleting photo: objectName=%s, force=%t", objectName, force)
+
+	if !force {
+		refs, err := s.repo.ProductReferences(ctx, []string{objectName})
+		if err != nil {
+			log.Errorf("Failed to check references for photo %s: %v", objectName, err)
+			return fmt.Errorf("failed to delete photo: %w", err)
+		}
+		if ids := refs[objectName]; len(ids) > 0 {
+			log.Warningf("Refusing to delete photo %s: referenced by products %v", objectName, ids)
+			return fmt.Errorf("%w: %v", ErrPhotoInUse, ids)
+		}
+	}
+
+	if err := s.repo.DeletePhoto(ctx, objectName); err != nil {
+		if errors.Is(err, ErrPhotoNotFound) {
+			log.Warningf("Failed to delete photo %s: not found", objectName)
+			return err
+		}
+		log.Errorf("Failed to delete photo %s: %v", objectName, err)
+		return fmt.Errorf("failed to delete photo: %w", err)
+	}
+
+	log.Infof("Successfully deleted photo: objectName=%s", objectName)
+	return nil
+}
+
+// DeletePhotos удаляет несколько фотографий, продолжая работу при ошибках отдельных объектов.
+func (s *PhotoService) DeletePhotos(ctx context.Context, objectNames []string, force bool) *BulkDeleteResult {
+	log := logger.FromContext(ctx)
+	log.Infof("Bulk deleting %d photos, force=%t", len(objectNames), force)
+
+	result := &BulkDeleteResult{Deleted: []string{}, Failed: []BulkDeleteError{}}
+	for _, name := range objectNames {
+		if err := s.DeletePhoto(ctx, name, force); err != nil {
+			result.Failed = append(result.Failed, BulkDeleteError{ObjectName: name, Error: err.Error()})
+			continue
+		}
+		result.Deleted = append(result.Deleted, name)
+	}
+
+	log.Infof("Bulk delete finished: deleted=%d, failed=%d", len(result.Deleted), len(result.Failed))
+	return result
+}
@@ -14,12 +14,11 @@ import (
 // ProductService предоставляет бизнес-логику для товаров.
 type ProductService struct {
 	repo *repository.ProductRepository
-	log  *logger.Logger
 }
 
 // NewProductService создаёт новый сервис для товаров.
-func NewProductService(repo *repository.ProductRepository, log *logger.Logger) *ProductService {
-	return &ProductService{repo: repo, log: log}
+func NewProductService(repo *repository.ProductRepository) *ProductService {
+	return &ProductService{repo: repo}
 }
 
 // validateProduct проверяет корректность полей продукта в зависимости от типа.
@@ -28,7 +27,7 @@ func (s *ProductService) validateProduct(product *models.Product) error {
 	if product.Name == "" {
 		return fmt.Errorf("name is required")
 	}
-	if product.Price <= 0 {
+	if !product.Price.IsPositive() {
 		return fmt.Errorf("price must be greater than 0")
 	}
 	if len(product.Images) == 0 {
@@ -43,6 +42,11 @@ func (s *ProductService) validateProduct(product *models.Product) error {
 		return fmt.Errorf("type must be either 'yarn' or 'garment'")
 	}
 
+	// Вес нужен для расчёта доставки; 0 — не указан
+	if product.Weight < 0 {
+		return fmt.Errorf("weight must not be negative")
+	}
+
 	// Проверка специфичных полей для yarn
 	if product.Type == "yarn" {
 		if product.Composition == "" {
@@ -80,53 +84,56 @@ func (s *ProductService) validateProduct(product *models.Product) error {
 
 // CreateProduct создаёт новый товар.
 func (s *ProductService) CreateProduct(ctx context.Context, product *models.Product) error {
-	s.log.Infof("Attempting to create product with name: %s, type: %s", product.Name, product.Type)
+	log := logger.FromContext(ctx)
+	log.Infof("Attempting to create product with name: %s, type: %s", product.Name, product.Type)
 
 	// Валидация продукта
 	if err := s.validateProduct(product); err != nil {
-		s.log.Errorf("Validation failed for product '%s': %v", product.Name, err)
+		log.Errorf("Validation failed for product '%s': %v", product.Name, err)
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
 	err := s.repo.CreateProduct(ctx, product)
 	if err != nil {
-		s.log.Errorf("Failed to create product '%s': %v", product.Name, err)
+		log.Errorf("Failed to create product '%s': %v", product.Name, err)
 		return fmt.Errorf("failed to create product: %w", err)
 	}
 
-	s.log.Infof("Successfully created product with ID: %d", product.ID)
+	log.Infof("Successfully created product with ID: %d", product.ID)
 	return nil
 }
 
 // GetProduct возвращает товар по ID.
 func (s *ProductService) GetProduct(ctx context.Context, id int) (*models.Product, error) {
-	s.log.Infof("Fetching product with ID: %d", id)
+	log := logger.FromContext(ctx)
+	log.Debugf("Fetching product with ID: %d", id)
 
 	product, err := s.repo.GetProduct(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			s.log.Warningf("Product with ID %d not found", id)
+			log.Warningf("Product with ID %d not found", id)
 			return nil, fmt.Errorf("product not found: %w", err)
 		}
-		s.log.Errorf("Failed to fetch product with ID %d: %v", id, err)
+		log.Errorf("Failed to fetch product with ID %d: %v", id, err)
 		return nil, fmt.Errorf("failed to fetch product: %w", err)
 	}
 
-	s.log.Infof("Fetched product with ID: %d, Name: %s, Type: %s", product.ID, product.Name, product.Type)
+	log.Debugf("Fetched product with ID: %d, Name: %s, Type: %s", product.ID, product.Name, product.Type)
 	return product, nil
 }
 
 // ListProducts возвращает все товары.
 func (s *ProductService) ListProducts(ctx context.Context) ([]*models.Product, error) {
-	s.log.Info("Fetching all products")
+	log := logger.FromContext(ctx)
+	log.Debug("Fetching all products")
 
 	products, err := s.repo.ListProducts(ctx)
 	if err != nil {
-		s.log.Errorf("Failed to fetch all products: %v", err)
+		log.Errorf("Failed to fetch all products: %v", err)
 		return nil, fmt.Errorf("failed to fetch all products: %w", err)
 	}
 
-	s.log.Infof("Successfully fetched %d products", len(products))
+	log.Debugf("Successfully fetched %d products", len(products))
 	return products, nil
 }
 
@@ -137,8 +144,8 @@ func (s *ProductService) SearchProducts(
 	color string,
 	page, limit int,
 ) ([]*models.Product, int, error) {
-
-	s.log.Infof("Searching products: name=%s, type=%s, categoryID=%d, color=%s, page=%d, limit=%d",
+	log := logger.FromContext(ctx)
+	log.Debugf("Searching products: name=%s, type=%s, categoryID=%d, color=%s, page=%d, limit=%d",
 		name, productType, categoryID, color, page, limit)
 
 	if productType != "" && productType != "yarn" && productType != "garment" {
@@ -159,42 +166,44 @@ func (s *ProductService) SearchProducts(
 
 // UpdateProduct обновляет существующий товар.
 func (s *ProductService) UpdateProduct(ctx context.Context, product *models.Product) error {
-	s.log.Infof("Updating product with ID: %d, Type: %s", product.ID, product.Type)
+	log := logger.FromContext(ctx)
+	log.Infof("Updating product with ID: %d, Type: %s", product.ID, product.Type)
 
 	// Валидация продукта
 	if err := s.validateProduct(product); err != nil {
-		s.log.Errorf("Validation failed for product ID %d: %v", product.ID, err)
+		log.Errorf("Validation failed for product ID %d: %v", product.ID, err)
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
 	err := s.repo.UpdateProduct(ctx, product)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			s.log.Warningf("Failed to update product with ID %d: product not found", product.ID)
+			log.Warningf("Failed to update product with ID %d: product not found", product.ID)
 			return fmt.Errorf("product with ID %d not found: %w", product.ID, err)
 		}
-		s.log.Errorf("Failed to update product with ID %d: %v", product.ID, err)
+		log.Errorf("Failed to update product with ID %d: %v", product.ID, err)
 		return fmt.Errorf("failed to update product: %w", err)
 	}
 
-	s.log.Infof("Successfully updated product with ID: %d", product.ID)
+	log.Infof("Successfully updated product with ID: %d", product.ID)
 	return nil
 }
 
 // DeleteProduct удаляет товар по ID.
 func (s *ProductService) DeleteProduct(ctx context.Context, id int) error {
-	s.log.Infof("Deleting product with ID: %d", id)
+	log := logger.FromContext(ctx)
+	log.Infof("Deleting product with ID: %d", id)
 
 	err := s.repo.DeleteProduct(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			s.log.Warningf("Failed to delete product with ID %d: product not found", id)
+			log.Warningf("Failed to delete product with ID %d: product not found", id)
 			return fmt.Errorf("product with ID %d not found: %w", id, err)
 		}
-		s.log.Errorf("Failed to delete product with ID %d: %v", id, err)
+		log.Errorf("Failed to delete product with ID %d: %v", id, err)
 		return fmt.Errorf("failed to delete product: %w", err)
 	}
 
-	s.log.Infof("Successfully deleted product with ID: %d", id)
+	log.Infof("Successfully deleted product with ID: %d", id)
 	return nil
 }
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/mailer"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Ошибки операций с профилем текущего пользователя.
+var (
+	ErrInvalidProfile    = errors.New("invalid profile data")
+	ErrWrongPassword     = errors.New("current password is incorrect")
+	ErrEmailTaken        = errors.New("email is already in use")
+	ErrInvalidEmailToken = errors.New("email confirmation token is invalid or expired")
+)
+
+// minPasswordLength — минимальная длина нового пароля.
+const minPasswordLength = 8
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{10,15}$`)
+
+// ProfileUpdate — частичное изменение профиля: nil означает «не менять».
+type ProfileUpdate struct {
+	Name  *string
+	Email *string
+	Phone *string
+	// Version — версия профиля, которую видел клиент (If-Match); 0 — без проверки.
+	Version int
+}
+
+// ProfileOptions — параметры подтверждения смены email.
+type ProfileOptions struct {
+	ConfirmURL string        // адрес страницы подтверждения, к нему добавляется ?token=
+	TokenTTL   time.Duration // срок действия ссылки подтверждения
+}
+
+// ProfileService реализует операции пользователя над собственным профилем.
+type ProfileService struct {
+	repo   *repository.UserRepository
+	mailer mailer.Mailer
+	opts   ProfileOptions
+}
+
+// NewProfileService создаёт сервис профиля. Письма для подтверждения email отправляются через m.
+func NewProfileService(repo *repository.UserRepository, m mailer.Mailer, opts ProfileOptions) *ProfileService {
+	return &ProfileService{repo: repo, mailer: m, opts: opts}
+}
+
+// UpdateProfile применяет изменения профиля и возвращает обновлённого пользователя.
+// Новый email не применяется сразу: на него отправляется ссылка подтверждения,
+// а сам адрес возвращается как pendingEmail.
+func (s *ProfileService) UpdateProfile(ctx context.Context, id int, update ProfileUpdate) (user *models.User, pendingEmail string, err error) {
+	log := logger.FromContext(ctx)
+	log.Infof("Updating profile of user ID: %d", id)
+
+	user, err = s.repo.GetUser(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", fmt.Errorf("user not found: %w", err)
+		}
+		return nil, "", fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if update.Version != 0 && update.Version != user.Version {
+		return nil, "", ErrVersionConflict
+	}
+
+	name, phone := user.Name, user.Phone
+	if update.Name != nil {
+		name = strings.TrimSpace(*update.Name)
+		if name == "" || len(name) > 255 {
+			return nil, "", fmt.Errorf("%w: name must be 1 to 255 characters", ErrInvalidProfile)
+		}
+	}
+	if update.Phone != nil {
+		phone = normalizePhone(*update.Phone)
+		if phone != "" && !phonePattern.MatchString(phone) {
+			return nil, "", fmt.Errorf("%w: phone must contain 10 to 15 digits", ErrInvalidProfile)
+		}
+	}
+	if name != user.Name || phone != user.Phone {
+		user.Name, user.Phone = name, phone
+		if err := s.repo.UpdateProfile(ctx, user); err != nil {
+			log.Errorf("Failed to update profile of user ID %d: %v", id, err)
+			return nil, "", fmt.Errorf("failed to update profile: %w", err)
+		}
+	}
+
+	if update.Email != nil && !strings.EqualFold(strings.TrimSpace(*update.Email), user.Email) {
+		pendingEmail, err = s.requestEmailChange(ctx, user, *update.Email)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	log.Infof("Updated profile of user ID: %d", id)
+	return user, pendingEmail, nil
+}
+
+// normalizePhone убирает из номера пробелы, дефисы и скобки.
+func normalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+}
+
+// requestEmailChange отправляет на новый адрес ссылку подтверждения.
+func (s *ProfileService) requestEmailChange(ctx context.Context, user *models.User, email string) (string, error) {
+	log := logger.FromContext(ctx)
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return "", fmt.Errorf("%w: email is malformed", ErrInvalidProfile)
+	}
+	email = addr.Address
+
+	if _, err := s.repo.GetUserByEmail(ctx, email); err == nil {
+		return "", ErrEmailTaken
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to check email: %w", err)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.SaveEmailChange(ctx, token, user.ID, email, s.opts.TokenTTL); err != nil {
+		log.Errorf("Failed to save email change for user ID %d: %v", user.ID, err)
+		return "", fmt.Errorf("failed to save email change: %w", err)
+	}
+
+	link := s.opts.ConfirmURL + "?token=" + url.QueryEscape(token)
+	msg := mailer.Message{
+		To:      email,
+		Subject: "Подтвердите новый адрес почты",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы использовать этот адрес для входа, перейдите по ссылке:\n%s\n\n"+
+			"Ссылка действует %s. Если вы не меняли адрес, просто проигнорируйте письмо.\n",
+			user.Name, link, s.opts.TokenTTL),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		log.Errorf("Failed to send email confirmation to %s: %v", logger.MaskEmail(email), err)
+		return "", fmt.Errorf("failed to send confirmation: %w", err)
+	}
+
+	log.Infof("Requested email change for user ID %d to %s", user.ID, logger.MaskEmail(email))
+	return email, nil
+}
+
+// ConfirmEmailChange применяет смену email по токену из письма.
+func (s *ProfileService) ConfirmEmailChange(ctx context.Context, token string) error {
+	log := logger.FromContext(ctx)
+
+	id, email, err := s.repo.TakeEmailChange(ctx, token)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrInvalidEmailToken
+		}
+		log.Errorf("Failed to load email change: %v", err)
+		return fmt.Errorf("failed to load email change: %w", err)
+	}
+
+	if err := s.repo.UpdateEmail(ctx, id, email); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrEmailTaken
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidEmailToken
+		}
+		log.Errorf("Failed to change email of user ID %d: %v", id, err)
+		return fmt.Errorf("failed to change email: %w", err)
+	}
+
+	log.Infof("Changed email of user ID %d to %s", id, logger.MaskEmail(email))
+	return nil
+}
+
+// ChangePassword меняет пароль после проверки текущего.
+func (s *ProfileService) ChangePassword(ctx context.Context, id int, current, next string) error {
+	log := logger.FromContext(ctx)
+
+	if err := s.checkPassword(ctx, id, current); err != nil {
+		return err
+	}
+	if len(next) < minPasswordLength {
+		return fmt.Errorf("%w: password must be at least %d characters", ErrInvalidProfile, minPasswordLength)
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(next), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.repo.UpdatePassword(ctx, id, string(hashed)); err != nil {
+		log.Errorf("Failed to change password of user ID %d: %v", id, err)
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	log.Infof("Changed password of user ID: %d", id)
+	return nil
+}
+
+// DeleteAccount удаляет аккаунт пользователя после проверки пароля.
+// Персональные данные обезличиваются, история заказов сохраняется.
+func (s *ProfileService) DeleteAccount(ctx context.Context, id int, password string) error {
+	log := logger.FromContext(ctx)
+
+	if err := s.checkPassword(ctx, id, password); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteUser(ctx, id); err != nil {
+		log.Errorf("Failed to delete account of user ID %d: %v", id, err)
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	log.Infof("Deleted account of user ID: %d", id)
+	return nil
+}
+
+func (s *ProfileService) checkPassword(ctx context.Context, id int, password string) error {
+	hashed, err := s.repo.GetUserPassword(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user not found: %w", err)
+		}
+		return fmt.Errorf("failed to retrieve user password: %w", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) != nil {
+		logger.FromContext(ctx).Warningf("Wrong current password for user ID %d", id)
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// newToken возвращает случайный токен для ссылок из писем.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
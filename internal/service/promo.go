@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/lib/pq"
+)
+
+// Ошибки промокодов.
+var (
+	ErrInvalidPromoCode       = errors.New("invalid promo code")
+	ErrPromoCodeTaken         = errors.New("promo code already exists")
+	ErrPromoCodeNotApplicable = errors.New("promo code cannot be applied to this order")
+	ErrPromoCodeExhausted     = repository.ErrPromoCodeExhausted
+)
+
+// PromoKinds — поддерживаемые виды скидки.
+var PromoKinds = []string{models.PromoPercent, models.PromoFixed, models.PromoFreeShipping}
+
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,50}$`)
+
+// NormalizePromoCode приводит введённый покупателем код к виду, в котором он хранится.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// DiscountItem — позиция заказа, от которой может считаться скидка.
+type DiscountItem struct {
+	ProductID  int
+	CategoryID int
+	Amount     money.Money // цена, умноженная на количество
+}
+
+// CalculateDiscount возвращает скидку промокода promo для заказа из позиций items
+// со стоимостью доставки deliveryCost. Срок действия и лимиты здесь не проверяются.
+// Процентная скидка считается от суммы подходящих позиций и округляется до копейки
+// (см. money.Money.Percent). Если заказ не подходит под условия промокода,
+// возвращается ErrPromoCodeNotApplicable.
+func CalculateDiscount(promo *models.PromoCode, items []DiscountItem, deliveryCost money.Money) (money.Money, error) {
+	var subtotal, eligible money.Money
+	var matched bool
+	for _, item := range items {
+		subtotal = subtotal.Add(item.Amount)
+		if promoAppliesTo(promo, item) {
+			eligible = eligible.Add(item.Amount)
+			matched = true
+		}
+	}
+	if subtotal.Cmp(promo.MinOrderTotal) < 0 {
+		return money.Money{}, fmt.Errorf("%w: order total must be at least %s", ErrPromoCodeNotApplicable, promo.MinOrderTotal)
+	}
+	if !matched {
+		return money.Money{}, fmt.Errorf("%w: no items in the order are eligible", ErrPromoCodeNotApplicable)
+	}
+
+	switch promo.Kind {
+	case models.PromoPercent:
+		return eligible.Percent(promo.PercentBP), nil
+	case models.PromoFixed:
+		return money.Min(promo.Amount, eligible), nil
+	case models.PromoFreeShipping:
+		return deliveryCost, nil
+	}
+	return money.Money{}, fmt.Errorf("%w: unknown kind %q", ErrPromoCodeNotApplicable, promo.Kind)
+}
+
+// AllocateDiscount распределяет скидку discount промокода promo по позициям items
+// пропорционально их стоимости (см. money.Money.Allocate): доли получают только
+// подходящие позиции, и их сумма точно равна discount. Скидка free_shipping относится
+// к доставке, поэтому доли позиций нулевые.
+func AllocateDiscount(promo *models.PromoCode, items []DiscountItem, discount money.Money) []money.Money {
+	if promo.Kind == models.PromoFreeShipping {
+		return make([]money.Money, len(items))
+	}
+	weights := make([]int64, len(items))
+	for i, item := range items {
+		if promoAppliesTo(promo, item) {
+			weights[i] = item.Amount.Amount
+		}
+	}
+	return discount.Allocate(weights)
+}
+
+// promoAppliesTo проверяет ограничения промокода по товарам и категориям.
+func promoAppliesTo(promo *models.PromoCode, item DiscountItem) bool {
+	if len(promo.ProductIDs) == 0 && len(promo.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range promo.ProductIDs {
+		if id == item.ProductID {
+			return true
+		}
+	}
+	for _, id := range promo.CategoryIDs {
+		if id == item.CategoryID {
+			return true
+		}
+	}
+	return false
+}
+
+// PromoService управляет промокодами и рассчитывает скидки по ним.
+type PromoService struct {
+	repo *repository.PromoRepository
+}
+
+// NewPromoService создаёт сервис промокодов.
+func NewPromoService(repo *repository.PromoRepository) *PromoService {
+	return &PromoService{repo: repo}
+}
+
+// Apply проверяет, что промокод code действует и его лимиты для покупателя userID
+// не исчерпаны, и рассчитывает скидку. Окончательно лимиты проверяются при сохранении заказа.
+func (s *PromoService) Apply(ctx context.Context, userID int, code string, items []DiscountItem, deliveryCost money.Money) (*models.PromoCode, money.Money, error) {
+	code = NormalizePromoCode(code)
+	promo, err := s.repo.GetPromoCodeByCode(ctx, code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, money.Money{}, fmt.Errorf("%w: promo code %q not found", ErrPromoCodeNotApplicable, code)
+	}
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Failed to fetch promo code %s: %v", code, err)
+		return nil, money.Money{}, fmt.Errorf("failed to fetch promo code: %w", err)
+	}
+
+	now := time.Now()
+	switch {
+	case !promo.IsActive:
+		return nil, money.Money{}, fmt.Errorf("%w: promo code %q is not active", ErrPromoCodeNotApplicable, code)
+	case promo.StartsAt != nil && now.Before(*promo.StartsAt):
+		return nil, money.Money{}, fmt.Errorf("%w: promo code %q is not valid yet", ErrPromoCodeNotApplicable, code)
+	case promo.EndsAt != nil && !now.Before(*promo.EndsAt):
+		return nil, money.Money{}, fmt.Errorf("%w: promo code %q has expired", ErrPromoCodeNotApplicable, code)
+	}
+
+	if promo.MaxUses != nil || promo.MaxUsesPerUser != nil {
+		total, byUser, err := s.repo.CountUsages(ctx, promo.ID, userID)
+		if err != nil {
+			logger.FromContext(ctx).Errorf("Failed to count usages of promo code ID %d: %v", promo.ID, err)
+			return nil, money.Money{}, fmt.Errorf("failed to count promo code usages: %w", err)
+		}
+		if (promo.MaxUses != nil && total >= *promo.MaxUses) || (promo.MaxUsesPerUser != nil && byUser >= *promo.MaxUsesPerUser) {
+			return nil, money.Money{}, fmt.Errorf("%w: %s", ErrPromoCodeExhausted, code)
+		}
+	}
+
+	discount, err := CalculateDiscount(promo, items, deliveryCost)
+	if err != nil {
+		return nil, money.Money{}, err
+	}
+	return promo, discount, nil
+}
+
+// ListPromoCodes возвращает все промокоды.
+func (s *PromoService) ListPromoCodes(ctx context.Context) ([]*models.PromoCode, error) {
+	codes, err := s.repo.ListPromoCodes(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("Failed to fetch promo codes: %v", err)
+		return nil, fmt.Errorf("failed to fetch promo codes: %w", err)
+	}
+	return codes, nil
+}
+
+// GetPromoCode возвращает промокод по ID.
+func (s *PromoService) GetPromoCode(ctx context.Context, id int) (*models.PromoCode, error) {
+	promo, err := s.repo.GetPromoCode(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("promo code not found: %w", err)
+		}
+		logger.FromContext(ctx).Errorf("Failed to fetch promo code ID %d: %v", id, err)
+		return nil, fmt.Errorf("failed to fetch promo code: %w", err)
+	}
+	return promo, nil
+}
+
+// CreatePromoCode проверяет и сохраняет новый промокод.
+func (s *PromoService) CreatePromoCode(ctx context.Context, promo *models.PromoCode) error {
+	if err := validatePromoCode(promo); err != nil {
+		return err
+	}
+	if err := s.repo.CreatePromoCode(ctx, promo); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrPromoCodeTaken
+		}
+		logger.FromContext(ctx).Errorf("Failed to create promo code %s: %v", promo.Code, err)
+		return fmt.Errorf("failed to create promo code: %w", err)
+	}
+	logger.FromContext(ctx).Infof("Created promo code ID %d: %s", promo.ID, promo.Code)
+	return nil
+}
+
+// UpdatePromoCode проверяет и сохраняет изменённый промокод. Уже оформленные
+// заказы сохраняют рассчитанную скидку.
+func (s *PromoService) UpdatePromoCode(ctx context.Context, promo *models.PromoCode) error {
+	if err := validatePromoCode(promo); err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePromoCode(ctx, promo); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrPromoCodeTaken
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("promo code not found: %w", err)
+		}
+		logger.FromContext(ctx).Errorf("Failed to update promo code ID %d: %v", promo.ID, err)
+		return fmt.Errorf("failed to update promo code: %w", err)
+	}
+	logger.FromContext(ctx).Infof("Updated promo code ID %d", promo.ID)
+	return nil
+}
+
+// DeletePromoCode удаляет промокод.
+func (s *PromoService) DeletePromoCode(ctx context.Context, id int) error {
+	if err := s.repo.DeletePromoCode(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("promo code not found: %w", err)
+		}
+		logger.FromContext(ctx).Errorf("Failed to delete promo code ID %d: %v", id, err)
+		return fmt.Errorf("failed to delete promo code: %w", err)
+	}
+	logger.FromContext(ctx).Infof("Deleted promo code ID %d", id)
+	return nil
+}
+
+// Stats возвращает статистику использования промокода.
+func (s *PromoService) Stats(ctx context.Context, id int) (*models.PromoCodeStats, error) {
+	stats, err := s.repo.Stats(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("promo code not found: %w", err)
+		}
+		logger.FromContext(ctx).Errorf("Failed to fetch stats of promo code ID %d: %v", id, err)
+		return nil, fmt.Errorf("failed to fetch promo code stats: %w", err)
+	}
+	return stats, nil
+}
+
+func validatePromoCode(promo *models.PromoCode) error {
+	promo.Code = NormalizePromoCode(promo.Code)
+	// Хранится только параметр, относящийся к виду скидки
+	if promo.Kind != models.PromoPercent {
+		promo.PercentBP = 0
+	}
+	if promo.Kind != models.PromoFixed {
+		promo.Amount = money.Money{}
+	}
+	switch {
+	case !promoCodePattern.MatchString(promo.Code):
+		return fmt.Errorf("%w: code must be 3-50 latin letters, digits, '-' or '_'", ErrInvalidPromoCode)
+	case !isPromoKind(promo.Kind):
+		return fmt.Errorf("%w: kind must be one of %s", ErrInvalidPromoCode, strings.Join(PromoKinds, ", "))
+	case promo.Kind == models.PromoPercent && (promo.PercentBP <= 0 || promo.PercentBP > 10000):
+		return fmt.Errorf("%w: percent_bp must be greater than 0 and at most 10000 (100%%)", ErrInvalidPromoCode)
+	case promo.Kind == models.PromoFixed && !promo.Amount.IsPositive():
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidPromoCode)
+	case promo.StartsAt != nil && promo.EndsAt != nil && !promo.EndsAt.After(*promo.StartsAt):
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromoCode)
+	case promo.MaxUses != nil && *promo.MaxUses <= 0:
+		return fmt.Errorf("%w: max_uses must be positive", ErrInvalidPromoCode)
+	case promo.MaxUsesPerUser != nil && *promo.MaxUsesPerUser <= 0:
+		return fmt.Errorf("%w: max_uses_per_user must be positive", ErrInvalidPromoCode)
+	case promo.MinOrderTotal.IsNegative():
+		return fmt.Errorf("%w: min_order_total must not be negative", ErrInvalidPromoCode)
+	}
+	for _, id := range append(append([]int{}, promo.ProductIDs...), promo.CategoryIDs...) {
+		if id <= 0 {
+			return fmt.Errorf("%w: product_ids and category_ids must be positive", ErrInvalidPromoCode)
+		}
+	}
+	return nil
+}
+
+func isPromoKind(kind string) bool {
+	for _, k := range PromoKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -5,166 +5,243 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
 	"github.com/alex-pyslar/petelka-api/internal/models"
 	"github.com/alex-pyslar/petelka-api/internal/repository"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrUserBlocked возвращается для пользователя, заблокированного администратором.
+var ErrUserBlocked = errors.New("user is blocked")
+
 // UserService предоставляет бизнес-логику для пользователей
 type UserService struct {
 	repo *repository.UserRepository
-	log  *logger.Logger
 }
 
 // NewUserService создаёт новый сервис для пользователей
-func NewUserService(repo *repository.UserRepository, log *logger.Logger) *UserService {
-	return &UserService{repo: repo, log: log}
+func NewUserService(repo *repository.UserRepository) *UserService {
+	return &UserService{repo: repo}
 }
 
 // CreateUser создаёт нового пользователя
 func (s *UserService) CreateUser(ctx context.Context, user *models.User) error {
-	s.log.Infof("Attempting to create user with email: %s", user.Email)
+	log := logger.FromContext(ctx)
+	log.Infof("Attempting to create user with email: %s", logger.MaskEmail(user.Email))
 
 	if user.Password == "" {
-		s.log.Errorf("Password is required for user with email: %s", user.Email)
+		log.Errorf("Password is required for user with email: %s", logger.MaskEmail(user.Email))
 		return fmt.Errorf("password is required")
 	}
 
 	// Хешируем пароль перед сохранением в репозиторий
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		s.log.Errorf("Failed to hash password for email %s: %v", user.Email, err)
+		log.Errorf("Failed to hash password for email %s: %v", logger.MaskEmail(user.Email), err)
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 	user.Password = string(hashedPassword)
 
 	err = s.repo.CreateUser(ctx, user)
 	if err != nil {
-		s.log.Errorf("Failed to create user with email %s: %v", user.Email, err)
+		log.Errorf("Failed to create user with email %s: %v", logger.MaskEmail(user.Email), err)
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
-	s.log.Infof("Successfully created user with ID: %d", user.ID)
+	metrics.RegistrationsTotal.Inc()
+	log.Infof("Successfully created user with ID: %d", user.ID)
 	return nil
 }
 
 // GetUser возвращает пользователя по ID
 func (s *UserService) GetUser(ctx context.Context, id int) (*models.User, error) {
-	s.log.Infof("Fetching user with ID: %d", id)
+	log := logger.FromContext(ctx)
+	log.Debugf("Fetching user with ID: %d", id)
 
 	user, err := s.repo.GetUser(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			s.log.Warningf("User with ID %d not found", id)
+			log.Warningf("User with ID %d not found", id)
 			return nil, fmt.Errorf("user not found: %w", err)
 		}
-		s.log.Errorf("Failed to fetch user with ID %d: %v", id, err)
+		log.Errorf("Failed to fetch user with ID %d: %v", id, err)
 		return nil, fmt.Errorf("failed to fetch user: %w", err)
 	}
 
-	s.log.Infof("Fetched user with ID: %d, Email: %s", user.ID, user.Email)
+	log.Debugf("Fetched user with ID: %d, Email: %s", user.ID, logger.MaskEmail(user.Email))
 	return user, nil
 }
 
 // GetUserByEmail возвращает пользователя по email
 func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	s.log.Infof("Fetching user with email: %s", email)
+	log := logger.FromContext(ctx)
+	log.Debugf("Fetching user with email: %s", logger.MaskEmail(email))
 
 	user, err := s.repo.GetUserByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			s.log.Warningf("User with email %s not found", email)
+			log.Warningf("User with email %s not found", logger.MaskEmail(email))
 			return nil, fmt.Errorf("user not found: %w", err)
 		}
-		s.log.Errorf("Failed to fetch user with email %s: %v", email, err)
+		log.Errorf("Failed to fetch user with email %s: %v", logger.MaskEmail(email), err)
 		return nil, fmt.Errorf("failed to fetch user by email: %w", err)
 	}
 
-	s.log.Infof("Fetched user with email: %s, ID: %d", user.Email, user.ID)
+	log.Debugf("Fetched user with email: %s, ID: %d", logger.MaskEmail(user.Email), user.ID)
 	return user, nil
 }
 
 // ListUsers возвращает всех пользователей
 func (s *UserService) ListUsers(ctx context.Context) ([]*models.User, error) {
-	s.log.Info("Fetching all users from repository")
+	log := logger.FromContext(ctx)
+	log.Debug("Fetching all users from repository")
 
 	users, err := s.repo.ListUsers(ctx)
 	if err != nil {
-		s.log.Errorf("Failed to fetch all users: %v", err)
+		log.Errorf("Failed to fetch all users: %v", err)
 		return nil, fmt.Errorf("failed to fetch all users: %w", err)
 	}
 
-	s.log.Infof("Successfully fetched %d users", len(users))
+	log.Debugf("Successfully fetched %d users", len(users))
 	return users, nil
 }
 
-// UpdateUser обновляет существующего пользователя
-func (s *UserService) UpdateUser(ctx context.Context, user *models.User) error {
-	s.log.Infof("Updating user with ID: %d", user.ID)
+// UserFilter — условия поиска пользователей (см. repository.UserFilter).
+type UserFilter = repository.UserFilter
 
-	// Хешируем пароль, если он был передан для обновления
-	if user.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-		if err != nil {
-			s.log.Errorf("Failed to hash password for user ID %d: %v", user.ID, err)
-			return fmt.Errorf("failed to hash new password: %w", err)
+// SearchUsers ищет пользователей по фильтру с пагинацией.
+func (s *UserService) SearchUsers(ctx context.Context, filter UserFilter) ([]*models.User, int, error) {
+	log := logger.FromContext(ctx)
+	log.Debugf("Searching users: role=%q status=%q page=%d limit=%d", filter.Role, filter.Status, filter.Page, filter.Limit)
+
+	users, total, err := s.repo.SearchUsers(ctx, filter)
+	if err != nil {
+		log.Errorf("Failed to search users: %v", err)
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+	return users, total, nil
+}
+
+// CheckActive проверяет, что пользователь существует и не заблокирован.
+// Для заблокированного возвращается ErrUserBlocked, для удалённого — ошибка с sql.ErrNoRows.
+func (s *UserService) CheckActive(ctx context.Context, id int) error {
+	user, err := s.repo.GetUser(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user not found: %w", err)
 		}
-		user.Password = string(hashedPassword)
+		return fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user.BlockedAt != nil {
+		return ErrUserBlocked
 	}
+	return nil
+}
+
+// BlockUser запрещает пользователю вход и доступ к API.
+func (s *UserService) BlockUser(ctx context.Context, id int) (*models.User, error) {
+	return s.setBlocked(ctx, id, true)
+}
+
+// UnblockUser снимает блокировку пользователя.
+func (s *UserService) UnblockUser(ctx context.Context, id int) (*models.User, error) {
+	return s.setBlocked(ctx, id, false)
+}
+
+func (s *UserService) setBlocked(ctx context.Context, id int, blocked bool) (*models.User, error) {
+	log := logger.FromContext(ctx)
+
+	if err := s.repo.SetBlocked(ctx, id, blocked); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user with ID %d not found: %w", id, err)
+		}
+		log.Errorf("Failed to change blocked status of user ID %d: %v", id, err)
+		return nil, fmt.Errorf("failed to change blocked status: %w", err)
+	}
+
+	if blocked {
+		log.Warningf("User ID %d blocked", id)
+	} else {
+		log.Infof("User ID %d unblocked", id)
+	}
+	return s.GetUser(ctx, id)
+}
+
+// UpdateUser обновляет существующего пользователя
+func (s *UserService) UpdateUser(ctx context.Context, user *models.User) error {
+	log := logger.FromContext(ctx)
+	log.Infof("Updating user with ID: %d", user.ID)
 
 	err := s.repo.UpdateUser(ctx, user)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			s.log.Warningf("Failed to update user with ID %d: user not found", user.ID)
+			log.Warningf("Failed to update user with ID %d: user not found", user.ID)
 			return fmt.Errorf("user with ID %d not found: %w", user.ID, err)
 		}
-		s.log.Errorf("Failed to update user with ID %d: %v", user.ID, err)
+		log.Errorf("Failed to update user with ID %d: %v", user.ID, err)
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
-	s.log.Infof("Successfully updated user with ID: %d", user.ID)
+	// Пароль меняется, только если он был передан: пустое значение оставляет прежний хеш
+	if user.Password != "" {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Errorf("Failed to hash password for user ID %d: %v", user.ID, err)
+			return fmt.Errorf("failed to hash new password: %w", err)
+		}
+		if err := s.repo.UpdatePassword(ctx, user.ID, string(hashedPassword)); err != nil {
+			log.Errorf("Failed to update password for user ID %d: %v", user.ID, err)
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		user.Password = ""
+	}
+
+	log.Infof("Successfully updated user with ID: %d", user.ID)
 	return nil
 }
 
-// DeleteUser удаляет пользователя по ID
+// DeleteUser удаляет пользователя по ID с обезличиванием персональных данных;
+// его заказы и отзывы сохраняются.
 func (s *UserService) DeleteUser(ctx context.Context, id int) error {
-	s.log.Infof("Deleting user with ID: %d", id)
+	log := logger.FromContext(ctx)
+	log.Infof("Deleting user with ID: %d", id)
 
 	err := s.repo.DeleteUser(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			s.log.Warningf("Failed to delete user with ID %d: user not found", id)
+			log.Warningf("Failed to delete user with ID %d: user not found", id)
 			return fmt.Errorf("user with ID %d not found: %w", id, err)
 		}
-		s.log.Errorf("Failed to delete user with ID %d: %v", id, err)
+		log.Errorf("Failed to delete user with ID %d: %v", id, err)
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 
-	s.log.Infof("Successfully deleted user with ID: %d", id)
+	log.Infof("Successfully deleted user with ID: %d", id)
 	return nil
 }
 
 // VerifyPassword проверяет пароль пользователя
 func (s *UserService) VerifyPassword(ctx context.Context, id int, password string) error {
-	s.log.Infof("Verifying password for user ID: %d", id)
+	log := logger.FromContext(ctx)
+	log.Debugf("Verifying password for user ID: %d", id)
 
 	hashedPassword, err := s.repo.GetUserPassword(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			s.log.Warningf("Password verification failed for user ID %d: user not found", id)
+			log.Warningf("Password verification failed for user ID %d: user not found", id)
 			return fmt.Errorf("user not found")
 		}
-		s.log.Errorf("Failed to retrieve password for user ID %d: %v", id, err)
+		log.Errorf("Failed to retrieve password for user ID %d: %v", id, err)
 		return fmt.Errorf("failed to retrieve user password: %w", err)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	if err != nil {
-		s.log.Warningf("Password mismatch for user ID %d: %v", id, err)
+		log.Warningf("Password mismatch for user ID %d: %v", id, err)
 		return fmt.Errorf("invalid password")
 	}
 
-	s.log.Infof("Password verified successfully for user ID %d", id)
+	log.Debugf("Password verified successfully for user ID %d", id)
 	return nil
 }
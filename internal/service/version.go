@@ -0,0 +1,7 @@
+package service
+
+import "github.com/alex-pyslar/petelka-api/internal/repository"
+
+// ErrVersionConflict возвращается при обновлении записи, которую успел изменить
+// другой запрос: клиенту нужно перечитать запись и повторить изменение.
+var ErrVersionConflict = repository.ErrVersionConflict
@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStore хранит объекты в каталоге на диске и раздаёт их через API по ссылкам,
+// подписанным HMAC. Предназначен для локальной разработки без MinIO.
+//
+// Content-Type объекта определяется по расширению ключа.
+type LocalStore struct {
+	dir     string
+	baseURL string
+	secret  []byte
+}
+
+// NewLocalStore создаёт хранилище в каталоге dir. baseURL — внешний адрес,
+// по которому смонтирован Handler (например, http://localhost:8080/api/storage).
+func NewLocalStore(dir, baseURL string, secret []byte) (*LocalStore, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("local storage signing key is empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir, baseURL: strings.TrimRight(baseURL, "/"), secret: secret}, nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.write(path, r, -1)
+	return err
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, mapFSError(err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, s.info(key, fi), nil
+}
+
+func (s *LocalStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, mapFSError(err)
+	}
+	return s.info(key, fi), nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) List(ctx context.Context, after string, limit int) ([]*ObjectInfo, string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var infos []*ObjectInfo
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || name <= after {
+			continue
+		}
+		if len(infos) == limit {
+			return infos, infos[len(infos)-1].Key, nil
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return nil, "", err
+		}
+		infos = append(infos, s.info(name, fi))
+	}
+	return infos, "", nil
+}
+
+func (s *LocalStore) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("signature", s.sign("get", key, expires))
+	return s.baseURL + "/" + url.PathEscape(key) + "?" + q.Encode(), nil
+}
+
+func (s *LocalStore) PresignUpload(ctx context.Context, key, contentType string, maxSize int64, expiry time.Duration) (*UploadPolicy, error) {
+	if _, err := s.path(key); err != nil {
+		return nil, err
+	}
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	max := strconv.FormatInt(maxSize, 10)
+	return &UploadPolicy{
+		URL: s.baseURL + "/",
+		FormData: map[string]string{
+			"key":          key,
+			"Content-Type": contentType,
+			"max_size":     max,
+			"expires":      expires,
+			"signature":    s.sign("upload", key, contentType, max, expires),
+		},
+	}, nil
+}
+
+func (s *LocalStore) Ping(ctx context.Context) error {
+	fi, err := os.Stat(s.dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.dir)
+	}
+	return nil
+}
+
+// ServeHTTP отдаёт объекты по подписанным ссылкам (GET/HEAD) и принимает
+// загрузки по подписанным формам (POST). Монтируется с http.StripPrefix.
+func (s *LocalStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.serveObject(w, r)
+	case http.MethodPost:
+		s.receiveUpload(w, r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *LocalStore) serveObject(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	q := r.URL.Query()
+	if !s.verify(q.Get("signature"), q.Get("expires"), "get", key) {
+		http.Error(w, "invalid or expired signature", http.StatusForbidden)
+		return
+	}
+
+	f, info, err := s.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "object not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("ETag", fmt.Sprintf("%q", info.ETag))
+	w.Header().Set("Content-Type", info.ContentType)
+	http.ServeContent(w, r, key, info.LastModified, f)
+}
+
+// receiveUpload читает multipart-форму потоково: поля формы должны идти до файла,
+// как и при POST-загрузке в S3.
+func (s *LocalStore) receiveUpload(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "multipart form expected", http.StatusBadRequest)
+		return
+	}
+
+	fields := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			http.Error(w, "file is required", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "invalid multipart form", http.StatusBadRequest)
+			return
+		}
+
+		if part.FormName() != "file" {
+			value, err := io.ReadAll(io.LimitReader(part, 4<<10))
+			if err != nil {
+				http.Error(w, "invalid multipart form", http.StatusBadRequest)
+				return
+			}
+			fields[part.FormName()] = string(value)
+			continue
+		}
+
+		key, contentType, max := fields["key"], fields["Content-Type"], fields["max_size"]
+		if !s.verify(fields["signature"], fields["expires"], "upload", key, contentType, max) {
+			http.Error(w, "invalid or expired signature", http.StatusForbidden)
+			return
+		}
+		if part.Header.Get("Content-Type") != "" && part.Header.Get("Content-Type") != contentType {
+			http.Error(w, "content type does not match policy", http.StatusBadRequest)
+			return
+		}
+		maxSize, err := strconv.ParseInt(max, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid max_size", http.StatusBadRequest)
+			return
+		}
+		path, err := s.path(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		n, err := s.write(path, part, maxSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if n == 0 {
+			os.Remove(path)
+			http.Error(w, "file is empty", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+}
+
+// write атомарно записывает содержимое r в path. При maxSize >= 0 файл
+// большего размера отклоняется.
+func (s *LocalStore) write(path string, r io.Reader, maxSize int64) (int64, error) {
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	src := r
+	if maxSize >= 0 {
+		src = io.LimitReader(r, maxSize+1)
+	}
+	n, err := io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if maxSize >= 0 && n > maxSize {
+		return 0, fmt.Errorf("file too large: max %d bytes", maxSize)
+	}
+	return n, os.Rename(tmp.Name(), path)
+}
+
+// path возвращает путь к файлу объекта, отклоняя ключи, выходящие за пределы каталога.
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, ".") || filepath.Base(key) != key {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+func (s *LocalStore) info(key string, fi os.FileInfo) *ObjectInfo {
+	contentType := mime.TypeByExtension(filepath.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &ObjectInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		ContentType:  contentType,
+		ETag:         fmt.Sprintf("%x-%x", fi.ModTime().UnixNano(), fi.Size()),
+		LastModified: fi.ModTime(),
+	}
+}
+
+func (s *LocalStore) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify проверяет подпись и срок действия ссылки или формы.
+func (s *LocalStore) verify(signature, expires string, parts ...string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	expected := s.sign(append(parts, expires)...)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+func mapFSError(err error) error {
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
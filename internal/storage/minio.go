@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// MinioStore хранит объекты в бакете MinIO (или любом S3-совместимом хранилище).
+type MinioStore struct {
+	client     *minio.Client
+	bucketName string
+}
+
+// NewMinioStore подключается к MinIO и создаёт бакет, если его ещё нет.
+func NewMinioStore(endpoint, accessKey, secretKey, bucket string, useSSL bool) (*MinioStore, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err = client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &MinioStore{client: client, bucketName: bucket}, nil
+}
+
+func (s *MinioStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucketName, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *MinioStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	obj, err := s.client.GetObject(ctx, s.bucketName, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, mapMinioError(err)
+	}
+
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, nil, mapMinioError(err)
+	}
+	return obj, toObjectInfo(info), nil
+}
+
+func (s *MinioStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, s.bucketName, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, mapMinioError(err)
+	}
+	return toObjectInfo(info), nil
+}
+
+func (s *MinioStore) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucketName, key, minio.RemoveObjectOptions{})
+}
+
+func (s *MinioStore) List(ctx context.Context, after string, limit int) ([]*ObjectInfo, string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	objects := s.client.ListObjects(ctx, s.bucketName, minio.ListObjectsOptions{
+		StartAfter:   after,
+		Recursive:    true,
+		WithMetadata: true,
+	})
+
+	var infos []*ObjectInfo
+	for obj := range objects {
+		if obj.Err != nil {
+			return nil, "", obj.Err
+		}
+		if len(infos) == limit {
+			// Есть хотя бы ещё один объект — отдаём курсор.
+			return infos, infos[len(infos)-1].Key, nil
+		}
+		infos = append(infos, toObjectInfo(obj))
+	}
+	return infos, "", nil
+}
+
+func (s *MinioStore) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	url, err := s.client.PresignedGetObject(ctx, s.bucketName, key, expiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return url.String(), nil
+}
+
+func (s *MinioStore) PresignUpload(ctx context.Context, key, contentType string, maxSize int64, expiry time.Duration) (*UploadPolicy, error) {
+	policy := minio.NewPostPolicy()
+	if err := policy.SetBucket(s.bucketName); err != nil {
+		return nil, err
+	}
+	if err := policy.SetKey(key); err != nil {
+		return nil, err
+	}
+	if err := policy.SetExpires(time.Now().UTC().Add(expiry)); err != nil {
+		return nil, err
+	}
+	if err := policy.SetContentType(contentType); err != nil {
+		return nil, err
+	}
+	if err := policy.SetContentLengthRange(1, maxSize); err != nil {
+		return nil, err
+	}
+
+	url, formData, err := s.client.PresignedPostPolicy(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadPolicy{URL: url.String(), FormData: formData}, nil
+}
+
+func (s *MinioStore) Ping(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucketName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s does not exist", s.bucketName)
+	}
+	return nil
+}
+
+func toObjectInfo(info minio.ObjectInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}
+}
+
+// mapMinioError приводит ошибку MinIO об отсутствии ключа к ErrNotFound.
+func mapMinioError(err error) error {
+	if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+		return ErrNotFound
+	}
+	return err
+}
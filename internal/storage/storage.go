@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound возвращается, если объекта нет в хранилище.
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo описывает метаданные объекта.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// UploadPolicy описывает подписанную форму для загрузки объекта напрямую в хранилище.
+// Клиент отправляет multipart/form-data POST на URL со всеми полями FormData и файлом в поле "file".
+type UploadPolicy struct {
+	URL      string
+	FormData map[string]string
+}
+
+// ObjectStore — абстракция объектного хранилища фотографий.
+type ObjectStore interface {
+	// Put сохраняет объект под ключом key.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get открывает объект для чтения. Вызывающая сторона обязана закрыть reader.
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error)
+	// Stat возвращает метаданные объекта.
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete удаляет объект. Удаление отсутствующего объекта не считается ошибкой.
+	Delete(ctx context.Context, key string) error
+	// List возвращает до limit объектов, следующих за after, и курсор следующей страницы.
+	List(ctx context.Context, after string, limit int) ([]*ObjectInfo, string, error)
+	// PresignGet возвращает ссылку на скачивание объекта со сроком жизни expiry.
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// PresignUpload возвращает форму для прямой загрузки объекта с ограничениями
+	// на Content-Type и размер.
+	PresignUpload(ctx context.Context, key, contentType string, maxSize int64, expiry time.Duration) (*UploadPolicy, error)
+	// Ping проверяет доступность хранилища.
+	Ping(ctx context.Context) error
+}
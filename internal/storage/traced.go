@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedStore оборачивает ObjectStore и открывает спан на каждую операцию с хранилищем.
+type tracedStore struct {
+	next   ObjectStore
+	system string
+}
+
+// WithTracing добавляет трассировку OpenTelemetry к хранилищу.
+// system — имя драйвера для атрибута storage.system (minio, local).
+func WithTracing(store ObjectStore, system string) ObjectStore {
+	return &tracedStore{next: store, system: system}
+}
+
+func (s *tracedStore) start(ctx context.Context, op, key string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("storage.system", s.system),
+		attribute.String("storage.operation", op),
+	}
+	if key != "" {
+		attrs = append(attrs, attribute.String("storage.key", key))
+	}
+	return tracing.Tracer().Start(ctx, "storage."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// endSpan завершает спан; отсутствие объекта ошибкой спана не считается.
+func endSpan(span trace.Span, err error) {
+	if !errors.Is(err, ErrNotFound) {
+		tracing.RecordError(span, err)
+	}
+	span.End()
+}
+
+func (s *tracedStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	ctx, span := s.start(ctx, "put", key)
+	span.SetAttributes(attribute.Int64("storage.size", size))
+	err := s.next.Put(ctx, key, r, size, contentType)
+	endSpan(span, err)
+	return err
+}
+
+func (s *tracedStore) Get(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	ctx, span := s.start(ctx, "get", key)
+	rc, info, err := s.next.Get(ctx, key)
+	endSpan(span, err)
+	return rc, info, err
+}
+
+func (s *tracedStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	ctx, span := s.start(ctx, "stat", key)
+	info, err := s.next.Stat(ctx, key)
+	endSpan(span, err)
+	return info, err
+}
+
+func (s *tracedStore) Delete(ctx context.Context, key string) error {
+	ctx, span := s.start(ctx, "delete", key)
+	err := s.next.Delete(ctx, key)
+	endSpan(span, err)
+	return err
+}
+
+func (s *tracedStore) List(ctx context.Context, after string, limit int) ([]*ObjectInfo, string, error) {
+	ctx, span := s.start(ctx, "list", "")
+	objects, next, err := s.next.List(ctx, after, limit)
+	endSpan(span, err)
+	return objects, next, err
+}
+
+func (s *tracedStore) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	ctx, span := s.start(ctx, "presign_get", key)
+	url, err := s.next.PresignGet(ctx, key, expiry)
+	endSpan(span, err)
+	return url, err
+}
+
+func (s *tracedStore) PresignUpload(ctx context.Context, key, contentType string, maxSize int64, expiry time.Duration) (*UploadPolicy, error) {
+	ctx, span := s.start(ctx, "presign_upload", key)
+	policy, err := s.next.PresignUpload(ctx, key, contentType, maxSize, expiry)
+	endSpan(span, err)
+	return policy, err
+}
+
+func (s *tracedStore) Ping(ctx context.Context) error {
+	ctx, span := s.start(ctx, "ping", "")
+	err := s.next.Ping(ctx)
+	endSpan(span, err)
+	return err
+}
@@ -0,0 +1,12 @@
+-- Реестр фотографий: загруженных через API и подтверждённых после прямой загрузки
+-- в хранилище по POST-политике.
+--
+-- Запуск: psql -v ON_ERROR_STOP=1 -1 -f migrations/001_photos.sql
+
+CREATE TABLE photos (
+    object_name VARCHAR(255) PRIMARY KEY,
+    size BIGINT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    uploaded_by INT REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
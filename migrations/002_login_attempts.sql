@@ -0,0 +1,16 @@
+-- Журнал попыток входа для защиты от подбора пароля и блокировки учётных записей.
+--
+-- Запуск: psql -v ON_ERROR_STOP=1 -1 -f migrations/002_login_attempts.sql
+
+CREATE TABLE login_attempts (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    reason VARCHAR(50) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX login_attempts_email_created_at_idx ON login_attempts (email, created_at);
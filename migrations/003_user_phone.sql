@@ -0,0 +1,5 @@
+-- Телефон в профиле пользователя (/me).
+--
+-- Запуск: psql -v ON_ERROR_STOP=1 -1 -f migrations/003_user_phone.sql
+
+ALTER TABLE users ADD COLUMN phone VARCHAR(32) NOT NULL DEFAULT '';
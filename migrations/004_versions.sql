@@ -0,0 +1,34 @@
+-- Версии и время изменения ресурсов для ETag/If-Match и JSON Merge Patch.
+--
+-- Запуск: psql -v ON_ERROR_STOP=1 -1 -f migrations/004_versions.sql
+
+ALTER TABLE users
+    ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+ALTER TABLE categories
+    ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+ALTER TABLE products
+    ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+ALTER TABLE orders
+    ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+ALTER TABLE comments
+    ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+-- Старые версии schema.sql создавали колонку image, хотя код работает с images
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'products' AND column_name = 'image') THEN
+        ALTER TABLE products RENAME COLUMN image TO images;
+    END IF;
+END $$;
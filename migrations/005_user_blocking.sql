@@ -0,0 +1,9 @@
+-- Блокировка и мягкое удаление пользователей, сортировка списка по дате регистрации.
+--
+-- Запуск: psql -v ON_ERROR_STOP=1 -1 -f migrations/005_user_blocking.sql
+
+ALTER TABLE users
+    ADD COLUMN blocked_at TIMESTAMP,
+    ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX users_created_at_idx ON users (created_at);
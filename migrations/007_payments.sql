@@ -0,0 +1,23 @@
+-- Попытки оплаты заказов через платёжного провайдера.
+--
+-- Запуск: psql -v ON_ERROR_STOP=1 -1 -f migrations/007_payments.sql
+
+CREATE TABLE payments (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id),
+    provider VARCHAR(50) NOT NULL,
+    provider_payment_id VARCHAR(255),
+    idempotence_key VARCHAR(64) NOT NULL UNIQUE,
+    status VARCHAR(20) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    confirmation_url TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX payments_order_id_idx ON payments (order_id);
+CREATE UNIQUE INDEX payments_provider_payment_idx ON payments (provider, provider_payment_id);
+-- У заказа не больше одной незавершённой попытки оплаты
+CREATE UNIQUE INDEX payments_order_pending_idx ON payments (order_id) WHERE status = 'pending';
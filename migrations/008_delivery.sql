@@ -0,0 +1,54 @@
+-- Адресная книга, правила стоимости доставки и рассчитываемые сервером суммы заказов.
+--
+-- Запуск: psql -v ON_ERROR_STOP=1 -1 -f migrations/008_delivery.sql
+
+ALTER TABLE products ADD COLUMN weight INT NOT NULL DEFAULT 0; -- граммы, для расчёта доставки
+
+ALTER TABLE orders
+    ADD COLUMN delivery_method VARCHAR(20) NOT NULL DEFAULT 'pickup',
+    ADD COLUMN delivery_cost DECIMAL(10,2) NOT NULL DEFAULT 0,
+    ADD COLUMN shipping_address JSONB; -- копия адреса на момент оформления
+
+CREATE TABLE addresses (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient VARCHAR(255) NOT NULL,
+    phone VARCHAR(32) NOT NULL,
+    region VARCHAR(100) NOT NULL,
+    city VARCHAR(100) NOT NULL,
+    street VARCHAR(255) NOT NULL,
+    postal_code VARCHAR(20) NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1
+);
+
+CREATE INDEX addresses_user_id_idx ON addresses (user_id);
+-- У пользователя не больше одного адреса по умолчанию
+CREATE UNIQUE INDEX addresses_user_default_idx ON addresses (user_id) WHERE is_default;
+
+-- Стоимость доставки: для способа и веса заказа выбирается правило региона
+-- (без учёта регистра), а если его нет — общее правило с пустым регионом;
+-- из подходящих — с наименьшим max_weight (NULL — без ограничения веса).
+CREATE TABLE delivery_rates (
+    id SERIAL PRIMARY KEY,
+    method VARCHAR(20) NOT NULL,
+    region VARCHAR(100) NOT NULL DEFAULT '',
+    max_weight INT, -- граммы
+    price DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1
+);
+
+CREATE INDEX delivery_rates_method_idx ON delivery_rates (method);
+
+INSERT INTO delivery_rates (method, region, max_weight, price) VALUES
+    ('pickup', '', NULL, 0),
+    ('courier', 'Москва', 5000, 350),
+    ('courier', 'Москва', NULL, 700),
+    ('post', '', 1000, 300),
+    ('post', '', 5000, 550),
+    ('post', '', 20000, 900);
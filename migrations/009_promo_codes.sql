@@ -0,0 +1,39 @@
+-- Промокоды, их использования и скидка в заказах.
+--
+-- Запуск: psql -v ON_ERROR_STOP=1 -1 -f migrations/009_promo_codes.sql
+
+ALTER TABLE orders
+    ADD COLUMN promo_code VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN discount DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+-- Промокоды. kind: percent (value — процент), fixed (value — сумма), free_shipping.
+-- Пустые product_ids и category_ids — скидка на все товары заказа.
+CREATE TABLE promo_codes (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE, -- в верхнем регистре
+    kind VARCHAR(20) NOT NULL,
+    value DECIMAL(10,2) NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    max_uses INT,
+    max_uses_per_user INT,
+    min_order_total DECIMAL(10,2) NOT NULL DEFAULT 0,
+    product_ids INT[] NOT NULL DEFAULT '{}',
+    category_ids INT[] NOT NULL DEFAULT '{}',
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1
+);
+
+-- Использования промокодов: по одной записи на заказ, по ним проверяются лимиты.
+CREATE TABLE promo_code_usages (
+    id SERIAL PRIMARY KEY,
+    promo_code_id INT NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id),
+    order_id INT NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+    discount DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX promo_code_usages_code_user_idx ON promo_code_usages (promo_code_id, user_id);
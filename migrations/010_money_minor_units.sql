@@ -0,0 +1,48 @@
+-- Перевод денежных сумм из DECIMAL(10,2) в копейки (BIGINT), добавление валюты
+-- документов и НДС заказов для баз данных, созданных по schema.sql до появления
+-- типа money.Money. Новые базы создаются по schema.sql сразу в новом виде, для них
+-- эта миграция не нужна.
+--
+-- Запуск: psql -v ON_ERROR_STOP=1 -1 -f migrations/010_money_minor_units.sql
+-- (-1 выполняет файл в одной транзакции: при ошибке данные не меняются).
+
+-- Прежние суммы были в рублях: валюта всех документов — RUB.
+
+ALTER TABLE products ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100);
+ALTER TABLE products ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+ALTER TABLE orders ALTER COLUMN total TYPE BIGINT USING ROUND(total * 100);
+ALTER TABLE orders ALTER COLUMN delivery_cost DROP DEFAULT;
+ALTER TABLE orders ALTER COLUMN delivery_cost TYPE BIGINT USING ROUND(delivery_cost * 100);
+ALTER TABLE orders ALTER COLUMN delivery_cost SET DEFAULT 0;
+ALTER TABLE orders ALTER COLUMN discount DROP DEFAULT;
+ALTER TABLE orders ALTER COLUMN discount TYPE BIGINT USING ROUND(discount * 100);
+ALTER TABLE orders ALTER COLUMN discount SET DEFAULT 0;
+ALTER TABLE orders ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB';
+-- НДС сохраняется для новых заказов; прежние оформлены без его расчёта
+ALTER TABLE orders ADD COLUMN vat_rate INT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN vat BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE order_items ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100);
+-- Скидка по позициям сохраняется для новых заказов; у прежних она есть только в orders.discount
+ALTER TABLE order_items ADD COLUMN discount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN vat BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE delivery_rates ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100);
+ALTER TABLE delivery_rates ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+-- value хранил и процент (percent), и сумму (fixed): разделяем на два столбца,
+-- процент — в сотых долях (12.5 -> 1250)
+ALTER TABLE promo_codes ADD COLUMN percent_bp INT NOT NULL DEFAULT 0;
+ALTER TABLE promo_codes ADD COLUMN amount BIGINT NOT NULL DEFAULT 0;
+UPDATE promo_codes SET percent_bp = ROUND(value * 100) WHERE kind = 'percent';
+UPDATE promo_codes SET amount = ROUND(value * 100) WHERE kind = 'fixed';
+ALTER TABLE promo_codes DROP COLUMN value;
+ALTER TABLE promo_codes ALTER COLUMN min_order_total DROP DEFAULT;
+ALTER TABLE promo_codes ALTER COLUMN min_order_total TYPE BIGINT USING ROUND(min_order_total * 100);
+ALTER TABLE promo_codes ALTER COLUMN min_order_total SET DEFAULT 0;
+ALTER TABLE promo_codes ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+ALTER TABLE promo_code_usages ALTER COLUMN discount TYPE BIGINT USING ROUND(discount * 100);
+
+ALTER TABLE payments ALTER COLUMN amount TYPE BIGINT USING ROUND(amount * 100);
@@ -4,21 +4,32 @@ CREATE TABLE users (
     name VARCHAR(255) NOT NULL,
     password VARCHAR(255) NOT NULL,
     role VARCHAR(255) NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    phone VARCHAR(32) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1,
+    blocked_at TIMESTAMP,
+    deleted_at TIMESTAMP
 );
 
+CREATE INDEX users_created_at_idx ON users (created_at);
+
 CREATE TABLE categories (
     id SERIAL PRIMARY KEY,
     name VARCHAR(100) NOT NULL,
-    type VARCHAR(50) NOT NULL
+    type VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1
 );
 
 CREATE TABLE products (
     id SERIAL PRIMARY KEY,
     name VARCHAR(255) NOT NULL,
     description TEXT,
-    price DECIMAL(10,2) NOT NULL,
-    image TEXT[] NOT NULL,
+    price BIGINT NOT NULL, -- копейки
+    currency CHAR(3) NOT NULL DEFAULT 'RUB', -- ISO 4217
+    images TEXT[] NOT NULL,
     category_id INT REFERENCES categories(id),
     type VARCHAR(50) NOT NULL,
     composition VARCHAR(255),
@@ -26,15 +37,29 @@ CREATE TABLE products (
     length_in_100g INT,
     size VARCHAR(50),
     garment_length VARCHAR(50),
-    color VARCHAR(50)
+    color VARCHAR(50),
+    weight INT NOT NULL DEFAULT 0, -- граммы, для расчёта доставки
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1
 );
 
 CREATE TABLE orders (
     id SERIAL PRIMARY KEY,
     user_id INT REFERENCES users(id),
-    total DECIMAL(10,2) NOT NULL,
+    total BIGINT NOT NULL, -- копейки
+    currency CHAR(3) NOT NULL DEFAULT 'RUB', -- валюта всех сумм заказа и его позиций
     status VARCHAR(50) NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    delivery_method VARCHAR(20) NOT NULL DEFAULT 'pickup',
+    delivery_cost BIGINT NOT NULL DEFAULT 0,
+    promo_code VARCHAR(50) NOT NULL DEFAULT '',
+    discount BIGINT NOT NULL DEFAULT 0,
+    vat_rate INT NOT NULL DEFAULT 0, -- проценты; 0 — без НДС
+    vat BIGINT NOT NULL DEFAULT 0, -- НДС позиций и доставки, включённый в total
+    shipping_address JSONB, -- копия адреса на момент оформления
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1
 );
 
 CREATE TABLE order_items (
@@ -42,13 +67,152 @@ CREATE TABLE order_items (
     order_id INT REFERENCES orders(id),
     product_id INT REFERENCES products(id),
     quantity INT NOT NULL,
-    price DECIMAL(10,2) NOT NULL
+    price BIGINT NOT NULL, -- копейки
+    discount BIGINT NOT NULL DEFAULT 0, -- доля скидки заказа на позицию, копейки
+    vat BIGINT NOT NULL DEFAULT 0 -- НДС, включённый в стоимость позиции после скидки
+);
+
+CREATE TABLE addresses (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient VARCHAR(255) NOT NULL,
+    phone VARCHAR(32) NOT NULL,
+    region VARCHAR(100) NOT NULL,
+    city VARCHAR(100) NOT NULL,
+    street VARCHAR(255) NOT NULL,
+    postal_code VARCHAR(20) NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1
+);
+
+CREATE INDEX addresses_user_id_idx ON addresses (user_id);
+-- У пользователя не больше одного адреса по умолчанию
+CREATE UNIQUE INDEX addresses_user_default_idx ON addresses (user_id) WHERE is_default;
+
+-- Стоимость доставки: для способа и веса заказа выбирается правило региона
+-- (без учёта регистра), а если его нет — общее правило с пустым регионом;
+-- из подходящих — с наименьшим max_weight (NULL — без ограничения веса).
+CREATE TABLE delivery_rates (
+    id SERIAL PRIMARY KEY,
+    method VARCHAR(20) NOT NULL,
+    region VARCHAR(100) NOT NULL DEFAULT '',
+    max_weight INT, -- граммы
+    price BIGINT NOT NULL, -- копейки
+    currency CHAR(3) NOT NULL DEFAULT 'RUB',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1
 );
 
+CREATE INDEX delivery_rates_method_idx ON delivery_rates (method);
+
+INSERT INTO delivery_rates (method, region, max_weight, price) VALUES
+    ('pickup', '', NULL, 0),
+    ('courier', 'Москва', 5000, 35000),
+    ('courier', 'Москва', NULL, 70000),
+    ('post', '', 1000, 30000),
+    ('post', '', 5000, 55000),
+    ('post', '', 20000, 90000);
+
+-- Промокоды. kind: percent (скидка percent_bp сотых процента, 1250 — 12.5%), fixed (скидка amount копеек), free_shipping.
+-- Пустые product_ids и category_ids — скидка на все товары заказа.
+CREATE TABLE promo_codes (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE, -- в верхнем регистре
+    kind VARCHAR(20) NOT NULL,
+    percent_bp INT NOT NULL DEFAULT 0,
+    amount BIGINT NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP,
+    ends_at TIMESTAMP,
+    max_uses INT,
+    max_uses_per_user INT,
+    min_order_total BIGINT NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL DEFAULT 'RUB', -- валюта amount и min_order_total
+    product_ids INT[] NOT NULL DEFAULT '{}',
+    category_ids INT[] NOT NULL DEFAULT '{}',
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1
+);
+
+-- Использования промокодов: по одной записи на заказ, по ним проверяются лимиты.
+CREATE TABLE promo_code_usages (
+    id SERIAL PRIMARY KEY,
+    promo_code_id INT NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id),
+    order_id INT NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+    discount BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX promo_code_usages_code_user_idx ON promo_code_usages (promo_code_id, user_id);
+
 CREATE TABLE comments (
     id SERIAL PRIMARY KEY,
     product_id INT REFERENCES products(id),
     user_id INT REFERENCES users(id),
     text TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INT NOT NULL DEFAULT 1
+);
+
+CREATE TABLE photos (
+    object_name VARCHAR(255) PRIMARY KEY,
+    size BIGINT NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    uploaded_by INT REFERENCES users(id),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
\ No newline at end of file
+);
+
+CREATE TABLE login_attempts (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    reason VARCHAR(50) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX login_attempts_email_created_at_idx ON login_attempts (email, created_at);
+
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(50) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id VARCHAR(255) NOT NULL DEFAULT '',
+    changes JSONB NOT NULL DEFAULT '{}',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX audit_log_resource_idx ON audit_log (resource_type, resource_id);
+
+CREATE TABLE payments (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id),
+    provider VARCHAR(50) NOT NULL,
+    provider_payment_id VARCHAR(255),
+    idempotence_key VARCHAR(64) NOT NULL UNIQUE,
+    status VARCHAR(20) NOT NULL,
+    amount BIGINT NOT NULL, -- копейки
+    currency CHAR(3) NOT NULL,
+    confirmation_url TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX payments_order_id_idx ON payments (order_id);
+CREATE UNIQUE INDEX payments_provider_payment_idx ON payments (provider, provider_payment_id);
+-- У заказа не больше одной незавершённой попытки оплаты
+CREATE UNIQUE INDEX payments_order_pending_idx ON payments (order_id) WHERE status = 'pending';
@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffJSON(t *testing.T) {
+	cases := []struct{ before, after, want string }{
+		{`{"name":"Yarn","price":100}`, `{"name":"Yarn","price":120}`, `{"price":{"before":100,"after":120}}`},
+		{``, `{"id":1,"name":"New"}`, `{"id":{"after":1},"name":{"after":"New"}}`},
+		{`{"id":1,"color":"red"}`, ``, `{"id":{"before":1},"color":{"before":"red"}}`},
+		{`{"color":"red"}`, `{}`, `{"color":{"before":"red"}}`},
+		{`{"a":{"b":1}}`, `{"a":{"b":1}}`, `{}`},
+		{`{"level":"info"}`, `["x"]`, `{"$":{"before":{"level":"info"},"after":["x"]}}`},
+	}
+	for _, c := range cases {
+		got, err := service.DiffJSON([]byte(c.before), []byte(c.after))
+		require.NoError(t, err)
+		assert.JSONEq(t, c.want, string(got), "%s -> %s", c.before, c.after)
+	}
+}
+
+// fakeAuditRecorder запоминает записи журнала вместо сохранения в базу.
+type fakeAuditRecorder struct {
+	entries []*models.AuditEntry
+}
+
+func (f *fakeAuditRecorder) Record(_ context.Context, entry *models.AuditEntry, before, after []byte) error {
+	changes, err := service.DiffJSON(before, after)
+	if err != nil {
+		return err
+	}
+	entry.Changes = changes
+	f.entries = append(f.entries, entry)
+	return nil
+}
+
+func TestAuditMiddlewareRecordsAdminChanges(t *testing.T) {
+	recorder := &fakeAuditRecorder{}
+	price := 100
+	audit := handler.AuditMiddleware(recorder, handler.AuditOptions{
+		Snapshots: map[string]handler.AuditSnapshot{
+			"products": func(_ context.Context, id string) (interface{}, error) {
+				if id != "7" {
+					return nil, errors.New("not found")
+				}
+				return map[string]interface{}{"id": 7, "name": "Yarn", "price": price}, nil
+			},
+		},
+	})
+
+	router := mux.NewRouter()
+	router.Use(handler.RouteMiddleware)
+	handler.MountAPI(router.PathPrefix("/api/v1").Subrouter(),
+		handler.APIOptions{JWTKey: testJWTKey, Admin: []mux.MiddlewareFunc{audit}},
+		handler.RouteFunc(func(_, _, admin *mux.Router) {
+			admin.HandleFunc("/products", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"id":9,"name":"New","price":50}`))
+			}).Methods("POST")
+			admin.HandleFunc("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+				if mux.Vars(r)["id"] != "7" {
+					http.Error(w, "Product not found", http.StatusNotFound)
+					return
+				}
+				if r.Method == "PUT" {
+					price = 120
+				}
+				fmt.Fprintf(w, `{"id":7,"name":"Yarn","price":%d}`, price)
+			}).Methods("GET", "PUT")
+			admin.HandleFunc("/users/{id}/block", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"id":3,"status":"blocked"}`))
+			}).Methods("POST")
+		}))
+	log, _ := newObservedLogger()
+	srv := handler.RequestIDMiddleware(log)(router)
+
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, 42, "admin"))
+		req.Header.Set(handler.RequestIDHeader, "req-"+method)
+		req.RemoteAddr = "203.0.113.5:5555"
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, do("GET", "/api/v1/products/7", ""))
+	require.Equal(t, http.StatusNotFound, do("PUT", "/api/v1/products/8", `{}`))
+	assert.Empty(t, recorder.entries, "reads and failed changes are not audited")
+
+	require.Equal(t, http.StatusOK, do("PUT", "/api/v1/products/7", `{"price":120}`))
+	require.Equal(t, http.StatusCreated, do("POST", "/api/v1/products", `{}`))
+	require.Equal(t, http.StatusOK, do("POST", "/api/v1/users/3/block", ""))
+	require.Len(t, recorder.entries, 3)
+
+	update := recorder.entries[0]
+	assert.Equal(t, 42, update.ActorID)
+	assert.Equal(t, "update", update.Action)
+	assert.Equal(t, "products", update.ResourceType)
+	assert.Equal(t, "7", update.ResourceID)
+	assert.Equal(t, "203.0.113.5", update.IP)
+	assert.Equal(t, "req-PUT", update.RequestID)
+	assert.JSONEq(t, `{"price":{"before":100,"after":120}}`, string(update.Changes))
+
+	create := recorder.entries[1]
+	assert.Equal(t, "create", create.Action)
+	assert.Equal(t, "9", create.ResourceID)
+	assert.JSONEq(t, `{"id":{"after":9},"name":{"after":"New"},"price":{"after":50}}`, string(create.Changes))
+
+	block := recorder.entries[2]
+	assert.Equal(t, "block", block.Action)
+	assert.Equal(t, "users", block.ResourceType)
+	assert.Equal(t, "3", block.ResourceID)
+}
+
+func TestListAuditRejectsInvalidQuery(t *testing.T) {
+	h := handler.NewAuditHandler(nil)
+	for _, query := range []string{"actor_id=abc", "from=2026/01/01", "to=tomorrow", "page=-1", "limit=0"} {
+		rec := httptest.NewRecorder()
+		h.ListAudit(rec, httptest.NewRequest("GET", "/api/v1/admin/audit?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestAuditLogFilterAndPrune(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+
+	ctx := context.Background()
+	audit := service.NewAuditService(repository.NewAuditRepository(db))
+	resourceID := fmt.Sprint(time.Now().UnixNano())
+
+	entry := &models.AuditEntry{Action: "update", ResourceType: "products", ResourceID: resourceID, IP: "127.0.0.1", RequestID: "req-1"}
+	require.NoError(t, audit.Record(ctx, entry, []byte(`{"price":100}`), []byte(`{"price":120}`)))
+	require.NotZero(t, entry.ID)
+
+	entries, total, err := audit.ListEntries(ctx, service.AuditFilter{ResourceType: "products", ResourceID: resourceID})
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	assert.Equal(t, "update", entries[0].Action)
+	var changes map[string]service.AuditChange
+	require.NoError(t, json.Unmarshal(entries[0].Changes, &changes))
+	assert.EqualValues(t, 120, changes["price"].After)
+
+	_, err = db.ExecContext(ctx, `UPDATE audit_log SET created_at = created_at - INTERVAL '400 days' WHERE id = $1`, entry.ID)
+	require.NoError(t, err)
+	deleted, err := audit.Prune(ctx, 365*24*time.Hour)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, deleted, int64(1))
+
+	_, total, err = audit.ListEntries(ctx, service.AuditFilter{ResourceType: "products", ResourceID: resourceID})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestDeleteUserAnonymizesAuditEntries(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	redisClient := setupTestRedis(t)
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	users := service.NewUserService(repository.NewUserRepository(db, redisClient, testCacheTTL))
+	audit := service.NewAuditService(repository.NewAuditRepository(db))
+	user := &models.User{Email: fmt.Sprintf("audit-%d@example.com", time.Now().UnixNano()), Name: "Audited", Password: "password123", Role: "user"}
+	require.NoError(t, users.CreateUser(ctx, user))
+	resourceID := fmt.Sprint(user.ID)
+
+	before := fmt.Sprintf(`{"email":%q,"name":"Audited","role":"user"}`, user.Email)
+	after := fmt.Sprintf(`{"email":%q,"name":"Audited","role":"admin"}`, user.Email)
+	require.NoError(t, audit.Record(ctx, &models.AuditEntry{Action: "create", ResourceType: "users", ResourceID: resourceID}, nil, []byte(before)))
+	require.NoError(t, audit.Record(ctx, &models.AuditEntry{Action: "update", ResourceType: "users", ResourceID: resourceID}, []byte(before), []byte(after)))
+
+	require.NoError(t, users.DeleteUser(ctx, user.ID))
+	// Запись об удалении добавляется после удаления и тоже не хранит персональные данные
+	require.NoError(t, audit.Record(ctx, &models.AuditEntry{Action: "delete", ResourceType: "users", ResourceID: resourceID}, []byte(after), nil))
+
+	entries, total, err := audit.ListEntries(ctx, service.AuditFilter{ResourceType: "users", ResourceID: resourceID})
+	require.NoError(t, err)
+	require.Equal(t, 3, total)
+	for _, entry := range entries {
+		assert.NotContains(t, string(entry.Changes), user.Email, entry.Action)
+		assert.NotContains(t, string(entry.Changes), "Audited", entry.Action)
+	}
+	var changes map[string]service.AuditChange
+	require.NoError(t, json.Unmarshal(entries[len(entries)-1].Changes, &changes))
+	assert.Equal(t, "user", changes["role"].After, "non-personal fields are kept")
+}
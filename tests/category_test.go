@@ -16,7 +16,7 @@ func TestCreateCategory(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	categoryRepo := repository.NewCategoryRepository(db, redisClient)
+	categoryRepo := repository.NewCategoryRepository(db, redisClient, testCacheTTL)
 	categoryService := service.NewCategoryService(categoryRepo)
 
 	category := &models.Category{
@@ -34,7 +34,7 @@ func TestGetCategory(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	categoryRepo := repository.NewCategoryRepository(db, redisClient)
+	categoryRepo := repository.NewCategoryRepository(db, redisClient, testCacheTTL)
 	categoryService := service.NewCategoryService(categoryRepo)
 
 	category := &models.Category{
@@ -53,7 +53,7 @@ func TestListCategories(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	categoryRepo := repository.NewCategoryRepository(db, redisClient)
+	categoryRepo := repository.NewCategoryRepository(db, redisClient, testCacheTTL)
 	categoryService := service.NewCategoryService(categoryRepo)
 
 	category1 := &models.Category{Name: "Category 1"}
@@ -72,7 +72,7 @@ func TestUpdateCategory(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	categoryRepo := repository.NewCategoryRepository(db, redisClient)
+	categoryRepo := repository.NewCategoryRepository(db, redisClient, testCacheTTL)
 	categoryService := service.NewCategoryService(categoryRepo)
 
 	category := &models.Category{Name: "Update Category"}
@@ -93,7 +93,7 @@ func TestDeleteCategory(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	categoryRepo := repository.NewCategoryRepository(db, redisClient)
+	categoryRepo := repository.NewCategoryRepository(db, redisClient, testCacheTTL)
 	categoryService := service.NewCategoryService(categoryRepo)
 
 	category := &models.Category{Name: "Delete Category"}
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckoutValidatesRequestBeforeStorage(t *testing.T) {
+	checkout := service.NewCheckoutService(nil, nil, nil, nil, nil, 0)
+	ctx := context.Background()
+
+	for name, req := range map[string]service.CheckoutRequest{
+		"no items":         {DeliveryMethod: models.DeliveryPickup},
+		"unknown method":   {Items: []service.CheckoutItem{{ProductID: 1, Quantity: 1}}, DeliveryMethod: "drone"},
+		"zero quantity":    {Items: []service.CheckoutItem{{ProductID: 1, Quantity: 0}}},
+		"too many of item": {Items: []service.CheckoutItem{{ProductID: 1, Quantity: 600}, {ProductID: 1, Quantity: 600}}},
+	} {
+		_, err := checkout.Quote(ctx, 1, req)
+		assert.ErrorIs(t, err, service.ErrInvalidCheckout, name)
+	}
+
+	addresses := service.NewAddressService(nil)
+	err := addresses.CreateAddress(ctx, &models.Address{UserID: 1, ShippingAddress: models.ShippingAddress{
+		Recipient: "Анна", Phone: "12-34", Region: "Москва", City: "Москва", Street: "Тверская, 1",
+	}})
+	assert.ErrorIs(t, err, service.ErrInvalidAddress)
+
+	delivery := service.NewDeliveryService(nil)
+	negative := -1
+	assert.ErrorIs(t, delivery.CreateRate(ctx, &models.DeliveryRate{Method: "drone"}), service.ErrInvalidDeliveryRate)
+	assert.ErrorIs(t, delivery.CreateRate(ctx, &models.DeliveryRate{Method: models.DeliveryPost, MaxWeight: &negative}), service.ErrInvalidDeliveryRate)
+}
+
+func TestCreateOrderRejectsInvalidBody(t *testing.T) {
+	h := handler.NewOrderHandler(nil, service.NewCheckoutService(nil, nil, nil, nil, nil, 0))
+
+	for body, want := range map[string]int{
+		`{"items":`: http.StatusBadRequest,
+		`{"items":[],"delivery_method":"pickup"}`:                                http.StatusBadRequest,
+		`{"items":[{"product_id":1,"quantity":1}],"delivery_method":"teleport"}`: http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), handler.UserIDKey, 1))
+		rec := httptest.NewRecorder()
+		h.CreateOrder(rec, req)
+		assert.Equal(t, want, rec.Code, body)
+	}
+}
+
+func TestCheckoutComputesTotalAndSnapshotsAddress(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	redisClient := setupTestRedis(t)
+	defer redisClient.Close()
+	ctx := context.Background()
+
+	users := service.NewUserService(repository.NewUserRepository(db, redisClient, testCacheTTL))
+	products := service.NewProductService(repository.NewProductRepository(db, redisClient, testCacheTTL))
+	addressRepo := repository.NewAddressRepository(db)
+	addresses := service.NewAddressService(addressRepo)
+	delivery := service.NewDeliveryService(repository.NewDeliveryRepository(db))
+	orderRepo := repository.NewOrderRepository(db, redisClient, testCacheTTL)
+	checkout := service.NewCheckoutService(orderRepo, repository.NewProductRepository(db, redisClient, testCacheTTL), addressRepo, delivery, service.NewPromoService(repository.NewPromoRepository(db)), 20)
+
+	user := &models.User{Email: fmt.Sprintf("checkout-%d@example.com", time.Now().UnixNano()), Name: "Buyer", Password: "password123", Role: "user"}
+	require.NoError(t, users.CreateUser(ctx, user))
+
+	// Отдельный регион, чтобы правила теста не пересекались с остальными
+	region := fmt.Sprintf("Регион-%d", time.Now().UnixNano())
+	light := 1000
+	rates := []*models.DeliveryRate{
+		{Method: models.DeliveryCourier, Region: region, MaxWeight: &light, Price: money.MustParse("200")},
+		{Method: models.DeliveryCourier, Region: region, Price: money.MustParse("500")},
+	}
+	for _, rate := range rates {
+		require.NoError(t, delivery.CreateRate(ctx, rate))
+		defer delivery.DeleteRate(ctx, rate.ID)
+	}
+	staleRate := *rates[1]
+	require.NoError(t, delivery.UpdateRate(ctx, rates[1]))
+	assert.Equal(t, 2, rates[1].Version)
+	assert.ErrorIs(t, delivery.UpdateRate(ctx, &staleRate), service.ErrVersionConflict)
+
+	yarn := &models.Product{
+		Name: "Меринос", Price: money.MustParse("350.50"), Images: []string{}, Type: "yarn", Composition: "100% шерсть",
+		CountryOfOrigin: "Италия", LengthIn100g: 250, Color: "серый", Weight: 100,
+	}
+	require.NoError(t, products.CreateProduct(ctx, yarn))
+	defer products.DeleteProduct(ctx, yarn.ID)
+
+	home := &models.Address{UserID: user.ID, ShippingAddress: models.ShippingAddress{
+		Recipient: " Анна ", Phone: "+7 (999) 123-45-67", Region: strings.ToLower(region), City: "Город", Street: "Садовая, 1",
+	}}
+	require.NoError(t, addresses.CreateAddress(ctx, home))
+	assert.True(t, home.IsDefault, "first address becomes the default one")
+	assert.Equal(t, "Анна", home.Recipient)
+	assert.Equal(t, "+79991234567", home.Phone)
+
+	work := &models.Address{UserID: user.ID, IsDefault: true, ShippingAddress: home.ShippingAddress}
+	work.Street = "Рабочая, 5"
+	require.NoError(t, addresses.CreateAddress(ctx, work))
+	list, err := addresses.ListAddresses(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, work.ID, list[0].ID)
+	assert.True(t, list[0].IsDefault)
+	assert.False(t, list[1].IsDefault)
+
+	// Чужой адрес недоступен
+	_, err = checkout.Quote(ctx, user.ID+100000, service.CheckoutRequest{
+		Items: []service.CheckoutItem{{ProductID: yarn.ID, Quantity: 1}}, DeliveryMethod: models.DeliveryCourier, AddressID: home.ID,
+	})
+	assert.ErrorIs(t, err, service.ErrInvalidCheckout)
+
+	// 6 мотков по 100 г — лёгкая посылка: правило до 1000 г
+	order, err := checkout.Checkout(ctx, user.ID, service.CheckoutRequest{
+		Items:          []service.CheckoutItem{{ProductID: yarn.ID, Quantity: 4}, {ProductID: yarn.ID, Quantity: 2}},
+		DeliveryMethod: models.DeliveryCourier,
+		AddressID:      home.ID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusNew, order.Status)
+	assert.Equal(t, money.MustParse("200"), order.DeliveryCost)
+	assert.Equal(t, money.MustParse("2303"), order.Total)
+	require.Len(t, order.Items, 1)
+	assert.Equal(t, 6, order.Items[0].Quantity)
+	assert.Equal(t, money.MustParse("350.50"), order.Items[0].Price)
+	// НДС 20% выделяется из каждой строки: 2103 × 20/120 = 350.50, 200 × 20/120 = 33.33
+	assert.Equal(t, "RUB", order.Currency)
+	assert.Equal(t, 20, order.VATRate)
+	assert.Equal(t, money.MustParse("350.50"), order.Items[0].VAT)
+	assert.Equal(t, money.MustParse("383.83"), order.VAT)
+	stored, err := orderRepo.ListOrderItems(ctx, order.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, money.MustParse("350.50"), stored[0].VAT)
+
+	// 12 мотков — тяжелее 1000 г: правило без ограничения веса
+	quote, err := checkout.Quote(ctx, user.ID, service.CheckoutRequest{
+		Items: []service.CheckoutItem{{ProductID: yarn.ID, Quantity: 12}}, DeliveryMethod: models.DeliveryCourier, AddressID: home.ID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("500"), quote.DeliveryCost)
+	assert.Zero(t, quote.ID, "quote is not saved")
+
+	// Курьер в регион без правил недоступен
+	far := &models.Address{UserID: user.ID, ShippingAddress: home.ShippingAddress}
+	far.Region = region + "-далеко"
+	require.NoError(t, addresses.CreateAddress(ctx, far))
+	_, err = checkout.Quote(ctx, user.ID, service.CheckoutRequest{
+		Items: []service.CheckoutItem{{ProductID: yarn.ID, Quantity: 1}}, DeliveryMethod: models.DeliveryCourier, AddressID: far.ID,
+	})
+	assert.ErrorIs(t, err, service.ErrDeliveryUnavailable)
+
+	// Изменение и удаление адреса не меняют оформленный заказ
+	home.Street = "Новая, 2"
+	require.NoError(t, addresses.UpdateAddress(ctx, home))
+	assert.Equal(t, 2, home.Version)
+	stale := *home
+	stale.Version = 1
+	assert.ErrorIs(t, addresses.UpdateAddress(ctx, &stale), service.ErrVersionConflict)
+	require.NoError(t, addresses.DeleteAddress(ctx, user.ID, home.ID))
+	saved, err := orderRepo.GetOrder(ctx, order.ID)
+	require.NoError(t, err)
+	require.NotNil(t, saved.ShippingAddress)
+	assert.Equal(t, "Садовая, 1", saved.ShippingAddress.Street)
+	assert.Equal(t, models.DeliveryCourier, saved.DeliveryMethod)
+	assert.Equal(t, money.MustParse("2303"), saved.Total)
+
+	assert.ErrorIs(t, addresses.DeleteAddress(ctx, user.ID+100000, work.ID), sql.ErrNoRows)
+}
+
+func TestPatchAddressAndRateRequireIfMatch(t *testing.T) {
+	addresses := handler.NewAddressHandler(nil)
+	delivery := handler.NewDeliveryHandler(nil)
+	for name, patch := range map[string]http.HandlerFunc{"address": addresses.PatchAddress, "rate": delivery.PatchRate} {
+		req := httptest.NewRequest("PATCH", "/", strings.NewReader(`{"region":"Москва"}`))
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		req.Header.Set("Content-Type", handler.MergePatchContentType)
+		rec := httptest.NewRecorder()
+		patch(rec, req)
+		assert.Equal(t, http.StatusPreconditionRequired, rec.Code, name)
+	}
+}
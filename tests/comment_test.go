@@ -16,7 +16,7 @@ func TestCreateComment(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	commentRepo := repository.NewCommentRepository(db, redisClient)
+	commentRepo := repository.NewCommentRepository(db, redisClient, testCacheTTL)
 	commentService := service.NewCommentService(commentRepo)
 
 	comment := &models.Comment{
@@ -36,7 +36,7 @@ func TestGetComment(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	commentRepo := repository.NewCommentRepository(db, redisClient)
+	commentRepo := repository.NewCommentRepository(db, redisClient, testCacheTTL)
 	commentService := service.NewCommentService(commentRepo)
 
 	comment := &models.Comment{
@@ -57,7 +57,7 @@ func TestListComments(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	commentRepo := repository.NewCommentRepository(db, redisClient)
+	commentRepo := repository.NewCommentRepository(db, redisClient, testCacheTTL)
 	commentService := service.NewCommentService(commentRepo)
 
 	comment1 := &models.Comment{ProductID: 1, UserID: 1, Text: "Comment 1"}
@@ -76,7 +76,7 @@ func TestUpdateComment(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	commentRepo := repository.NewCommentRepository(db, redisClient)
+	commentRepo := repository.NewCommentRepository(db, redisClient, testCacheTTL)
 	commentService := service.NewCommentService(commentRepo)
 
 	comment := &models.Comment{ProductID: 1, UserID: 1, Text: "Old Comment"}
@@ -97,7 +97,7 @@ func TestDeleteComment(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	commentRepo := repository.NewCommentRepository(db, redisClient)
+	commentRepo := repository.NewCommentRepository(db, redisClient, testCacheTTL)
 	commentService := service.NewCommentService(commentRepo)
 
 	comment := &models.Comment{ProductID: 1, UserID: 1, Text: "Delete Comment"}
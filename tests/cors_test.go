@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCORSTestServer() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/products", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}).Methods("GET")
+	return handler.CorsMiddleware(handler.CORSOptions{
+		AllowedOrigins:   []string{"https://petelka.shop", "https://*.petelka.shop", "http://localhost:3000"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})(router)
+}
+
+func TestCORSAllowsConfiguredOrigins(t *testing.T) {
+	srv := newCORSTestServer()
+	cases := map[string]bool{
+		"https://petelka.shop":         true,
+		"https://staging.petelka.shop": true,
+		"https://a.b.petelka.shop":     true,
+		"http://localhost:3000":        true,
+		"http://localhost:8080":        false,
+		"http://staging.petelka.shop":  false,
+		"https://evilpetelka.shop":     false,
+		"https://petelka.shop.evil.io": false,
+	}
+	for origin, allowed := range cases {
+		req := httptest.NewRequest("GET", "/api/products", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code, origin)
+		assert.Contains(t, rec.Header().Values("Vary"), "Origin", origin)
+		if allowed {
+			assert.Equal(t, origin, rec.Header().Get("Access-Control-Allow-Origin"), origin)
+			assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"), origin)
+			assert.Equal(t, "X-Request-ID", rec.Header().Get("Access-Control-Expose-Headers"), origin)
+		} else {
+			assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"), origin)
+		}
+	}
+}
+
+func TestCORSPreflightHandledBeforeRouting(t *testing.T) {
+	srv := newCORSTestServer()
+
+	req := httptest.NewRequest("OPTIONS", "/api/products", nil)
+	req.Header.Set("Origin", "https://staging.petelka.shop")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://staging.petelka.shop", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, Authorization", rec.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+
+	req = httptest.NewRequest("OPTIONS", "/api/products", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptHashPattern совпадает со строками вида "$2a$10$..." — хешами bcrypt.
+var bcryptHashPattern = regexp.MustCompile(`\$2[aby]\$\d{2}\$[./A-Za-z0-9]{53}`)
+
+func assertNoPasswordHash(t *testing.T, name string, body []byte) {
+	t.Helper()
+	assert.Falsef(t, bcryptHashPattern.Match(body), "%s leaks a password hash: %s", name, body)
+}
+
+func TestResponseMappersOmitPasswordHash(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &models.User{ID: 1, Email: "user@example.com", Name: "User", Role: "user", Password: string(hash)}
+	require.True(t, bcryptHashPattern.MatchString(user.Password))
+
+	for name, v := range map[string]any{
+		"models.User":      user,
+		"UserResponse":     handler.NewUserResponse(user),
+		"[]UserResponse":   handler.NewUserResponses([]*models.User{user}),
+		"UpdateMeResponse": handler.UpdateMeResponse{UserResponse: handler.NewUserResponse(user)},
+	} {
+		body, err := json.Marshal(v)
+		require.NoError(t, err)
+		assertNoPasswordHash(t, name, body)
+	}
+}
+
+func TestResponseMappersReturnEmptyLists(t *testing.T) {
+	body, err := json.Marshal(handler.NewProductResponses(nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[]`, string(body))
+}
+
+// TestAPIResponsesContainNoPasswordHashes обходит все GET-маршруты API от имени
+// администратора и проверяет, что ни один ответ и ни одна запись кеша не содержат хеш пароля.
+func TestAPIResponsesContainNoPasswordHashes(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	redisClient := setupTestRedis(t)
+	defer redisClient.Close()
+
+	userRepo := repository.NewUserRepository(db, redisClient, testCacheTTL)
+	users := service.NewUserService(userRepo)
+	orders := service.NewOrderService(repository.NewOrderRepository(db, redisClient, testCacheTTL))
+	comments := service.NewCommentService(repository.NewCommentRepository(db, redisClient, testCacheTTL))
+	ctx := context.Background()
+
+	user := &models.User{
+		Email: fmt.Sprintf("dto-%d@example.com", time.Now().UnixNano()), Name: "DTO", Password: "password123", Role: "admin",
+	}
+	require.NoError(t, users.CreateUser(ctx, user))
+
+	router := mux.NewRouter()
+	groups := handler.MountAPI(router.PathPrefix("/api/v1").Subrouter(), handler.APIOptions{JWTKey: testJWTKey},
+		handler.NewUserHandler(users),
+		handler.NewMeHandler(users, nil, orders, comments),
+		handler.NewProductHandler(service.NewProductService(repository.NewProductRepository(db, redisClient, testCacheTTL))),
+		handler.NewCategoryHandler(service.NewCategoryService(repository.NewCategoryRepository(db, redisClient, testCacheTTL))),
+		handler.NewOrderHandler(orders, nil),
+		handler.NewCommentHandler(comments),
+	)
+	token := signTestToken(t, user.ID, "admin")
+
+	for _, route := range groups.Routes() {
+		if route.Method != "GET" {
+			continue
+		}
+		path := strings.ReplaceAll(route.Path, "{id}", strconv.Itoa(user.ID))
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		body, err := io.ReadAll(rec.Result().Body)
+		require.NoError(t, err)
+		assert.NotEqualf(t, http.StatusInternalServerError, rec.Code, "GET %s: %s", path, body)
+		assertNoPasswordHash(t, "GET "+path, body)
+	}
+
+	cached, err := redisClient.Get(ctx, fmt.Sprintf("user:%d", user.ID)).Bytes()
+	require.NoError(t, err)
+	assertNoPasswordHash(t, "cached user", cached)
+}
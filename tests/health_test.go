@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadiness(t *testing.T) {
+	ok := handler.HealthCheck{Name: "ok", Check: func(ctx context.Context) error { return nil }}
+	failing := handler.HealthCheck{Name: "failing", Check: func(ctx context.Context) error { return errors.New("down") }}
+	slow := handler.HealthCheck{Name: "slow", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	tests := []struct {
+		name   string
+		checks []handler.HealthCheck
+		want   int
+	}{
+		{"all ok", []handler.HealthCheck{ok}, http.StatusOK},
+		{"one failing", []handler.HealthCheck{ok, failing}, http.StatusServiceUnavailable},
+		{"timeout", []handler.HealthCheck{slow}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewHealthHandler(50*time.Millisecond, tt.checks...)
+			rec := httptest.NewRecorder()
+			h.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestReadinessHidesCheckErrors(t *testing.T) {
+	failing := handler.HealthCheck{Name: "postgres", Check: func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.0.5:5432: connect: connection refused")
+	}}
+	ok := handler.HealthCheck{Name: "redis", Check: func(ctx context.Context) error { return nil }}
+	h := handler.NewHealthHandler(time.Second, failing, ok)
+
+	rec := httptest.NewRecorder()
+	h.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "10.0.0.5")
+
+	var resp struct {
+		Checks map[string]string `json:"checks"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, map[string]string{"postgres": "fail", "redis": "ok"}, resp.Checks)
+}
+
+func TestReadinessWhileDraining(t *testing.T) {
+	h := handler.NewHealthHandler(time.Second)
+	h.SetDraining()
+
+	rec := httptest.NewRecorder()
+	h.Readiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.Liveness(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
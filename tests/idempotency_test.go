@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/idempotency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func idempotentRequest(userID int, key, body string) *http.Request {
+	req := httptest.NewRequest("POST", "/api/v1/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(handler.IdempotencyKeyHeader, key)
+	}
+	return req.WithContext(context.WithValue(req.Context(), handler.UserIDKey, userID))
+}
+
+func TestIdempotencyMiddlewareReplaysResponse(t *testing.T) {
+	_, client := setupMiniRedis(t)
+	store := idempotency.NewStore(client, time.Hour, time.Minute)
+
+	var calls int32
+	h := handler.IdempotencyMiddleware(store, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":%d}`, n)
+	}))
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set("X-Request-ID", "first")
+	h.ServeHTTP(rec, idempotentRequest(1, "order-1", `{"total":100}`))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"id":1}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	rec.Header().Set("X-Request-ID", "second")
+	h.ServeHTTP(rec, idempotentRequest(1, "order-1", `{"total":100}`))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"id":1}`, rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "second", rec.Header().Get("X-Request-ID"), "headers of outer middleware are not replayed")
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	// Тот же ключ с другим телом
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, idempotentRequest(1, "order-1", `{"total":200}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// Ключи разных пользователей не пересекаются
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, idempotentRequest(2, "order-1", `{"total":100}`))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+
+	// Без заголовка запрос выполняется каждый раз
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest(1, "", `{"total":100}`))
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest(1, "", `{"total":100}`))
+	assert.EqualValues(t, 4, atomic.LoadInt32(&calls))
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, idempotentRequest(1, strings.Repeat("k", 256), `{}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestIdempotencyMiddlewareConcurrentDuplicate(t *testing.T) {
+	_, client := setupMiniRedis(t)
+	store := idempotency.NewStore(client, time.Hour, time.Minute)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	h := handler.IdempotencyMiddleware(store, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, idempotentRequest(1, "order-1", `{}`))
+		done <- rec.Code
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, idempotentRequest(1, "order-1", `{}`))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	close(release)
+	assert.Equal(t, http.StatusCreated, <-done)
+}
+
+func TestIdempotencyMiddlewareDoesNotStoreServerErrors(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	store := idempotency.NewStore(client, time.Hour, time.Minute)
+
+	status := http.StatusInternalServerError
+	h := handler.IdempotencyMiddleware(store, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, idempotentRequest(1, "order-1", `{}`))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	// Ключ освобождён: повтор выполняется заново
+	status = http.StatusCreated
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, idempotentRequest(1, "order-1", `{}`))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+
+	// Сохранённый ответ истекает через TTL
+	mr.FastForward(2 * time.Hour)
+	status = http.StatusAccepted
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, idempotentRequest(1, "order-1", `{}`))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	// Без Redis повтор нельзя проверить
+	mr.Close()
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, idempotentRequest(1, "order-2", `{}`))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
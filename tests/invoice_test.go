@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/invoice"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/payment"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/alex-pyslar/petelka-api/internal/storage"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderInvoice(t *testing.T) {
+	doc := &invoice.Document{
+		Kind:     invoice.KindReceipt,
+		IssuedAt: time.Date(2026, 1, 2, 15, 4, 0, 0, time.UTC),
+		Buyer:    "Анна (anna@example.com)",
+		Order: &models.Order{
+			ID: 12, DeliveryMethod: models.DeliveryCourier, DeliveryCost: money.MustParse("200"),
+			PromoCode: "SALE", Discount: money.MustParse("50"), Total: money.MustParse("951.00"),
+			VATRate: 20, VAT: money.MustParse("158.50"),
+			ShippingAddress: &models.ShippingAddress{Recipient: "Анна", City: "Москва", Street: "Тверская, 1"},
+			Items: []models.OrderItem{
+				{ProductID: 1, Quantity: 2, Price: money.MustParse("350.50"), Discount: money.MustParse("40.73"), VAT: money.MustParse("110.05")},
+				{ProductID: 2, Quantity: 1, Price: money.MustParse("100"), Discount: money.MustParse("9.27"), VAT: money.MustParse("15.12")},
+			},
+		},
+		ProductNames: map[int]string{1: "Альпака, белая"},
+	}
+	assert.Equal(t, "Квитанция об оплате заказа № 12 от 02.01.2026", doc.Title())
+	assert.Equal(t, "В т.ч. НДС 20%", doc.VATLabel())
+	assert.Equal(t, money.MustParse("33.33"), doc.DeliveryVAT(), "НДС заказа за вычетом НДС позиций")
+
+	shop := invoice.Shop{Name: "Петелька", INN: "7700000000"}
+	var first, second bytes.Buffer
+	require.NoError(t, invoice.Render(&first, shop, doc))
+	require.NoError(t, invoice.Render(&second, shop, doc))
+	assert.True(t, bytes.HasPrefix(first.Bytes(), []byte("%PDF-")))
+	assert.Contains(t, first.String(), "/FontFile2", "шрифт с кириллицей встроен в документ")
+	assert.Equal(t, first.Bytes(), second.Bytes(), "одинаковые данные дают одинаковый документ")
+
+	doc.Order.VATRate, doc.Order.VAT = 0, money.Money{}
+	assert.Equal(t, "Без НДС", doc.VATLabel())
+	var untaxed bytes.Buffer
+	require.NoError(t, invoice.Render(&untaxed, shop, doc))
+	assert.NotEqual(t, first.Bytes(), untaxed.Bytes())
+}
+
+func TestOrderInvoiceIsStoredOncePaid(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	t.Cleanup(teardown)
+	redisClient := setupTestRedis(t)
+	t.Cleanup(func() { redisClient.Close() })
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	store, err := storage.NewLocalStore(dir, "http://localhost/api/storage", []byte("test-signing-key"))
+	require.NoError(t, err)
+
+	userRepo := repository.NewUserRepository(db, redisClient, testCacheTTL)
+	productRepo := repository.NewProductRepository(db, redisClient, testCacheTTL)
+	orderRepo := repository.NewOrderRepository(db, redisClient, testCacheTTL)
+	paymentRepo := repository.NewPaymentRepository(db, redisClient)
+	invoices := service.NewInvoiceService(repository.NewInvoiceRepository(store), orderRepo, productRepo, userRepo, paymentRepo,
+		invoice.Shop{Name: "Петелька"})
+	fake := payment.NewFakeProvider(testWebhookSecret, "https://shop.test/pay")
+	payments := service.NewPaymentService(paymentRepo, orderRepo, fake,
+		service.PaymentOptions{ReturnURL: "https://shop.test/orders", Timeout: time.Second, Receipts: invoices})
+	h := handler.NewInvoiceHandler(invoices)
+
+	user := &models.User{Email: fmt.Sprintf("invoice-%d@example.com", time.Now().UnixNano()), Name: "Buyer", Password: "password123", Role: "user"}
+	require.NoError(t, service.NewUserService(userRepo).CreateUser(ctx, user))
+	productService := service.NewProductService(productRepo)
+	product := &models.Product{Name: "Альпака", Price: money.MustParse("800"), Images: []string{}, Type: "yarn", Composition: "100% альпака",
+		CountryOfOrigin: "Перу", LengthIn100g: 200, Color: "белый", Weight: 100}
+	require.NoError(t, productService.CreateProduct(ctx, product))
+	t.Cleanup(func() { productService.DeleteProduct(ctx, product.ID) })
+	order := &models.Order{UserID: user.ID, Total: money.MustParse("1600"), Status: models.OrderStatusNew}
+	require.NoError(t, orderRepo.CreateOrderWithItems(ctx, order,
+		[]models.OrderItem{{ProductID: product.ID, Quantity: 2, Price: product.Price}}))
+
+	get := func(userID int, role string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{"id": fmt.Sprint(order.ID)})
+		reqCtx := context.WithValue(context.WithValue(req.Context(), handler.UserIDKey, userID), handler.UserRoleKey, role)
+		rec := httptest.NewRecorder()
+		h.GetInvoice(rec, req.WithContext(reqCtx))
+		return rec
+	}
+
+	// Счёт неоплаченного заказа доступен владельцу и не сохраняется
+	rec := get(user.ID, "user")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.True(t, bytes.HasPrefix(rec.Body.Bytes(), []byte("%PDF-")))
+	assert.NoFileExists(t, filepath.Join(dir, fmt.Sprintf("order-%d.pdf", order.ID)))
+	assert.Equal(t, http.StatusNotFound, get(user.ID+100000, "user").Code, "чужой заказ")
+
+	// Оплата сохраняет квитанцию
+	p, err := payments.PayOrder(ctx, user.ID, order.ID)
+	require.NoError(t, err)
+	body, signature, err := fake.Complete(p.ProviderPaymentID, payment.StatusSucceeded)
+	require.NoError(t, err)
+	require.NoError(t, payments.HandleWebhook(ctx, http.Header{payment.SignatureHeader: {signature}}, body))
+	stored, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("order-%d.pdf", order.ID)))
+	require.NoError(t, err)
+
+	// Квитанция не меняется вместе с каталогом и доступна администратору
+	product.Name = "Альпака (новое название)"
+	require.NoError(t, productService.UpdateProduct(ctx, product))
+	rec = get(user.ID+100000, "admin")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, stored, rec.Body.Bytes())
+	assert.Equal(t, stored, get(user.ID, "user").Body.Bytes())
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func newBufferedLogger(t *testing.T, opts logger.Options) (*logger.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	opts.Output = zapcore.AddSync(&buf)
+	log, err := logger.New(opts)
+	require.NoError(t, err)
+	return log, &buf
+}
+
+func TestLoggerLevelAndFormat(t *testing.T) {
+	log, buf := newBufferedLogger(t, logger.Options{Level: "warn", Format: "console"})
+
+	log.Infof("hidden %d", 1)
+	log.Warningf("visible %d", 2)
+
+	out := buf.String()
+	assert.NotContains(t, out, "hidden")
+	assert.Contains(t, out, "visible 2")
+	assert.False(t, strings.HasPrefix(out, "{"), "console format must not be JSON")
+}
+
+func TestLoggerPackageLevelOverride(t *testing.T) {
+	// Вызовы из этого файла относятся к пакету tests
+	log, buf := newBufferedLogger(t, logger.Options{
+		Level:         "error",
+		Format:        "json",
+		PackageLevels: map[string]string{"tests": "debug"},
+	})
+	log.Debug("debug from tests package")
+	assert.Contains(t, buf.String(), "debug from tests package")
+
+	log, buf = newBufferedLogger(t, logger.Options{
+		Level:         "debug",
+		Format:        "json",
+		PackageLevels: map[string]string{"tests": "error"},
+	})
+	log.Warning("warning from tests package")
+	assert.Empty(t, buf.String())
+}
+
+func TestLoggerSampling(t *testing.T) {
+	log, buf := newBufferedLogger(t, logger.Options{
+		Level:              "info",
+		Format:             "json",
+		SamplingInitial:    2,
+		SamplingThereafter: 1000,
+		PackageLevels:      map[string]string{"tests": "warn"},
+	})
+
+	for i := 0; i < 100; i++ {
+		log.Warning("repeated warning")
+	}
+	log.Warning("another warning")
+	log.Info("info from tests package")
+
+	out := buf.String()
+	assert.Equal(t, 2, strings.Count(out, "repeated warning"), "only the first SamplingInitial entries per second are written")
+	assert.Contains(t, out, "another warning", "messages are sampled independently")
+	assert.NotContains(t, out, "info from tests package", "package levels still apply")
+}
+
+func TestLoggerRuntimeLevelChange(t *testing.T) {
+	log, buf := newBufferedLogger(t, logger.Options{Level: "info", Format: "json"})
+	child := log.With() // дочерние логгеры разделяют уровень с родителем
+
+	child.Debug("before change")
+	assert.Empty(t, buf.String())
+
+	rec := httptest.NewRecorder()
+	log.LevelHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/admin/log-level", strings.NewReader(`{"level":"debug"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	child.Debug("after change")
+	assert.Contains(t, buf.String(), "after change")
+
+	rec = httptest.NewRecorder()
+	log.LevelHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/log-level", nil))
+	assert.JSONEq(t, `{"level":"debug"}`, rec.Body.String())
+}
+
+func TestLoggerInvalidOptions(t *testing.T) {
+	_, err := logger.New(logger.Options{Level: "loud", Format: "json"})
+	assert.Error(t, err)
+	_, err = logger.New(logger.Options{Level: "info", Format: "xml"})
+	assert.Error(t, err)
+}
+
+func TestMaskEmailAndPhone(t *testing.T) {
+	assert.Equal(t, "i***@mail.ru", logger.MaskEmail("ivan.petrov@mail.ru"))
+	assert.Equal(t, "и***@почта.рф", logger.MaskEmail("иван@почта.рф"))
+	assert.True(t, utf8.ValidString(logger.MaskEmail("ёлка@mail.ru")))
+	assert.Equal(t, "***", logger.MaskEmail("not-an-email"))
+	assert.Equal(t, "", logger.MaskEmail(""))
+	assert.Equal(t, "***67", logger.MaskPhone("+7 (999) 123-45-67"))
+	assert.Equal(t, "***", logger.MaskPhone("12"))
+}
@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/metrics"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsMiddlewareUsesRouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(handler.MetricsMiddleware)
+	router.HandleFunc("/api/test-metrics/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "404" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}).Methods("GET")
+
+	const route = "/api/test-metrics/{id}"
+	okBefore := testutil.ToFloat64(metrics.HTTPRequestsTotal.WithLabelValues(route, "GET", "200"))
+	notFoundBefore := testutil.ToFloat64(metrics.HTTPRequestsTotal.WithLabelValues(route, "GET", "404"))
+
+	for _, path := range []string{"/api/test-metrics/1", "/api/test-metrics/2", "/api/test-metrics/404"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	assert.Equal(t, okBefore+2, testutil.ToFloat64(metrics.HTTPRequestsTotal.WithLabelValues(route, "GET", "200")))
+	assert.Equal(t, notFoundBefore+1, testutil.ToFloat64(metrics.HTTPRequestsTotal.WithLabelValues(route, "GET", "404")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.HTTPRequestsInFlight.WithLabelValues(route, "GET")))
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var testJWTKey = []byte("test-secret")
+
+// newObservedLogger возвращает логгер, записи которого можно проверить в тесте.
+func newObservedLogger() (*logger.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	return &logger.Logger{Logger: zap.New(core)}, logs
+}
+
+func signTestToken(t *testing.T, userID int, role string) string {
+	claims := &handler.Claims{
+		UserID: userID,
+		Role:   role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testJWTKey)
+	require.NoError(t, err)
+	return token
+}
+
+// newMiddlewareTestServer собирает цепочку middleware так же, как main.
+func newMiddlewareTestServer(log *logger.Logger) http.Handler {
+	router := mux.NewRouter()
+	router.Use(handler.RouteMiddleware)
+	router.HandleFunc("/api/public/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Info("inside handler")
+		w.Write([]byte(handler.RequestIDFromContext(r.Context())))
+	}).Methods("GET")
+
+	admin := router.PathPrefix("/api/admin").Subrouter()
+	admin.Use(handler.AuthMiddleware(testJWTKey, nil), handler.AdminMiddleware)
+	admin.HandleFunc("/things", func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Info("admin handler")
+	}).Methods("GET")
+
+	return handler.RequestIDMiddleware(log)(handler.AccessLogMiddleware(router))
+}
+
+func TestRequestIDGeneratedAndEchoed(t *testing.T) {
+	log, _ := newObservedLogger()
+	srv := newMiddlewareTestServer(log)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/api/public/1", nil))
+
+	id := rec.Header().Get(handler.RequestIDHeader)
+	assert.NotEmpty(t, id)
+	assert.Equal(t, id, rec.Body.String(), "handler must see the same request ID")
+}
+
+func TestRequestIDAcceptedFromClient(t *testing.T) {
+	log, _ := newObservedLogger()
+	srv := newMiddlewareTestServer(log)
+
+	req := httptest.NewRequest("GET", "/api/public/1", nil)
+	req.Header.Set(handler.RequestIDHeader, "client-id-123")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	assert.Equal(t, "client-id-123", rec.Header().Get(handler.RequestIDHeader))
+
+	req = httptest.NewRequest("GET", "/api/public/1", nil)
+	req.Header.Set(handler.RequestIDHeader, "bad id\twith spaces")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	assert.NotEqual(t, "bad id\twith spaces", rec.Header().Get(handler.RequestIDHeader))
+}
+
+func TestContextLoggerCarriesRequestFields(t *testing.T) {
+	log, logs := newObservedLogger()
+	srv := newMiddlewareTestServer(log)
+
+	req := httptest.NewRequest("GET", "/api/admin/things", nil)
+	req.Header.Set(handler.RequestIDHeader, "req-1")
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, 42, "admin"))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	entries := logs.FilterMessage("admin handler").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "req-1", fields["request_id"])
+	assert.Equal(t, int64(42), fields["user_id"])
+	assert.Equal(t, "/api/admin/things", fields["route"])
+
+	access := logs.FilterMessage("http request").All()
+	require.Len(t, access, 1)
+	fields = access[0].ContextMap()
+	assert.Equal(t, "req-1", fields["request_id"])
+	assert.Equal(t, "/api/admin/things", fields["route"])
+	assert.Equal(t, int64(http.StatusOK), fields["status"])
+	assert.Equal(t, int64(42), fields["user_id"])
+}
+
+func TestAdminMiddlewareWithoutAuthDoesNotPanic(t *testing.T) {
+	h := handler.AdminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not be reached")
+	}))
+
+	rec := httptest.NewRecorder()
+	assert.NotPanics(t, func() {
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/users", nil))
+	})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestAccessLogForUnmatchedRoute(t *testing.T) {
+	log, logs := newObservedLogger()
+	srv := newMiddlewareTestServer(log)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/nope", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	access := logs.FilterMessage("http request").All()
+	require.Len(t, access, 1)
+	assert.Equal(t, int64(http.StatusNotFound), access[0].ContextMap()["status"])
+	assert.NotEmpty(t, rec.Header().Get(handler.RequestIDHeader))
+}
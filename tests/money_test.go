@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// propertyRuns — число случайных наборов в property-тестах; генератор с фиксированным
+// seed, чтобы падение воспроизводилось.
+const propertyRuns = 2000
+
+func TestMoneyParse(t *testing.T) {
+	for in, want := range map[string]int64{
+		"350": 35000, "350.5": 35050, "350.50": 35050, "0.01": 1, "-12.05": -1205, "0": 0,
+	} {
+		got, err := money.Parse(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, money.New(want), got, in)
+	}
+	for _, in := range []string{"", "-", "1.", ".5", "1.005", "1,5", "1e3", "+1", " 1", "12345678901234567"} {
+		_, err := money.Parse(in)
+		assert.ErrorIs(t, err, money.ErrInvalidAmount, in)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(models.OrderItem{Price: money.New(35050)})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"price":"350.50"`)
+
+	var item models.OrderItem
+	require.NoError(t, json.Unmarshal([]byte(`{"price": 0.3}`), &item))
+	assert.Equal(t, money.New(30), item.Price, "число читается по записи, без float64")
+	require.NoError(t, json.Unmarshal([]byte(`{"price": "19.99"}`), &item))
+	assert.Equal(t, money.New(1999), item.Price)
+	assert.Error(t, json.Unmarshal([]byte(`{"price": 1.999}`), &item))
+}
+
+func TestMoneyRoundTripProperty(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < propertyRuns; i++ {
+		m := money.New(rnd.Int63n(1e12) - 5e11)
+		parsed, err := money.Parse(m.String())
+		require.NoError(t, err)
+		require.Equal(t, m, parsed)
+
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+		var decoded money.Money
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.Equal(t, m, decoded)
+	}
+}
+
+func TestMoneyRounding(t *testing.T) {
+	// Половина копейки округляется от нуля
+	assert.Equal(t, money.New(5), money.New(50).Percent(1000))
+	assert.Equal(t, money.New(1), money.New(10).Percent(500))
+	assert.Equal(t, money.New(-1), money.New(-10).Percent(500))
+	assert.Equal(t, money.New(0), money.New(9).Percent(500))
+
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < propertyRuns; i++ {
+		amount, bp := rnd.Int63n(1e9), rnd.Int63n(10001)
+		got := money.New(amount).Percent(bp).Amount
+		// |got - amount*bp/10000| <= 1/2 копейки
+		diff := got*10000 - amount*bp
+		require.LessOrEqual(t, 2*abs64(diff), int64(10000), "%d * %d bp", amount, bp)
+	}
+}
+
+func TestMoneyVAT(t *testing.T) {
+	assert.Equal(t, money.New(2000), money.New(12000).VAT(20))
+	assert.Equal(t, money.New(3333), money.New(20000).VAT(20))
+	assert.Equal(t, money.New(909), money.New(10000).VAT(10))
+	assert.Equal(t, money.New(1), money.New(3).VAT(20), "0.5 копейки округляется от нуля")
+	assert.Equal(t, money.New(-1), money.New(-3).VAT(20))
+	assert.Equal(t, money.New(0), money.New(12000).VAT(0), "без НДС")
+
+	rnd := rand.New(rand.NewSource(5))
+	for _, rate := range []int64{5, 7, 10, 20, 22} {
+		for i := 0; i < propertyRuns; i++ {
+			amount := rnd.Int63n(1e9)
+			got := money.New(amount).VAT(rate).Amount
+			// |got - amount*rate/(100+rate)| <= 1/2 копейки
+			diff := got*(100+rate) - amount*rate
+			require.LessOrEqual(t, 2*abs64(diff), 100+rate, "%d at %d%%", amount, rate)
+		}
+	}
+}
+
+func TestOrderResponsesCarryCurrencyAndVAT(t *testing.T) {
+	order := &models.Order{ID: 1, Total: money.New(240000), VATRate: 20, VAT: money.New(40000),
+		Items: []models.OrderItem{{ProductID: 2, Quantity: 1, Price: money.New(240000), VAT: money.New(40000)}}}
+	body, err := json.Marshal(handler.NewOrderResponse(order))
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"currency":"RUB"`, "пустая валюта — валюта по умолчанию")
+	assert.Contains(t, string(body), `"vat_rate":20,"vat":"400.00"`)
+	assert.Contains(t, string(body), `"discount":"0.00","vat":"400.00"`)
+
+	body, err = json.Marshal(handler.NewProductResponse(&models.Product{Price: money.New(35050), Currency: "RUB"}))
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"price":"350.50","currency":"RUB"`)
+}
+
+func TestMoneyAllocateProperty(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	for i := 0; i < propertyRuns; i++ {
+		total := money.New(rnd.Int63n(1e7) - 1e6)
+		weights := make([]int64, 1+rnd.Intn(10))
+		var weightSum int64
+		for j := range weights {
+			weights[j] = rnd.Int63n(1e6)
+			weightSum += weights[j]
+		}
+		if weightSum == 0 {
+			continue
+		}
+
+		parts := total.Allocate(weights)
+		require.Equal(t, total, money.Sum(parts...))
+		for j, part := range parts {
+			// Часть отличается от точной доли total*w/sum меньше чем на копейку
+			diff := part.Amount*weightSum - total.Amount*weights[j]
+			require.Less(t, abs64(diff), weightSum, "part %d of %s", j, total)
+		}
+	}
+}
+
+// TestOrderTotalsMatchLineSumsProperty проверяет, что итог заказа, скидка которого
+// распределена по позициям, совпадает с суммой позиций до копейки.
+func TestOrderTotalsMatchLineSumsProperty(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	for i := 0; i < propertyRuns; i++ {
+		var items []service.DiscountItem
+		for j := 0; j < 1+rnd.Intn(8); j++ {
+			price, quantity := money.New(1+rnd.Int63n(500000)), 1+rnd.Int63n(10)
+			items = append(items, service.DiscountItem{ProductID: j + 1, CategoryID: 1 + rnd.Intn(2), Amount: price.Mul(quantity)})
+		}
+		// Скидка только на первую категорию, чтобы часть позиций в ней не участвовала
+		promo := &models.PromoCode{Kind: models.PromoPercent, PercentBP: 1 + rnd.Int63n(10000), CategoryIDs: []int{items[0].CategoryID}}
+		if rnd.Intn(2) == 0 {
+			promo = &models.PromoCode{Kind: models.PromoFixed, Amount: money.New(1 + rnd.Int63n(1000000)), CategoryIDs: []int{items[0].CategoryID}}
+		}
+		delivery := money.New(rnd.Int63n(100000))
+
+		discount, err := service.CalculateDiscount(promo, items, delivery)
+		require.NoError(t, err)
+
+		subtotal := money.Money{}
+		for _, item := range items {
+			subtotal = subtotal.Add(item.Amount)
+		}
+		total := subtotal.Add(delivery).Sub(discount)
+
+		lines := money.Money{}
+		for j, share := range service.AllocateDiscount(promo, items, discount) {
+			if items[j].CategoryID != items[0].CategoryID {
+				require.True(t, share.IsZero(), "item %d is not eligible", j)
+			}
+			line := items[j].Amount.Sub(share)
+			require.False(t, line.IsNegative())
+			lines = lines.Add(line)
+		}
+		require.Equal(t, total, lines.Add(delivery), "promo %s %d bp %s", promo.Kind, promo.PercentBP, promo.Amount)
+	}
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
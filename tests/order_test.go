@@ -3,6 +3,7 @@ package tests
 import (
 	"context"
 	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
 	"github.com/alex-pyslar/petelka-api/internal/repository"
 	"github.com/alex-pyslar/petelka-api/internal/service"
 	"testing"
@@ -16,12 +17,12 @@ func TestCreateOrder(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	orderRepo := repository.NewOrderRepository(db, redisClient)
+	orderRepo := repository.NewOrderRepository(db, redisClient, testCacheTTL)
 	orderService := service.NewOrderService(orderRepo)
 
 	order := &models.Order{
 		UserID: 1,
-		Total:  100.0,
+		Total:  money.MustParse("100"),
 		Status: "pending",
 	}
 
@@ -36,12 +37,12 @@ func TestGetOrder(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	orderRepo := repository.NewOrderRepository(db, redisClient)
+	orderRepo := repository.NewOrderRepository(db, redisClient, testCacheTTL)
 	orderService := service.NewOrderService(orderRepo)
 
 	order := &models.Order{
 		UserID: 1,
-		Total:  200.0,
+		Total:  money.MustParse("200"),
 		Status: "pending",
 	}
 	orderService.CreateOrder(context.Background(), order)
@@ -57,11 +58,11 @@ func TestListOrders(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	orderRepo := repository.NewOrderRepository(db, redisClient)
+	orderRepo := repository.NewOrderRepository(db, redisClient, testCacheTTL)
 	orderService := service.NewOrderService(orderRepo)
 
-	order1 := &models.Order{UserID: 1, Total: 100.0, Status: "pending"}
-	order2 := &models.Order{UserID: 1, Total: 200.0, Status: "pending"}
+	order1 := &models.Order{UserID: 1, Total: money.MustParse("100"), Status: "pending"}
+	order2 := &models.Order{UserID: 1, Total: money.MustParse("200"), Status: "pending"}
 	orderService.CreateOrder(context.Background(), order1)
 	orderService.CreateOrder(context.Background(), order2)
 
@@ -76,10 +77,10 @@ func TestUpdateOrder(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	orderRepo := repository.NewOrderRepository(db, redisClient)
+	orderRepo := repository.NewOrderRepository(db, redisClient, testCacheTTL)
 	orderService := service.NewOrderService(orderRepo)
 
-	order := &models.Order{UserID: 1, Total: 100.0, Status: "pending"}
+	order := &models.Order{UserID: 1, Total: money.MustParse("100"), Status: "pending"}
 	orderService.CreateOrder(context.Background(), order)
 
 	order.Status = "completed"
@@ -97,10 +98,10 @@ func TestDeleteOrder(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	orderRepo := repository.NewOrderRepository(db, redisClient)
+	orderRepo := repository.NewOrderRepository(db, redisClient, testCacheTTL)
 	orderService := service.NewOrderService(orderRepo)
 
-	order := &models.Order{UserID: 1, Total: 100.0, Status: "pending"}
+	order := &models.Order{UserID: 1, Total: money.MustParse("100"), Status: "pending"}
 	orderService.CreateOrder(context.Background(), order)
 
 	err := orderService.DeleteOrder(context.Background(), order.ID)
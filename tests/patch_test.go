@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Примеры из приложения A RFC 7386.
+func TestMergePatchRFCExamples(t *testing.T) {
+	cases := []struct{ doc, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		got, err := handler.MergePatch([]byte(c.doc), []byte(c.patch))
+		require.NoError(t, err)
+		assert.JSONEq(t, c.want, string(got), "%s + %s", c.doc, c.patch)
+	}
+}
+
+func TestMergePatchKeepsNumberPrecision(t *testing.T) {
+	got, err := handler.MergePatch([]byte(`{"id":9007199254740993,"price":10.10}`), []byte(`{"name":"x"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":9007199254740993,"price":10.10,"name":"x"}`, string(got))
+}
+
+// newCachedProductHandler возвращает обработчик товаров, который читает товар из кеша,
+// а запись в недоступную базу завершает ошибкой.
+func newCachedProductHandler(t *testing.T, product models.Product) *handler.ProductHandler {
+	_, client := setupMiniRedis(t)
+	data, err := json.Marshal(product)
+	require.NoError(t, err)
+	require.NoError(t, client.Set(context.Background(), fmt.Sprintf("product:%d", product.ID), data, time.Minute).Err())
+	repo := repository.NewProductRepository(unreachableDB(t), client, time.Minute)
+	return handler.NewProductHandler(service.NewProductService(repo))
+}
+
+func TestPatchProductPreconditionsAndValidation(t *testing.T) {
+	h := newCachedProductHandler(t, models.Product{ID: 7, Name: "Yarn", Type: "yarn", Price: money.MustParse("100"), Version: 3})
+
+	cases := []struct {
+		name        string
+		ifMatch     string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"missing if-match", "", handler.MergePatchContentType, `{"name":"New"}`, http.StatusPreconditionRequired},
+		{"stale version", `"2"`, handler.MergePatchContentType, `{"name":"New"}`, http.StatusPreconditionFailed},
+		{"weak etag", `W/"3"`, handler.MergePatchContentType, `{"name":"New"}`, http.StatusPreconditionFailed},
+		{"unsupported content type", `"3"`, "text/plain", `{"name":"New"}`, http.StatusUnsupportedMediaType},
+		{"not an object", `"3"`, handler.MergePatchContentType, `["name"]`, http.StatusBadRequest},
+		{"unknown field", `"3"`, handler.MergePatchContentType, `{"version":10}`, http.StatusBadRequest},
+		{"invalid type", `"3"`, handler.MergePatchContentType, `{"type":"socks"}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("PATCH", "/api/v1/products/7", strings.NewReader(c.body))
+		req = mux.SetURLVars(req, map[string]string{"id": "7"})
+		req.Header.Set("If-Match", c.ifMatch)
+		req.Header.Set("Content-Type", c.contentType)
+		rec := httptest.NewRecorder()
+		h.PatchProduct(rec, req)
+		assert.Equal(t, c.want, rec.Code, c.name)
+	}
+
+	req := httptest.NewRequest("PUT", "/api/v1/products/7", strings.NewReader(`{"name":"New","price":1,"type":"yarn"}`))
+	req.Header.Set("If-Match", `W/"3"`)
+	rec := httptest.NewRecorder()
+	h.UpdateProduct(rec, mux.SetURLVars(req, map[string]string{"id": "7"}))
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code, "PUT with a weak If-Match")
+}
+
+func TestGetProductReturnsETag(t *testing.T) {
+	h := newCachedProductHandler(t, models.Product{ID: 7, Name: "Yarn", Type: "yarn", Version: 3})
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/products/7", nil), map[string]string{"id": "7"})
+	rec := httptest.NewRecorder()
+	h.GetProduct(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+}
+
+func TestPatchProductWithOptimisticLocking(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	redisClient := setupTestRedis(t)
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	categories := service.NewCategoryService(repository.NewCategoryRepository(db, redisClient, testCacheTTL))
+	products := service.NewProductService(repository.NewProductRepository(db, redisClient, testCacheTTL))
+	category := &models.Category{Name: "Patch", Type: "yarn"}
+	require.NoError(t, categories.CreateCategory(ctx, category))
+	product := &models.Product{Name: "Merino", Description: "Soft", Price: money.MustParse("100"), CategoryID: category.ID, Type: "yarn", Color: "red"}
+	require.NoError(t, products.CreateProduct(ctx, product))
+	assert.Equal(t, 1, product.Version)
+	assert.False(t, product.CreatedAt.IsZero())
+
+	h := handler.NewProductHandler(products)
+	patch := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(product.ID)})
+		req.Header.Set("Content-Type", handler.MergePatchContentType)
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+		h.PatchProduct(rec, req)
+		return rec
+	}
+
+	rec := patch(`"1"`, `{"price":120,"color":null}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	var got handler.ProductResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(t, money.MustParse("120"), got.Price)
+	assert.Equal(t, "Merino", got.Name)
+	assert.Equal(t, "Soft", got.Description)
+	assert.Empty(t, got.Color)
+	assert.Equal(t, 2, got.Version)
+
+	// Второй администратор правит товар по устаревшей версии
+	assert.Equal(t, http.StatusPreconditionFailed, patch(`"1"`, `{"name":"Lost update"}`).Code)
+
+	req := httptest.NewRequest("PUT", "/", strings.NewReader(`{"name":"Lost update","price":1,"type":"yarn"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(product.ID)})
+	req.Header.Set("If-Match", `"1"`)
+	rec = httptest.NewRecorder()
+	h.UpdateProduct(rec, req)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	stored, err := products.GetProduct(ctx, product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Merino", stored.Name)
+	assert.Equal(t, 2, stored.Version)
+
+	// PUT без If-Match, как у старых клиентов, перезаписывает товар без проверки версии
+	body := fmt.Sprintf(`{"name":"Legacy","price":1,"type":"yarn","category_id":%d}`, category.ID)
+	req = httptest.NewRequest("PUT", "/", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(product.ID)})
+	rec = httptest.NewRecorder()
+	h.UpdateProduct(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+}
+
+func TestUpdateUserKeepsPasswordWhenOmitted(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	redisClient := setupTestRedis(t)
+	defer redisClient.Close()
+
+	users := service.NewUserService(repository.NewUserRepository(db, redisClient, testCacheTTL))
+	ctx := context.Background()
+	user := &models.User{Email: fmt.Sprintf("keep-%d@example.com", time.Now().UnixNano()), Name: "Keep", Password: "password123", Role: "user"}
+	require.NoError(t, users.CreateUser(ctx, user))
+
+	update := &models.User{ID: user.ID, Email: user.Email, Name: "Renamed", Role: "user", Version: user.Version}
+	require.NoError(t, users.UpdateUser(ctx, update))
+	assert.Equal(t, user.Version+1, update.Version)
+	assert.NoError(t, users.VerifyPassword(ctx, user.ID, "password123"))
+
+	stale := &models.User{ID: user.ID, Email: user.Email, Name: "Stale", Role: "user", Version: user.Version}
+	assert.ErrorIs(t, users.UpdateUser(ctx, stale), service.ErrVersionConflict)
+}
+
+func TestPatchCommentAndOrderRequireIfMatch(t *testing.T) {
+	comments := handler.NewCommentHandler(nil)
+	orders := handler.NewOrderHandler(nil, nil)
+	for name, patch := range map[string]http.HandlerFunc{"comment": comments.PatchComment, "order": orders.PatchOrder} {
+		req := httptest.NewRequest("PATCH", "/", strings.NewReader(`{"status":"shipped"}`))
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		req.Header.Set("Content-Type", handler.MergePatchContentType)
+		rec := httptest.NewRecorder()
+		patch(rec, req)
+		assert.Equal(t, http.StatusPreconditionRequired, rec.Code, name)
+	}
+}
+
+func TestPatchCommentAndOrderWithOptimisticLocking(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	redisClient := setupTestRedis(t)
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	users := service.NewUserService(repository.NewUserRepository(db, redisClient, testCacheTTL))
+	categories := service.NewCategoryService(repository.NewCategoryRepository(db, redisClient, testCacheTTL))
+	products := service.NewProductService(repository.NewProductRepository(db, redisClient, testCacheTTL))
+	comments := service.NewCommentService(repository.NewCommentRepository(db, redisClient, testCacheTTL))
+	orders := service.NewOrderService(repository.NewOrderRepository(db, redisClient, testCacheTTL))
+
+	user := &models.User{Email: fmt.Sprintf("patch-%d@example.com", time.Now().UnixNano()), Name: "Patch", Password: "password123", Role: "user"}
+	require.NoError(t, users.CreateUser(ctx, user))
+	category := &models.Category{Name: "Patch", Type: "yarn"}
+	require.NoError(t, categories.CreateCategory(ctx, category))
+	product := &models.Product{Name: "Merino", Price: money.MustParse("100"), CategoryID: category.ID, Type: "yarn"}
+	require.NoError(t, products.CreateProduct(ctx, product))
+	comment := &models.Comment{ProductID: product.ID, UserID: user.ID, Text: "Nice"}
+	require.NoError(t, comments.CreateComment(ctx, comment))
+	order := &models.Order{UserID: user.ID, Total: money.MustParse("100"), Status: "pending"}
+	require.NoError(t, orders.CreateOrder(ctx, order))
+
+	patch := func(h http.HandlerFunc, id int, ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(id)})
+		req.Header.Set("Content-Type", handler.MergePatchContentType)
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	commentHandler := handler.NewCommentHandler(comments)
+	rec := patch(commentHandler.PatchComment, comment.ID, `"1"`, `{"text":"Edited"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	var gotComment handler.CommentResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&gotComment))
+	assert.Equal(t, "Edited", gotComment.Text)
+	assert.Equal(t, product.ID, gotComment.ProductID)
+	assert.Equal(t, http.StatusPreconditionFailed, patch(commentHandler.PatchComment, comment.ID, `"1"`, `{"text":"Lost"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, patch(commentHandler.PatchComment, comment.ID, `"2"`, `{"rating":5}`).Code)
+
+	orderHandler := handler.NewOrderHandler(orders, nil)
+	rec = patch(orderHandler.PatchOrder, order.ID, `"1"`, `{"status":"shipped"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	var gotOrder handler.OrderResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&gotOrder))
+	assert.Equal(t, "shipped", gotOrder.Status)
+	assert.Equal(t, money.MustParse("100"), gotOrder.Total)
+	assert.Equal(t, http.StatusPreconditionFailed, patch(orderHandler.PatchOrder, order.ID, `"1"`, `{"status":"cancelled"}`).Code)
+
+	stored, err := orders.GetOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "shipped", stored.Status)
+	assert.Equal(t, 2, stored.Version)
+}
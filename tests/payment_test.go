@@ -0,0 +1,328 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/payment"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+func TestFakeProviderWebhookSignature(t *testing.T) {
+	fake := payment.NewFakeProvider(testWebhookSecret, "https://shop.test/pay")
+	created, err := fake.CreatePayment(context.Background(), payment.CreateRequest{
+		Amount: money.MustParse("150"), Currency: "RUB", IdempotenceKey: "key-1", Metadata: map[string]string{"payment_id": "5"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, payment.StatusPending, created.Status)
+	assert.Equal(t, "https://shop.test/pay?payment="+created.ID, created.ConfirmationURL)
+
+	again, err := fake.CreatePayment(context.Background(), payment.CreateRequest{Amount: money.MustParse("150"), Currency: "RUB", IdempotenceKey: "key-1"})
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, again.ID, "same idempotence key returns the same payment")
+
+	body, signature, err := fake.Complete(created.ID, payment.StatusSucceeded)
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set(payment.SignatureHeader, signature)
+	event, err := fake.ParseWebhook(context.Background(), header, body)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, event.PaymentID)
+	assert.Equal(t, payment.StatusSucceeded, event.Status)
+	assert.Equal(t, "5", event.Metadata["payment_id"])
+
+	tampered := bytes.Replace(body, []byte("succeeded"), []byte("canceled!"), 1)
+	_, err = fake.ParseWebhook(context.Background(), header, tampered)
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+	_, err = fake.ParseWebhook(context.Background(), http.Header{}, body)
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+
+	refund := []byte(`{"type":"notification","event":"refund.succeeded","object":{"id":"r1","status":"succeeded"}}`)
+	header.Set(payment.SignatureHeader, payment.Sign([]byte(testWebhookSecret), refund))
+	event, err = fake.ParseWebhook(context.Background(), header, refund)
+	require.NoError(t, err)
+	assert.Nil(t, event, "notifications that are not about payments are ignored")
+}
+
+func TestYooKassaProviderRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "shop" || pass != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v3/payments":
+			assert.Equal(t, "idem-1", r.Header.Get("Idempotence-Key"))
+			var req map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, map[string]interface{}{"value": "99.90", "currency": "RUB"}, req["amount"])
+			assert.Equal(t, true, req["capture"])
+			w.Write([]byte(`{"id":"yk-1","status":"pending","amount":{"value":"99.90","currency":"RUB"},
+				"confirmation":{"type":"redirect","confirmation_url":"https://yoomoney.test/checkout/yk-1"}}`))
+		case r.Method == "GET" && r.URL.Path == "/v3/payments/yk-1":
+			w.Write([]byte(`{"id":"yk-1","status":"succeeded","amount":{"value":"99.90","currency":"RUB"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	provider := payment.NewYooKassaProvider(srv.URL+"/v3", "shop", "key")
+	ctx := context.Background()
+
+	created, err := provider.CreatePayment(ctx, payment.CreateRequest{Amount: money.MustParse("99.90"), Currency: "RUB", IdempotenceKey: "idem-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "yk-1", created.ID)
+	assert.Equal(t, "https://yoomoney.test/checkout/yk-1", created.ConfirmationURL)
+
+	current, err := provider.GetPayment(ctx, "yk-1")
+	require.NoError(t, err)
+	assert.Equal(t, payment.StatusSucceeded, current.Status)
+	assert.Equal(t, money.Money{Amount: 9990, Currency: "RUB"}, current.Amount)
+
+	_, err = provider.GetPayment(ctx, "missing")
+	assert.ErrorIs(t, err, payment.ErrNotFound)
+}
+
+func TestYooKassaWebhookTrustsOnlyAPI(t *testing.T) {
+	fetched := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		if r.Method == "GET" && r.URL.Path == "/v3/payments/yk-1" {
+			w.Write([]byte(`{"id":"yk-1","status":"pending","amount":{"value":"99.90","currency":"RUB"},"metadata":{"payment_id":"7"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	provider := payment.NewYooKassaProvider(srv.URL+"/v3", "shop", "key")
+	ctx := context.Background()
+
+	// Настоящие уведомления YooKassa не подписаны, а их тело может быть подделано
+	forged := []byte(`{"type":"notification","event":"payment.succeeded",
+		"object":{"id":"yk-1","status":"succeeded","amount":{"value":"1.00","currency":"RUB"},"metadata":{"payment_id":"8"}}}`)
+	event, err := provider.ParseWebhook(ctx, http.Header{}, forged)
+	require.NoError(t, err)
+	assert.Equal(t, "yk-1", event.PaymentID)
+	assert.Equal(t, payment.StatusPending, event.Status, "status comes from the API")
+	assert.Equal(t, money.Money{Amount: 9990, Currency: "RUB"}, event.Amount, "amount comes from the API")
+	assert.Equal(t, "7", event.Metadata["payment_id"])
+
+	unknown := []byte(`{"type":"notification","event":"payment.succeeded","object":{"id":"yk-404","status":"succeeded"}}`)
+	_, err = provider.ParseWebhook(ctx, http.Header{}, unknown)
+	assert.ErrorIs(t, err, payment.ErrInvalidNotification)
+
+	fetched = 0
+	refund := []byte(`{"type":"notification","event":"refund.succeeded","object":{"id":"r1","status":"succeeded"}}`)
+	event, err = provider.ParseWebhook(ctx, http.Header{}, refund)
+	require.NoError(t, err)
+	assert.Nil(t, event)
+	assert.Zero(t, fetched, "notifications that are not about payments are not verified")
+}
+
+func TestPaymentWebhookRejectsInvalidSignature(t *testing.T) {
+	fake := payment.NewFakeProvider(testWebhookSecret, "https://shop.test/pay")
+	h := handler.NewPaymentHandler(service.NewPaymentService(nil, nil, fake, service.PaymentOptions{Timeout: time.Second}))
+
+	body := []byte(`{"type":"notification","event":"payment.succeeded","object":{"id":"fake-1","status":"succeeded"}}`)
+	for name, signature := range map[string]string{
+		"missing": "",
+		"wrong":   payment.Sign([]byte("other-secret"), body),
+	} {
+		req := httptest.NewRequest("POST", "/api/v1/payments/webhook", bytes.NewReader(body))
+		req.Header.Set(payment.SignatureHeader, signature)
+		rec := httptest.NewRecorder()
+		h.Webhook(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, name)
+	}
+
+	// С пустым ключом подпись может посчитать кто угодно
+	unsigned := payment.NewFakeProvider("", "https://shop.test/pay")
+	_, err := unsigned.ParseWebhook(context.Background(), http.Header{payment.SignatureHeader: {payment.Sign(nil, body)}}, body)
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+}
+
+func TestPaymentWebhookRejectsAmountMismatch(t *testing.T) {
+	f := newPaymentFixture(t, time.Second)
+	p, err := f.payments.PayOrder(f.ctx, f.user.ID, f.order.ID)
+	require.NoError(t, err)
+
+	for name, amount := range map[string]string{
+		"amount":   `{"value":"1.00","currency":"RUB"}`,
+		"currency": `{"value":"250.50","currency":"USD"}`,
+		"missing":  `{}`,
+	} {
+		body := []byte(fmt.Sprintf(`{"type":"notification","event":"payment.succeeded","object":{"id":%q,"status":"succeeded","amount":%s}}`,
+			p.ProviderPaymentID, amount))
+		req := httptest.NewRequest("POST", "/api/v1/payments/webhook", bytes.NewReader(body))
+		req.Header.Set(payment.SignatureHeader, payment.Sign([]byte(testWebhookSecret), body))
+		rec := httptest.NewRecorder()
+		f.handler.Webhook(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+	}
+	order, err := f.orders.GetOrder(f.ctx, f.order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusNew, order.Status, "order stays unpaid")
+
+	require.Equal(t, http.StatusOK, f.deliver(t, p.ProviderPaymentID, payment.StatusSucceeded))
+	order, err = f.orders.GetOrder(f.ctx, f.order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusPaid, order.Status)
+}
+
+// paymentFixture — заказ покупателя и сервис оплаты с фиктивным провайдером.
+type paymentFixture struct {
+	ctx      context.Context
+	fake     *payment.FakeProvider
+	payments *service.PaymentService
+	orders   *service.OrderService
+	handler  *handler.PaymentHandler
+	user     *models.User
+	order    *models.Order
+}
+
+func newPaymentFixture(t *testing.T, timeout time.Duration) *paymentFixture {
+	db, teardown := setupTestDB(t)
+	t.Cleanup(teardown)
+	redisClient := setupTestRedis(t)
+	t.Cleanup(func() { redisClient.Close() })
+
+	f := &paymentFixture{ctx: context.Background(), fake: payment.NewFakeProvider(testWebhookSecret, "https://shop.test/pay")}
+	users := service.NewUserService(repository.NewUserRepository(db, redisClient, testCacheTTL))
+	orderRepo := repository.NewOrderRepository(db, redisClient, testCacheTTL)
+	f.orders = service.NewOrderService(orderRepo)
+	f.payments = service.NewPaymentService(repository.NewPaymentRepository(db, redisClient), orderRepo, f.fake,
+		service.PaymentOptions{ReturnURL: "https://shop.test/orders", Timeout: timeout})
+	f.handler = handler.NewPaymentHandler(f.payments)
+
+	f.user = &models.User{Email: fmt.Sprintf("pay-%d@example.com", time.Now().UnixNano()), Name: "Buyer", Password: "password123", Role: "user"}
+	require.NoError(t, users.CreateUser(f.ctx, f.user))
+	f.order = &models.Order{UserID: f.user.ID, Total: money.MustParse("250.50"), Status: "new"}
+	require.NoError(t, f.orders.CreateOrder(f.ctx, f.order))
+	return f
+}
+
+// deliver отправляет подписанное уведомление о платеже в обработчик вебхука.
+func (f *paymentFixture) deliver(t *testing.T, providerPaymentID, status string) int {
+	body, signature, err := f.fake.Complete(providerPaymentID, status)
+	require.NoError(t, err)
+	req := httptest.NewRequest("POST", "/api/v1/payments/webhook", bytes.NewReader(body))
+	req.Header.Set(payment.SignatureHeader, signature)
+	rec := httptest.NewRecorder()
+	f.handler.Webhook(rec, req)
+	return rec.Code
+}
+
+func TestPayOrderSuccessIsIdempotent(t *testing.T) {
+	f := newPaymentFixture(t, time.Second)
+
+	// Чужой заказ оплатить нельзя
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/", nil), map[string]string{"id": fmt.Sprint(f.order.ID)})
+	rec := httptest.NewRecorder()
+	f.handler.PayOrder(rec, req.WithContext(context.WithValue(req.Context(), handler.UserIDKey, f.user.ID+100000)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	f.handler.PayOrder(rec, req.WithContext(context.WithValue(req.Context(), handler.UserIDKey, f.user.ID)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var started handler.PaymentResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&started))
+	assert.Equal(t, models.PaymentStatusPending, started.Status)
+	assert.Equal(t, money.MustParse("250.50"), started.Amount)
+	assert.NotEmpty(t, started.ConfirmationURL)
+
+	again, err := f.payments.PayOrder(f.ctx, f.user.ID, f.order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, started.ID, again.ID, "pending payment is reused")
+
+	require.Equal(t, http.StatusOK, f.deliver(t, again.ProviderPaymentID, payment.StatusSucceeded))
+	paid, err := f.orders.GetOrder(f.ctx, f.order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusPaid, paid.Status)
+
+	// Повторное уведомление принимается, но ничего не меняет
+	require.Equal(t, http.StatusOK, f.deliver(t, again.ProviderPaymentID, payment.StatusSucceeded))
+	same, err := f.orders.GetOrder(f.ctx, f.order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, paid.Version, same.Version)
+
+	_, err = f.payments.PayOrder(f.ctx, f.user.ID, f.order.ID)
+	assert.ErrorIs(t, err, service.ErrOrderAlreadyPaid)
+
+	refunded, err := f.payments.RefundOrder(f.ctx, f.order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusRefunded, refunded.Status)
+	amount, ok := f.fake.Refunded(again.ProviderPaymentID)
+	require.True(t, ok)
+	assert.Equal(t, money.MustParse("250.50"), amount)
+	order, err := f.orders.GetOrder(f.ctx, f.order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusRefunded, order.Status)
+}
+
+func TestPayOrderFailureAndCancel(t *testing.T) {
+	f := newPaymentFixture(t, time.Second)
+
+	f.fake.FailNext(errors.New("card declined"))
+	_, err := f.payments.PayOrder(f.ctx, f.user.ID, f.order.ID)
+	assert.ErrorIs(t, err, service.ErrPaymentFailed)
+
+	// После отказа провайдера создаётся новая попытка
+	p, err := f.payments.PayOrder(f.ctx, f.user.ID, f.order.ID)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, f.deliver(t, p.ProviderPaymentID, payment.StatusCanceled))
+
+	order, err := f.orders.GetOrder(f.ctx, f.order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "new", order.Status)
+	_, err = f.payments.RefundOrder(f.ctx, f.order.ID)
+	assert.ErrorIs(t, err, service.ErrNothingToRefund)
+
+	retry, err := f.payments.PayOrder(f.ctx, f.user.ID, f.order.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, p.ID, retry.ID)
+}
+
+func TestPayOrderTimeoutRetriesWithSameKey(t *testing.T) {
+	f := newPaymentFixture(t, 20*time.Millisecond)
+
+	f.fake.SetDelay(time.Second)
+	_, err := f.payments.PayOrder(f.ctx, f.user.ID, f.order.ID)
+	assert.ErrorIs(t, err, service.ErrPaymentTimeout)
+
+	f.fake.SetDelay(0)
+	first, err := f.payments.PayOrder(f.ctx, f.user.ID, f.order.ID)
+	require.NoError(t, err)
+	second, err := f.payments.PayOrder(f.ctx, f.user.ID, f.order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, first.ProviderPaymentID, second.ProviderPaymentID)
+
+	// Провайдер подтвердил оплату, но уведомление потерялось: статус уточняется при повторном запросе
+	_, _, err = f.fake.Complete(first.ProviderPaymentID, payment.StatusSucceeded)
+	require.NoError(t, err)
+	_, err = f.payments.PayOrder(f.ctx, f.user.ID, f.order.ID)
+	assert.ErrorIs(t, err, service.ErrOrderAlreadyPaid)
+	order, err := f.orders.GetOrder(f.ctx, f.order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OrderStatusPaid, order.Status)
+}
@@ -0,0 +1,459 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/alex-pyslar/petelka-api/internal/storage"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStoreOnlyPhotoService создаёт сервис фотографий без базы данных и Redis
+// для сценариев, которые обращаются только к хранилищу.
+func newStoreOnlyPhotoService(t *testing.T) (*service.PhotoService, *storage.LocalStore) {
+	store, _ := setupLocalStore(t)
+	photoRepo := repository.NewPhotoRepository(nil, store, nil)
+	return service.NewPhotoService(photoRepo, time.Minute), store
+}
+
+func TestPhotoServiceOpen(t *testing.T) {
+	photoService, store := newStoreOnlyPhotoService(t)
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "photo.jpg", strings.NewReader("jpeg data"), 9, "image/jpeg"))
+
+	obj, err := photoService.Open(ctx, "photo.jpg")
+	require.NoError(t, err)
+	defer obj.Reader.Close()
+	assert.Equal(t, int64(9), obj.Size)
+	assert.Equal(t, "image/jpeg", obj.ContentType)
+
+	_, err = photoService.Open(ctx, "missing.jpg")
+	assert.ErrorIs(t, err, service.ErrPhotoNotFound)
+}
+
+func TestPhotoServiceShortLivedURL(t *testing.T) {
+	photoService, store := newStoreOnlyPhotoService(t)
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "photo.png", strings.NewReader("png data"), 8, "image/png"))
+
+	url, err := photoService.GetShortLivedURL(ctx, "photo.png")
+	require.NoError(t, err)
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "png data", string(body))
+}
+
+func TestPhotoDownloadURLMode(t *testing.T) {
+	photoService, store, mr := newDirectUploadService(t, unreachableDB(t))
+	h := handler.NewPhotoHandler(photoService, handler.PhotoDelivery{Mode: "url"})
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "photo.jpg", strings.NewReader("jpeg data"), 9, "image/jpeg"))
+
+	download := func(objectName string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/photos/"+objectName, nil), map[string]string{"objectName": objectName})
+		rec := httptest.NewRecorder()
+		h.Download(rec, req)
+		return rec
+	}
+
+	rec := download("photo.jpg")
+	require.Equal(t, http.StatusOK, rec.Code)
+	url := rec.Body.String()
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	// Ссылка кэшируется на половину срока жизни
+	assert.InDelta(t, 30*time.Second, mr.TTL("photo_url:photo.jpg"), float64(time.Second))
+	assert.Equal(t, url, download("photo.jpg").Body.String())
+
+	assert.Equal(t, http.StatusNotFound, download("missing.jpg").Code)
+	assert.False(t, mr.Exists("photo_url:missing.jpg"), "missing objects are not cached")
+}
+
+func TestPhotoDownloadRedirectMode(t *testing.T) {
+	photoService, store := newStoreOnlyPhotoService(t)
+	h := handler.NewPhotoHandler(photoService, handler.PhotoDelivery{Mode: "redirect"})
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "photo.jpg", strings.NewReader("jpeg data"), 9, "image/jpeg"))
+
+	download := func(objectName string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/api/v1/photos/"+objectName, nil), map[string]string{"objectName": objectName})
+		rec := httptest.NewRecorder()
+		h.Download(rec, req)
+		return rec
+	}
+
+	rec := download("photo.jpg")
+	require.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	resp, err := http.Get(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	rec = download("missing.jpg")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+}
+
+func TestPhotoServiceUploadValidation(t *testing.T) {
+	photoService, _ := newStoreOnlyPhotoService(t)
+	ctx := context.Background()
+
+	_, _, err := photoService.Upload(ctx, strings.NewReader("x"), 1, "script.exe", "application/octet-stream", 1)
+	assert.Error(t, err)
+
+	_, _, err = photoService.Upload(ctx, strings.NewReader(""), 0, "photo.jpg", "image/jpeg", 1)
+	assert.Error(t, err)
+
+	_, _, err = photoService.Upload(ctx, strings.NewReader("x"), 64<<20, "photo.jpg", "image/jpeg", 1)
+	assert.Error(t, err)
+}
+
+func TestPhotoServiceUpload(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	redisClient := setupTestRedis(t)
+	defer redisClient.Close()
+
+	store, _ := setupLocalStore(t)
+	photoRepo := repository.NewPhotoRepository(db, store, redisClient)
+	photoService := service.NewPhotoService(photoRepo, time.Minute)
+
+	objectName, url, err := photoService.Upload(context.Background(), strings.NewReader("jpeg data"), 9, "photo.jpg", "image/jpeg", 0)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, url)
+
+	obj, err := photoService.Open(context.Background(), objectName)
+	require.NoError(t, err)
+	obj.Reader.Close()
+	assert.Equal(t, int64(9), obj.Size)
+}
+
+// newDirectUploadService создаёт сервис фотографий с локальным хранилищем и miniredis
+// для сценариев прямой загрузки. db может быть недоступной базой.
+func newDirectUploadService(t *testing.T, db *sql.DB) (*service.PhotoService, *storage.LocalStore, *miniredis.Miniredis) {
+	store, _ := setupLocalStore(t)
+	mr, client := setupMiniRedis(t)
+	return service.NewPhotoService(repository.NewPhotoRepository(db, store, client), time.Minute), store, mr
+}
+
+func uploadByPolicy(t *testing.T, upload *service.UploadURL, contentType string, data []byte) {
+	resp := postUploadForm(t, &storage.UploadPolicy{URL: upload.URL, FormData: upload.FormData}, contentType, data)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestConfirmUploadRejectsUnknownAndForeignUploads(t *testing.T) {
+	photoService, _, mr := newDirectUploadService(t, unreachableDB(t))
+	ctx := context.Background()
+
+	_, err := photoService.ConfirmUpload(ctx, "unknown.jpg", 1)
+	assert.ErrorIs(t, err, service.ErrPhotoNotFound)
+
+	upload, err := photoService.CreateUploadURL(ctx, "photo.jpg", 9, 1)
+	require.NoError(t, err)
+	_, err = photoService.ConfirmUpload(ctx, upload.ObjectName, 2)
+	assert.ErrorIs(t, err, service.ErrPhotoNotFound, "upload requested by another user")
+
+	// Файл ещё не загружен: запись сохраняется до загрузки
+	_, err = photoService.ConfirmUpload(ctx, upload.ObjectName, 1)
+	assert.ErrorIs(t, err, service.ErrPhotoNotFound)
+	assert.True(t, mr.Exists("photo_upload:"+upload.ObjectName))
+
+	mr.FastForward(3 * time.Minute)
+	_, err = photoService.ConfirmUpload(ctx, upload.ObjectName, 1)
+	assert.ErrorIs(t, err, service.ErrPhotoNotFound, "expired upload")
+}
+
+func TestConfirmUploadRemovesInvalidObjects(t *testing.T) {
+	photoService, store, mr := newDirectUploadService(t, unreachableDB(t))
+	ctx := context.Background()
+
+	_, err := photoService.CreateUploadURL(ctx, "script.exe", 9, 1)
+	assert.Error(t, err)
+	_, err = photoService.CreateUploadURL(ctx, "photo.jpg", 64<<20, 1)
+	assert.Error(t, err)
+
+	// Неверный Content-Type отклоняет сама политика загрузки, а объекты с неверным
+	// размером могли быть записаны в хранилище в обход неё
+	for name, size := range map[string]int64{"empty": 0, "oversized": 32<<20 + 1} {
+		upload, err := photoService.CreateUploadURL(ctx, "photo.jpg", 9, 1)
+		require.NoError(t, err)
+		require.NoError(t, store.Put(ctx, upload.ObjectName, io.LimitReader(zeroReader{}, size), size, "image/jpeg"))
+
+		_, err = photoService.ConfirmUpload(ctx, upload.ObjectName, 1)
+		assert.ErrorIs(t, err, service.ErrInvalidUpload, name)
+		_, err = store.Stat(ctx, upload.ObjectName)
+		assert.ErrorIs(t, err, storage.ErrNotFound, name)
+		assert.False(t, mr.Exists("photo_upload:"+upload.ObjectName), name)
+	}
+}
+
+func TestConfirmUploadKeepsPendingRecordOnFailure(t *testing.T) {
+	photoService, store, mr := newDirectUploadService(t, unreachableDB(t))
+	ctx := context.Background()
+
+	upload, err := photoService.CreateUploadURL(ctx, "photo.jpg", 9, 1)
+	require.NoError(t, err)
+	uploadByPolicy(t, upload, "image/jpeg", []byte("jpeg data"))
+
+	// База недоступна: объект и запись остаются, подтверждение можно повторить
+	_, err = photoService.ConfirmUpload(ctx, upload.ObjectName, 1)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, service.ErrPhotoNotFound)
+	_, err = store.Stat(ctx, upload.ObjectName)
+	assert.NoError(t, err)
+	assert.True(t, mr.Exists("photo_upload:"+upload.ObjectName))
+}
+
+func TestConfirmUpload(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	photoService, _, mr := newDirectUploadService(t, db)
+	ctx := context.Background()
+
+	upload, err := photoService.CreateUploadURL(ctx, "Photo.PNG", 8, 0)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(upload.ObjectName, ".png"))
+	assert.Equal(t, int64(32<<20), upload.MaxSize)
+	uploadByPolicy(t, upload, "image/png", []byte("png data"))
+
+	photo, err := photoService.ConfirmUpload(ctx, upload.ObjectName, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), photo.Size)
+	assert.Equal(t, "image/png", photo.ContentType)
+	assert.False(t, mr.Exists("photo_upload:"+upload.ObjectName))
+
+	_, err = photoService.ConfirmUpload(ctx, upload.ObjectName, 0)
+	assert.ErrorIs(t, err, service.ErrPhotoNotFound, "confirming twice")
+}
+
+// zeroReader бесконечно отдаёт нулевые байты.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// photoHandlerFixture — обработчик фотографий с локальным хранилищем, базой и товарами.
+type photoHandlerFixture struct {
+	ctx      context.Context
+	store    *storage.LocalStore
+	photos   *service.PhotoService
+	products *service.ProductService
+	handler  *handler.PhotoHandler
+}
+
+func newPhotoHandlerFixture(t *testing.T) *photoHandlerFixture {
+	db, teardown := setupTestDB(t)
+	t.Cleanup(teardown)
+	store, _ := setupLocalStore(t)
+	_, client := setupMiniRedis(t)
+
+	f := &photoHandlerFixture{ctx: context.Background(), store: store}
+	f.photos = service.NewPhotoService(repository.NewPhotoRepository(db, store, client), time.Minute)
+	f.products = service.NewProductService(repository.NewProductRepository(db, client, testCacheTTL))
+	f.handler = handler.NewPhotoHandler(f.photos, handler.PhotoDelivery{Mode: "redirect"})
+	return f
+}
+
+// upload загружает фотографии и возвращает их имена по порядку листинга.
+func (f *photoHandlerFixture) upload(t *testing.T, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		name, _, err := f.photos.Upload(f.ctx, strings.NewReader("jpeg data"), 9, "photo.jpg", "image/jpeg", 0)
+		require.NoError(t, err)
+		names[i] = name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// useInProduct создаёт товар, в изображениях которого есть ссылка на фотографию.
+func (f *photoHandlerFixture) useInProduct(t *testing.T, objectName string) *models.Product {
+	product := &models.Product{Name: "Пряжа с фото", Price: money.MustParse("100"), Images: []string{"/api/v1/photos/" + objectName},
+		Type: "yarn", Composition: "100% хлопок", CountryOfOrigin: "Турция", LengthIn100g: 300, Color: "синий", Weight: 100}
+	require.NoError(t, f.products.CreateProduct(f.ctx, product))
+	t.Cleanup(func() { f.products.DeleteProduct(f.ctx, product.ID) })
+	return product
+}
+
+func (f *photoHandlerFixture) list(t *testing.T, query string) (int, handler.PhotoListResponse) {
+	rec := httptest.NewRecorder()
+	f.handler.ListPhotos(rec, httptest.NewRequest("GET", "/api/v1/photos?"+query, nil))
+	var resp handler.PhotoListResponse
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	}
+	return rec.Code, resp
+}
+
+func (f *photoHandlerFixture) delete(objectName, query string) int {
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/api/v1/photos/"+objectName+"?"+query, nil),
+		map[string]string{"objectName": objectName})
+	rec := httptest.NewRecorder()
+	f.handler.DeletePhoto(rec, req)
+	return rec.Code
+}
+
+func TestListPhotosPaginates(t *testing.T) {
+	f := newPhotoHandlerFixture(t)
+	names := f.upload(t, 3)
+	product := f.useInProduct(t, names[0])
+
+	code, page := f.list(t, "limit=2")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Photos, 2)
+	assert.Equal(t, names[0], page.Photos[0].ObjectName)
+	assert.Equal(t, []int{product.ID}, page.Photos[0].ProductIDs)
+	assert.Equal(t, "image/jpeg", page.Photos[0].ContentType)
+	assert.Equal(t, []int{}, page.Photos[1].ProductIDs)
+	assert.Equal(t, names[1], page.NextAfter)
+
+	code, page = f.list(t, "limit=2&after="+page.NextAfter)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Photos, 1)
+	assert.Equal(t, names[2], page.Photos[0].ObjectName)
+	assert.Empty(t, page.NextAfter)
+
+	code, _ = f.list(t, "limit=abc")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestDeletePhotoChecksProductReferences(t *testing.T) {
+	f := newPhotoHandlerFixture(t)
+	names := f.upload(t, 1)
+	f.useInProduct(t, names[0])
+
+	assert.Equal(t, http.StatusConflict, f.delete(names[0], ""))
+	_, err := f.store.Stat(f.ctx, names[0])
+	require.NoError(t, err, "referenced photo is kept")
+
+	assert.Equal(t, http.StatusNoContent, f.delete(names[0], "force=true"))
+	_, err = f.store.Stat(f.ctx, names[0])
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	assert.Equal(t, http.StatusNotFound, f.delete(names[0], "force=true"))
+}
+
+func TestBulkDeletePhotosReportsPartialResults(t *testing.T) {
+	f := newPhotoHandlerFixture(t)
+	names := f.upload(t, 2)
+	f.useInProduct(t, names[1])
+
+	body, err := json.Marshal(handler.BulkDeleteRequest{ObjectNames: []string{names[0], names[1], "missing.jpg"}})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	f.handler.BulkDeletePhotos(rec, httptest.NewRequest("POST", "/api/v1/photos/bulk-delete", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var result service.BulkDeleteResult
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&result))
+	assert.Equal(t, []string{names[0]}, result.Deleted)
+	require.Len(t, result.Failed, 2)
+	assert.Equal(t, names[1], result.Failed[0].ObjectName)
+	assert.Contains(t, result.Failed[0].Error, "referenced by products")
+	assert.Equal(t, "missing.jpg", result.Failed[1].ObjectName)
+
+	_, err = f.store.Stat(f.ctx, names[1])
+	assert.NoError(t, err, "referenced photo is kept")
+}
+
+func TestBulkDeletePhotosValidatesRequest(t *testing.T) {
+	h := handler.NewPhotoHandler(nil, handler.PhotoDelivery{})
+	tooMany := make([]string, 101)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("photo-%d.jpg", i)
+	}
+	tooManyBody, err := json.Marshal(handler.BulkDeleteRequest{ObjectNames: tooMany})
+	require.NoError(t, err)
+
+	for name, body := range map[string]string{
+		"invalid json": "{",
+		"empty":        `{"objectNames": []}`,
+		"too many":     string(tooManyBody),
+	} {
+		rec := httptest.NewRecorder()
+		h.BulkDeletePhotos(rec, httptest.NewRequest("POST", "/api/v1/photos/bulk-delete", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, name)
+	}
+}
+
+// downloadPhoto выполняет GET /photos/{objectName} с заголовками headers.
+func downloadPhoto(h *handler.PhotoHandler, objectName string, headers map[string]string) *httptest.ResponseRecorder {
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/photos/"+objectName, nil), map[string]string{"objectName": objectName})
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	h.Download(rec, req)
+	return rec
+}
+
+func TestPhotoDownloadProxyMode(t *testing.T) {
+	photoService, store := newStoreOnlyPhotoService(t)
+	h := handler.NewPhotoHandler(photoService, handler.PhotoDelivery{Mode: handler.PhotoDeliveryProxy, CacheMaxAge: time.Hour})
+	require.NoError(t, store.Put(context.Background(), "photo.jpg", strings.NewReader("jpeg data"), 9, "image/jpeg"))
+
+	rec := downloadPhoto(h, "photo.jpg", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "jpeg data", rec.Body.String())
+	assert.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=3600", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+	assert.NotEmpty(t, rec.Header().Get("Last-Modified"))
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	rec = downloadPhoto(h, "photo.jpg", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = downloadPhoto(h, "photo.jpg", map[string]string{"Range": "bytes=5-8"})
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "data", rec.Body.String())
+	assert.Equal(t, "bytes 5-8/9", rec.Header().Get("Content-Range"))
+
+	rec = downloadPhoto(h, "photo.jpg", map[string]string{"Range": "bytes=100-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rec.Code)
+
+	assert.Equal(t, http.StatusNotFound, downloadPhoto(h, "missing.jpg", nil).Code)
+}
+
+func TestPhotoUploadHandlersValidateRequest(t *testing.T) {
+	h := handler.NewPhotoHandler(nil, handler.PhotoDelivery{})
+
+	rec := httptest.NewRecorder()
+	h.UploadURL(rec, httptest.NewRequest("POST", "/api/photos/upload-url", strings.NewReader("{")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ConfirmUpload(rec, httptest.NewRequest("POST", "/api/photos//confirm", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -3,6 +3,7 @@ package tests
 import (
 	"context"
 	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
 	"github.com/alex-pyslar/petelka-api/internal/repository"
 	"github.com/alex-pyslar/petelka-api/internal/service"
 	"testing"
@@ -16,13 +17,13 @@ func TestCreateProduct(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	productRepo := repository.NewProductRepository(db, redisClient)
+	productRepo := repository.NewProductRepository(db, redisClient, testCacheTTL)
 	productService := service.NewProductService(productRepo)
 
 	product := &models.Product{
 		Name:        "Test Product",
 		Description: "Test Description",
-		Price:       100.0,
+		Price:       money.MustParse("100"),
 		CategoryID:  1,
 	}
 
@@ -37,13 +38,13 @@ func TestGetProduct(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	productRepo := repository.NewProductRepository(db, redisClient)
+	productRepo := repository.NewProductRepository(db, redisClient, testCacheTTL)
 	productService := service.NewProductService(productRepo)
 
 	product := &models.Product{
 		Name:        "Test Product 2",
 		Description: "Test Description 2",
-		Price:       200.0,
+		Price:       money.MustParse("200"),
 		CategoryID:  1,
 	}
 	productService.CreateProduct(context.Background(), product)
@@ -59,11 +60,11 @@ func TestListProducts(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	productRepo := repository.NewProductRepository(db, redisClient)
+	productRepo := repository.NewProductRepository(db, redisClient, testCacheTTL)
 	productService := service.NewProductService(productRepo)
 
-	product1 := &models.Product{Name: "Product 1", Description: "Desc 1", Price: 100.0, CategoryID: 1}
-	product2 := &models.Product{Name: "Product 2", Description: "Desc 2", Price: 200.0, CategoryID: 1}
+	product1 := &models.Product{Name: "Product 1", Description: "Desc 1", Price: money.MustParse("100"), CategoryID: 1}
+	product2 := &models.Product{Name: "Product 2", Description: "Desc 2", Price: money.MustParse("200"), CategoryID: 1}
 	productService.CreateProduct(context.Background(), product1)
 	productService.CreateProduct(context.Background(), product2)
 
@@ -78,10 +79,10 @@ func TestUpdateProduct(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	productRepo := repository.NewProductRepository(db, redisClient)
+	productRepo := repository.NewProductRepository(db, redisClient, testCacheTTL)
 	productService := service.NewProductService(productRepo)
 
-	product := &models.Product{Name: "Update Product", Description: "Old Desc", Price: 100.0, CategoryID: 1}
+	product := &models.Product{Name: "Update Product", Description: "Old Desc", Price: money.MustParse("100"), CategoryID: 1}
 	productService.CreateProduct(context.Background(), product)
 
 	product.Description = "New Desc"
@@ -99,10 +100,10 @@ func TestDeleteProduct(t *testing.T) {
 	redisClient := setupTestRedis(t)
 	defer redisClient.Close()
 
-	productRepo := repository.NewProductRepository(db, redisClient)
+	productRepo := repository.NewProductRepository(db, redisClient, testCacheTTL)
 	productService := service.NewProductService(productRepo)
 
-	product := &models.Product{Name: "Delete Product", Description: "Delete Desc", Price: 100.0, CategoryID: 1}
+	product := &models.Product{Name: "Delete Product", Description: "Delete Desc", Price: money.MustParse("100"), CategoryID: 1}
 	productService.CreateProduct(context.Background(), product)
 
 	err := productService.DeleteProduct(context.Background(), product.ID)
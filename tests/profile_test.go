@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/mailer"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testProfileOptions = service.ProfileOptions{
+	ConfirmURL: "https://petelka.shop/confirm-email",
+	TokenTTL:   time.Hour,
+}
+
+func TestConfirmEmailRejectsUnknownToken(t *testing.T) {
+	_, client := setupMiniRedis(t)
+	repo := repository.NewUserRepository(unreachableDB(t), client, testCacheTTL)
+	profile := service.NewProfileService(repo, mailer.LogMailer{}, testProfileOptions)
+	h := handler.NewMeHandler(nil, profile, nil, nil)
+
+	req := httptest.NewRequest("POST", "/api/v1/auth/email/confirm", strings.NewReader(`{"token":"nope"}`))
+	rec := httptest.NewRecorder()
+	h.ConfirmEmail(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestProfileUpdateAndEmailChange(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	redisClient := setupTestRedis(t)
+	defer redisClient.Close()
+
+	repo := repository.NewUserRepository(db, redisClient, testCacheTTL)
+	users := service.NewUserService(repo)
+	m := &fakeMailer{sent: make(chan mailer.Message, 1)}
+	profile := service.NewProfileService(repo, m, testProfileOptions)
+	ctx := context.Background()
+
+	suffix := time.Now().UnixNano()
+	user := &models.User{Email: fmt.Sprintf("me-%d@example.com", suffix), Name: "Me", Password: "password123", Role: "user"}
+	require.NoError(t, users.CreateUser(ctx, user))
+
+	name, phone, email := "New Name", "+7 (900) 123-45-67", fmt.Sprintf("new-%d@example.com", suffix)
+	updated, pending, err := profile.UpdateProfile(ctx, user.ID, service.ProfileUpdate{Name: &name, Phone: &phone, Email: &email})
+	require.NoError(t, err)
+	assert.Equal(t, "New Name", updated.Name)
+	assert.Equal(t, "+79001234567", updated.Phone)
+	assert.Equal(t, user.Email, updated.Email, "email changes only after confirmation")
+	assert.Equal(t, email, pending)
+
+	msg := <-m.sent
+	assert.Equal(t, email, msg.To)
+	link := msg.Body[strings.Index(msg.Body, "https://"):]
+	link = link[:strings.IndexByte(link, '\n')]
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+
+	require.NoError(t, profile.ConfirmEmailChange(ctx, parsed.Query().Get("token")))
+	got, err := users.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, email, got.Email)
+	assert.ErrorIs(t, profile.ConfirmEmailChange(ctx, parsed.Query().Get("token")), service.ErrInvalidEmailToken)
+
+	assert.ErrorIs(t, profile.ChangePassword(ctx, user.ID, "wrong", "new-password"), service.ErrWrongPassword)
+	require.NoError(t, profile.ChangePassword(ctx, user.ID, "password123", "new-password"))
+	require.NoError(t, users.VerifyPassword(ctx, user.ID, "new-password"))
+
+	require.NoError(t, profile.DeleteAccount(ctx, user.ID, "new-password"))
+}
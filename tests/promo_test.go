@@ -0,0 +1,294 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateDiscount(t *testing.T) {
+	items := []service.DiscountItem{
+		{ProductID: 1, CategoryID: 10, Amount: money.MustParse("1000")},
+		{ProductID: 2, CategoryID: 20, Amount: money.MustParse("500")},
+	}
+
+	tests := []struct {
+		name    string
+		promo   models.PromoCode
+		want    money.Money
+		wantErr error
+	}{
+		{name: "percent of all items", promo: models.PromoCode{Kind: models.PromoPercent, PercentBP: 1000}, want: money.MustParse("150")},
+		{name: "percent of category", promo: models.PromoCode{Kind: models.PromoPercent, PercentBP: 1500, CategoryIDs: []int{20}}, want: money.MustParse("75")},
+		{name: "product or category", promo: models.PromoCode{Kind: models.PromoPercent, PercentBP: 1000, ProductIDs: []int{1}, CategoryIDs: []int{20}}, want: money.MustParse("150")},
+		{name: "fixed", promo: models.PromoCode{Kind: models.PromoFixed, Amount: money.MustParse("300")}, want: money.MustParse("300")},
+		{name: "fixed capped by eligible items", promo: models.PromoCode{Kind: models.PromoFixed, Amount: money.MustParse("800"), ProductIDs: []int{2}}, want: money.MustParse("500")},
+		{name: "free shipping", promo: models.PromoCode{Kind: models.PromoFreeShipping}, want: money.MustParse("350")},
+		{name: "minimum total reached", promo: models.PromoCode{Kind: models.PromoFixed, Amount: money.MustParse("100"), MinOrderTotal: money.MustParse("1500")}, want: money.MustParse("100")},
+		{name: "minimum total not reached", promo: models.PromoCode{Kind: models.PromoFixed, Amount: money.MustParse("100"), MinOrderTotal: money.MustParse("1500.01")}, wantErr: service.ErrPromoCodeNotApplicable},
+		{name: "no eligible items", promo: models.PromoCode{Kind: models.PromoPercent, PercentBP: 1000, CategoryIDs: []int{30}}, wantErr: service.ErrPromoCodeNotApplicable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.CalculateDiscount(&tt.promo, items, money.MustParse("350"))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAllocateDiscount(t *testing.T) {
+	items := []service.DiscountItem{
+		{ProductID: 1, CategoryID: 10, Amount: money.MustParse("100")},
+		{ProductID: 2, CategoryID: 20, Amount: money.MustParse("500")},
+		{ProductID: 3, CategoryID: 10, Amount: money.MustParse("200")},
+	}
+
+	// 100.00 на позиции 100 и 200 делится как 33.33 и 66.67, остаток — большей доле
+	promo := &models.PromoCode{Kind: models.PromoFixed, Amount: money.MustParse("100"), CategoryIDs: []int{10}}
+	assert.Equal(t, []money.Money{money.MustParse("33.33"), {}, money.MustParse("66.67")},
+		service.AllocateDiscount(promo, items, money.MustParse("100")))
+
+	free := &models.PromoCode{Kind: models.PromoFreeShipping}
+	assert.Equal(t, make([]money.Money, 3), service.AllocateDiscount(free, items, money.MustParse("350")))
+}
+
+func TestCreatePromoCodeValidation(t *testing.T) {
+	promos := service.NewPromoService(nil)
+	ctx := context.Background()
+	zero := 0
+	start := time.Now()
+	end := start.Add(-time.Hour)
+
+	for name, promo := range map[string]models.PromoCode{
+		"short code":          {Code: "ab", Kind: models.PromoFixed, Amount: money.MustParse("100")},
+		"bad characters":      {Code: "скидка", Kind: models.PromoFixed, Amount: money.MustParse("100")},
+		"unknown kind":        {Code: "SALE", Kind: "gift", Amount: money.MustParse("100")},
+		"percent over 100":    {Code: "SALE", Kind: models.PromoPercent, PercentBP: 10001},
+		"zero fixed":          {Code: "SALE", Kind: models.PromoFixed},
+		"window ends early":   {Code: "SALE", Kind: models.PromoFixed, Amount: money.MustParse("100"), StartsAt: &start, EndsAt: &end},
+		"zero max uses":       {Code: "SALE", Kind: models.PromoFixed, Amount: money.MustParse("100"), MaxUses: &zero},
+		"negative min total":  {Code: "SALE", Kind: models.PromoFixed, Amount: money.MustParse("100"), MinOrderTotal: money.MustParse("-1")},
+		"invalid category id": {Code: "SALE", Kind: models.PromoFixed, Amount: money.MustParse("100"), CategoryIDs: []int{0}},
+	} {
+		promo := promo
+		assert.ErrorIs(t, promos.CreatePromoCode(ctx, &promo), service.ErrInvalidPromoCode, name)
+	}
+}
+
+// promoFixture — покупатели, товар и сервисы для оформления заказов с промокодами.
+type promoFixture struct {
+	ctx      context.Context
+	users    []*models.User
+	product  *models.Product
+	orders   *repository.OrderRepository
+	promos   *service.PromoService
+	checkout *service.CheckoutService
+}
+
+func newPromoFixture(t *testing.T, users int) *promoFixture {
+	db, teardown := setupTestDB(t)
+	t.Cleanup(teardown)
+	redisClient := setupTestRedis(t)
+	t.Cleanup(func() { redisClient.Close() })
+
+	f := &promoFixture{ctx: context.Background()}
+	userService := service.NewUserService(repository.NewUserRepository(db, redisClient, testCacheTTL))
+	productRepo := repository.NewProductRepository(db, redisClient, testCacheTTL)
+	f.promos = service.NewPromoService(repository.NewPromoRepository(db))
+	f.orders = repository.NewOrderRepository(db, redisClient, testCacheTTL)
+	f.checkout = service.NewCheckoutService(f.orders, productRepo,
+		repository.NewAddressRepository(db), service.NewDeliveryService(repository.NewDeliveryRepository(db)), f.promos, 0)
+
+	for i := 0; i < users; i++ {
+		user := &models.User{Email: fmt.Sprintf("promo-%d-%d@example.com", time.Now().UnixNano(), i), Name: "Buyer", Password: "password123", Role: "user"}
+		require.NoError(t, userService.CreateUser(f.ctx, user))
+		f.users = append(f.users, user)
+	}
+
+	f.product = &models.Product{Name: "Альпака", Price: money.MustParse("800"), Images: []string{}, Type: "yarn", Composition: "100% альпака",
+		CountryOfOrigin: "Перу", LengthIn100g: 200, Color: "белый", Weight: 100}
+	productService := service.NewProductService(productRepo)
+	require.NoError(t, productService.CreateProduct(f.ctx, f.product))
+	t.Cleanup(func() { productService.DeleteProduct(f.ctx, f.product.ID) })
+	return f
+}
+
+func (f *promoFixture) createPromo(t *testing.T, promo *models.PromoCode) {
+	promo.Code = fmt.Sprintf("T%d", time.Now().UnixNano())
+	require.NoError(t, f.promos.CreatePromoCode(f.ctx, promo))
+	t.Cleanup(func() { f.promos.DeletePromoCode(f.ctx, promo.ID) })
+}
+
+func (f *promoFixture) order(user *models.User, code string) (*models.Order, error) {
+	return f.checkout.Checkout(f.ctx, user.ID, service.CheckoutRequest{
+		Items:     []service.CheckoutItem{{ProductID: f.product.ID, Quantity: 2}},
+		PromoCode: code,
+	})
+}
+
+func TestCheckoutAppliesPromoCodeWithPerUserLimit(t *testing.T) {
+	f := newPromoFixture(t, 2)
+	once := 1
+	promo := &models.PromoCode{Kind: models.PromoPercent, PercentBP: 2500, MaxUsesPerUser: &once, MinOrderTotal: money.MustParse("1000"), IsActive: true}
+	f.createPromo(t, promo)
+
+	order, err := f.order(f.users[0], " "+promo.Code+" ")
+	require.NoError(t, err)
+	assert.Equal(t, promo.Code, order.PromoCode)
+	assert.Equal(t, money.MustParse("400"), order.Discount)
+	assert.Equal(t, money.MustParse("1200"), order.Total)
+	require.Len(t, order.Items, 1)
+	assert.Equal(t, money.MustParse("400"), order.Items[0].Discount)
+
+	_, err = f.order(f.users[0], promo.Code)
+	assert.ErrorIs(t, err, service.ErrPromoCodeExhausted)
+
+	_, err = f.order(f.users[1], promo.Code)
+	require.NoError(t, err)
+
+	stats, err := f.promos.Stats(f.ctx, promo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Uses)
+	assert.Equal(t, 2, stats.Users)
+	assert.Equal(t, money.MustParse("800"), stats.DiscountTotal)
+	assert.Equal(t, money.MustParse("2400"), stats.OrdersTotal)
+
+	// Неактивный и просроченный промокоды не применяются
+	promo.IsActive = false
+	require.NoError(t, f.promos.UpdatePromoCode(f.ctx, promo))
+	_, err = f.order(f.users[1], promo.Code)
+	assert.ErrorIs(t, err, service.ErrPromoCodeNotApplicable)
+
+	expired := time.Now().Add(-time.Minute)
+	promo.IsActive, promo.EndsAt = true, &expired
+	require.NoError(t, f.promos.UpdatePromoCode(f.ctx, promo))
+	_, err = f.order(f.users[1], promo.Code)
+	assert.ErrorIs(t, err, service.ErrPromoCodeNotApplicable)
+}
+
+func TestPatchPromoCodeKeepsOmittedFields(t *testing.T) {
+	f := newPromoFixture(t, 0)
+	twice := 2
+	promo := &models.PromoCode{Kind: models.PromoFixed, Amount: money.MustParse("300"), MaxUses: &twice, IsActive: true}
+	f.createPromo(t, promo)
+	h := handler.NewPromoHandler(f.promos)
+	id := fmt.Sprint(promo.ID)
+
+	patch := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/v1/promo-codes/"+id, strings.NewReader(body))
+		req.Header.Set("Content-Type", handler.MergePatchContentType)
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+		h.PatchPromoCode(rec, mux.SetURLVars(req, map[string]string{"id": id}))
+		return rec
+	}
+
+	rec := patch(`"1"`, `{"is_active": false, "max_uses": null}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	var resp handler.PromoCodeResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.False(t, resp.IsActive)
+	assert.Nil(t, resp.MaxUses)
+	assert.Equal(t, promo.Code, resp.Code)
+	assert.Equal(t, money.MustParse("300"), resp.Amount)
+
+	assert.Equal(t, http.StatusPreconditionFailed, patch(`"1"`, `{"is_active": true}`).Code)
+	assert.Equal(t, http.StatusBadRequest, patch(`"2"`, `{"kind": "gift"}`).Code)
+
+	// Полное обновление с устаревшей версией тоже отклоняется
+	stale := *promo
+	stale.Version = 1
+	assert.ErrorIs(t, f.promos.UpdatePromoCode(f.ctx, &stale), service.ErrVersionConflict)
+}
+
+func TestPatchPromoCodeRequiresIfMatch(t *testing.T) {
+	h := handler.NewPromoHandler(nil)
+	req := httptest.NewRequest("PATCH", "/api/v1/promo-codes/1", strings.NewReader(`{"is_active": false}`))
+	req.Header.Set("Content-Type", handler.MergePatchContentType)
+	rec := httptest.NewRecorder()
+	h.PatchPromoCode(rec, mux.SetURLVars(req, map[string]string{"id": "1"}))
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+}
+
+// TestCreateOrderRechecksPromoCode проверяет, что промокод, выключенный, истёкший или
+// удалённый между расчётом и сохранением заказа, не применяется.
+func TestCreateOrderRechecksPromoCode(t *testing.T) {
+	f := newPromoFixture(t, 1)
+	promo := &models.PromoCode{Kind: models.PromoFixed, Amount: money.MustParse("100"), IsActive: true}
+	f.createPromo(t, promo)
+	create := func() error {
+		order := &models.Order{UserID: f.users[0].ID, Status: models.OrderStatusNew, Total: money.MustParse("1500"),
+			PromoCode: promo.Code, Discount: money.MustParse("100")}
+		return f.orders.CreateOrderWithItems(f.ctx, order, []models.OrderItem{{ProductID: f.product.ID, Quantity: 2, Price: f.product.Price}})
+	}
+
+	promo.IsActive = false
+	require.NoError(t, f.promos.UpdatePromoCode(f.ctx, promo))
+	assert.ErrorIs(t, create(), repository.ErrPromoCodeUnavailable, "inactive")
+
+	later := time.Now().Add(time.Hour)
+	promo.IsActive, promo.StartsAt = true, &later
+	require.NoError(t, f.promos.UpdatePromoCode(f.ctx, promo))
+	assert.ErrorIs(t, create(), repository.ErrPromoCodeUnavailable, "not started")
+
+	expired := time.Now().Add(-time.Minute)
+	promo.StartsAt, promo.EndsAt = nil, &expired
+	require.NoError(t, f.promos.UpdatePromoCode(f.ctx, promo))
+	assert.ErrorIs(t, create(), repository.ErrPromoCodeUnavailable, "expired")
+
+	require.NoError(t, f.promos.DeletePromoCode(f.ctx, promo.ID))
+	assert.ErrorIs(t, create(), repository.ErrPromoCodeUnavailable, "deleted")
+}
+
+func TestCheckoutPromoCodeLimitUnderConcurrency(t *testing.T) {
+	f := newPromoFixture(t, 5)
+	once := 1
+	promo := &models.PromoCode{Kind: models.PromoFixed, Amount: money.MustParse("100"), MaxUses: &once, IsActive: true}
+	f.createPromo(t, promo)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(f.users))
+	for i, user := range f.users {
+		wg.Add(1)
+		go func(i int, user *models.User) {
+			defer wg.Done()
+			_, errs[i] = f.order(user, promo.Code)
+		}(i, user)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, service.ErrPromoCodeExhausted)
+	}
+	assert.Equal(t, 1, succeeded)
+
+	stats, err := f.promos.Stats(f.ctx, promo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Uses)
+}
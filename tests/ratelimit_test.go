@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/ratelimit"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupMiniRedis поднимает Redis в памяти для тестов, которым не нужен настоящий сервер.
+func setupMiniRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func newRateLimitedHandler(client *redis.Client, policy ratelimit.Policy, failOpen bool) http.Handler {
+	limiter := ratelimit.NewLimiter(client)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	return handler.RateLimitMiddleware(limiter, policy, handler.KeyByIP(false), failOpen)(ok)
+}
+
+func requestFrom(ip string) *http.Request {
+	req := httptest.NewRequest("POST", "/api/auth/login", nil)
+	req.RemoteAddr = ip + ":12345"
+	return req
+}
+
+func TestRateLimiterSlidingWindow(t *testing.T) {
+	_, client := setupMiniRedis(t)
+	limiter := ratelimit.NewLimiter(client)
+	policy := ratelimit.Policy{Name: "test", Limit: 3, Window: time.Minute}
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		res, err := limiter.Allow(ctx, policy, "ip:1.2.3.4")
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 3-i, res.Remaining)
+	}
+
+	res, err := limiter.Allow(ctx, policy, "ip:1.2.3.4")
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.True(t, res.ResetAfter > 0 && res.ResetAfter <= time.Minute)
+
+	// Другой ключ считается отдельно
+	res, err = limiter.Allow(ctx, policy, "ip:5.6.7.8")
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestRateLimitMiddlewareHeaders(t *testing.T) {
+	_, client := setupMiniRedis(t)
+	h := newRateLimitedHandler(client, ratelimit.Policy{Name: "auth", Limit: 2, Window: time.Minute}, true)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, requestFrom("10.0.0.1"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+
+	h.ServeHTTP(httptest.NewRecorder(), requestFrom("10.0.0.1"))
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, requestFrom("10.0.0.1"))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.True(t, retryAfter >= 1 && retryAfter <= 60)
+}
+
+func TestRateLimitMiddlewareFailOpenAndClosed(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	policy := ratelimit.Policy{Name: "public", Limit: 1, Window: time.Minute}
+	mr.Close() // Redis недоступен
+
+	rec := httptest.NewRecorder()
+	newRateLimitedHandler(client, policy, true).ServeHTTP(rec, requestFrom("10.0.0.2"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	newRateLimitedHandler(client, policy, false).ServeHTTP(rec, requestFrom("10.0.0.2"))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestClientIPForwardedFor(t *testing.T) {
+	req := requestFrom("10.0.0.3")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	assert.Equal(t, "10.0.0.3", handler.ClientIP(req, false))
+	assert.Equal(t, "203.0.113.7", handler.ClientIP(req, true))
+}
+
+func TestClientIPIgnoresForgedForwardedFor(t *testing.T) {
+	// Клиент прислал поддельный адрес, прокси дописал настоящий
+	req := requestFrom("10.0.0.3")
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+	assert.Equal(t, "203.0.113.7", handler.ClientIP(req, true))
+
+	// Прокси может добавить отдельный заголовок вместо дописывания в существующий
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.Header.Add("X-Forwarded-For", "203.0.113.7")
+	assert.Equal(t, "203.0.113.7", handler.ClientIP(req, true))
+
+	// Ротация поддельного адреса не обходит лимит
+	_, client := setupMiniRedis(t)
+	policy := ratelimit.Policy{Name: "public", Limit: 1, Window: time.Minute}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	limited := handler.RateLimitMiddleware(ratelimit.NewLimiter(client), policy, handler.KeyByIP(true), false)(ok)
+	codes := make([]int, 0, 2)
+	for _, forged := range []string{"198.51.100.1", "198.51.100.2"} {
+		req := requestFrom("10.0.0.3")
+		req.Header.Set("X-Forwarded-For", forged+", 203.0.113.7")
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAPIModules создаёт модули API без сервисов: для регистрации маршрутов они не нужны.
+func newTestAPIModules() []handler.RouteRegistrar {
+	return []handler.RouteRegistrar{
+		handler.NewAuthHandler(nil, nil, handler.AuthOptions{JWTKey: testJWTKey}),
+		handler.NewMeHandler(nil, nil, nil, nil),
+		handler.NewProductHandler(nil),
+		handler.NewCategoryHandler(nil),
+		handler.NewCommentHandler(nil),
+		handler.NewOrderHandler(nil, nil),
+		handler.NewUserHandler(nil),
+		handler.NewPhotoHandler(nil, handler.PhotoDelivery{Mode: "url"}),
+		handler.NewAuditHandler(nil),
+		handler.NewPaymentHandler(nil),
+		handler.NewAddressHandler(nil),
+		handler.NewDeliveryHandler(nil),
+		handler.NewPromoHandler(nil),
+		handler.NewInvoiceHandler(nil),
+	}
+}
+
+func TestAPIRoutesAndAccessLevels(t *testing.T) {
+	router := mux.NewRouter()
+	groups := handler.MountAPI(router.PathPrefix("/api/v1").Subrouter(),
+		handler.APIOptions{JWTKey: testJWTKey}, newTestAPIModules()...)
+
+	want := []handler.RouteInfo{
+		{Method: "GET", Path: "/api/v1/admin/audit", Access: handler.AccessAdmin},
+		{Method: "POST", Path: "/api/v1/auth/email/confirm", Access: handler.AccessPublic},
+		{Method: "POST", Path: "/api/v1/auth/login", Access: handler.AccessPublic},
+		{Method: "POST", Path: "/api/v1/auth/register", Access: handler.AccessPublic},
+		{Method: "GET", Path: "/api/v1/categories", Access: handler.AccessPublic},
+		{Method: "POST", Path: "/api/v1/categories", Access: handler.AccessAdmin},
+		{Method: "DELETE", Path: "/api/v1/categories/{id}", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/categories/{id}", Access: handler.AccessPublic},
+		{Method: "PATCH", Path: "/api/v1/categories/{id}", Access: handler.AccessAdmin},
+		{Method: "PUT", Path: "/api/v1/categories/{id}", Access: handler.AccessAdmin},
+		{Method: "POST", Path: "/api/v1/comments", Access: handler.AccessProtected},
+		{Method: "DELETE", Path: "/api/v1/comments/{id}", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/comments/{id}", Access: handler.AccessPublic},
+		{Method: "PATCH", Path: "/api/v1/comments/{id}", Access: handler.AccessAdmin},
+		{Method: "PUT", Path: "/api/v1/comments/{id}", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/delivery-rates", Access: handler.AccessPublic},
+		{Method: "POST", Path: "/api/v1/delivery-rates", Access: handler.AccessAdmin},
+		{Method: "DELETE", Path: "/api/v1/delivery-rates/{id}", Access: handler.AccessAdmin},
+		{Method: "PATCH", Path: "/api/v1/delivery-rates/{id}", Access: handler.AccessAdmin},
+		{Method: "PUT", Path: "/api/v1/delivery-rates/{id}", Access: handler.AccessAdmin},
+		{Method: "DELETE", Path: "/api/v1/me", Access: handler.AccessProtected},
+		{Method: "GET", Path: "/api/v1/me", Access: handler.AccessProtected},
+		{Method: "PATCH", Path: "/api/v1/me", Access: handler.AccessProtected},
+		{Method: "GET", Path: "/api/v1/me/addresses", Access: handler.AccessProtected},
+		{Method: "POST", Path: "/api/v1/me/addresses", Access: handler.AccessProtected},
+		{Method: "DELETE", Path: "/api/v1/me/addresses/{id}", Access: handler.AccessProtected},
+		{Method: "PATCH", Path: "/api/v1/me/addresses/{id}", Access: handler.AccessProtected},
+		{Method: "PUT", Path: "/api/v1/me/addresses/{id}", Access: handler.AccessProtected},
+		{Method: "GET", Path: "/api/v1/me/orders", Access: handler.AccessProtected},
+		{Method: "POST", Path: "/api/v1/me/password", Access: handler.AccessProtected},
+		{Method: "GET", Path: "/api/v1/me/reviews", Access: handler.AccessProtected},
+		{Method: "GET", Path: "/api/v1/orders", Access: handler.AccessAdmin},
+		{Method: "POST", Path: "/api/v1/orders", Access: handler.AccessProtected},
+		{Method: "POST", Path: "/api/v1/orders/quote", Access: handler.AccessProtected},
+		{Method: "DELETE", Path: "/api/v1/orders/{id}", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/orders/{id}", Access: handler.AccessAdmin},
+		{Method: "PATCH", Path: "/api/v1/orders/{id}", Access: handler.AccessAdmin},
+		{Method: "PUT", Path: "/api/v1/orders/{id}", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/orders/{id}/invoice.pdf", Access: handler.AccessProtected},
+		{Method: "POST", Path: "/api/v1/orders/{id}/pay", Access: handler.AccessProtected},
+		{Method: "POST", Path: "/api/v1/orders/{id}/refund", Access: handler.AccessAdmin},
+		{Method: "POST", Path: "/api/v1/payments/webhook", Access: handler.AccessPublic},
+		{Method: "GET", Path: "/api/v1/photos", Access: handler.AccessAdmin},
+		{Method: "POST", Path: "/api/v1/photos", Access: handler.AccessAdmin},
+		{Method: "POST", Path: "/api/v1/photos/bulk-delete", Access: handler.AccessAdmin},
+		{Method: "POST", Path: "/api/v1/photos/upload-url", Access: handler.AccessAdmin},
+		{Method: "DELETE", Path: "/api/v1/photos/{objectName}", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/photos/{objectName}", Access: handler.AccessPublic},
+		{Method: "HEAD", Path: "/api/v1/photos/{objectName}", Access: handler.AccessPublic},
+		{Method: "POST", Path: "/api/v1/photos/{objectName}/confirm", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/products", Access: handler.AccessPublic},
+		{Method: "POST", Path: "/api/v1/products", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/products/search", Access: handler.AccessPublic},
+		{Method: "DELETE", Path: "/api/v1/products/{id}", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/products/{id}", Access: handler.AccessPublic},
+		{Method: "PATCH", Path: "/api/v1/products/{id}", Access: handler.AccessAdmin},
+		{Method: "PUT", Path: "/api/v1/products/{id}", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/promo-codes", Access: handler.AccessAdmin},
+		{Method: "POST", Path: "/api/v1/promo-codes", Access: handler.AccessAdmin},
+		{Method: "DELETE", Path: "/api/v1/promo-codes/{id}", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/promo-codes/{id}", Access: handler.AccessAdmin},
+		{Method: "PATCH", Path: "/api/v1/promo-codes/{id}", Access: handler.AccessAdmin},
+		{Method: "PUT", Path: "/api/v1/promo-codes/{id}", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/promo-codes/{id}/stats", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/users", Access: handler.AccessAdmin},
+		{Method: "DELETE", Path: "/api/v1/users/{id}", Access: handler.AccessAdmin},
+		{Method: "GET", Path: "/api/v1/users/{id}", Access: handler.AccessAdmin},
+		{Method: "PATCH", Path: "/api/v1/users/{id}", Access: handler.AccessAdmin},
+		{Method: "PUT", Path: "/api/v1/users/{id}", Access: handler.AccessAdmin},
+		{Method: "POST", Path: "/api/v1/users/{id}/block", Access: handler.AccessAdmin},
+		{Method: "POST", Path: "/api/v1/users/{id}/unblock", Access: handler.AccessAdmin},
+		{Method: "POST", Path: "/api/v1/users/{id}/unlock", Access: handler.AccessAdmin},
+	}
+	assert.Equal(t, want, groups.Routes())
+
+	// Уровень доступа действительно проверяется: без токена закрытые маршруты отвечают 401
+	for _, route := range groups.Routes() {
+		if route.Access == handler.AccessPublic {
+			continue
+		}
+		req := httptest.NewRequest(route.Method, route.Path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "%s %s", route.Method, route.Path)
+	}
+
+	// Пользователь без роли администратора не проходит в админские маршруты
+	req := httptest.NewRequest("GET", "/api/v1/users", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, 1, "user"))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestLegacyRoutesAreDeprecated(t *testing.T) {
+	router := mux.NewRouter()
+	modules := newTestAPIModules()
+	handler.MountAPI(router.PathPrefix("/api/v1").Subrouter(), handler.APIOptions{JWTKey: testJWTKey}, modules...)
+	legacy := router.PathPrefix("/api").Subrouter()
+	legacy.Use(handler.DeprecationMiddleware(handler.Deprecation{
+		Since:     time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		Sunset:    time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC),
+		Successor: "/api/v1",
+	}))
+	handler.MountAPI(legacy, handler.APIOptions{JWTKey: testJWTKey}, modules...)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/users", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "@1790812800", rec.Header().Get("Deprecation"))
+	assert.Equal(t, "Thu, 01 Apr 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v1>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/users", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+}
@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupLocalStore создаёт локальное хранилище во временном каталоге и HTTP-сервер,
+// который раздаёт его подписанные ссылки.
+func setupLocalStore(t *testing.T) (*storage.LocalStore, *httptest.Server) {
+	var store *storage.LocalStore
+	server := httptest.NewServer(http.StripPrefix("/storage", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		store.ServeHTTP(w, r)
+	})))
+	t.Cleanup(server.Close)
+
+	store, err := storage.NewLocalStore(t.TempDir(), server.URL+"/storage", []byte("test-secret"))
+	require.NoError(t, err)
+	return store, server
+}
+
+func TestLocalStorePutGetStat(t *testing.T) {
+	store, _ := setupLocalStore(t)
+	ctx := context.Background()
+
+	err := store.Put(ctx, "photo.jpg", strings.NewReader("jpeg data"), 9, "image/jpeg")
+	require.NoError(t, err)
+
+	info, err := store.Stat(ctx, "photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), info.Size)
+	assert.Equal(t, "image/jpeg", info.ContentType)
+	assert.NotEmpty(t, info.ETag)
+
+	reader, _, err := store.Get(ctx, "photo.jpg")
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg data", string(data))
+
+	_, err = store.Stat(ctx, "missing.jpg")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	require.NoError(t, store.Delete(ctx, "photo.jpg"))
+	_, _, err = store.Get(ctx, "photo.jpg")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestLocalStoreRejectsInvalidKeys(t *testing.T) {
+	store, _ := setupLocalStore(t)
+
+	for _, key := range []string{"", "../etc/passwd", "dir/photo.jpg", ".hidden"} {
+		err := store.Put(context.Background(), key, strings.NewReader("x"), 1, "image/jpeg")
+		assert.Error(t, err, key)
+	}
+}
+
+func TestLocalStoreList(t *testing.T) {
+	store, _ := setupLocalStore(t)
+	ctx := context.Background()
+	for _, key := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		require.NoError(t, store.Put(ctx, key, strings.NewReader("x"), 1, "image/jpeg"))
+	}
+
+	page, next, err := store.List(ctx, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "a.jpg", page[0].Key)
+	assert.Equal(t, "b.jpg", next)
+
+	page, next, err = store.List(ctx, next, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "c.jpg", page[0].Key)
+	assert.Empty(t, next)
+}
+
+func TestLocalStorePresignGet(t *testing.T) {
+	store, _ := setupLocalStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "photo.png", strings.NewReader("0123456789"), 10, "image/png"))
+
+	url, err := store.PresignGet(ctx, "photo.png", time.Minute)
+	require.NoError(t, err)
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0123456789", string(body))
+	assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+	etag := resp.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// Range
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Range", "bytes=2-4")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "234", string(body))
+
+	// If-None-Match
+	req, _ = http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// Подделанная подпись
+	resp, err = http.Get(strings.Replace(url, "signature=", "signature=00", 1))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Истёкшая ссылка
+	expired, err := store.PresignGet(ctx, "photo.png", -time.Minute)
+	require.NoError(t, err)
+	resp, err = http.Get(expired)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+// postUploadForm отправляет форму загрузки так же, как это делает браузер.
+func postUploadForm(t *testing.T, policy *storage.UploadPolicy, contentType string, data []byte) *http.Response {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range policy.FormData {
+		require.NoError(t, mw.WriteField(k, v))
+	}
+	h := make(map[string][]string)
+	h["Content-Disposition"] = []string{`form-data; name="file"; filename="upload"`}
+	h["Content-Type"] = []string{contentType}
+	part, err := mw.CreatePart(h)
+	require.NoError(t, err)
+	part.Write(data)
+	require.NoError(t, mw.Close())
+
+	resp, err := http.Post(policy.URL, mw.FormDataContentType(), &buf)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func TestLocalStorePresignUpload(t *testing.T) {
+	store, _ := setupLocalStore(t)
+	ctx := context.Background()
+
+	policy, err := store.PresignUpload(ctx, "upload.jpg", "image/jpeg", 16, time.Minute)
+	require.NoError(t, err)
+
+	resp := postUploadForm(t, policy, "image/jpeg", []byte("small file"))
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	info, err := store.Stat(ctx, "upload.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), info.Size)
+
+	// Превышение размера
+	policy, err = store.PresignUpload(ctx, "big.jpg", "image/jpeg", 4, time.Minute)
+	require.NoError(t, err)
+	resp = postUploadForm(t, policy, "image/jpeg", []byte("too large"))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	_, err = store.Stat(ctx, "big.jpg")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Неверный Content-Type
+	policy, err = store.PresignUpload(ctx, "other.jpg", "image/jpeg", 16, time.Minute)
+	require.NoError(t, err)
+	resp = postUploadForm(t, policy, "text/html", []byte("<html>"))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Подмена ключа ломает подпись
+	policy, err = store.PresignUpload(ctx, "mine.jpg", "image/jpeg", 16, time.Minute)
+	require.NoError(t, err)
+	policy.FormData["key"] = "theirs.jpg"
+	resp = postUploadForm(t, policy, "image/jpeg", []byte("data"))
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/logger"
+	"github.com/alex-pyslar/petelka-api/internal/storage"
+	"github.com/alex-pyslar/petelka-api/internal/tracing"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// setupTestTracing подменяет глобальный провайдер трассировок на записывающий спаны в память.
+func setupTestTracing(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func TestTracingNamesSpanByRouteAndPropagatesContext(t *testing.T) {
+	recorder := setupTestTracing(t)
+	log, logs := newObservedLogger()
+
+	router := mux.NewRouter()
+	router.Use(handler.RouteMiddleware)
+	router.HandleFunc("/api/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Info("inside handler")
+	}).Methods("GET")
+	srv := tracing.Middleware(handler.RequestIDMiddleware(log)(router))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/api/products/7", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /api/products/{id}", spans[0].Name())
+	assert.Equal(t, traceID, spans[0].SpanContext().TraceID().String())
+
+	entries := logs.FilterMessage("inside handler").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, traceID, entries[0].ContextMap()["trace_id"])
+}
+
+func TestTracingSkipsTechnicalEndpoints(t *testing.T) {
+	recorder := setupTestTracing(t)
+	srv := tracing.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, path := range []string{"/metrics", "/healthz", "/readyz"} {
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	assert.Empty(t, recorder.Ended())
+}
+
+func TestTracedStoreRecordsOperations(t *testing.T) {
+	recorder := setupTestTracing(t)
+	local, _ := setupLocalStore(t)
+	store := storage.WithTracing(local, "local")
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, "traced.jpg", strings.NewReader("data"), 4, "image/jpeg"))
+	_, err := store.Stat(ctx, "missing.jpg")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "storage.put", spans[0].Name())
+	assert.Equal(t, "storage.stat", spans[1].Name())
+	assert.Empty(t, spans[1].Events(), "missing objects must not be recorded as errors")
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alex-pyslar/petelka-api/internal/handler"
+	"github.com/alex-pyslar/petelka-api/internal/models"
+	"github.com/alex-pyslar/petelka-api/internal/money"
+	"github.com/alex-pyslar/petelka-api/internal/repository"
+	"github.com/alex-pyslar/petelka-api/internal/service"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCachedUserService возвращает сервис пользователей, который видит только users из кеша.
+func newCachedUserService(t *testing.T, users ...models.User) *service.UserService {
+	_, client := setupMiniRedis(t)
+	for _, u := range users {
+		data, err := json.Marshal(u)
+		require.NoError(t, err)
+		require.NoError(t, client.Set(context.Background(), fmt.Sprintf("user:%d", u.ID), data, time.Minute).Err())
+	}
+	return service.NewUserService(repository.NewUserRepository(unreachableDB(t), client, time.Minute))
+}
+
+func TestAuthMiddlewareRejectsBlockedUsers(t *testing.T) {
+	blockedAt := time.Now()
+	users := newCachedUserService(t,
+		models.User{ID: 1, Email: "active@example.com", Role: "user"},
+		models.User{ID: 2, Email: "blocked@example.com", Role: "user", BlockedAt: &blockedAt},
+	)
+
+	router := mux.NewRouter()
+	handler.MountAPI(router, handler.APIOptions{JWTKey: testJWTKey, Accounts: users},
+		handler.RouteFunc(func(public, protected, admin *mux.Router) {
+			protected.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+		}))
+
+	for id, want := range map[int]int{1: http.StatusOK, 2: http.StatusForbidden} {
+		req := httptest.NewRequest("GET", "/ping", nil)
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, id, "user"))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, want, rec.Code, "user %d", id)
+	}
+}
+
+func TestListUsersRejectsInvalidQuery(t *testing.T) {
+	h := handler.NewUserHandler(nil)
+	for _, query := range []string{
+		"status=gone",
+		"created_from=01.02.2026",
+		"created_to=2026-13-01",
+		"page=0",
+		"limit=abc",
+	} {
+		rec := httptest.NewRecorder()
+		h.ListUsers(rec, httptest.NewRequest("GET", "/api/v1/users?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestAdminCannotBlockOrDeleteOwnAccount(t *testing.T) {
+	h := handler.NewUserHandler(nil)
+	ctx := context.WithValue(context.Background(), handler.UserIDKey, 5)
+	for _, tc := range []struct {
+		method string
+		call   func(http.ResponseWriter, *http.Request)
+	}{
+		{"POST", h.BlockUser},
+		{"DELETE", h.DeleteUser},
+	} {
+		req := httptest.NewRequest(tc.method, "/", nil).WithContext(ctx)
+		req = mux.SetURLVars(req, map[string]string{"id": "5"})
+		rec := httptest.NewRecorder()
+		tc.call(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, tc.method)
+	}
+}
+
+func TestUserSearchBlockAndSoftDelete(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	redisClient := setupTestRedis(t)
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	users := service.NewUserService(repository.NewUserRepository(db, redisClient, testCacheTTL))
+	orders := service.NewOrderService(repository.NewOrderRepository(db, redisClient, testCacheTTL))
+
+	marker := fmt.Sprintf("admin-%d", time.Now().UnixNano())
+	user := &models.User{Email: marker + "@example.com", Name: "Search Me", Password: "password123", Role: "user", Phone: "+79001234567"}
+	require.NoError(t, users.CreateUser(ctx, user))
+	order := &models.Order{UserID: user.ID, Total: money.MustParse("10"), Status: "new"}
+	require.NoError(t, orders.CreateOrder(ctx, order))
+
+	found, total, err := users.SearchUsers(ctx, service.UserFilter{Email: marker, Role: "user", CreatedFrom: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	assert.Equal(t, user.ID, found[0].ID)
+
+	blocked, err := users.BlockUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.UserStatusBlocked, blocked.Status())
+	assert.ErrorIs(t, users.CheckActive(ctx, user.ID), service.ErrUserBlocked)
+	_, total, err = users.SearchUsers(ctx, service.UserFilter{Email: marker, Status: models.UserStatusActive})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	_, err = users.UnblockUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.NoError(t, users.CheckActive(ctx, user.ID))
+
+	// Пользователь с заказом удаляется мягко: заказ остаётся, персональные данные — нет
+	require.NoError(t, users.DeleteUser(ctx, user.ID))
+	_, err = users.GetUser(ctx, user.ID)
+	assert.Error(t, err)
+	assert.Error(t, users.VerifyPassword(ctx, user.ID, "password123"))
+
+	deleted, total, err := users.SearchUsers(ctx, service.UserFilter{Status: models.UserStatusDeleted, Name: "Deleted user", Limit: 1000})
+	require.NoError(t, err)
+	require.NotZero(t, total)
+	var anonymized *models.User
+	for _, u := range deleted {
+		if u.ID == user.ID {
+			anonymized = u
+		}
+	}
+	require.NotNil(t, anonymized)
+	assert.NotContains(t, anonymized.Email, marker)
+	assert.Empty(t, anonymized.Phone)
+
+	kept, err := orders.GetOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, kept.UserID)
+
+	assert.Error(t, users.DeleteUser(ctx, user.ID), "deleting twice reports not found")
+}
+
+func TestSoftDeleteScrubsAddressesAndOrderSnapshots(t *testing.T) {
+	db, teardown := setupTestDB(t)
+	defer teardown()
+	redisClient := setupTestRedis(t)
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	users := service.NewUserService(repository.NewUserRepository(db, redisClient, testCacheTTL))
+	orders := service.NewOrderService(repository.NewOrderRepository(db, redisClient, testCacheTTL))
+	addresses := service.NewAddressService(repository.NewAddressRepository(db))
+
+	user := &models.User{Email: fmt.Sprintf("scrub-%d@example.com", time.Now().UnixNano()), Name: "Buyer", Password: "password123", Role: "user"}
+	require.NoError(t, users.CreateUser(ctx, user))
+	home := &models.Address{UserID: user.ID, ShippingAddress: models.ShippingAddress{
+		Recipient: "Анна", Phone: "+79991234567", Region: "Московская область", City: "Химки", Street: "Садовая, 1",
+		PostalCode: "141400", Comment: "код домофона 15",
+	}}
+	require.NoError(t, addresses.CreateAddress(ctx, home))
+	snapshot := home.ShippingAddress
+	order := &models.Order{UserID: user.ID, Total: money.MustParse("10"), Status: "new", ShippingAddress: &snapshot}
+	require.NoError(t, orders.CreateOrder(ctx, order))
+	// Заказ попадает в кеш до удаления
+	_, err := orders.GetOrder(ctx, order.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, users.DeleteUser(ctx, user.ID))
+
+	list, err := addresses.ListAddresses(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, list, "address book is removed")
+
+	kept, err := orders.GetOrder(ctx, order.ID)
+	require.NoError(t, err)
+	require.NotNil(t, kept.ShippingAddress)
+	assert.Equal(t, models.ShippingAddress{Region: "Московская область", City: "Химки"}, *kept.ShippingAddress,
+		"only region and city are kept for delivery statistics")
+}